@@ -0,0 +1,72 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// docxBody is the part of a Word document holding its main text
+const docxBody = "word/document.xml"
+
+// extractDOCX collects the text runs of a Word document's body, a paragraph
+// per line. Headers, footers and comments are left out.
+func extractDOCX(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	for _, part := range archive.File {
+		if part.Name != docxBody {
+			continue
+		}
+		body, err := part.Open()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		return docxText(io.LimitReader(body, maxInflated))
+	}
+	return "", errors.New("docx has no document body")
+}
+
+func docxText(body io.Reader) (string, error) {
+	var text strings.Builder
+	decoder := xml.NewDecoder(body)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteString(" ")
+			case "br", "cr":
+				text.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		}
+	}
+	return normalize(text.String()), nil
+}
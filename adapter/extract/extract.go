@@ -0,0 +1,71 @@
+package extract
+
+import (
+	"context"
+	"io"
+	"mime"
+	"strings"
+	"taskflow/internal/domain/search"
+	"unicode/utf8"
+)
+
+// Content types text is extracted from
+const (
+	contentTypeText = "text/plain"
+	contentTypePDF  = "application/pdf"
+	contentTypeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+)
+
+// maxInflated bounds how much a compressed part of a document may expand to,
+// so small files can't exhaust memory
+const maxInflated = 64 << 20
+
+type extractor struct{}
+
+// NewExtractor returns an extractor reading plain text, PDF and Word (docx)
+// files with the standard library only. It reads documents whole, so callers
+// bound their size.
+func NewExtractor() search.Extractor {
+	return extractor{}
+}
+
+func (extractor) Extract(ctx context.Context, contentType string, content io.Reader) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", search.ErrUnsupported
+	}
+	switch mediaType {
+	case contentTypeText:
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return "", err
+		}
+		return strings.ToValidUTF8(string(data), string(utf8.RuneError)), nil
+	case contentTypePDF:
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return "", err
+		}
+		return extractPDF(data)
+	case contentTypeDOCX:
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return "", err
+		}
+		return extractDOCX(data)
+	}
+	return "", search.ErrUnsupported
+}
+
+// normalize trims the lines of extracted text, collapsing runs of spaces and
+// dropping empty lines
+func normalize(text string) string {
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
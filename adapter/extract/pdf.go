@@ -0,0 +1,288 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// kerningSpace is how far back a TJ adjustment must move, in thousandths of
+// an em, to read as a space between words
+const kerningSpace = -250
+
+// extractPDF collects the text shown by a PDF's content streams. Only
+// uncompressed and Flate streams are read, and strings are decoded as
+// Latin-1 or UTF-16, so text set in fonts with custom encodings comes out
+// garbled or not at all.
+func extractPDF(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", errors.New("not a PDF document")
+	}
+
+	var text strings.Builder
+	inflated := 0
+	rest := data
+	for {
+		start := streamStart(rest)
+		if start < 0 {
+			break
+		}
+		dict := rest[:start]
+		if obj := bytes.LastIndex(dict, []byte("obj")); obj >= 0 {
+			dict = dict[obj:]
+		}
+		body := rest[start:]
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		rest = body[end+len("endstream"):]
+		body = body[:end]
+
+		content, ok := decodeStream(dict, body, maxInflated-inflated)
+		if !ok {
+			continue
+		}
+		inflated += len(content)
+		pdfText(content, &text)
+	}
+	return normalize(text.String()), nil
+}
+
+// streamStart returns where the data of the next stream in data begins, or
+// -1 if there is none
+func streamStart(data []byte) int {
+	offset := 0
+	for {
+		i := bytes.Index(data[offset:], []byte("stream"))
+		if i < 0 {
+			return -1
+		}
+		i += offset
+		offset = i + len("stream")
+		if i > 0 && data[i-1] == 'd' {
+			// endstream
+			continue
+		}
+		if bytes.HasPrefix(data[offset:], []byte("\r\n")) {
+			return offset + 2
+		}
+		if bytes.HasPrefix(data[offset:], []byte("\n")) || bytes.HasPrefix(data[offset:], []byte("\r")) {
+			return offset + 1
+		}
+	}
+}
+
+// decodeStream returns the content of a stream with the given dictionary,
+// or false for images and filters other than Flate
+func decodeStream(dict, body []byte, limit int) ([]byte, bool) {
+	if limit <= 0 || bytes.Contains(dict, []byte("/Image")) {
+		return nil, false
+	}
+	filters := bytes.Count(bytes.ReplaceAll(dict, []byte("/DecodeParms"), nil), []byte("Decode"))
+	switch {
+	case filters == 0:
+		return body, true
+	case filters == 1 && bytes.Contains(dict, []byte("/FlateDecode")):
+		r, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, false
+		}
+		defer r.Close()
+		// Keep what inflated before a truncated or corrupt end
+		content, _ := io.ReadAll(io.LimitReader(r, int64(limit)))
+		return content, len(content) > 0
+	}
+	return nil, false
+}
+
+// pdfText writes the strings shown between BT and ET in a content stream
+func pdfText(content []byte, text *strings.Builder) {
+	var shown []string
+	inText, inArray := false, false
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case isPDFSpace(c):
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, next := literalString(content, i+1)
+			shown = append(shown, s)
+			i = next
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			i += 2
+		case c == '<':
+			s, next := hexString(content, i+1)
+			shown = append(shown, s)
+			i = next
+		case c == '[':
+			inArray = true
+			i++
+		case c == ']':
+			inArray = false
+			i++
+		case c == '>' || c == '{' || c == '}' || c == ')':
+			i++
+		case c == '/':
+			i = tokenEnd(content, i+1)
+		default:
+			end := tokenEnd(content, i)
+			token := string(content[i:end])
+			i = end
+			if n, err := strconv.ParseFloat(token, 64); err == nil {
+				if inArray && n <= kerningSpace {
+					shown = append(shown, " ")
+				}
+				continue
+			}
+			switch token {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				text.WriteString("\n")
+			case "Tj", "TJ":
+				if inText {
+					text.WriteString(strings.Join(shown, ""))
+				}
+			case "'", "\"":
+				if inText {
+					text.WriteString("\n" + strings.Join(shown, ""))
+				}
+			case "Td", "TD", "T*", "Tm":
+				if inText {
+					text.WriteString("\n")
+				}
+			}
+			shown = shown[:0]
+		}
+	}
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func tokenEnd(content []byte, i int) int {
+	for i < len(content) && !isPDFSpace(content[i]) && !isPDFDelimiter(content[i]) {
+		i++
+	}
+	return i
+}
+
+// literalString reads a (string) whose opening parenthesis ends before i,
+// returning it and where it ends
+func literalString(content []byte, i int) (string, int) {
+	var s []byte
+	depth := 1
+	for ; i < len(content); i++ {
+		c := content[i]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return decodeString(s), i + 1
+			}
+		case '\\':
+			i++
+			if i >= len(content) {
+				return decodeString(s), i
+			}
+			switch e := content[i]; e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b', 'f':
+			case '\r':
+				// Line continuation
+				if i+1 < len(content) && content[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for j := 0; j < 3 && i < len(content) && content[i] >= '0' && content[i] <= '7'; j++ {
+						n = n*8 + int(content[i]-'0')
+						i++
+					}
+					i--
+					s = append(s, byte(n))
+				} else {
+					s = append(s, e)
+				}
+			}
+			continue
+		}
+		s = append(s, c)
+	}
+	return decodeString(s), i
+}
+
+// hexString reads a <hex string> whose opening bracket ends before i
+func hexString(content []byte, i int) (string, int) {
+	var s []byte
+	high, odd := byte(0), false
+	for ; i < len(content) && content[i] != '>'; i++ {
+		v, ok := hexValue(content[i])
+		if !ok {
+			continue
+		}
+		if odd {
+			s = append(s, high<<4|v)
+		} else {
+			high = v
+		}
+		odd = !odd
+	}
+	if odd {
+		s = append(s, high<<4)
+	}
+	return decodeString(s), i + 1
+}
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// decodeString reads a string as UTF-16 if it has a byte order mark and as
+// Latin-1 otherwise
+func decodeString(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(s))
+	for i, c := range s {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
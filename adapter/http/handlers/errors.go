@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"taskflow/internal/domain/shared"
+
+	"github.com/gin-gonic/gin"
+)
+
+// domainErrorStatus maps domain error codes to HTTP status codes
+var domainErrorStatus = map[string]int{
+	shared.ErrCodeValidation:    http.StatusBadRequest,
+	shared.ErrCodeInvalidInput:  http.StatusBadRequest,
+	shared.ErrCodeNotFound:      http.StatusNotFound,
+	shared.ErrCodeConflict:      http.StatusConflict,
+	shared.ErrCodeUnauthorized:  http.StatusUnauthorized,
+	shared.ErrCodeForbidden:     http.StatusForbidden,
+	shared.ErrCodeTimeout:       http.StatusGatewayTimeout,
+	shared.ErrCodeTooLarge:      http.StatusRequestEntityTooLarge,
+	shared.ErrCodeUnsupported:   http.StatusUnsupportedMediaType,
+	shared.ErrCodeGone:          http.StatusGone,
+	shared.ErrCodeLocked:        http.StatusLocked,
+	shared.ErrCodeQuotaExceeded: http.StatusInsufficientStorage,
+}
+
+// respondError writes a domain error with its mapped status, or a 500 with
+// fallbackMessage for anything else
+func respondError(c *gin.Context, err error, fallbackMessage string) {
+	var domainErr *shared.DomainError
+	if errors.As(err, &domainErr) {
+		if status, ok := domainErrorStatus[domainErr.Code]; ok {
+			body := gin.H{"error": domainErr.Message, "code": domainErr.Code}
+			if domainErr.Details != "" {
+				body["details"] = domainErr.Details
+			}
+			c.JSON(status, body)
+			return
+		}
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallbackMessage})
+}
@@ -1,65 +1,413 @@
 package handlers
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
 	"net/http"
-	"path/filepath"
-	"strings"
+	"strconv"
 	fileDomain "taskflow/internal/domain/file"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type FileHandler struct {
-	fileService fileDomain.FileService
+	fileService      fileDomain.FileService
+	thumbnailService fileDomain.ThumbnailService
+	archiveService   fileDomain.ArchiveService
 }
 
-func NewFileHandler(fileService fileDomain.FileService) *FileHandler {
+func NewFileHandler(fileService fileDomain.FileService, thumbnailService fileDomain.ThumbnailService, archiveService fileDomain.ArchiveService) *FileHandler {
 	return &FileHandler{
-		fileService: fileService,
+		fileService:      fileService,
+		thumbnailService: thumbnailService,
+		archiveService:   archiveService,
 	}
 }
 
+// UploadFile streams the "file" part of a multipart body to the file service
+// without buffering it in memory or on disk. Type and size are enforced by the
+// service while the content is read.
 func (h *FileHandler) UploadFile(c *gin.Context) {
-	file, header, err := c.Request.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+	part, ok := filePart(c)
+	if !ok {
 		return
 	}
-	defer file.Close()
+	defer part.Close()
 
-	// Validate file type
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	allowedTypes := map[string]bool{
-		".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
-		".txt": true, ".pdf": true, ".doc": true, ".docx": true,
+	createReq := &fileDomain.CreateFileRequest{
+		Filename:    part.FileName(),
+		ContentType: part.Header.Get("Content-Type"),
 	}
 
-	if !allowedTypes[ext] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File type not allowed"})
+	response, err := h.fileService.UploadFile(c.Request.Context(), createReq, part)
+	if err != nil {
+		respondError(c, err, "Failed to upload file")
 		return
 	}
 
-	// Validate file size (10MB limit)
-	if header.Size > 10*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large"})
+	c.JSON(http.StatusOK, response)
+}
+
+// ReplaceContent streams the "file" part of a multipart body as the new
+// content of a file, keeping the current content as an earlier version
+func (h *FileHandler) ReplaceContent(c *gin.Context) {
+	id, ok := parseFileID(c)
+	if !ok {
+		return
+	}
+	part, ok := filePart(c)
+	if !ok {
 		return
 	}
+	defer part.Close()
 
 	createReq := &fileDomain.CreateFileRequest{
-		Filename:    header.Filename,
-		ContentType: header.Header.Get("Content-Type"),
-		Size:        header.Size,
+		Filename:    part.FileName(),
+		ContentType: part.Header.Get("Content-Type"),
 	}
 
-	response, err := h.fileService.UploadFile(
-		c.Request.Context(),
-		createReq,
-		file,
-	)
+	file, err := h.fileService.ReplaceContent(c.Request.Context(), id, createReq, part)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err, "Failed to replace file content")
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, file)
+}
+
+// filePart skips to the "file" part of a multipart body, writing a 400 if
+// there is none
+func filePart(c *gin.Context) (*multipart.Part, bool) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected multipart/form-data body"})
+		return nil, false
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+			return nil, false
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Malformed multipart body"})
+			return nil, false
+		}
+		if part.FormName() == "file" && part.FileName() != "" {
+			return part, true
+		}
+		part.Close()
+	}
+}
+
+func (h *FileHandler) CreateUploadURL(c *gin.Context) {
+	var req fileDomain.CreateUploadURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response, err := h.fileService.CreateUploadURL(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err, "Failed to create upload URL")
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *FileHandler) CompleteUpload(c *gin.Context) {
+	id, ok := parseFileID(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.fileService.CompleteUpload(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to complete upload")
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+func (h *FileHandler) GetFile(c *gin.Context) {
+	id, ok := parseFileID(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.fileService.GetFile(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to get file")
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+// DownloadFile streams the file content, honouring Range, If-Range and
+// If-None-Match. If the stored content of a full download fails its checksum
+// the response is cut short so the client never sees it complete.
+func (h *FileHandler) DownloadFile(c *gin.Context) {
+	id, ok := parseFileID(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.fileService.GetFile(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to get file")
+		return
+	}
+	if err := file.CheckDownloadable(); err != nil {
+		respondError(c, err, "Failed to download file")
+		return
+	}
+
+	var etag string
+	if tag := file.EntityTag(); tag != "" {
+		etag = `"` + tag + `"`
+		c.Header("ETag", etag)
+	}
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Last-Modified", file.UpdatedAt.UTC().Format(http.TimeFormat))
+
+	if etag != "" && etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	status, offset, length := http.StatusOK, int64(0), file.Size
+	if header := c.GetHeader("Range"); header != "" && ifRangeAllows(c.GetHeader("If-Range"), etag, file.UpdatedAt) {
+		start, n, err := parseRange(header, file.Size)
+		switch {
+		case errors.Is(err, errRangeNotSatisfiable):
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", file.Size))
+			c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": "Range not satisfiable"})
+			return
+		case err == nil:
+			status, offset, length = http.StatusPartialContent, start, n
+		}
+	}
+
+	var content io.ReadCloser
+	if status == http.StatusPartialContent {
+		content, err = h.fileService.OpenFileRange(c.Request.Context(), file, offset, length)
+	} else {
+		content, err = h.fileService.OpenFile(c.Request.Context(), file)
+	}
+	if err != nil {
+		respondError(c, err, "Failed to download file")
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+	c.Header("Content-Type", file.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(length, 10))
+	if status == http.StatusPartialContent {
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, file.Size))
+	}
+	if digest, err := hex.DecodeString(file.Checksum); err == nil && len(digest) > 0 {
+		c.Header("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(digest)+":")
+	}
+	c.Status(status)
+
+	if _, err := io.Copy(c.Writer, content); err != nil {
+		c.Error(err) // nolint: errcheck
+		c.Abort()
+	}
+}
+
+// GetThumbnail serves the smallest thumbnail at least ?size= pixels across,
+// or the largest available when size is omitted
+func (h *FileHandler) GetThumbnail(c *gin.Context) {
+	id, ok := parseFileID(c)
+	if !ok {
+		return
+	}
+
+	size := 0
+	if raw := c.Query("size"); raw != "" {
+		var err error
+		if size, err = strconv.Atoi(raw); err != nil || size <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid size"})
+			return
+		}
+	} else {
+		size = math.MaxInt
+	}
+
+	thumbnail, content, err := h.thumbnailService.GetThumbnail(c.Request.Context(), id, size)
+	if err != nil {
+		respondError(c, err, "Failed to get thumbnail")
+		return
+	}
+	defer content.Close()
+
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.DataFromReader(http.StatusOK, -1, thumbnail.ContentType, content, nil)
+}
+
+func (h *FileHandler) DeleteFile(c *gin.Context) {
+	id, ok := parseFileID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.fileService.DeleteFile(c.Request.Context(), id); err != nil {
+		respondError(c, err, "Failed to delete file")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListVersions lists the current and the kept earlier versions of a file,
+// newest first
+func (h *FileHandler) ListVersions(c *gin.Context) {
+	id, ok := parseFileID(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.fileService.ListVersions(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to list file versions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// DownloadVersion streams one version of a file. Like full downloads, content
+// failing its checksum is cut short.
+func (h *FileHandler) DownloadVersion(c *gin.Context) {
+	id, ok := parseFileID(c)
+	if !ok {
+		return
+	}
+	number, ok := parseVersion(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.fileService.GetFile(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to get file")
+		return
+	}
+	version, content, err := h.fileService.DownloadVersion(c.Request.Context(), id, number)
+	if err != nil {
+		respondError(c, err, "Failed to download file version")
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+	c.Header("Content-Type", version.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(version.Size, 10))
+	if digest, err := hex.DecodeString(version.Checksum); err == nil && len(digest) > 0 {
+		c.Header("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(digest)+":")
+	}
+	c.Status(http.StatusOK)
+
+	if _, err := io.Copy(c.Writer, content); err != nil {
+		c.Error(err) // nolint: errcheck
+		c.Abort()
+	}
+}
+
+// RestoreVersion makes an earlier version the current content of a file
+func (h *FileHandler) RestoreVersion(c *gin.Context) {
+	id, ok := parseFileID(c)
+	if !ok {
+		return
+	}
+	number, ok := parseVersion(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.fileService.RestoreVersion(c.Request.Context(), id, number)
+	if err != nil {
+		respondError(c, err, "Failed to restore file version")
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+// CreateArchive streams a zip of the selected files. The selection is checked
+// before the response starts; failures after that cut the archive short.
+func (h *FileHandler) CreateArchive(c *gin.Context) {
+	var req fileDomain.ArchiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	archive, err := h.archiveService.Prepare(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err, "Failed to create archive")
+		return
+	}
+
+	filename := "files-" + time.Now().UTC().Format("20060102-150405") + ".zip"
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+
+	if err := h.archiveService.Write(c.Request.Context(), archive, c.Writer); err != nil {
+		c.Error(err) // nolint: errcheck
+		c.Abort()
+	}
+}
+
+// GetUsage returns the storage used by the caller and their workspace
+func (h *FileHandler) GetUsage(c *gin.Context) {
+	usage, err := h.fileService.GetUsage(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "Failed to get usage")
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// parseFileID validates the :id path parameter, writing a 400 if it is invalid
+func parseFileID(c *gin.Context) (string, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return "", false
+	}
+	return id.String(), true
+}
+
+// parseVersion validates the :version path parameter, writing a 400 if it is
+// invalid
+func parseVersion(c *gin.Context) (int, bool) {
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil || number <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return 0, false
+	}
+	return number, true
 }
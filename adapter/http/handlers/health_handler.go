@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"taskflow/pkg/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	registry *health.Registry
+}
+
+func NewHealthHandler(registry *health.Registry) *HealthHandler {
+	return &HealthHandler{
+		registry: registry,
+	}
+}
+
+// Liveness reports whether the process is able to serve requests at all.
+// It never checks dependencies so a database outage doesn't restart pods.
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// Readiness reports whether the service and its dependencies can take traffic
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.registry.Readiness(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status != health.StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, report)
+}
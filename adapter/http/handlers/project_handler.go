@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"taskflow/internal/domain/todo"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ProjectHandler struct {
+	projectService todo.ProjectService
+}
+
+func NewProjectHandler(projectService todo.ProjectService) *ProjectHandler {
+	return &ProjectHandler{
+		projectService: projectService,
+	}
+}
+
+// ListProjects returns the workspace's projects in order with their todo
+// counts; archived ones only with archived=true
+func (h *ProjectHandler) ListProjects(c *gin.Context) {
+	includeArchived, err := strconv.ParseBool(c.DefaultQuery("archived", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archived must be true or false"})
+		return
+	}
+
+	projects, err := h.projectService.ListProjects(c.Request.Context(), includeArchived)
+	if err != nil {
+		respondError(c, err, "Failed to list projects")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"projects": projects})
+}
+
+func (h *ProjectHandler) CreateProject(c *gin.Context) {
+	var req todo.ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	project, err := h.projectService.CreateProject(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err, "Failed to create project")
+		return
+	}
+
+	c.JSON(http.StatusCreated, project)
+}
+
+func (h *ProjectHandler) GetProject(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	project, err := h.projectService.GetProject(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to get project")
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+func (h *ProjectHandler) UpdateProject(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	var req todo.UpdateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	project, err := h.projectService.UpdateProject(c.Request.Context(), id, &req)
+	if err != nil {
+		respondError(c, err, "Failed to update project")
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+func (h *ProjectHandler) ReorderProjects(c *gin.Context) {
+	var req todo.ReorderProjectsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	projects, err := h.projectService.ReorderProjects(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err, "Failed to reorder projects")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"projects": projects})
+}
+
+func (h *ProjectHandler) DeleteProject(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	if err := h.projectService.DeleteProject(c.Request.Context(), id); err != nil {
+		respondError(c, err, "Failed to delete project")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
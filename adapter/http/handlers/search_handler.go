@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"taskflow/internal/domain/search"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	searchService search.Service
+}
+
+func NewSearchHandler(searchService search.Service) *SearchHandler {
+	return &SearchHandler{
+		searchService: searchService,
+	}
+}
+
+// Search ranks todos by their description and the text of their attachments
+func (h *SearchHandler) Search(c *gin.Context) {
+	query := c.Query("q")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter q is required"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	results, err := h.searchService.Search(c.Request.Context(), query, limit)
+	if err != nil {
+		respondError(c, err, "Failed to search")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":   query,
+		"results": results,
+	})
+}
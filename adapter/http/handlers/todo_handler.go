@@ -2,9 +2,9 @@ package handlers
 
 import (
 	"errors"
+	"io"
 	"net/http"
 	"strconv"
-	"taskflow/internal/domain/shared"
 	"taskflow/internal/domain/todo"
 
 	"github.com/gin-gonic/gin"
@@ -33,19 +33,7 @@ func (h *TodoHandler) CreateTodo(c *gin.Context) {
 
 	todoItem, err := h.todoService.CreateTodo(c.Request.Context(), &req)
 	if err != nil {
-		// Check for domain errors
-		var domainErr *shared.DomainError
-		if errors.As(err, &domainErr) {
-			switch domainErr.Code {
-			case shared.ErrCodeValidation:
-				c.JSON(http.StatusBadRequest, gin.H{"error": domainErr.Message})
-				return
-			case shared.ErrCodeNotFound:
-				c.JSON(http.StatusNotFound, gin.H{"error": domainErr.Message})
-				return
-			}
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create todo"})
+		respondError(c, err, "Failed to create todo")
 		return
 	}
 
@@ -80,9 +68,50 @@ func (h *TodoHandler) ListTodos(c *gin.Context) {
 		offset = 0
 	}
 
-	todos, err := h.todoService.ListTodos(c.Request.Context(), limit, offset)
+	// Todos match all tags unless match=any; priorities are alternatives
+	filter := todo.ListFilter{Tags: c.QueryArray("tag")}
+	switch c.DefaultQuery("match", "all") {
+	case "all":
+	case "any":
+		filter.AnyTag = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match must be all or any"})
+		return
+	}
+	for _, priority := range c.QueryArray("priority") {
+		filter.Priorities = append(filter.Priorities, todo.Priority(priority))
+	}
+	for _, status := range c.QueryArray("status") {
+		filter.Statuses = append(filter.Statuses, todo.Status(status))
+	}
+	switch c.DefaultQuery("sort", "created") {
+	case "created":
+	case "rank":
+		filter.OrderByRank = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be created or rank"})
+		return
+	}
+	// Todos of archived projects are hidden unless asked for, or listed by
+	// project
+	if project := c.Query("project"); project != "" {
+		projectID, err := uuid.Parse(project)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project must be a UUID"})
+			return
+		}
+		filter.ProjectID = &projectID
+	}
+	// assignee=me lists the caller's todos
+	filter.Assignee = c.Query("assignee")
+	if filter.IncludeArchived, err = strconv.ParseBool(c.DefaultQuery("archived", "false")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archived must be true or false"})
+		return
+	}
+
+	todos, err := h.todoService.ListTodos(c.Request.Context(), filter, limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve todos"})
+		respondError(c, err, "Failed to retrieve todos")
 		return
 	}
 
@@ -115,11 +144,7 @@ func (h *TodoHandler) UpdateTodo(c *gin.Context) {
 
 	todoItem, err := h.todoService.UpdateTodo(c.Request.Context(), id, &req)
 	if err != nil {
-		if errors.Is(err, errors.New("todo not found")) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update todo"})
+		respondError(c, err, "Failed to update todo")
 		return
 	}
 
@@ -136,13 +161,350 @@ func (h *TodoHandler) DeleteTodo(c *gin.Context) {
 
 	err = h.todoService.DeleteTodo(c.Request.Context(), id)
 	if err != nil {
-		if errors.Is(err, errors.New("todo not found")) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete todo"})
+		respondError(c, err, "Failed to delete todo")
 		return
 	}
 
 	c.JSON(http.StatusNoContent, nil)
 }
+
+func (h *TodoHandler) ListAttachments(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	attachments, err := h.todoService.ListAttachments(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to list attachments")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attachments": attachments})
+}
+
+func (h *TodoHandler) AttachFile(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	var req todo.AttachRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	attachment, err := h.todoService.AttachFile(c.Request.Context(), id, req.FileID)
+	if err != nil {
+		respondError(c, err, "Failed to attach file")
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+func (h *TodoHandler) DetachFile(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+	fileID, err := uuid.Parse(c.Param("fileId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	if err := h.todoService.DetachFile(c.Request.Context(), id, fileID.String()); err != nil {
+		respondError(c, err, "Failed to detach file")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TodoHandler) ListSubtasks(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	subtasks, err := h.todoService.ListSubtasks(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to list subtasks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"subtasks": subtasks})
+}
+
+func (h *TodoHandler) AddChecklistItem(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	var req todo.ChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	item, err := h.todoService.AddChecklistItem(c.Request.Context(), id, &req)
+	if err != nil {
+		respondError(c, err, "Failed to add checklist item")
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+func (h *TodoHandler) UpdateChecklistItem(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+	itemID, err := uuid.Parse(c.Param("itemId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	var req todo.UpdateChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	item, err := h.todoService.UpdateChecklistItem(c.Request.Context(), id, itemID, &req)
+	if err != nil {
+		respondError(c, err, "Failed to update checklist item")
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+func (h *TodoHandler) DeleteChecklistItem(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+	itemID, err := uuid.Parse(c.Param("itemId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	if err := h.todoService.DeleteChecklistItem(c.Request.Context(), id, itemID); err != nil {
+		respondError(c, err, "Failed to delete checklist item")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TodoHandler) GetDependencies(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	graph, err := h.todoService.GetDependencies(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to get dependencies")
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
+
+func (h *TodoHandler) AddDependency(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	var req todo.DependencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	dependency, warnings, err := h.todoService.AddDependency(c.Request.Context(), id, &req)
+	if err != nil {
+		respondError(c, err, "Failed to add dependency")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"dependency": dependency, "warnings": warnings})
+}
+
+func (h *TodoHandler) RemoveDependency(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+	blockerID, err := uuid.Parse(c.Param("blockerId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	if err := h.todoService.RemoveDependency(c.Request.Context(), id, blockerID); err != nil {
+		respondError(c, err, "Failed to remove dependency")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TodoHandler) PlanTodos(c *gin.Context) {
+	var req todo.PlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	plan, err := h.todoService.PlanTodos(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err, "Failed to plan todos")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// MoveTodo places a todo between others of a board column, moving it to
+// another column first when a status is given
+func (h *TodoHandler) MoveTodo(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	var req todo.MoveTodoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	moved, err := h.todoService.MoveTodo(c.Request.Context(), id, &req)
+	if err != nil {
+		respondError(c, err, "Failed to move todo")
+		return
+	}
+
+	c.JSON(http.StatusOK, moved)
+}
+
+// AssignTodo reads an optional body; without one the user is the caller
+func (h *TodoHandler) AssignTodo(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	var req todo.ParticipantRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	participant, err := h.todoService.AssignTodo(c.Request.Context(), id, &req)
+	if err != nil {
+		respondError(c, err, "Failed to assign todo")
+		return
+	}
+
+	c.JSON(http.StatusCreated, participant)
+}
+
+func (h *TodoHandler) UnassignTodo(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	if err := h.todoService.UnassignTodo(c.Request.Context(), id, c.Param("userId")); err != nil {
+		respondError(c, err, "Failed to unassign todo")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// WatchTodo reads an optional body; without one the user is the caller
+func (h *TodoHandler) WatchTodo(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	var req todo.ParticipantRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	participant, err := h.todoService.WatchTodo(c.Request.Context(), id, &req)
+	if err != nil {
+		respondError(c, err, "Failed to watch todo")
+		return
+	}
+
+	c.JSON(http.StatusCreated, participant)
+}
+
+func (h *TodoHandler) UnwatchTodo(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	if err := h.todoService.UnwatchTodo(c.Request.Context(), id, c.Param("userId")); err != nil {
+		respondError(c, err, "Failed to unwatch todo")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
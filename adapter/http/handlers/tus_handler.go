@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	fileDomain "taskflow/internal/domain/file"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,termination,expiration"
+	tusContentType = "application/offset+octet-stream"
+)
+
+// TusHandler implements the tus 1.0 resumable upload protocol
+// (https://tus.io/protocols/resumable-upload) on top of the resumable upload
+// service. Completed uploads become regular files whose ID is returned in the
+// X-File-ID header.
+type TusHandler struct {
+	uploadService fileDomain.ResumableUploadService
+}
+
+func NewTusHandler(uploadService fileDomain.ResumableUploadService) *TusHandler {
+	return &TusHandler{
+		uploadService: uploadService,
+	}
+}
+
+// RequireTusResumable sets the Tus-Resumable header on every response and
+// rejects requests made with an unsupported protocol version
+func (h *TusHandler) RequireTusResumable(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+
+	if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return
+	}
+
+	c.Next()
+}
+
+// Options advertises the supported protocol version and extensions
+func (h *TusHandler) Options(c *gin.Context) {
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(h.uploadService.MaxSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// Create starts an upload. The filename is taken from the "filename" (or
+// "name") key of the Upload-Metadata header.
+func (h *TusHandler) Create(c *gin.Context) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Length header"})
+		return
+	}
+
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Metadata header"})
+		return
+	}
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = metadata["name"]
+	}
+	if filename == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Metadata must include a filename"})
+		return
+	}
+
+	upload, err := h.uploadService.Create(c.Request.Context(), &fileDomain.CreateResumableUploadRequest{
+		Filename: filename,
+		Length:   length,
+		Metadata: c.GetHeader("Upload-Metadata"),
+	})
+	if err != nil {
+		respondError(c, err, "Failed to create upload")
+		return
+	}
+
+	c.Header("Location", strings.TrimRight(c.Request.URL.Path, "/")+"/"+upload.ID.String())
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// Head reports how many bytes of the upload have been received
+func (h *TusHandler) Head(c *gin.Context) {
+	id, ok := parseUploadID(c)
+	if !ok {
+		return
+	}
+
+	upload, err := h.uploadService.Get(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to get upload")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.Metadata != "" {
+		c.Header("Upload-Metadata", upload.Metadata)
+	}
+	setUploadState(c, upload)
+	c.Status(http.StatusOK)
+}
+
+// Patch appends the request body to the upload at Upload-Offset
+func (h *TusHandler) Patch(c *gin.Context) {
+	id, ok := parseUploadID(c)
+	if !ok {
+		return
+	}
+
+	if c.ContentType() != tusContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be " + tusContentType})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Offset header"})
+		return
+	}
+
+	upload, err := h.uploadService.WriteChunk(c.Request.Context(), id, offset, c.Request.Body)
+	if err != nil {
+		respondError(c, err, "Failed to write upload chunk")
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	setUploadState(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// Terminate discards an unfinished upload
+func (h *TusHandler) Terminate(c *gin.Context) {
+	id, ok := parseUploadID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.uploadService.Terminate(c.Request.Context(), id); err != nil {
+		respondError(c, err, "Failed to terminate upload")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func parseUploadID(c *gin.Context) (string, bool) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return "", false
+	}
+	return id, true
+}
+
+// setUploadState adds the expiry of unfinished uploads, or the file ID of
+// completed ones
+func setUploadState(c *gin.Context, upload *fileDomain.ResumableUpload) {
+	if upload.Completed() {
+		c.Header("X-File-ID", *upload.FileID)
+		return
+	}
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// parseTusMetadata decodes an Upload-Metadata header: comma-separated
+// "key base64value" pairs where the value is optional
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
@@ -25,3 +25,8 @@ func Recovery() gin.HandlerFunc {
 func Timeout() gin.HandlerFunc {
 	return middleware.Timeout(middleware.DefaultTimeoutConfig())
 }
+
+// Tracing returns a tracing middleware using the shared middleware package
+func Tracing() gin.HandlerFunc {
+	return middleware.Tracing(middleware.DefaultTracingConfig())
+}
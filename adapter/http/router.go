@@ -7,22 +7,51 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(todoHandler *handlers.TodoHandler, fileHandler *handlers.FileHandler) *gin.Engine {
+func SetupRouter(todoHandler *handlers.TodoHandler, fileHandler *handlers.FileHandler, healthHandler *handlers.HealthHandler, tusHandler *handlers.TusHandler, searchHandler *handlers.SearchHandler, tagHandler *handlers.TagHandler, projectHandler *handlers.ProjectHandler) *gin.Engine {
 	r := gin.New()
 
 	// Add middleware
+	r.Use(middleware.Tracing(middleware.DefaultTracingConfig()))
 	r.Use(middleware.RequestLogger(middleware.DefaultRequestLoggerConfig()))
+	r.Use(middleware.Identity())
 	r.Use(middleware.CORS(middleware.DefaultCORSConfig()))
 	r.Use(middleware.Recovery(middleware.DefaultRecoveryConfig()))
 	r.Use(middleware.Timeout(middleware.DefaultTimeoutConfig()))
 
-	// Health check
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Health checks
+	r.GET("/health", healthHandler.Readiness)
+	r.GET("/livez", healthHandler.Liveness)
+	r.GET("/readyz", healthHandler.Readiness)
 
 	// File upload
 	r.POST("/upload", fileHandler.UploadFile)
+	r.GET("/usage", fileHandler.GetUsage)
+
+	// File endpoints
+	fileGroup := r.Group("/files")
+	{
+		fileGroup.POST("/upload-url", fileHandler.CreateUploadURL)
+		fileGroup.POST("/archive", fileHandler.CreateArchive)
+		fileGroup.POST("/:id/complete", fileHandler.CompleteUpload)
+		fileGroup.GET("/:id", fileHandler.GetFile)
+		fileGroup.GET("/:id/download", fileHandler.DownloadFile)
+		fileGroup.GET("/:id/thumbnail", fileHandler.GetThumbnail)
+		fileGroup.PUT("/:id/content", fileHandler.ReplaceContent)
+		fileGroup.GET("/:id/versions", fileHandler.ListVersions)
+		fileGroup.GET("/:id/versions/:version/download", fileHandler.DownloadVersion)
+		fileGroup.POST("/:id/versions/:version/restore", fileHandler.RestoreVersion)
+		fileGroup.DELETE("/:id", fileHandler.DeleteFile)
+	}
+
+	// Resumable uploads (tus protocol)
+	tusGroup := r.Group("/files/tus", tusHandler.RequireTusResumable)
+	{
+		tusGroup.OPTIONS("", tusHandler.Options)
+		tusGroup.POST("", tusHandler.Create)
+		tusGroup.HEAD("/:id", tusHandler.Head)
+		tusGroup.PATCH("/:id", tusHandler.Patch)
+		tusGroup.DELETE("/:id", tusHandler.Terminate)
+	}
 
 	// Todo endpoints
 	todoGroup := r.Group("/todo")
@@ -32,7 +61,47 @@ func SetupRouter(todoHandler *handlers.TodoHandler, fileHandler *handlers.FileHa
 		todoGroup.GET("", todoHandler.ListTodos)
 		todoGroup.PUT("/:id", todoHandler.UpdateTodo)
 		todoGroup.DELETE("/:id", todoHandler.DeleteTodo)
+		todoGroup.GET("/:id/attachments", todoHandler.ListAttachments)
+		todoGroup.POST("/:id/attachments", todoHandler.AttachFile)
+		todoGroup.DELETE("/:id/attachments/:fileId", todoHandler.DetachFile)
+		todoGroup.GET("/:id/subtasks", todoHandler.ListSubtasks)
+		todoGroup.POST("/:id/checklist", todoHandler.AddChecklistItem)
+		todoGroup.PATCH("/:id/checklist/:itemId", todoHandler.UpdateChecklistItem)
+		todoGroup.DELETE("/:id/checklist/:itemId", todoHandler.DeleteChecklistItem)
+		todoGroup.GET("/:id/dependencies", todoHandler.GetDependencies)
+		todoGroup.POST("/:id/dependencies", todoHandler.AddDependency)
+		todoGroup.DELETE("/:id/dependencies/:blockerId", todoHandler.RemoveDependency)
+		todoGroup.POST("/plan", todoHandler.PlanTodos)
+		todoGroup.POST("/:id/move", todoHandler.MoveTodo)
+		todoGroup.POST("/:id/assignees", todoHandler.AssignTodo)
+		todoGroup.DELETE("/:id/assignees/:userId", todoHandler.UnassignTodo)
+		todoGroup.POST("/:id/watchers", todoHandler.WatchTodo)
+		todoGroup.DELETE("/:id/watchers/:userId", todoHandler.UnwatchTodo)
 	}
 
+	// Tag catalogue
+	tagGroup := r.Group("/tags")
+	{
+		tagGroup.GET("", tagHandler.ListTags)
+		tagGroup.POST("", tagHandler.CreateTag)
+		tagGroup.PATCH("/:id", tagHandler.RenameTag)
+		tagGroup.POST("/:id/merge", tagHandler.MergeTag)
+		tagGroup.DELETE("/:id", tagHandler.DeleteTag)
+	}
+
+	// Projects
+	projectGroup := r.Group("/projects")
+	{
+		projectGroup.GET("", projectHandler.ListProjects)
+		projectGroup.POST("", projectHandler.CreateProject)
+		projectGroup.POST("/reorder", projectHandler.ReorderProjects)
+		projectGroup.GET("/:id", projectHandler.GetProject)
+		projectGroup.PATCH("/:id", projectHandler.UpdateProject)
+		projectGroup.DELETE("/:id", projectHandler.DeleteProject)
+	}
+
+	// Search
+	r.GET("/search", searchHandler.Search)
+
 	return r
 }
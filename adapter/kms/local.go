@@ -0,0 +1,89 @@
+package kms
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"taskflow/internal/domain/shared"
+)
+
+// keyFile is the JSON layout of a local master key file:
+//
+//	{"currentKeyId": "2026-10", "keys": {"2026-10": "<base64 32 bytes>", "2025-04": "..."}}
+//
+// Rotating adds a key and makes it current; older keys stay until every data
+// key wrapped with them has been rewrapped.
+type keyFile struct {
+	CurrentKeyID string            `json:"currentKeyId"`
+	Keys         map[string]string `json:"keys"`
+}
+
+type localKeyManager struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewLocalKeyManager loads AES-256 master keys from a JSON file. It suits
+// deployments without a cloud KMS; the file must be protected like any
+// other secret.
+func NewLocalKeyManager(path string) (shared.KeyManager, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid key file: %w", err)
+	}
+
+	m := &localKeyManager{current: file.CurrentKeyID, keys: map[string]cipher.AEAD{}}
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("master key %q must be 32 base64-encoded bytes", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if m.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := m.keys[m.current]; !ok {
+		return nil, fmt.Errorf("current master key %q is not in the key file", m.current)
+	}
+	return m, nil
+}
+
+func (m *localKeyManager) CurrentKeyID() string {
+	return m.current
+}
+
+// Wrap seals the data key with the current master key. The key ID is
+// authenticated too, so a wrapped key can't be passed off as another key's.
+func (m *localKeyManager) Wrap(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	aead := m.keys[m.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return m.current, aead.Seal(nonce, nonce, dataKey, []byte(m.current)), nil
+}
+
+func (m *localKeyManager) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := m.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %q", keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped data key is too short")
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, []byte(keyID))
+}
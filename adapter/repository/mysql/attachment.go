@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) todo.AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+func (r *attachmentRepository) Add(ctx context.Context, attachment *todo.Attachment) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(attachment).Error
+}
+
+func (r *attachmentRepository) Remove(ctx context.Context, todoID uuid.UUID, fileID string) error {
+	result := r.db.WithContext(ctx).Delete(&todo.Attachment{}, "todo_id = ? AND file_id = ?", todoID.String(), fileID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return shared.NewNotFoundError("file is not attached to this todo")
+	}
+	return nil
+}
+
+func (r *attachmentRepository) ListByTodos(ctx context.Context, todoIDs []uuid.UUID) ([]*todo.Attachment, error) {
+	ids := make([]string, len(todoIDs))
+	for i, id := range todoIDs {
+		ids[i] = id.String()
+	}
+
+	var attachments []*todo.Attachment
+	err := r.db.WithContext(ctx).Where("todo_id IN ?", ids).Order("created_at ASC").Find(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *attachmentRepository) ListByFile(ctx context.Context, fileID string) ([]*todo.Attachment, error) {
+	var attachments []*todo.Attachment
+	err := r.db.WithContext(ctx).Where("file_id = ?", fileID).Find(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *attachmentRepository) ListByFiles(ctx context.Context, fileIDs []string) ([]*todo.Attachment, error) {
+	var attachments []*todo.Attachment
+	err := r.db.WithContext(ctx).Where("file_id IN ?", fileIDs).Find(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *attachmentRepository) DeleteByTodo(ctx context.Context, todoID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&todo.Attachment{}, "todo_id = ?", todoID.String()).Error
+}
+
+func (r *attachmentRepository) DeleteByFile(ctx context.Context, fileID string) error {
+	return r.db.WithContext(ctx).Delete(&todo.Attachment{}, "file_id = ?", fileID).Error
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/shared"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type blobRepository struct {
+	db *gorm.DB
+}
+
+func NewBlobRepository(db *gorm.DB) file.BlobRepository {
+	return &blobRepository{db: db}
+}
+
+// Acquire inserts the blob with one reference, or atomically adds a reference
+// if a concurrent or earlier upload already created it
+func (r *blobRepository) Acquire(ctx context.Context, blob *file.Blob) (*file.Blob, error) {
+	var stored file.Blob
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		candidate := *blob
+		candidate.RefCount = 1
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "checksum"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"ref_count":  gorm.Expr("ref_count + 1"),
+				"updated_at": time.Now(),
+			}),
+		}).Create(&candidate).Error
+		if err != nil {
+			return err
+		}
+		return tx.First(&stored, "checksum = ?", blob.Checksum).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (r *blobRepository) Release(ctx context.Context, checksum, storageKey string) (int64, error) {
+	var remaining int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var blob file.Blob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&blob, "checksum = ? AND storage_key = ?", checksum, storageKey).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return shared.ErrNotFound
+			}
+			return err
+		}
+
+		remaining = blob.RefCount - 1
+		if remaining <= 0 {
+			remaining = 0
+			return tx.Delete(&blob).Error
+		}
+		return tx.Model(&blob).Updates(map[string]interface{}{
+			"ref_count":  gorm.Expr("ref_count - 1"),
+			"updated_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return remaining, nil
+}
+
+func (r *blobRepository) ListAfter(ctx context.Context, afterChecksum string, limit int) ([]*file.Blob, error) {
+	var blobs []*file.Blob
+	err := r.db.WithContext(ctx).Where("checksum > ?", afterChecksum).Order("checksum ASC").Limit(limit).Find(&blobs).Error
+	if err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
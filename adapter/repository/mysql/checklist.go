@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type checklistRepository struct {
+	db *gorm.DB
+}
+
+func NewChecklistRepository(db *gorm.DB) todo.ChecklistRepository {
+	return &checklistRepository{db: db}
+}
+
+func (r *checklistRepository) Create(ctx context.Context, item *todo.ChecklistItem) error {
+	return r.db.WithContext(ctx).Create(item).Error
+}
+
+func (r *checklistRepository) Get(ctx context.Context, id uuid.UUID) (*todo.ChecklistItem, error) {
+	var item todo.ChecklistItem
+	err := r.db.WithContext(ctx).First(&item, "id = ?", id.String()).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, shared.NewNotFoundError("checklist item not found")
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *checklistRepository) Update(ctx context.Context, item *todo.ChecklistItem) error {
+	return r.db.WithContext(ctx).Model(item).Select("text", "done", "updated_at").Updates(item).Error
+}
+
+func (r *checklistRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&todo.ChecklistItem{}, "id = ?", id.String()).Error
+}
+
+func (r *checklistRepository) ListByTodos(ctx context.Context, todoIDs []uuid.UUID) ([]*todo.ChecklistItem, error) {
+	ids := make([]string, len(todoIDs))
+	for i, id := range todoIDs {
+		ids[i] = id.String()
+	}
+
+	var items []*todo.ChecklistItem
+	err := r.db.WithContext(ctx).Where("todo_id IN ?", ids).Order("position ASC").Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *checklistRepository) DeleteByTodo(ctx context.Context, todoID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&todo.ChecklistItem{}, "todo_id = ?", todoID.String()).Error
+}
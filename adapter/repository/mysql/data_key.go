@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"taskflow/internal/domain/shared"
+
+	"gorm.io/gorm"
+)
+
+type dataKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewDataKeyRepository(db *gorm.DB) shared.DataKeyRepository {
+	return &dataKeyRepository{db: db}
+}
+
+func (r *dataKeyRepository) Save(ctx context.Context, key *shared.DataKey) error {
+	return r.db.WithContext(ctx).Save(key).Error
+}
+
+func (r *dataKeyRepository) Get(ctx context.Context, storageKey string) (*shared.DataKey, error) {
+	var key shared.DataKey
+	err := r.db.WithContext(ctx).First(&key, "storage_key = ?", storageKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, shared.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *dataKeyRepository) Delete(ctx context.Context, storageKey string) error {
+	return r.db.WithContext(ctx).Delete(&shared.DataKey{}, "storage_key = ?", storageKey).Error
+}
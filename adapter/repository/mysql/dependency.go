@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type dependencyRepository struct {
+	db *gorm.DB
+}
+
+func NewDependencyRepository(db *gorm.DB) todo.DependencyRepository {
+	return &dependencyRepository{db: db}
+}
+
+// Add walks up from the blocker to everything it waits for, locking each
+// todo it reaches along with the todo itself. Two inserts that would close a
+// cycle together each reach the other's todo, so one waits for the other or
+// fails as a deadlock instead of both passing the check.
+func (r *dependencyRepository) Add(ctx context.Context, dependency *todo.Dependency) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockTodos(tx, []string{dependency.TodoID.String()}); err != nil {
+			return err
+		}
+		seen := map[string]bool{dependency.BlockedByID.String(): true}
+		for level := []string{dependency.BlockedByID.String()}; len(level) > 0; {
+			if err := lockTodos(tx, level); err != nil {
+				return err
+			}
+			var blockers []*todo.Dependency
+			if err := tx.Where("todo_id IN ?", level).Find(&blockers).Error; err != nil {
+				return err
+			}
+			level = nil
+			for _, blocker := range blockers {
+				if blocker.BlockedByID == dependency.TodoID {
+					return todo.ErrDependencyCycle
+				}
+				if id := blocker.BlockedByID.String(); !seen[id] {
+					seen[id] = true
+					level = append(level, id)
+				}
+			}
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(dependency).Error
+	})
+}
+
+func lockTodos(tx *gorm.DB, ids []string) error {
+	var locked []string
+	return tx.Model(&todo.TodoItem{}).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Pluck("id", &locked).Error
+}
+
+func (r *dependencyRepository) Remove(ctx context.Context, todoID, blockedByID uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&todo.Dependency{}, "todo_id = ? AND blocked_by_id = ?", todoID.String(), blockedByID.String())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return shared.NewNotFoundError("todo does not depend on this todo")
+	}
+	return nil
+}
+
+func (r *dependencyRepository) ListBlockers(ctx context.Context, todoIDs []uuid.UUID) ([]*todo.Dependency, error) {
+	return r.list(ctx, "todo_id IN ?", todoIDs)
+}
+
+func (r *dependencyRepository) ListBlocked(ctx context.Context, blockedByIDs []uuid.UUID) ([]*todo.Dependency, error) {
+	return r.list(ctx, "blocked_by_id IN ?", blockedByIDs)
+}
+
+func (r *dependencyRepository) list(ctx context.Context, condition string, todoIDs []uuid.UUID) ([]*todo.Dependency, error) {
+	ids := make([]string, len(todoIDs))
+	for i, id := range todoIDs {
+		ids[i] = id.String()
+	}
+
+	var dependencies []*todo.Dependency
+	err := r.db.WithContext(ctx).Where(condition, ids).Order("created_at ASC").Find(&dependencies).Error
+	if err != nil {
+		return nil, err
+	}
+	return dependencies, nil
+}
+
+func (r *dependencyRepository) DeleteByTodo(ctx context.Context, todoID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&todo.Dependency{}, "todo_id = ? OR blocked_by_id = ?", todoID.String(), todoID.String()).Error
+}
@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/shared"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -23,13 +26,37 @@ func (r *fileRepository) GetByID(ctx context.Context, id string) (*file.File, er
 	var fileItem file.File
 	err := r.db.WithContext(ctx).First(&fileItem, "id = ?", id).Error
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, shared.NewNotFoundError("file not found")
+		}
 		return nil, err
 	}
 	return &fileItem, nil
 }
 
-func (r *fileRepository) Update(ctx context.Context, file *file.File) error {
-	return r.db.WithContext(ctx).Model(file).Updates(file).Error
+// Update writes only the named fields, zero values included, so writers
+// owning different fields don't overwrite each other
+func (r *fileRepository) Update(ctx context.Context, file *file.File, fields ...string) error {
+	return r.db.WithContext(ctx).Model(file).Select(fields).Updates(file).Error
+}
+
+func (r *fileRepository) UpdateContent(ctx context.Context, file *file.File, version int, storageKey string, fields ...string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(file).
+		Where("version = ? AND storage_key = ?", version, storageKey).
+		Select(fields).
+		Updates(file)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *fileRepository) UpdateStatus(ctx context.Context, file *file.File, from string, fields ...string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(file).Where("status = ?", from).Select(fields).Updates(file)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
 }
 
 func (r *fileRepository) Delete(ctx context.Context, id string) error {
@@ -44,3 +71,49 @@ func (r *fileRepository) List(ctx context.Context, limit, offset int) ([]*file.F
 	}
 	return files, nil
 }
+
+func (r *fileRepository) ListPendingBefore(ctx context.Context, before time.Time, limit int) ([]*file.File, error) {
+	var files []*file.File
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND created_at < ?", file.StatusPending, before).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (r *fileRepository) ListQuarantinedBefore(ctx context.Context, before time.Time, limit int) ([]*file.File, error) {
+	var files []*file.File
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND scan_status = ? AND created_at < ?", file.StatusAvailable, file.ScanQuarantined, before).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (r *fileRepository) ListAfter(ctx context.Context, afterID string, limit int) ([]*file.File, error) {
+	var files []*file.File
+	err := r.db.WithContext(ctx).Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (r *fileRepository) ListWrappedAfter(ctx context.Context, keyID, afterID string, limit int) ([]*file.File, error) {
+	var files []*file.File
+	err := r.db.WithContext(ctx).
+		Where("key_id <> '' AND key_id <> ? AND id > ?", keyID, afterID).
+		Order("id ASC").Limit(limit).Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"taskflow/pkg/health"
+
+	"gorm.io/gorm"
+)
+
+// PingCheck returns a health check that pings the database connection pool
+func PingCheck(db *gorm.DB) health.CheckFunc {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
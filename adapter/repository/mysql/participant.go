@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type participantRepository struct {
+	db *gorm.DB
+}
+
+func NewParticipantRepository(db *gorm.DB) todo.ParticipantRepository {
+	return &participantRepository{db: db}
+}
+
+func (r *participantRepository) Add(ctx context.Context, participant *todo.Participant) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(participant).Error
+}
+
+func (r *participantRepository) Remove(ctx context.Context, todoID uuid.UUID, userID string, role todo.Role) error {
+	result := r.db.WithContext(ctx).Delete(&todo.Participant{}, "todo_id = ? AND user_id = ? AND role = ?", todoID.String(), userID, role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 && role == todo.RoleAssignee {
+		return shared.NewNotFoundError("user is not assigned to this todo")
+	}
+	if result.RowsAffected == 0 {
+		return shared.NewNotFoundError("user is not watching this todo")
+	}
+	return nil
+}
+
+func (r *participantRepository) ListByTodos(ctx context.Context, todoIDs []uuid.UUID) ([]*todo.Participant, error) {
+	ids := make([]string, len(todoIDs))
+	for i, id := range todoIDs {
+		ids[i] = id.String()
+	}
+
+	var participants []*todo.Participant
+	err := r.db.WithContext(ctx).Where("todo_id IN ?", ids).Order("created_at ASC").Find(&participants).Error
+	if err != nil {
+		return nil, err
+	}
+	return participants, nil
+}
+
+func (r *participantRepository) DeleteByTodo(ctx context.Context, todoID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&todo.Participant{}, "todo_id = ?", todoID.String()).Error
+}
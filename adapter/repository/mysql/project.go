@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type projectRepository struct {
+	db *gorm.DB
+}
+
+func NewProjectRepository(db *gorm.DB) todo.ProjectRepository {
+	return &projectRepository{db: db}
+}
+
+// withCounts selects projects with how many todos they have and how many of
+// those are done
+func (r *projectRepository) withCounts(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&todo.Project{}).
+		Select("projects.*, COUNT(todo_items.id) AS todo_count, COALESCE(SUM(todo_items.status = ?), 0) AS done_count", todo.StatusDone).
+		Joins("LEFT JOIN todo_items ON todo_items.project_id = projects.id").
+		Group("projects.id")
+}
+
+func (r *projectRepository) Create(ctx context.Context, project *todo.Project) error {
+	return r.db.WithContext(ctx).Create(project).Error
+}
+
+func (r *projectRepository) Get(ctx context.Context, id uuid.UUID) (*todo.Project, error) {
+	var project todo.Project
+	err := r.withCounts(ctx).Where("projects.id = ?", id.String()).Take(&project).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, shared.NewNotFoundError("project not found")
+		}
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (r *projectRepository) List(ctx context.Context, workspaceID string, includeArchived bool) ([]*todo.Project, error) {
+	query := r.withCounts(ctx).Where("projects.workspace_id = ?", workspaceID)
+	if !includeArchived {
+		query = query.Where("projects.archived = ?", false)
+	}
+
+	var projects []*todo.Project
+	err := query.Order("projects.position ASC").Order("projects.created_at ASC").Find(&projects).Error
+	if err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (r *projectRepository) Update(ctx context.Context, project *todo.Project) error {
+	return r.db.WithContext(ctx).Model(project).Select("name", "description", "color", "archived", "updated_at").Updates(project).Error
+}
+
+func (r *projectRepository) Reorder(ctx context.Context, ids []uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for position, id := range ids {
+			err := tx.Model(&todo.Project{}).Where("id = ?", id.String()).Update("position", position).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *projectRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&todo.Project{}, "id = ?", id.String()).Error
+}
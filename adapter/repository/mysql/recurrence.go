@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type recurrenceRepository struct {
+	db *gorm.DB
+}
+
+func NewRecurrenceRepository(db *gorm.DB) todo.RecurrenceRepository {
+	return &recurrenceRepository{db: db}
+}
+
+func (r *recurrenceRepository) Create(ctx context.Context, recurrence *todo.Recurrence) error {
+	return r.db.WithContext(ctx).Create(recurrence).Error
+}
+
+func (r *recurrenceRepository) Get(ctx context.Context, id uuid.UUID) (*todo.Recurrence, error) {
+	var recurrence todo.Recurrence
+	err := r.db.WithContext(ctx).Where("id = ?", id.String()).First(&recurrence).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, shared.NewNotFoundError("recurrence not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &recurrence, nil
+}
+
+func (r *recurrenceRepository) ListByIDs(ctx context.Context, ids []uuid.UUID) ([]*todo.Recurrence, error) {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = id.String()
+	}
+
+	var recurrences []*todo.Recurrence
+	if err := r.db.WithContext(ctx).Where("id IN ?", values).Find(&recurrences).Error; err != nil {
+		return nil, err
+	}
+	return recurrences, nil
+}
+
+func (r *recurrenceRepository) Update(ctx context.Context, recurrence *todo.Recurrence) error {
+	return r.db.WithContext(ctx).Model(recurrence).Select("*").Omit("created_at").Updates(recurrence).Error
+}
+
+func (r *recurrenceRepository) Advance(ctx context.Context, recurrence *todo.Recurrence, fromSequence int, fromDue time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&todo.Recurrence{}).
+		Where("id = ? AND sequence = ? AND last_due = ?", recurrence.ID.String(), fromSequence, fromDue).
+		Updates(map[string]interface{}{
+			"sequence":   recurrence.Sequence,
+			"last_due":   recurrence.LastDue,
+			"ended":      recurrence.Ended,
+			"updated_at": recurrence.UpdatedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *recurrenceRepository) ListDue(ctx context.Context, before time.Time, limit int) ([]*todo.Recurrence, error) {
+	var recurrences []*todo.Recurrence
+	err := r.db.WithContext(ctx).
+		Where("ended = ? AND last_due <= ?", false, before).
+		Order("last_due ASC").
+		Limit(limit).
+		Find(&recurrences).Error
+	if err != nil {
+		return nil, err
+	}
+	return recurrences, nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/shared"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type resumableUploadRepository struct {
+	db *gorm.DB
+}
+
+func NewResumableUploadRepository(db *gorm.DB) file.ResumableUploadRepository {
+	return &resumableUploadRepository{db: db}
+}
+
+func (r *resumableUploadRepository) Create(ctx context.Context, upload *file.ResumableUpload) error {
+	return r.db.WithContext(ctx).Create(upload).Error
+}
+
+func (r *resumableUploadRepository) GetByID(ctx context.Context, id string) (*file.ResumableUpload, error) {
+	var upload file.ResumableUpload
+	err := r.db.WithContext(ctx).First(&upload, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, shared.NewNotFoundError("upload not found")
+		}
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// Update saves every column, including zeroed tail sizes, but never touches
+// the lock lease which is managed separately
+func (r *resumableUploadRepository) Update(ctx context.Context, upload *file.ResumableUpload) error {
+	upload.UpdatedAt = time.Now()
+	return r.db.WithContext(ctx).Model(upload).Select("*").Omit("locked_until", "created_at").Updates(upload).Error
+}
+
+func (r *resumableUploadRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&file.ResumableUpload{}, "id = ?", id).Error
+}
+
+func (r *resumableUploadRepository) ListExpired(ctx context.Context, before time.Time, limit int) ([]*file.ResumableUpload, error) {
+	var uploads []*file.ResumableUpload
+	err := r.db.WithContext(ctx).
+		Where("expires_at < ?", before).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&uploads).Error
+	if err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+func (r *resumableUploadRepository) Lock(ctx context.Context, id string, until time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&file.ResumableUpload{}).
+		Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", id, time.Now()).
+		Update("locked_until", until)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *resumableUploadRepository) ExtendLock(ctx context.Context, id string, until time.Time) error {
+	return r.db.WithContext(ctx).Model(&file.ResumableUpload{}).
+		Where("id = ?", id).
+		Update("locked_until", until).Error
+}
+
+func (r *resumableUploadRepository) Unlock(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Model(&file.ResumableUpload{}).
+		Where("id = ?", id).
+		Update("locked_until", nil).Error
+}
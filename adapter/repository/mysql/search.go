@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"taskflow/internal/domain/search"
+	"taskflow/internal/domain/shared"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type documentRepository struct {
+	db *gorm.DB
+}
+
+func NewDocumentRepository(db *gorm.DB) search.DocumentRepository {
+	return &documentRepository{db: db}
+}
+
+func (r *documentRepository) Save(ctx context.Context, doc *search.Document) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(doc).Error
+}
+
+func (r *documentRepository) Get(ctx context.Context, id string) (*search.Document, error) {
+	var doc search.Document
+	err := r.db.WithContext(ctx).First(&doc, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, shared.NewNotFoundError("search document not found")
+		}
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (r *documentRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&search.Document{}, "id = ?", id).Error
+}
+
+func (r *documentRepository) ListAfter(ctx context.Context, afterID string, limit int) ([]*search.Document, error) {
+	var docs []*search.Document
+	err := r.db.WithContext(ctx).Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&docs).Error
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// fullTextIndex searches the documents table with its FULLTEXT index. The
+// document repository keeps the table current, so Put and Delete do nothing.
+type fullTextIndex struct {
+	db *gorm.DB
+}
+
+// NewFullTextIndex creates an index that searches the stored documents in
+// MySQL, sharing them between instances
+func NewFullTextIndex(db *gorm.DB) search.Index {
+	return &fullTextIndex{db: db}
+}
+
+func (i *fullTextIndex) Put(ctx context.Context, doc *search.Document) error {
+	return nil
+}
+
+func (i *fullTextIndex) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+type fullTextHit struct {
+	search.Document
+	Score float64
+}
+
+func (i *fullTextIndex) Search(ctx context.Context, terms []string, limit int) ([]*search.Hit, error) {
+	query := strings.Join(terms, " ")
+	var rows []*fullTextHit
+	err := i.db.WithContext(ctx).Model(&search.Document{}).
+		Select("*, MATCH(text) AGAINST(? IN NATURAL LANGUAGE MODE) AS score", query).
+		Where("MATCH(text) AGAINST(? IN NATURAL LANGUAGE MODE)", query).
+		Order("score DESC").Limit(limit).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]*search.Hit, len(rows))
+	for n, row := range rows {
+		doc := row.Document
+		hits[n] = &search.Hit{Document: &doc, Score: row.Score}
+	}
+	return hits, nil
+}
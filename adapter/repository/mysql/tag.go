@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type tagRepository struct {
+	db *gorm.DB
+}
+
+func NewTagRepository(db *gorm.DB) todo.TagRepository {
+	return &tagRepository{db: db}
+}
+
+// withCounts selects tags with how many todos have them
+func (r *tagRepository) withCounts(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&todo.Tag{}).
+		Select("tags.*, COUNT(todo_tags.todo_id) AS todo_count").
+		Joins("LEFT JOIN todo_tags ON todo_tags.tag_id = tags.id").
+		Group("tags.id")
+}
+
+func (r *tagRepository) Ensure(ctx context.Context, workspaceID string, names []string) ([]*todo.Tag, error) {
+	now := time.Now()
+	tags := make([]*todo.Tag, len(names))
+	for i, name := range names {
+		tags[i] = &todo.Tag{ID: uuid.New(), WorkspaceID: workspaceID, Name: name, CreatedAt: now}
+	}
+	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return nil, err
+	}
+	return r.GetByNames(ctx, workspaceID, names)
+}
+
+func (r *tagRepository) Get(ctx context.Context, id uuid.UUID) (*todo.Tag, error) {
+	var tag todo.Tag
+	err := r.withCounts(ctx).Where("tags.id = ?", id.String()).Take(&tag).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, shared.NewNotFoundError("tag not found")
+		}
+		return nil, err
+	}
+	return &tag, nil
+}
+
+func (r *tagRepository) GetByNames(ctx context.Context, workspaceID string, names []string) ([]*todo.Tag, error) {
+	var tags []*todo.Tag
+	err := r.db.WithContext(ctx).Where("workspace_id = ? AND name IN ?", workspaceID, names).Order("name ASC").Find(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (r *tagRepository) List(ctx context.Context, workspaceID string) ([]*todo.Tag, error) {
+	var tags []*todo.Tag
+	err := r.withCounts(ctx).Where("tags.workspace_id = ?", workspaceID).Order("tags.name ASC").Find(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (r *tagRepository) Update(ctx context.Context, tag *todo.Tag) error {
+	return r.db.WithContext(ctx).Model(tag).Update("name", tag.Name).Error
+}
+
+func (r *tagRepository) Merge(ctx context.Context, fromID, intoID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT IGNORE INTO todo_tags (todo_id, tag_id)
+			SELECT todo_id, ? FROM todo_tags WHERE tag_id = ?`, intoID.String(), fromID.String()).Error
+		if err != nil {
+			return err
+		}
+		return deleteTag(tx, fromID)
+	})
+}
+
+func (r *tagRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteTag(tx, id)
+	})
+}
+
+func deleteTag(tx *gorm.DB, id uuid.UUID) error {
+	if err := tx.Delete(&todo.TodoTag{}, "tag_id = ?", id.String()).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&todo.Tag{}, "id = ?", id.String()).Error
+}
+
+func (r *tagRepository) SetTodoTags(ctx context.Context, todoID uuid.UUID, tagIDs []uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&todo.TodoTag{}, "todo_id = ?", todoID.String()).Error; err != nil {
+			return err
+		}
+		if len(tagIDs) == 0 {
+			return nil
+		}
+		links := make([]*todo.TodoTag, len(tagIDs))
+		for i, tagID := range tagIDs {
+			links[i] = &todo.TodoTag{TodoID: todoID, TagID: tagID}
+		}
+		return tx.Create(&links).Error
+	})
+}
+
+func (r *tagRepository) ListByTodos(ctx context.Context, todoIDs []uuid.UUID) ([]*todo.TodoTag, error) {
+	ids := make([]string, len(todoIDs))
+	for i, id := range todoIDs {
+		ids[i] = id.String()
+	}
+
+	var tags []*todo.TodoTag
+	err := r.db.WithContext(ctx).Model(&todo.TodoTag{}).
+		Select("todo_tags.*, tags.name").
+		Joins("JOIN tags ON tags.id = todo_tags.tag_id").
+		Where("todo_tags.todo_id IN ?", ids).
+		Order("tags.name ASC").Find(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/search"
+	"taskflow/internal/domain/shared"
 	"taskflow/internal/domain/todo"
 
 	"github.com/google/uuid"
@@ -15,14 +18,66 @@ func NewGormConnection(databaseURL string) (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := db.Use(NewTracingPlugin()); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
 
 func RunGormMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
+	countUsage := !db.Migrator().HasTable(&file.Usage{})
+	err := db.AutoMigrate(
 		&todo.TodoItem{},
+		&todo.Attachment{},
+		&todo.Tag{},
+		&todo.TodoTag{},
+		&todo.ChecklistItem{},
+		&todo.Dependency{},
+		&todo.Recurrence{},
+		&todo.Project{},
+		&todo.Participant{},
 		&file.File{},
+		&file.ResumableUpload{},
+		&file.Blob{},
+		&file.Usage{},
+		&file.FileVersion{},
+		&search.Document{},
+		&shared.DataKey{},
 	)
+	if err != nil {
+		return err
+	}
+	if err := migrateTodoFileIDs(db); err != nil {
+		return err
+	}
+	if countUsage {
+		return migrateUsage(db)
+	}
+	return nil
+}
+
+// migrateUsage fills the usage counters from the files stored before they
+// were kept. Those files were stored without a workspace, and uploads in
+// progress at that time are not counted.
+func migrateUsage(db *gorm.DB) error {
+	return db.Exec(`INSERT INTO usages (scope, owner_id, bytes, files, updated_at)
+		SELECT ?, owner_id, SUM(size), COUNT(*), NOW() FROM files WHERE owner_id <> '' GROUP BY owner_id`, file.ScopeUser).Error
+}
+
+// migrateTodoFileIDs moves the single file reference todos used to have into
+// the attachments table, keeping only references to files that still exist
+func migrateTodoFileIDs(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&todo.TodoItem{}, "file_id") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT IGNORE INTO attachments (todo_id, file_id, created_at)
+			SELECT t.id, t.file_id, t.updated_at FROM todo_items t JOIN files f ON f.id = t.file_id`).Error
+		if err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&todo.TodoItem{}, "file_id")
+	})
 }
 
 type todoRepository struct {
@@ -41,24 +96,127 @@ func (r *todoRepository) GetByID(ctx context.Context, id uuid.UUID) (*todo.TodoI
 	var todoItem todo.TodoItem
 	err := r.db.WithContext(ctx).First(&todoItem, "id = ?", id.String()).Error
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, shared.NewNotFoundError("todo not found")
+		}
 		return nil, err
 	}
 	return &todoItem, nil
 }
 
-func (r *todoRepository) List(ctx context.Context, limit, offset int) ([]*todo.TodoItem, error) {
+func (r *todoRepository) List(ctx context.Context, filter todo.ListFilter, limit, offset int) ([]*todo.TodoItem, error) {
+	query := r.db.WithContext(ctx)
+	if filter.ProjectID != nil {
+		query = query.Where("project_id = ?", filter.ProjectID.String())
+	} else if !filter.IncludeArchived {
+		archived := r.db.Model(&todo.Project{}).Select("id").Where("archived = ?", true)
+		query = query.Where("project_id IS NULL OR project_id NOT IN (?)", archived)
+	}
+	if len(filter.Priorities) > 0 {
+		query = query.Where("priority IN ?", filter.Priorities)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if filter.Assignee != "" {
+		assigned := r.db.Model(&todo.Participant{}).Select("todo_id").Where("user_id = ? AND role = ?", filter.Assignee, todo.RoleAssignee)
+		query = query.Where("id IN (?)", assigned)
+	}
+	if len(filter.Tags) > 0 {
+		tagged := r.db.Table("todo_tags").Select("todo_tags.todo_id").
+			Joins("JOIN tags ON tags.id = todo_tags.tag_id").
+			Where("tags.workspace_id = ? AND tags.name IN ?", filter.WorkspaceID, filter.Tags)
+		if !filter.AnyTag {
+			tagged = tagged.Group("todo_tags.todo_id").Having("COUNT(*) = ?", len(filter.Tags))
+		}
+		query = query.Where("id IN (?)", tagged)
+	}
+
+	if filter.OrderByRank {
+		query = query.Order("`rank` = '' ASC").Order("`rank` ASC").Order("created_at ASC")
+	} else {
+		query = query.Order("created_at DESC")
+	}
+
 	var todos []*todo.TodoItem
-	err := r.db.WithContext(ctx).Order("created_at DESC").Limit(limit).Offset(offset).Find(&todos).Error
+	err := query.Limit(limit).Offset(offset).Find(&todos).Error
 	if err != nil {
 		return nil, err
 	}
 	return todos, nil
 }
 
+// Update writes every column but the rank, so clearing the parent or
+// completion time sticks
 func (r *todoRepository) Update(ctx context.Context, todoItem *todo.TodoItem) error {
-	return r.db.WithContext(ctx).Model(todoItem).Updates(todoItem).Error
+	return r.db.WithContext(ctx).Model(todoItem).Select("*").Omit("created_at", "rank").Updates(todoItem).Error
 }
 
 func (r *todoRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&todo.TodoItem{}, "id = ?", id.String()).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&todo.TodoTag{}, "todo_id = ?", id.String()).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&todo.TodoItem{}, "id = ?", id.String()).Error
+	})
+}
+
+func (r *todoRepository) ListChildren(ctx context.Context, parentIDs []uuid.UUID) ([]*todo.TodoItem, error) {
+	ids := make([]string, len(parentIDs))
+	for i, id := range parentIDs {
+		ids[i] = id.String()
+	}
+
+	var todos []*todo.TodoItem
+	err := r.db.WithContext(ctx).Where("parent_id IN ?", ids).Order("created_at ASC").Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (r *todoRepository) AdjacentRank(ctx context.Context, status todo.Status, rank string, before bool, exclude uuid.UUID) (string, error) {
+	query := r.db.WithContext(ctx).Model(&todo.TodoItem{}).
+		Where("status = ? AND `rank` <> '' AND id <> ?", status, exclude.String())
+	switch {
+	case before && rank != "":
+		query = query.Where("`rank` < ?", rank).Order("`rank` DESC")
+	case before:
+		query = query.Order("`rank` DESC")
+	default:
+		query = query.Where("`rank` > ?", rank).Order("`rank` ASC")
+	}
+
+	var ranks []string
+	if err := query.Limit(1).Pluck("rank", &ranks).Error; err != nil {
+		return "", err
+	}
+	if len(ranks) == 0 {
+		return "", nil
+	}
+	return ranks[0], nil
+}
+
+func (r *todoRepository) ListColumn(ctx context.Context, status todo.Status) ([]*todo.TodoItem, error) {
+	var todos []*todo.TodoItem
+	err := r.db.WithContext(ctx).Select("id", "rank", "created_at").Where("status = ?", status).
+		Order("`rank` = '' ASC").Order("`rank` ASC").Order("created_at ASC").Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// SetRanks writes ranks without touching updated_at, since respacing doesn't
+// change the order
+func (r *todoRepository) SetRanks(ctx context.Context, ranks map[uuid.UUID]string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for id, rank := range ranks {
+			err := tx.Model(&todo.TodoItem{}).Where("id = ?", id.String()).UpdateColumn("rank", rank).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
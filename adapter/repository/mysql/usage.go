@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"taskflow/internal/domain/file"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type usageRepository struct {
+	db *gorm.DB
+}
+
+func NewUsageRepository(db *gorm.DB) file.UsageRepository {
+	return &usageRepository{db: db}
+}
+
+func (r *usageRepository) Get(ctx context.Context, scope, ownerID string) (*file.Usage, error) {
+	var usage file.Usage
+	err := r.db.WithContext(ctx).First(&usage, "scope = ? AND owner_id = ?", scope, ownerID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &file.Usage{Scope: scope, OwnerID: ownerID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// Charge creates the counter if needed, then adds to it in a single
+// conditional update so concurrent uploads can't both slip under the limit
+func (r *usageRepository) Charge(ctx context.Context, scope, ownerID string, bytes, files int64, limit file.Quota) (bool, error) {
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&file.Usage{Scope: scope, OwnerID: ownerID, UpdatedAt: time.Now()}).Error
+	if err != nil {
+		return false, err
+	}
+
+	query := r.db.WithContext(ctx).Model(&file.Usage{}).Where("scope = ? AND owner_id = ?", scope, ownerID)
+	if limit.Bytes > 0 {
+		query = query.Where("bytes + ? <= ?", bytes, limit.Bytes)
+	}
+	if limit.Files > 0 {
+		query = query.Where("files + ? <= ?", files, limit.Files)
+	}
+	result := query.Updates(map[string]interface{}{
+		"bytes":      gorm.Expr("bytes + ?", bytes),
+		"files":      gorm.Expr("files + ?", files),
+		"updated_at": time.Now(),
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *usageRepository) Credit(ctx context.Context, scope, ownerID string, bytes, files int64) error {
+	return r.db.WithContext(ctx).Model(&file.Usage{}).
+		Where("scope = ? AND owner_id = ?", scope, ownerID).
+		Updates(map[string]interface{}{
+			"bytes":      gorm.Expr("GREATEST(bytes - ?, 0)", bytes),
+			"files":      gorm.Expr("GREATEST(files - ?, 0)", files),
+			"updated_at": time.Now(),
+		}).Error
+}
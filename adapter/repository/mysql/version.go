@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/shared"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type versionRepository struct {
+	db *gorm.DB
+}
+
+func NewVersionRepository(db *gorm.DB) file.VersionRepository {
+	return &versionRepository{db: db}
+}
+
+func (r *versionRepository) Create(ctx context.Context, version *file.FileVersion) error {
+	return r.db.WithContext(ctx).Create(version).Error
+}
+
+func (r *versionRepository) Get(ctx context.Context, fileID string, number int) (*file.FileVersion, error) {
+	var version file.FileVersion
+	err := r.db.WithContext(ctx).First(&version, "file_id = ? AND number = ?", fileID, number).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, shared.NewNotFoundError("version not found")
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (r *versionRepository) Update(ctx context.Context, version *file.FileVersion) error {
+	return r.db.WithContext(ctx).Model(version).Select("*").Omit("created_at").Updates(version).Error
+}
+
+func (r *versionRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&file.FileVersion{}, "id = ?", id).Error
+}
+
+func (r *versionRepository) ListByFile(ctx context.Context, fileID string) ([]*file.FileVersion, error) {
+	var versions []*file.FileVersion
+	err := r.db.WithContext(ctx).Where("file_id = ?", fileID).Order("number DESC").Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *versionRepository) ListBefore(ctx context.Context, before time.Time, limit int) ([]*file.FileVersion, error) {
+	var versions []*file.FileVersion
+	err := r.db.WithContext(ctx).Where("created_at < ?", before).Order("created_at ASC").Limit(limit).Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *versionRepository) ListAfter(ctx context.Context, afterID string, limit int) ([]*file.FileVersion, error) {
+	var versions []*file.FileVersion
+	err := r.db.WithContext(ctx).Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *versionRepository) ListWrappedAfter(ctx context.Context, keyID, afterID string, limit int) ([]*file.FileVersion, error) {
+	var versions []*file.FileVersion
+	err := r.db.WithContext(ctx).
+		Where("key_id <> '' AND key_id <> ? AND id > ?", keyID, afterID).
+		Order("id ASC").Limit(limit).Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
@@ -0,0 +1,161 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/health"
+	"time"
+)
+
+// chunkSize is the size of the INSTREAM chunks sent to clamd
+const chunkSize = 64 * 1024
+
+type clamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner returns a scanner that streams content to a ClamAV clamd
+// daemon using the INSTREAM command. address is "host:port" for TCP or an
+// absolute path for a Unix socket. timeout bounds each scan.
+func NewClamdScanner(address string, timeout time.Duration) shared.MalwareScanner {
+	network := "tcp"
+	if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+	return &clamdScanner{
+		network: network,
+		address: address,
+		timeout: timeout,
+	}
+}
+
+func (s *clamdScanner) Scan(ctx context.Context, content io.Reader) (*shared.ScanResult, error) {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, chunkSize)
+	for {
+		n, readErr := content.Read(buf)
+		if n > 0 {
+			if err := writeChunk(conn, buf[:n]); err != nil {
+				return nil, fmt.Errorf("clamd closed the stream: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+	// A zero-length chunk ends the stream
+	if err := writeChunk(conn, nil); err != nil {
+		return nil, err
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return nil, err
+	}
+	return parseScanReply(reply)
+}
+
+// Ping checks that clamd is answering
+func (s *clamdScanner) Ping(ctx context.Context) error {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected clamd reply: %q", reply)
+	}
+	return nil
+}
+
+// dial connects to clamd with a deadline of timeout, or the context deadline
+// if that is sooner
+func (s *clamdScanner) dial(ctx context.Context) (net.Conn, error) {
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func writeChunk(w io.Writer, data []byte) error {
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readReply reads one NUL-terminated reply
+func readReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadBytes(0)
+	if err != nil && len(reply) == 0 {
+		return "", err
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+// parseScanReply interprets "stream: OK", "stream: <signature> FOUND" and
+// "<message> ERROR" replies
+func parseScanReply(reply string) (*shared.ScanResult, error) {
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return &shared.ScanResult{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return &shared.ScanResult{Infected: true, Signature: strings.TrimSuffix(result, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamd scan failed: %s", result)
+	}
+}
+
+// PingCheck returns a health check that pings clamd
+func PingCheck(scanner shared.MalwareScanner) health.CheckFunc {
+	return func(ctx context.Context) error {
+		if pinger, ok := scanner.(interface{ Ping(context.Context) error }); ok {
+			return pinger.Ping(ctx)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,126 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"taskflow/internal/domain/search"
+)
+
+// BM25 parameters: k1 limits how much repeating a word raises a score and b
+// how much longer documents are penalised
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// memoryIndex is an inverted index held in process. It is lost on restart
+// and rebuilt from the document repository.
+type memoryIndex struct {
+	mu       sync.RWMutex
+	docs     map[string]*search.Document
+	lengths  map[string]int
+	postings map[string]map[string]int
+	total    int
+}
+
+// NewMemoryIndex creates an empty in-process index ranking documents with
+// BM25
+func NewMemoryIndex() search.Index {
+	return &memoryIndex{
+		docs:     map[string]*search.Document{},
+		lengths:  map[string]int{},
+		postings: map[string]map[string]int{},
+	}
+}
+
+func (i *memoryIndex) Put(ctx context.Context, doc *search.Document) error {
+	counts := map[string]int{}
+	terms := search.Tokenize(doc.Text)
+	for _, term := range terms {
+		counts[term]++
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(doc.ID)
+	i.docs[doc.ID] = doc
+	i.lengths[doc.ID] = len(terms)
+	i.total += len(terms)
+	for term, count := range counts {
+		if i.postings[term] == nil {
+			i.postings[term] = map[string]int{}
+		}
+		i.postings[term][doc.ID] = count
+	}
+	return nil
+}
+
+func (i *memoryIndex) Delete(ctx context.Context, id string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(id)
+	return nil
+}
+
+// remove drops a document; the caller holds the write lock
+func (i *memoryIndex) remove(id string) {
+	doc, ok := i.docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range search.Tokenize(doc.Text) {
+		if docs := i.postings[term]; docs != nil {
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(i.postings, term)
+			}
+		}
+	}
+	i.total -= i.lengths[id]
+	delete(i.lengths, id)
+	delete(i.docs, id)
+}
+
+func (i *memoryIndex) Search(ctx context.Context, terms []string, limit int) ([]*search.Hit, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if len(i.docs) == 0 {
+		return []*search.Hit{}, nil
+	}
+
+	n := float64(len(i.docs))
+	avgLength := float64(i.total) / n
+	if avgLength == 0 {
+		avgLength = 1
+	}
+	scores := map[string]float64{}
+	for _, term := range terms {
+		docs := i.postings[term]
+		if len(docs) == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+		for id, count := range docs {
+			tf := float64(count)
+			norm := 1 - b + b*float64(i.lengths[id])/avgLength
+			scores[id] += idf * tf * (k1 + 1) / (tf + k1*norm)
+		}
+	}
+
+	hits := make([]*search.Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, &search.Hit{Document: i.docs[id], Score: score})
+	}
+	sort.Slice(hits, func(x, y int) bool {
+		if hits[x].Score != hits[y].Score {
+			return hits[x].Score > hits[y].Score
+		}
+		return hits[x].Document.ID < hits[y].Document.ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
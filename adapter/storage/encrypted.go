@@ -0,0 +1,456 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+	"time"
+)
+
+const (
+	// encryptionChunkSize is the plaintext sealed by each AES-GCM chunk, so
+	// objects can be encrypted and decrypted while streaming and read from
+	// the middle
+	encryptionChunkSize = 64 * 1024
+	// encryptionOverhead is the GCM tag appended to every chunk
+	encryptionOverhead = 16
+	sealedChunkSize    = encryptionChunkSize + encryptionOverhead
+)
+
+var (
+	errNotListable  = errors.New("wrapped storage cannot list objects")
+	errNotMultipart = errors.New("wrapped storage does not support multipart uploads")
+	errChunkOpen    = errors.New("encrypted object failed authentication")
+)
+
+// encryptedStorage encrypts objects before they reach the wrapped storage.
+// Every object is sealed with its own random AES-256 data key, in chunks of
+// encryptionChunkSize whose nonce is their index. Data keys are kept wrapped
+// by a master key in a DataKeyRepository, so rotating the master key only
+// rewraps them and never rewrites objects.
+type encryptedStorage struct {
+	inner shared.Storage
+	keys  shared.DataKeyRepository
+	kms   shared.KeyManager
+}
+
+// NewEncryptedStorage wraps any storage adapter. Objects stored before
+// encryption was enabled have no data key and are read as they are.
+// Presigned URLs and direct uploads would bypass encryption, so they are
+// not offered.
+func NewEncryptedStorage(inner shared.Storage, keys shared.DataKeyRepository, kms shared.KeyManager) shared.Storage {
+	return &encryptedStorage{inner: inner, keys: keys, kms: kms}
+}
+
+func (s *encryptedStorage) Upload(ctx context.Context, filename string, content io.Reader, contentType string) (string, error) {
+	dataKey, aead, err := s.newDataKey(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	sealer := newSealingReader(content, aead)
+	key, err := s.inner.Upload(ctx, filename, sealer, contentType)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey.StorageKey = key
+	dataKey.Size = sealer.n
+	if err := s.keys.Save(ctx, dataKey); err != nil {
+		// Without its data key the object can never be read
+		if err := s.inner.Delete(ctx, key); err != nil {
+			logging.FromContext(ctx).Error("failed to delete object without data key", "error", err, "key", key)
+		}
+		return "", err
+	}
+	return key, nil
+}
+
+func (s *encryptedStorage) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	dataKey, aead, err := s.openDataKey(ctx, key)
+	if errors.Is(err, shared.ErrNotFound) {
+		return s.inner.Download(ctx, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := s.inner.Download(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return newOpeningReader(content, aead, 0, dataKey.Size), nil
+}
+
+// DownloadRange reads only the chunks covering the range and drops the
+// plaintext before offset
+func (s *encryptedStorage) DownloadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	dataKey, aead, err := s.openDataKey(ctx, key)
+	if errors.Is(err, shared.ErrNotFound) {
+		return s.inner.DownloadRange(ctx, key, offset, length)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || length <= 0 || offset+length > dataKey.Size {
+		return nil, fmt.Errorf("range %d+%d is outside the %d byte object", offset, length, dataKey.Size)
+	}
+
+	first := offset / encryptionChunkSize
+	plainEnd := min((offset+length-1)/encryptionChunkSize*encryptionChunkSize+encryptionChunkSize, dataKey.Size)
+	sealedStart := first * sealedChunkSize
+	content, err := s.inner.DownloadRange(ctx, key, sealedStart, sealedSize(plainEnd)-sealedStart)
+	if err != nil {
+		return nil, err
+	}
+
+	opener := newOpeningReader(content, aead, uint64(first), plainEnd-first*encryptionChunkSize)
+	if _, err := io.CopyN(io.Discard, opener, offset-first*encryptionChunkSize); err != nil {
+		opener.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(opener, length), opener}, nil
+}
+
+func (s *encryptedStorage) Delete(ctx context.Context, key string) error {
+	if err := s.inner.Delete(ctx, key); err != nil {
+		return err
+	}
+	return s.keys.Delete(ctx, key)
+}
+
+func (s *encryptedStorage) GetURL(ctx context.Context, key string) (string, error) {
+	return "", errors.New("presigned URLs would serve encrypted content")
+}
+
+func (s *encryptedStorage) ListObjects(ctx context.Context, fn func(shared.ObjectInfo) error) error {
+	listable, ok := s.inner.(shared.ListableStorage)
+	if !ok {
+		return errNotListable
+	}
+	return listable.ListObjects(ctx, fn)
+}
+
+func (s *encryptedStorage) CurrentKeyID() string {
+	return s.kms.CurrentKeyID()
+}
+
+func (s *encryptedStorage) KeyID(ctx context.Context, key string) (string, error) {
+	dataKey, err := s.keys.Get(ctx, key)
+	if errors.Is(err, shared.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return dataKey.KeyID, nil
+}
+
+func (s *encryptedStorage) Rewrap(ctx context.Context, key string) (string, error) {
+	dataKey, err := s.keys.Get(ctx, key)
+	if errors.Is(err, shared.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if dataKey.KeyID == s.kms.CurrentKeyID() {
+		return dataKey.KeyID, nil
+	}
+
+	plain, err := s.kms.Unwrap(ctx, dataKey.KeyID, dataKey.WrappedKey)
+	if err != nil {
+		return "", err
+	}
+	if dataKey.KeyID, dataKey.WrappedKey, err = s.kms.Wrap(ctx, plain); err != nil {
+		return "", err
+	}
+	dataKey.UpdatedAt = time.Now()
+	if err := s.keys.Save(ctx, dataKey); err != nil {
+		return "", err
+	}
+	return dataKey.KeyID, nil
+}
+
+// MinPartSize is a whole number of chunks. Every part except the last must
+// be exactly this size, since a part's position decides its chunk nonces.
+func (s *encryptedStorage) MinPartSize() int64 {
+	multipart, ok := s.inner.(shared.MultipartStorage)
+	if !ok {
+		return encryptionChunkSize
+	}
+	return (multipart.MinPartSize() + encryptionChunkSize - 1) / encryptionChunkSize * encryptionChunkSize
+}
+
+func (s *encryptedStorage) CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	multipart, ok := s.inner.(shared.MultipartStorage)
+	if !ok {
+		return "", errNotMultipart
+	}
+	dataKey, _, err := s.newDataKey(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	uploadID, err := multipart.CreateMultipartUpload(ctx, key, contentType)
+	if err != nil {
+		return "", err
+	}
+	if err := s.keys.Save(ctx, dataKey); err != nil {
+		if err := multipart.AbortMultipartUpload(ctx, key, uploadID); err != nil {
+			logging.FromContext(ctx).Error("failed to abort multipart upload", "error", err, "key", key)
+		}
+		return "", err
+	}
+	return uploadID, nil
+}
+
+func (s *encryptedStorage) UploadPart(ctx context.Context, key string, uploadID string, number int, content io.ReadSeeker) (string, error) {
+	multipart, ok := s.inner.(shared.MultipartStorage)
+	if !ok {
+		return "", errNotMultipart
+	}
+	_, aead, err := s.openDataKey(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	plain, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+	first := uint64(number-1) * uint64(s.MinPartSize()/encryptionChunkSize)
+	sealed := make([]byte, 0, sealedSize(int64(len(plain))))
+	for index := first; len(plain) > 0; index++ {
+		chunk := plain[:min(len(plain), encryptionChunkSize)]
+		sealed = aead.Seal(sealed, chunkNonce(index), chunk, nil)
+		plain = plain[len(chunk):]
+	}
+	return multipart.UploadPart(ctx, key, uploadID, number, bytes.NewReader(sealed))
+}
+
+// CompleteMultipartUpload records the plaintext size, which parts carry
+func (s *encryptedStorage) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []shared.UploadedPart) error {
+	multipart, ok := s.inner.(shared.MultipartStorage)
+	if !ok {
+		return errNotMultipart
+	}
+	dataKey, err := s.keys.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := multipart.CompleteMultipartUpload(ctx, key, uploadID, parts); err != nil {
+		return err
+	}
+
+	dataKey.Size = 0
+	for _, part := range parts {
+		dataKey.Size += part.Size
+	}
+	dataKey.UpdatedAt = time.Now()
+	return s.keys.Save(ctx, dataKey)
+}
+
+func (s *encryptedStorage) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	multipart, ok := s.inner.(shared.MultipartStorage)
+	if !ok {
+		return errNotMultipart
+	}
+	if err := multipart.AbortMultipartUpload(ctx, key, uploadID); err != nil {
+		return err
+	}
+	return s.keys.Delete(ctx, key)
+}
+
+// Put seals content with a fresh data key every time, as reusing one for
+// different content under the same nonces would break GCM
+func (s *encryptedStorage) Put(ctx context.Context, key string, content io.ReadSeeker, contentType string) error {
+	multipart, ok := s.inner.(shared.MultipartStorage)
+	if !ok {
+		return errNotMultipart
+	}
+	dataKey, aead, err := s.newDataKey(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	sealer := newSealingReader(content, aead)
+	sealed, err := io.ReadAll(sealer)
+	if err != nil {
+		return err
+	}
+	if err := multipart.Put(ctx, key, bytes.NewReader(sealed), contentType); err != nil {
+		return err
+	}
+	dataKey.Size = sealer.n
+	return s.keys.Save(ctx, dataKey)
+}
+
+// newDataKey generates a data key and wraps it with the current master key
+func (s *encryptedStorage) newDataKey(ctx context.Context, storageKey string) (*shared.DataKey, cipher.AEAD, error) {
+	plain := make([]byte, 32)
+	if _, err := rand.Read(plain); err != nil {
+		return nil, nil, err
+	}
+	keyID, wrapped, err := s.kms.Wrap(ctx, plain)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newChunkAEAD(plain)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	return &shared.DataKey{
+		StorageKey: storageKey,
+		KeyID:      keyID,
+		WrappedKey: wrapped,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}, aead, nil
+}
+
+// openDataKey unwraps the data key of an object, returning shared.ErrNotFound
+// if it was stored unencrypted
+func (s *encryptedStorage) openDataKey(ctx context.Context, key string) (*shared.DataKey, cipher.AEAD, error) {
+	dataKey, err := s.keys.Get(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := s.kms.Unwrap(ctx, dataKey.KeyID, dataKey.WrappedKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newChunkAEAD(plain)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dataKey, aead, nil
+}
+
+func newChunkAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce is the nonce of the chunk at index. Data keys seal a single
+// object, so chunk indexes never repeat under one key.
+func chunkNonce(index uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], index)
+	return nonce
+}
+
+// sealedSize is the stored size of size bytes of plaintext
+func sealedSize(size int64) int64 {
+	chunks := (size + encryptionChunkSize - 1) / encryptionChunkSize
+	return size + chunks*encryptionOverhead
+}
+
+// sealingReader encrypts r chunk by chunk as it is read, counting the
+// plaintext in n
+type sealingReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	index  uint64
+	n      int64
+	plain  []byte
+	sealed []byte
+	out    []byte
+	done   bool
+}
+
+func newSealingReader(r io.Reader, aead cipher.AEAD) *sealingReader {
+	return &sealingReader{
+		r:      r,
+		aead:   aead,
+		plain:  make([]byte, encryptionChunkSize),
+		sealed: make([]byte, 0, sealedChunkSize),
+	}
+}
+
+func (r *sealingReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(r.r, r.plain)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			r.done = true
+		} else if err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			r.out = r.aead.Seal(r.sealed[:0], chunkNonce(r.index), r.plain[:n], nil)
+			r.index++
+			r.n += int64(n)
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// openingReader decrypts sealed chunks starting at index, expecting exactly
+// remaining bytes of plaintext. Missing or altered chunks fail the read.
+type openingReader struct {
+	rc        io.ReadCloser
+	aead      cipher.AEAD
+	index     uint64
+	remaining int64
+	sealed    []byte
+	out       []byte
+}
+
+func newOpeningReader(rc io.ReadCloser, aead cipher.AEAD, index uint64, remaining int64) *openingReader {
+	return &openingReader{
+		rc:        rc,
+		aead:      aead,
+		index:     index,
+		remaining: remaining,
+		sealed:    make([]byte, sealedChunkSize),
+	}
+}
+
+func (r *openingReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.remaining <= 0 {
+			return 0, io.EOF
+		}
+		sealed := r.sealed[:min(r.remaining, encryptionChunkSize)+encryptionOverhead]
+		if _, err := io.ReadFull(r.rc, sealed); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		plain, err := r.aead.Open(sealed[:0], chunkNonce(r.index), sealed, nil)
+		if err != nil {
+			return 0, errChunkOpen
+		}
+		r.index++
+		r.remaining -= int64(len(plain))
+		r.out = plain
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *openingReader) Close() error {
+	return r.rc.Close()
+}
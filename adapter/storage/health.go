@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"context"
+	"taskflow/pkg/health"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// BucketCheck returns a health check that issues a HEAD request for the bucket
+func BucketCheck(s3Client *s3.S3, bucket string) health.CheckFunc {
+	return func(ctx context.Context) error {
+		_, err := s3Client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{
+			Bucket: aws.String(bucket),
+		})
+		return err
+	}
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"taskflow/internal/domain/shared"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ListObjects calls fn with every object in the bucket, a page at a time, so
+// buckets of any size can be walked in constant memory
+func (r *s3Storage) ListObjects(ctx context.Context, fn func(shared.ObjectInfo) error) error {
+	var fnErr error
+	err := r.s3Client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(r.bucket),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			info := shared.ObjectInfo{
+				Key:          aws.StringValue(object.Key),
+				Size:         aws.Int64Value(object.Size),
+				ETag:         aws.StringValue(object.ETag),
+				LastModified: aws.TimeValue(object.LastModified),
+			}
+			if fnErr = fn(info); fnErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"taskflow/internal/domain/shared"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+func (r *s3Storage) MinPartSize() int64 {
+	return s3manager.MinUploadPartSize
+}
+
+func (r *s3Storage) CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	result, err := r.s3Client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(result.UploadId), nil
+}
+
+func (r *s3Storage) UploadPart(ctx context.Context, key string, uploadID string, number int, content io.ReadSeeker) (string, error) {
+	result, err := r.s3Client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(r.bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(int64(number)),
+		Body:       content,
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(result.ETag), nil
+}
+
+func (r *s3Storage) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []shared.UploadedPart) error {
+	completed := make([]*s3.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, &s3.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int64(int64(part.Number)),
+		})
+	}
+
+	_, err := r.s3Client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(r.bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	return err
+}
+
+func (r *s3Storage) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	_, err := r.s3Client.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(r.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil && isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (r *s3Storage) Put(ctx context.Context, key string, content io.ReadSeeker, contentType string) error {
+	_, err := r.s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(key),
+		Body:        content,
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
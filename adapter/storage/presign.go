@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func (r *s3Storage) PresignPut(ctx context.Context, key string, contentType string, expires time.Duration) (*shared.PresignedUpload, error) {
+	req, _ := r.s3Client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	req.SetContext(ctx)
+
+	url, signedHeaders, err := req.PresignRequest(expires)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(signedHeaders))
+	for name := range signedHeaders {
+		if strings.EqualFold(name, "Host") {
+			continue
+		}
+		headers[name] = signedHeaders.Get(name)
+	}
+
+	return &shared.PresignedUpload{
+		Method:    http.MethodPut,
+		URL:       url,
+		Headers:   headers,
+		ExpiresAt: time.Now().Add(expires),
+	}, nil
+}
+
+// PresignPost builds a browser-style POST policy signed with SigV4. Unlike a
+// presigned PUT, the policy lets S3 itself reject bodies that are too large or
+// carry a different Content-Type.
+func (r *s3Storage) PresignPost(ctx context.Context, key string, contentType string, maxSize int64, expires time.Duration) (*shared.PresignedUpload, error) {
+	creds, err := r.s3Client.Config.Credentials.GetWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(expires)
+	amzDate := now.Format("20060102T150405Z")
+	shortDate := now.Format("20060102")
+	region := aws.StringValue(r.s3Client.Config.Region)
+	credential := strings.Join([]string{creds.AccessKeyID, shortDate, region, "s3", "aws4_request"}, "/")
+
+	conditions := []interface{}{
+		map[string]string{"bucket": r.bucket},
+		map[string]string{"key": key},
+		map[string]string{"Content-Type": contentType},
+		[]interface{}{"content-length-range", 1, maxSize},
+		map[string]string{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+		map[string]string{"x-amz-credential": credential},
+		map[string]string{"x-amz-date": amzDate},
+	}
+	if creds.SessionToken != "" {
+		conditions = append(conditions, map[string]string{"x-amz-security-token": creds.SessionToken})
+	}
+
+	policyJSON, err := json.Marshal(map[string]interface{}{
+		"expiration": expiresAt.Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	policy := base64.StdEncoding.EncodeToString(policyJSON)
+
+	signingKey := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), shortDate)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, policy))
+
+	fields := map[string]string{
+		"key":              key,
+		"Content-Type":     contentType,
+		"policy":           policy,
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": credential,
+		"x-amz-date":       amzDate,
+		"x-amz-signature":  signature,
+	}
+	if creds.SessionToken != "" {
+		fields["x-amz-security-token"] = creds.SessionToken
+	}
+
+	return &shared.PresignedUpload{
+		Method:    http.MethodPost,
+		URL:       strings.TrimRight(r.s3Client.Endpoint, "/") + "/" + r.bucket,
+		Fields:    fields,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (r *s3Storage) Stat(ctx context.Context, key string) (*shared.ObjectInfo, error) {
+	result, err := r.s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, shared.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &shared.ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(result.ContentLength),
+		ETag:         strings.Trim(aws.StringValue(result.ETag), `"`),
+		ContentType:  aws.StringValue(result.ContentType),
+		LastModified: aws.TimeValue(result.LastModified),
+	}, nil
+}
+
+func isNotFound(err error) bool {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case "NotFound", s3.ErrCodeNoSuchKey, s3.ErrCodeNoSuchUpload:
+			return true
+		}
+	}
+	return false
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
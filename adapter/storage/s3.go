@@ -2,15 +2,18 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"path/filepath"
 	"taskflow/internal/domain/shared"
 	"taskflow/pkg/config"
+	"taskflow/pkg/logging"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/google/uuid"
 )
 
@@ -21,29 +24,37 @@ func NewS3Client(cfg config.S3Config) *s3.S3 {
 		Endpoint:         aws.String(cfg.Endpoint),
 		S3ForcePathStyle: aws.Bool(true),
 	}))
+	addTracingHandlers(&sess.Handlers)
 
 	return s3.New(sess)
 }
 
 type s3Storage struct {
 	s3Client *s3.S3
+	uploader *s3manager.Uploader
 	bucket   string
 }
 
 func NewS3Storage(s3Client *s3.S3, bucket string) shared.Storage {
 	return &s3Storage{
 		s3Client: s3Client,
-		bucket:   bucket,
+		// The uploader streams non-seekable readers as multipart uploads,
+		// holding at most Concurrency parts in memory
+		uploader: s3manager.NewUploaderWithClient(s3Client, func(u *s3manager.Uploader) {
+			u.PartSize = s3manager.MinUploadPartSize
+			u.Concurrency = 2
+		}),
+		bucket: bucket,
 	}
 }
 
 func (r *s3Storage) Upload(ctx context.Context, filename string, content io.Reader, contentType string) (string, error) {
 	fileID := uuid.New().String() + filepath.Ext(filename)
 
-	_, err := r.s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+	_, err := r.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
 		Bucket:      aws.String(r.bucket),
 		Key:         aws.String(fileID),
-		Body:        aws.ReadSeekCloser(content),
+		Body:        content,
 		ContentType: aws.String(contentType),
 	})
 
@@ -51,6 +62,7 @@ func (r *s3Storage) Upload(ctx context.Context, filename string, content io.Read
 		return "", err
 	}
 
+	logging.FromContext(ctx).Debug("object uploaded", "bucket", r.bucket, "key", fileID)
 	return fileID, nil
 }
 
@@ -67,13 +79,30 @@ func (r *s3Storage) Download(ctx context.Context, fileID string) (io.ReadCloser,
 	return result.Body, nil
 }
 
+func (r *s3Storage) DownloadRange(ctx context.Context, fileID string, offset, length int64) (io.ReadCloser, error) {
+	result, err := r.s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(fileID),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Body, nil
+}
+
 func (r *s3Storage) Delete(ctx context.Context, fileID string) error {
 	_, err := r.s3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(r.bucket),
 		Key:    aws.String(fileID),
 	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	logging.FromContext(ctx).Debug("object deleted", "bucket", r.bucket, "key", fileID)
+	return nil
 }
 
 func (r *s3Storage) GetURL(ctx context.Context, fileID string) (string, error) {
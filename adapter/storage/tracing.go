@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// addTracingHandlers wraps every S3 API call made through handlers in a
+// client span named after the operation
+func addTracingHandlers(handlers *request.Handlers) {
+	tracer := otel.Tracer("taskflow/s3")
+	startSpan := func(r *request.Request) {
+		ctx, _ := tracer.Start(r.Context(), "S3."+r.Operation.Name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("rpc.system", "aws-api"),
+				attribute.String("rpc.service", "S3"),
+				attribute.String("rpc.method", r.Operation.Name),
+			),
+		)
+		r.SetContext(ctx)
+	}
+
+	handlers.Build.PushFrontNamed(request.NamedHandler{
+		Name: "taskflow.tracing.start",
+		Fn: func(r *request.Request) {
+			if !r.IsPresigned() {
+				startSpan(r)
+			}
+		},
+	})
+
+	handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "taskflow.tracing.end",
+		Fn:   endSpan,
+	})
+
+	// Presigning only builds and signs the request, so Complete never runs
+	// for it; its span covers the signing instead
+	handlers.Sign.PushFrontNamed(request.NamedHandler{
+		Name: "taskflow.tracing.presign.start",
+		Fn: func(r *request.Request) {
+			if r.IsPresigned() {
+				startSpan(r)
+			}
+		},
+	})
+	handlers.Sign.PushBackNamed(request.NamedHandler{
+		Name: "taskflow.tracing.presign.end",
+		Fn: func(r *request.Request) {
+			if r.IsPresigned() {
+				endSpan(r)
+			}
+		},
+	})
+}
+
+func endSpan(r *request.Request) {
+	span := trace.SpanFromContext(r.Context())
+	if r.HTTPResponse != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", r.HTTPResponse.StatusCode))
+	}
+	if r.Error != nil {
+		span.RecordError(r.Error)
+		span.SetStatus(codes.Error, r.Error.Error())
+	}
+	span.End()
+}
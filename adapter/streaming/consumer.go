@@ -0,0 +1,211 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	consumeBatchSize = 10
+	consumeBlock     = 5 * time.Second
+	consumeRetryWait = time.Second
+	// claimInterval is how often messages left pending are looked for
+	claimInterval = 30 * time.Second
+	// claimIdle is how long a message stays pending before it is retried, so
+	// it is also the longest a handler can take without being run twice
+	claimIdle = 5 * time.Minute
+	// maxDeliveries is how often a message is handled before it is given up
+	// on and dead-lettered
+	maxDeliveries = 5
+)
+
+type redisSubscriber struct {
+	client   *redis.Client
+	consumer string
+	tracer   trace.Tracer
+}
+
+// NewRedisSubscriber returns a subscriber reading streams through consumer
+// groups. consumer names this instance within its groups.
+func NewRedisSubscriber(client *redis.Client, consumer string) shared.Subscriber {
+	return &redisSubscriber{
+		client:   client,
+		consumer: consumer,
+		tracer:   otel.Tracer("taskflow/streaming"),
+	}
+}
+
+// DeadLetterStream names the stream messages on topic are moved to once
+// they have failed maxDeliveries times
+func DeadLetterStream(topic string) string {
+	return topic + ".dead"
+}
+
+// Subscribe waits for new messages and, every claimInterval, takes over the
+// ones left pending for claimIdle: those whose handler failed, on this
+// instance or another, and those read by instances that are gone. They are
+// handled again until they have been delivered maxDeliveries times, and then
+// moved to the topic's dead-letter stream.
+func (r *redisSubscriber) Subscribe(ctx context.Context, topic string, group string, handler shared.MessageHandler) error {
+	logger := logging.FromContext(ctx).With("topic", topic, "group", group)
+
+	err := r.client.XGroupCreateMkStream(ctx, topic, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	nextClaim := time.Now()
+	for {
+		if !time.Now().Before(nextClaim) {
+			if err := r.claimPending(ctx, topic, group, handler); err != nil && ctx.Err() == nil {
+				logger.Error("failed to retry pending messages", "error", err)
+			}
+			nextClaim = time.Now().Add(claimInterval)
+		}
+
+		streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: r.consumer,
+			Streams:  []string{topic, ">"},
+			Count:    consumeBatchSize,
+			Block:    consumeBlock,
+		}).Result()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil && !errors.Is(err, redis.Nil) {
+			logger.Error("failed to read stream", "error", err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(consumeRetryWait):
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				r.handle(ctx, topic, group, message, handler)
+			}
+		}
+	}
+}
+
+// claimPending claims the messages of a group pending for at least claimIdle
+// for this consumer and handles them again. XAUTOCLAIM would do this in one
+// command, but go-redis v8 can't read its Redis 7 reply, so they are listed
+// with XPENDING, which also gives their delivery counts, and claimed with
+// XCLAIM.
+func (r *redisSubscriber) claimPending(ctx context.Context, topic, group string, handler shared.MessageHandler) error {
+	for ctx.Err() == nil {
+		pending, err := r.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: topic,
+			Group:  group,
+			Idle:   claimIdle,
+			Start:  "-",
+			End:    "+",
+			Count:  consumeBatchSize,
+		}).Result()
+		if err != nil || len(pending) == 0 {
+			return err
+		}
+
+		deliveries := make(map[string]int64, len(pending))
+		ids := make([]string, 0, len(pending))
+		for _, entry := range pending {
+			deliveries[entry.ID] = entry.RetryCount
+			ids = append(ids, entry.ID)
+		}
+		// Claiming resets the idle time, so messages another consumer claimed
+		// in the meantime are left to it and claimed ones aren't listed again
+		messages, err := r.client.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   topic,
+			Group:    group,
+			Consumer: r.consumer,
+			MinIdle:  claimIdle,
+			Messages: ids,
+		}).Result()
+		if err != nil {
+			return err
+		}
+
+		for _, message := range messages {
+			switch {
+			case message.Values == nil:
+				// Trimmed from the stream while pending; nothing is left to handle
+				if err := r.client.XAck(ctx, topic, group, message.ID).Err(); err != nil {
+					return err
+				}
+			case deliveries[message.ID] >= maxDeliveries:
+				r.deadLetter(ctx, topic, group, message, deliveries[message.ID])
+			default:
+				r.handle(ctx, topic, group, message, handler)
+			}
+		}
+
+		if len(pending) < consumeBatchSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+// deadLetter moves a message that keeps failing to the topic's dead-letter
+// stream, noting where it came from, and acknowledges it
+func (r *redisSubscriber) deadLetter(ctx context.Context, topic, group string, message redis.XMessage, deliveries int64) {
+	logger := logging.FromContext(ctx).With("topic", topic, "group", group, "message_id", message.ID)
+
+	values := make(map[string]interface{}, len(message.Values)+2)
+	for key, value := range message.Values {
+		values[key] = value
+	}
+	values["group"] = group
+	values["message_id"] = message.ID
+	if err := r.client.XAdd(ctx, &redis.XAddArgs{Stream: DeadLetterStream(topic), Values: values}).Err(); err != nil {
+		logger.Error("failed to dead-letter message", "error", err)
+		return
+	}
+	if err := r.client.XAck(ctx, topic, group, message.ID).Err(); err != nil {
+		logger.Error("failed to acknowledge dead-lettered message", "error", err)
+		return
+	}
+	logger.Warn("message dead-lettered", "deliveries", deliveries, "stream", DeadLetterStream(topic))
+}
+
+func (r *redisSubscriber) handle(ctx context.Context, topic, group string, message redis.XMessage, handler shared.MessageHandler) {
+	ctx = ExtractTraceContext(ctx, message.Values)
+	ctx, span := r.tracer.Start(ctx, topic+" process", trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "redis"),
+			attribute.String("messaging.destination.name", topic),
+			attribute.String("messaging.consumer.group.name", group),
+			attribute.String("messaging.message.id", message.ID),
+		),
+	)
+	defer span.End()
+
+	ctx = logging.With(ctx, "topic", topic, "group", group, "message_id", message.ID)
+	logger := logging.FromContext(ctx)
+
+	data, _ := message.Values["data"].(string)
+	if err := handler(ctx, []byte(data)); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		logger.Error("failed to handle message", "error", err)
+		return
+	}
+
+	if err := r.client.XAck(ctx, topic, group, message.ID).Err(); err != nil {
+		logger.Error("failed to acknowledge message", "error", err)
+	}
+}
@@ -0,0 +1,110 @@
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"taskflow/pkg/health"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// PingCheck returns a health check that sends PING to Redis
+func PingCheck(client *redis.Client) health.CheckFunc {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
+
+// ConsumerLagCheck returns a health check that fails when any consumer group
+// on the given streams has more than maxLag messages not yet delivered to any
+// of its consumers. Delivered but unacknowledged messages are retried by the
+// consumers and don't count. Streams that do not exist yet or have no groups
+// are considered healthy.
+func ConsumerLagCheck(client *redis.Client, streams []string, maxLag int64) health.CheckFunc {
+	return func(ctx context.Context) error {
+		var lagging []string
+		for _, stream := range streams {
+			groups, err := consumerGroups(ctx, client, stream)
+			if err != nil {
+				if isMissingStream(err) {
+					continue
+				}
+				return err
+			}
+			for _, group := range groups {
+				lag, err := group.lag(ctx, client, stream, maxLag)
+				if err != nil {
+					return err
+				}
+				if lag > maxLag {
+					lagging = append(lagging, fmt.Sprintf("%s/%s=%d", stream, group.name, lag))
+				}
+			}
+		}
+		if len(lagging) > 0 {
+			return fmt.Errorf("consumer lag above %d: %s", maxLag, strings.Join(lagging, ", "))
+		}
+		return nil
+	}
+}
+
+// consumerGroup is what the lag check reads from XINFO GROUPS
+type consumerGroup struct {
+	name            string
+	lastDeliveredID string
+	// reportedLag is the lag Redis 7 reports, or -1 if it isn't known
+	reportedLag int64
+}
+
+// consumerGroups runs XINFO GROUPS itself, since go-redis v8 only reads the
+// reply of Redis versions before 7, which don't report lag
+func consumerGroups(ctx context.Context, client *redis.Client, stream string) ([]consumerGroup, error) {
+	reply, err := client.Do(ctx, "XINFO", "GROUPS", stream).Slice()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]consumerGroup, 0, len(reply))
+	for _, entry := range reply {
+		fields, ok := entry.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected XINFO GROUPS reply: %v", entry)
+		}
+		group := consumerGroup{reportedLag: -1}
+		for i := 0; i+1 < len(fields); i += 2 {
+			key, _ := fields[i].(string)
+			switch value := fields[i+1].(type) {
+			case string:
+				if key == "name" {
+					group.name = value
+				} else if key == "last-delivered-id" {
+					group.lastDeliveredID = value
+				}
+			case int64:
+				if key == "lag" {
+					group.reportedLag = value
+				}
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// lag returns the number of entries after the last one delivered to the
+// group. When Redis can't tell, as after entries were deleted or before Redis
+// 7, they are counted, up to one more than max.
+func (g consumerGroup) lag(ctx context.Context, client *redis.Client, stream string, max int64) (int64, error) {
+	if g.reportedLag >= 0 {
+		return g.reportedLag, nil
+	}
+	undelivered, err := client.XRangeN(ctx, stream, "("+g.lastDeliveredID, "+", max+1).Result()
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(undelivered)), nil
+}
+
+func isMissingStream(err error) bool {
+	return err == redis.Nil || strings.Contains(err.Error(), "no such key")
+}
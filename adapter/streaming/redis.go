@@ -4,23 +4,32 @@ import (
 	"context"
 	"encoding/json"
 	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
 
 	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func NewRedisClient(redisURL string) *redis.Client {
-	return redis.NewClient(&redis.Options{
+	client := redis.NewClient(&redis.Options{
 		Addr: redisURL,
 	})
+	client.AddHook(NewTracingHook())
+	return client
 }
 
 type redisMessaging struct {
 	client *redis.Client
+	tracer trace.Tracer
 }
 
 func NewRedisMessaging(client *redis.Client) shared.Messaging {
 	return &redisMessaging{
 		client: client,
+		tracer: otel.Tracer("taskflow/streaming"),
 	}
 }
 
@@ -30,12 +39,31 @@ func (r *redisMessaging) Publish(ctx context.Context, topic string, message inte
 		return err
 	}
 
-	return r.client.XAdd(ctx, &redis.XAddArgs{
+	ctx, span := r.tracer.Start(ctx, topic+" publish", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "redis"),
+			attribute.String("messaging.destination.name", topic),
+		),
+	)
+	defer span.End()
+
+	values := map[string]interface{}{
+		"data": string(messageJSON),
+	}
+	injectTraceContext(ctx, values)
+
+	err = r.client.XAdd(ctx, &redis.XAddArgs{
 		Stream: topic,
-		Values: map[string]interface{}{
-			"data": string(messageJSON),
-		},
+		Values: values,
 	}).Err()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	logging.FromContext(ctx).Debug("message published", "topic", topic)
+	return nil
 }
 
 func (r *redisMessaging) PublishWithKey(ctx context.Context, topic string, key string, message interface{}) error {
@@ -44,11 +72,31 @@ func (r *redisMessaging) PublishWithKey(ctx context.Context, topic string, key s
 		return err
 	}
 
-	return r.client.XAdd(ctx, &redis.XAddArgs{
+	ctx, span := r.tracer.Start(ctx, topic+" publish", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "redis"),
+			attribute.String("messaging.destination.name", topic),
+			attribute.String("messaging.message.id", key),
+		),
+	)
+	defer span.End()
+
+	values := map[string]interface{}{
+		"data": string(messageJSON),
+	}
+	injectTraceContext(ctx, values)
+
+	err = r.client.XAdd(ctx, &redis.XAddArgs{
 		Stream: topic,
 		ID:     key,
-		Values: map[string]interface{}{
-			"data": string(messageJSON),
-		},
+		Values: values,
 	}).Err()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	logging.FromContext(ctx).Debug("message published", "topic", topic)
+	return nil
 }
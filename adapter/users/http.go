@@ -0,0 +1,51 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"taskflow/internal/domain/todo"
+	"time"
+)
+
+type httpDirectory struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPDirectory returns a directory that asks a users service about
+// users with GET {baseURL}/users/{id}, passing the workspace in the
+// X-Tenant-ID header as the gateway does. 200 means the user exists and 404
+// that it doesn't. timeout bounds each lookup.
+func NewHTTPDirectory(baseURL string, timeout time.Duration) todo.UserDirectory {
+	return &httpDirectory{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (d *httpDirectory) Exists(ctx context.Context, workspaceID string, userID string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+"/users/"+url.PathEscape(userID), nil)
+	if err != nil {
+		return false, err
+	}
+	if workspaceID != "" {
+		req.Header.Set("X-Tenant-ID", workspaceID)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("users service returned %s", resp.Status)
+}
@@ -0,0 +1,18 @@
+package users
+
+import (
+	"context"
+	"taskflow/internal/domain/todo"
+)
+
+type openDirectory struct{}
+
+// NewOpenDirectory returns a directory that knows every user ID. It is for
+// development and deployments where the gateway is the only user store.
+func NewOpenDirectory() todo.UserDirectory {
+	return openDirectory{}
+}
+
+func (openDirectory) Exists(ctx context.Context, workspaceID string, userID string) (bool, error) {
+	return true, nil
+}
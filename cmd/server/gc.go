@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"taskflow/adapter/repository/mysql"
+	"taskflow/adapter/storage"
+	"taskflow/adapter/streaming"
+	"taskflow/internal/domain/file"
+	"taskflow/pkg/config"
+)
+
+// runGC runs one storage garbage collection and prints its report as JSON:
+//
+//	server gc [-dry-run] [-grace 48h] [-unattached-after 720h]
+func runGC(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be deleted without deleting anything")
+	grace := flags.Duration("grace", cfg.GC.GracePeriod, "ignore objects and files younger than this")
+	unattachedAfter := flags.Duration("unattached-after", cfg.GC.UnattachedAfter, "also delete files attached to no todo once this old; 0 keeps them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := repository.NewGormConnection(cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	uploadPolicy, err := file.NewUploadPolicy(cfg.Upload.MaxSize, cfg.Upload.AllowedExtensions)
+	if err != nil {
+		return err
+	}
+	fileStorage, err := newFileStorage(cfg, db, storage.NewS3Client(cfg.S3Config))
+	if err != nil {
+		return err
+	}
+	// Deleted files are announced on Redis before the command exits, so they
+	// leave the search index
+	messaging := streaming.NewRedisMessaging(streaming.NewRedisClient(cfg.RedisURL))
+	fileDeps, err := newFileDeps(cfg, db, fileStorage, messaging, uploadPolicy, newQuotas(cfg, db), newTodoDeps(db, messaging))
+	if err != nil {
+		return err
+	}
+	fileService := file.NewFileService(fileDeps)
+
+	report, err := fileService.CollectGarbage(context.Background(), file.GCOptions{
+		GracePeriod:     *grace,
+		UnattachedAfter: *unattachedAfter,
+		DryRun:          *dryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
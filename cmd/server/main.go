@@ -4,28 +4,64 @@ import (
 	"context"
 	"errors"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
+	// Recurring todos follow the rules of IANA time zones, which containers
+	// often don't install
+	_ "time/tzdata"
 
 	"taskflow/adapter/cache"
+	"taskflow/adapter/extract"
 	router "taskflow/adapter/http"
 	"taskflow/adapter/http/handlers"
+	"taskflow/adapter/kms"
 	"taskflow/adapter/repository/mysql"
+	"taskflow/adapter/scanner"
+	searchindex "taskflow/adapter/search"
 	"taskflow/adapter/storage"
 	"taskflow/adapter/streaming"
+	"taskflow/adapter/users"
 	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/search"
+	"taskflow/internal/domain/shared"
 	"taskflow/internal/domain/todo"
 	"taskflow/pkg/config"
+	"taskflow/pkg/health"
+	"taskflow/pkg/logging"
+	"taskflow/pkg/scheduler"
+	"taskflow/pkg/tracing"
 
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func main() {
 	cfg := config.Load()
 
+	slog.SetDefault(logging.New(logging.Config{
+		Level:      cfg.LogLevel,
+		Format:     cfg.LogFormat,
+		RedactKeys: slices.Concat(logging.DefaultRedactKeys, cfg.RedactKeys),
+	}))
+
+	if len(os.Args) > 1 && os.Args[1] == "gc" {
+		if err := runGC(cfg, os.Args[2:]); err != nil {
+			log.Fatal("Garbage collection failed:", err)
+		}
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatal("Failed to set up tracing:", err)
+	}
+
 	db, err := repository.NewGormConnection(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
@@ -35,24 +71,180 @@ func main() {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
-	todoRepo := repository.NewTodoRepository(db)
-
 	s3Client := storage.NewS3Client(cfg.S3Config)
-	fileStorage := storage.NewS3Storage(s3Client, cfg.S3Config.Bucket)
+	fileStorage, err := newFileStorage(cfg, db, s3Client)
+	if err != nil {
+		log.Fatal("Invalid encryption configuration:", err)
+	}
 	fileRepo := repository.NewFileRepository(db) // Assuming we have a file repository
 
 	redisClient := streaming.NewRedisClient(cfg.RedisURL)
 	messaging := streaming.NewRedisMessaging(redisClient)
 	cache := cache.NewRedisCache(redisClient)
 
-	todoService := todo.NewTodoService(todoRepo, messaging, cache)
-	fileService := file.NewFileService(fileRepo, fileStorage)
+	uploadPolicy, err := file.NewUploadPolicy(cfg.Upload.MaxSize, cfg.Upload.AllowedExtensions)
+	if err != nil {
+		log.Fatal("Invalid upload configuration:", err)
+	}
+	uploadPolicy.PresignExpiry = cfg.Upload.PresignExpiry
+	quotas := newQuotas(cfg, db)
+	todoDeps := newTodoDeps(db, messaging)
+	fileDeps, err := newFileDeps(cfg, db, fileStorage, messaging, uploadPolicy, quotas, todoDeps)
+	if err != nil {
+		log.Fatal("Invalid attachment configuration:", err)
+	}
+	fileService := file.NewFileService(fileDeps)
+	switch cfg.Users.Backend {
+	case "http":
+		todoDeps.Users = users.NewHTTPDirectory(cfg.Users.URL, cfg.Users.Timeout)
+	default:
+		log.Println("No users backend is configured; todos can be assigned to any user ID")
+		todoDeps.Users = users.NewOpenDirectory()
+	}
+	todoDeps.Cache = cache
+	todoDeps.Files = fileService
+	todoService := todo.NewTodoService(todoDeps)
+
+	var malwareScanner file.MalwareScanner
+	switch cfg.Scanner.Backend {
+	case "clamd":
+		malwareScanner = scanner.NewClamdScanner(cfg.Scanner.ClamdAddress, cfg.Scanner.Timeout)
+	default:
+		log.Println("Malware scanning is disabled; uploads are released unscanned")
+		malwareScanner = scanner.NewNoopScanner()
+	}
+	scanService := file.NewScanService(fileRepo, fileStorage, malwareScanner, messaging)
+	thumbnailService := file.NewThumbnailService(fileRepo, fileStorage, file.ThumbnailConfig{
+		Sizes:     cfg.Thumbnails.Sizes,
+		MaxPixels: cfg.Thumbnails.MaxPixels,
+	})
+
+	hostname, _ := os.Hostname()
+	searchService, searchGroup := newSearchService(cfg, db, fileService, todoDeps.Todos, todoDeps.Attachments, hostname)
+	if cfg.Search.Backend == "memory" {
+		if _, err := searchService.Rebuild(context.Background()); err != nil {
+			log.Fatal("Failed to build search index:", err)
+		}
+	}
+
+	resumableUploadService, err := file.NewResumableUploadService(
+		fileDeps,
+		repository.NewResumableUploadRepository(db),
+		file.ResumableUploadConfig{
+			Expiry:  cfg.Upload.ResumableExpiry,
+			LockTTL: cfg.Upload.ResumableLockTTL,
+		},
+	)
+	if err != nil {
+		log.Fatal("Invalid storage configuration:", err)
+	}
+
+	healthRegistry := health.NewRegistry(health.Config{
+		DefaultTimeout: cfg.Health.CheckTimeout,
+		CacheTTL:       cfg.Health.CacheTTL,
+	})
+	healthRegistry.Register("mysql", repository.PingCheck(db), health.CheckOptions{})
+	healthRegistry.Register("redis", streaming.PingCheck(redisClient), health.CheckOptions{})
+	healthRegistry.Register("s3", storage.BucketCheck(s3Client, cfg.S3Config.Bucket), health.CheckOptions{})
+	healthRegistry.Register("consumer_lag",
+		streaming.ConsumerLagCheck(redisClient, cfg.Health.ConsumerStreams, cfg.Health.MaxConsumerLag),
+		health.CheckOptions{Optional: cfg.Health.ConsumerLagOptional},
+	)
+	if cfg.Scanner.Backend == "clamd" {
+		// Uploads stay quarantined while clamd is down but the service keeps serving
+		healthRegistry.Register("clamd", scanner.PingCheck(malwareScanner), health.CheckOptions{Optional: true})
+	}
 
 	todoHandler := handlers.NewTodoHandler(todoService)
-	fileHandler := handlers.NewFileHandler(fileService)
+	archiveService := file.NewArchiveService(fileService, fileDeps.References, file.ArchiveLimits{
+		MaxFiles: cfg.Archive.MaxFiles,
+		MaxSize:  cfg.Archive.MaxSize,
+	})
+	fileHandler := handlers.NewFileHandler(fileService, thumbnailService, archiveService)
+	healthHandler := handlers.NewHealthHandler(healthRegistry)
+	tusHandler := handlers.NewTusHandler(resumableUploadService)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	tagHandler := handlers.NewTagHandler(todo.NewTagService(todoDeps.Tags))
+	projectHandler := handlers.NewProjectHandler(todo.NewProjectService(todoDeps.Projects))
+
+	// Background jobs stop when shutdown begins
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	scheduler.Every(jobsCtx, "pending-upload-cleanup", cfg.Upload.CleanupInterval, func(ctx context.Context) error {
+		_, err := fileService.CleanupPendingUploads(ctx, cfg.Upload.PendingTTL)
+		return err
+	})
+	subscriber := streaming.NewRedisSubscriber(redisClient, hostname)
+	go func() {
+		if err := subscriber.Subscribe(jobsCtx, file.TopicFileUploaded, "scanner", scanService.HandleFileUploaded); err != nil {
+			slog.Error("malware scan consumer stopped", "error", err)
+		}
+	}()
+	go func() {
+		if err := subscriber.Subscribe(jobsCtx, file.TopicFileScanned, "thumbnails", thumbnailService.HandleFileScanned); err != nil {
+			slog.Error("thumbnail consumer stopped", "error", err)
+		}
+	}()
+	searchTopics := map[string]shared.MessageHandler{
+		todo.TopicTodoCreated: searchService.HandleTodoChanged,
+		todo.TopicTodoUpdated: searchService.HandleTodoChanged,
+		todo.TopicTodoDeleted: searchService.HandleTodoDeleted,
+		file.TopicFileScanned: searchService.HandleFileScanned,
+		file.TopicFileDeleted: searchService.HandleFileDeleted,
+	}
+	for topic, handler := range searchTopics {
+		go func() {
+			if err := subscriber.Subscribe(jobsCtx, topic, searchGroup, handler); err != nil {
+				slog.Error("search consumer stopped", "error", err, "topic", topic)
+			}
+		}()
+	}
+	scheduler.Every(jobsCtx, "quarantine-rescan", cfg.Scanner.SweepInterval, func(ctx context.Context) error {
+		_, err := scanService.RescanQuarantined(ctx, cfg.Scanner.RescanAfter)
+		return err
+	})
+
+	scheduler.Every(jobsCtx, "storage-gc", cfg.GC.Interval, func(ctx context.Context) error {
+		_, err := fileService.CollectGarbage(ctx, file.GCOptions{
+			GracePeriod:     cfg.GC.GracePeriod,
+			UnattachedAfter: cfg.GC.UnattachedAfter,
+			DryRun:          cfg.GC.DryRun,
+		})
+		return err
+	})
+
+	if cfg.Encryption.KeyFile != "" {
+		scheduler.Every(jobsCtx, "data-key-rewrap", cfg.Encryption.RewrapInterval, func(ctx context.Context) error {
+			_, err := fileService.RewrapKeys(ctx)
+			return err
+		})
+	}
+
+	if cfg.Versions.MaxAge > 0 {
+		scheduler.Every(jobsCtx, "version-prune", cfg.Versions.PruneInterval, func(ctx context.Context) error {
+			_, err := fileService.PruneVersions(ctx)
+			return err
+		})
+	}
+
+	scheduler.Every(jobsCtx, "resumable-upload-cleanup", cfg.Upload.CleanupInterval, func(ctx context.Context) error {
+		_, err := resumableUploadService.CleanupExpired(ctx)
+		return err
+	})
+
+	scheduler.Every(jobsCtx, "recurrence", cfg.Recurrence.Interval, func(ctx context.Context) error {
+		_, err := todoService.MaterializeRecurrences(ctx)
+		return err
+	})
+
+	scheduler.Every(jobsCtx, "rank-rebalance", cfg.Ranks.RebalanceInterval, func(ctx context.Context) error {
+		_, err := todoService.RebalanceRanks(ctx)
+		return err
+	})
 
 	gin.SetMode(gin.ReleaseMode)
-	r := router.SetupRouter(todoHandler, fileHandler)
+	r := router.SetupRouter(todoHandler, fileHandler, healthHandler, tusHandler, searchHandler, tagHandler, projectHandler)
 
 	srv := &http.Server{
 		Addr:    ":" + cfg.Port,
@@ -70,6 +262,12 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
+	stopJobs()
+
+	// Fail readiness first and give load balancers time to notice before
+	// we stop accepting connections
+	healthRegistry.SetShuttingDown()
+	time.Sleep(cfg.Health.ShutdownDrainDelay)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -78,5 +276,89 @@ func main() {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	if err := shutdownTracing(ctx); err != nil {
+		log.Println("Failed to flush traces:", err)
+	}
+
 	log.Println("Server exited")
 }
+
+// newTodoDeps returns the repositories of the todo service, which the file
+// service also needs to delete the todos of a file
+func newTodoDeps(db *gorm.DB, messaging shared.Messaging) todo.Deps {
+	return todo.Deps{
+		Todos:        repository.NewTodoRepository(db),
+		Attachments:  repository.NewAttachmentRepository(db),
+		Messaging:    messaging,
+		Tags:         repository.NewTagRepository(db),
+		Checklists:   repository.NewChecklistRepository(db),
+		Dependencies: repository.NewDependencyRepository(db),
+		Recurrences:  repository.NewRecurrenceRepository(db),
+		Projects:     repository.NewProjectRepository(db),
+		Participants: repository.NewParticipantRepository(db),
+	}
+}
+
+// newFileDeps wires what the file services are built from, together with the todo attachments
+// that decide whether a file may be deleted
+func newFileDeps(cfg *config.Config, db *gorm.DB, fileStorage shared.Storage, messaging shared.Messaging, uploadPolicy file.UploadPolicy, quotas *file.Quotas, todoDeps todo.Deps) (file.Deps, error) {
+	var blobRepo file.BlobRepository
+	if cfg.Upload.Deduplicate {
+		blobRepo = repository.NewBlobRepository(db)
+	}
+	deletePolicy, err := todo.ParseFileDeletePolicy(cfg.Attachments.FileDeletePolicy)
+	if err != nil {
+		return file.Deps{}, err
+	}
+	versioning := file.NewVersioning(repository.NewVersionRepository(db), file.VersionPolicy{
+		MaxVersions: cfg.Versions.MaxCount,
+		MaxAge:      cfg.Versions.MaxAge,
+	})
+	return file.Deps{
+		Files:      repository.NewFileRepository(db),
+		Blobs:      blobRepo,
+		Storage:    fileStorage,
+		Messaging:  messaging,
+		Policy:     uploadPolicy,
+		References: todo.NewFileReferences(todoDeps, deletePolicy),
+		Quotas:     quotas,
+		Versions:   versioning,
+	}, nil
+}
+
+// newSearchService returns the search service and the consumer group it
+// reads events with. An in-process index is built by every instance, so each
+// instance reads the events in a group of its own.
+func newSearchService(cfg *config.Config, db *gorm.DB, files search.Files, todoRepo todo.Repository, attachmentRepo todo.AttachmentRepository, hostname string) (search.Service, string) {
+	index, group := searchindex.NewMemoryIndex(), "search-"+hostname
+	if cfg.Search.Backend == "mysql" {
+		index, group = repository.NewFullTextIndex(db), "search"
+	}
+	searchService := search.NewService(repository.NewDocumentRepository(db), index, extract.NewExtractor(), files,
+		todo.NewSearchTodos(todoRepo, attachmentRepo), search.Config{
+			MaxFileSize: cfg.Search.MaxFileSize,
+			MaxTextSize: cfg.Search.MaxTextSize,
+		})
+	return searchService, group
+}
+
+func newQuotas(cfg *config.Config, db *gorm.DB) *file.Quotas {
+	return file.NewQuotas(repository.NewUsageRepository(db), file.QuotaConfig{
+		User:      file.Quota{Bytes: cfg.Quota.UserBytes, Files: cfg.Quota.UserFiles},
+		Workspace: file.Quota{Bytes: cfg.Quota.WorkspaceBytes, Files: cfg.Quota.WorkspaceFiles},
+	})
+}
+
+// newFileStorage returns the S3 storage, encrypting objects when a master key
+// file is configured
+func newFileStorage(cfg *config.Config, db *gorm.DB, s3Client *s3.S3) (shared.Storage, error) {
+	fileStorage := storage.NewS3Storage(s3Client, cfg.S3Config.Bucket)
+	if cfg.Encryption.KeyFile == "" {
+		return fileStorage, nil
+	}
+	keys, err := kms.NewLocalKeyManager(cfg.Encryption.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewEncryptedStorage(fileStorage, repository.NewDataKeyRepository(db), keys), nil
+}
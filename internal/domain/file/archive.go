@@ -0,0 +1,210 @@
+package file
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+	"time"
+)
+
+// manifestName is the archive entry describing the other entries
+const manifestName = "manifest.json"
+
+// ArchiveRequest selects the files of an archive: files by ID, the files
+// attached to todos, or both. Files selected twice are archived once.
+type ArchiveRequest struct {
+	FileIDs []string `json:"fileIds" binding:"omitempty,dive,uuid"`
+	TodoIDs []string `json:"todoIds" binding:"omitempty,dive,uuid"`
+}
+
+// ArchiveLimits bounds what one archive may hold. Zero fields are
+// unlimited.
+type ArchiveLimits struct {
+	MaxFiles int
+	// MaxSize is the total size of the archived files before compression
+	MaxSize int64
+}
+
+// ArchiveEntry is a file in an archive under a name unique within it
+type ArchiveEntry struct {
+	Name        string    `json:"name"`
+	FileID      string    `json:"fileId"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum,omitempty"`
+	ModifiedAt  time.Time `json:"modifiedAt"`
+}
+
+// ArchiveSkip is a selected file left out of an archive and why
+type ArchiveSkip struct {
+	FileID   string `json:"fileId"`
+	Filename string `json:"filename"`
+	Reason   string `json:"reason"`
+}
+
+// Archive is a checked selection of files, ready to be written
+type Archive struct {
+	Entries []*ArchiveEntry `json:"files"`
+	Skipped []*ArchiveSkip  `json:"skipped"`
+	Size    int64           `json:"size"`
+}
+
+type archiveService struct {
+	files      FileService
+	references References
+	limits     ArchiveLimits
+}
+
+// NewArchiveService creates the archive service. When references is nil
+// archives can't select files by todo.
+func NewArchiveService(files FileService, references References, limits ArchiveLimits) ArchiveService {
+	return &archiveService{files: files, references: references, limits: limits}
+}
+
+func (s *archiveService) Prepare(ctx context.Context, req *ArchiveRequest) (*Archive, error) {
+	if len(req.FileIDs) == 0 && len(req.TodoIDs) == 0 {
+		return nil, shared.NewValidationError("select at least one file or todo")
+	}
+
+	fileIDs := req.FileIDs
+	if len(req.TodoIDs) > 0 {
+		if s.references == nil {
+			return nil, shared.NewDomainError(shared.ErrCodeInvalidInput, "Archives of todos are not supported", "")
+		}
+		attached, err := s.references.ReferencedFiles(ctx, req.TodoIDs)
+		if err != nil {
+			return nil, err
+		}
+		fileIDs = append(append([]string{}, fileIDs...), attached...)
+	}
+
+	archive := &Archive{Entries: []*ArchiveEntry{}, Skipped: []*ArchiveSkip{}}
+	names := newEntryNames()
+	selected := map[string]bool{}
+	for _, fileID := range fileIDs {
+		if selected[fileID] {
+			continue
+		}
+		selected[fileID] = true
+
+		file, err := s.files.GetFile(ctx, fileID)
+		if err != nil {
+			return nil, err
+		}
+		if err := archivable(file); err != nil {
+			archive.Skipped = append(archive.Skipped, &ArchiveSkip{FileID: fileID, Filename: file.Filename, Reason: err.Error()})
+			continue
+		}
+
+		archive.Entries = append(archive.Entries, &ArchiveEntry{
+			Name:        names.add(file.Filename, fileID),
+			FileID:      fileID,
+			ContentType: file.ContentType,
+			Size:        file.Size,
+			Checksum:    file.Checksum,
+			ModifiedAt:  file.UpdatedAt,
+		})
+		archive.Size += file.Size
+		if s.limits.MaxFiles > 0 && len(archive.Entries) > s.limits.MaxFiles {
+			return nil, shared.NewDomainError(shared.ErrCodeTooLarge, "Archive too large", fmt.Sprintf("at most %d files can be archived at once", s.limits.MaxFiles))
+		}
+		if s.limits.MaxSize > 0 && archive.Size > s.limits.MaxSize {
+			return nil, shared.NewDomainError(shared.ErrCodeTooLarge, "Archive too large", fmt.Sprintf("at most %d bytes can be archived at once", s.limits.MaxSize))
+		}
+	}
+	return archive, nil
+}
+
+func archivable(file *File) error {
+	if file.Status != StatusAvailable {
+		return errUploadIncomplete
+	}
+	return file.CheckDownloadable()
+}
+
+// Write streams the archive as a zip, fetching each file from storage as it
+// is reached, and ends it with the manifest. Content is checksum-verified
+// on the way; a failure or a cancelled ctx stops the archive unfinished, so
+// clients never receive it as complete.
+func (s *archiveService) Write(ctx context.Context, archive *Archive, w io.Writer) error {
+	logger := logging.FromContext(ctx)
+	zw := zip.NewWriter(w)
+
+	for _, entry := range archive.Entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.writeEntry(ctx, zw, entry); err != nil {
+			logger.Error("failed to write archive entry", "error", err, "file_id", entry.FileID)
+			return err
+		}
+	}
+
+	manifest, err := zw.CreateHeader(&zip.FileHeader{Name: manifestName, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(manifest)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(archive); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	logger.Info("archive written", "files", len(archive.Entries), "skipped", len(archive.Skipped), "size", archive.Size)
+	return nil
+}
+
+func (s *archiveService) writeEntry(ctx context.Context, zw *zip.Writer, entry *ArchiveEntry) error {
+	content, err := s.files.DownloadFile(ctx, entry.FileID)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	// Images are compressed already
+	method := zip.Deflate
+	if strings.HasPrefix(entry.ContentType, "image/") {
+		method = zip.Store
+	}
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: entry.Name, Method: method, Modified: entry.ModifiedAt})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, content)
+	return err
+}
+
+// entryNames hands out archive entry names that are safe to extract and
+// unique, also on case-insensitive file systems
+type entryNames map[string]bool
+
+func newEntryNames() entryNames {
+	return entryNames{manifestName: true}
+}
+
+func (n entryNames) add(filename, fileID string) string {
+	// Entries are flat: separators would let names escape the extraction
+	// directory
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(filename)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	if name == "" {
+		name = fileID
+	}
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; n[strings.ToLower(name)]; i++ {
+		name = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	n[strings.ToLower(name)] = true
+	return name
+}
@@ -0,0 +1,95 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log/slog"
+	"taskflow/internal/domain/shared"
+	"time"
+)
+
+// Blob is a stored object shared by every file with the same content. It is
+// only used in content-addressed (deduplicating) mode, where RefCount counts
+// the files pointing at StorageKey and the object is deleted when it drops to
+// zero.
+type Blob struct {
+	Checksum   string    `json:"checksum" db:"checksum" gorm:"primaryKey;size:64"`
+	StorageKey string    `json:"storageKey" db:"storage_key"`
+	Size       int64     `json:"size" db:"size"`
+	RefCount   int64     `json:"refCount" db:"ref_count"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt  time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+var errChecksumMismatch = shared.NewDomainError(shared.ErrCodeInternal, "File content failed integrity verification", "")
+
+// checksumReader streams stored content while hashing it. The final byte is
+// withheld until the SHA-256 has been checked, so a corrupted object never
+// reaches the client complete.
+type checksumReader struct {
+	rc        io.ReadCloser
+	hash      hash.Hash
+	remaining int64
+	expected  string
+	logger    *slog.Logger
+	fileID    string
+}
+
+func newChecksumReader(rc io.ReadCloser, size int64, expected string, logger *slog.Logger, fileID string) *checksumReader {
+	return &checksumReader{
+		rc:        rc,
+		hash:      sha256.New(),
+		remaining: size,
+		expected:  expected,
+		logger:    logger,
+		fileID:    fileID,
+	}
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if r.remaining > 1 {
+		if int64(len(p)) > r.remaining-1 {
+			p = p[:r.remaining-1]
+		}
+		n, err := r.rc.Read(p)
+		r.hash.Write(p[:n])
+		r.remaining -= int64(n)
+		if err == io.EOF {
+			return n, r.fail("content is shorter than recorded")
+		}
+		return n, err
+	}
+
+	n, err := r.rc.Read(p[:1])
+	if n == 0 {
+		if err == io.EOF {
+			return 0, r.fail("content is shorter than recorded")
+		}
+		return 0, err
+	}
+	r.hash.Write(p[:1])
+	r.remaining = 0
+	if hex.EncodeToString(r.hash.Sum(nil)) != r.expected {
+		return 0, r.fail("checksum mismatch")
+	}
+	return 1, nil
+}
+
+func (r *checksumReader) Close() error {
+	return r.rc.Close()
+}
+
+func (r *checksumReader) fail(reason string) error {
+	r.remaining = 0
+	r.logger.Error("stored file failed integrity verification", "file_id", r.fileID, "reason", reason)
+	return errChecksumMismatch
+}
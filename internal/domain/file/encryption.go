@@ -0,0 +1,97 @@
+package file
+
+import (
+	"context"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+)
+
+// keyIDOf returns the master key protecting a stored object, or "" if
+// storage doesn't encrypt
+func keyIDOf(ctx context.Context, storage Storage, storageKey string) (string, error) {
+	encrypted, ok := storage.(EncryptedStorage)
+	if !ok {
+		return "", nil
+	}
+	return encrypted.KeyID(ctx, storageKey)
+}
+
+// RewrapKeys moves the data keys of files, their thumbnails and their
+// earlier versions still wrapped with an older master key to the current one.
+// Only the wrapped keys change, so it is cheap enough to run until nothing is
+// left on a retired key.
+func (s *fileService) RewrapKeys(ctx context.Context) (int, error) {
+	encrypted, ok := s.storage.(EncryptedStorage)
+	if !ok {
+		return 0, shared.NewDomainError(shared.ErrCodeInvalidInput, "Storage is not encrypted", "")
+	}
+	logger := logging.FromContext(ctx)
+	current := encrypted.CurrentKeyID()
+
+	rewrapped := 0
+	afterID := ""
+	for {
+		files, err := s.fileRepo.ListWrappedAfter(ctx, current, afterID, gcBatchSize)
+		if err != nil {
+			return rewrapped, err
+		}
+		for _, file := range files {
+			if err := s.rewrap(ctx, encrypted, file); err != nil {
+				logger.Error("failed to rewrap file data key", "error", err, "file_id", file.ID, "key_id", file.KeyID)
+				continue
+			}
+			rewrapped++
+		}
+		if len(files) < gcBatchSize {
+			break
+		}
+		afterID = files[len(files)-1].ID.String()
+	}
+
+	if s.versions != nil {
+		afterID = ""
+		for {
+			versions, err := s.versions.repo.ListWrappedAfter(ctx, current, afterID, gcBatchSize)
+			if err != nil {
+				return rewrapped, err
+			}
+			for _, version := range versions {
+				keyID, err := encrypted.Rewrap(ctx, version.StorageKey)
+				if err == nil {
+					version.KeyID = keyID
+					err = s.versions.repo.Update(ctx, version)
+				}
+				if err != nil {
+					logger.Error("failed to rewrap file version data key", "error", err, "file_id", version.FileID, "version", version.Number)
+					continue
+				}
+				rewrapped++
+			}
+			if len(versions) < gcBatchSize {
+				break
+			}
+			afterID = versions[len(versions)-1].ID.String()
+		}
+	}
+
+	if rewrapped > 0 {
+		logger.Info("file data keys rewrapped", "count", rewrapped, "key_id", current)
+	}
+	return rewrapped, nil
+}
+
+func (s *fileService) rewrap(ctx context.Context, encrypted EncryptedStorage, file *File) error {
+	for _, thumbnail := range file.Thumbnails {
+		if _, err := encrypted.Rewrap(ctx, thumbnail.StorageKey); err != nil {
+			return err
+		}
+	}
+	keyID, err := encrypted.Rewrap(ctx, file.StorageKey)
+	if err != nil {
+		return err
+	}
+	// Content replaced meanwhile has its own data key
+	file.KeyID = keyID
+	_, err = s.fileRepo.UpdateContent(ctx, file, file.Version, file.StorageKey, "KeyID")
+	return err
+}
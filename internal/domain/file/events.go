@@ -0,0 +1,36 @@
+package file
+
+import (
+	"context"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+)
+
+// TopicFileUploaded is published with the File once its content is available
+const TopicFileUploaded = "file.uploaded"
+
+// TopicFileScanned is published with the File once it has been scanned for
+// malware, whatever the verdict
+const TopicFileScanned = "file.scanned"
+
+// TopicFileDeleted is published with the File once it has been deleted
+const TopicFileDeleted = "file.deleted"
+
+// publishUploaded announces a file whose content is now available, keeping
+// the trace but not the request deadline
+func publishUploaded(ctx context.Context, messaging shared.Messaging, file *File) {
+	publish(ctx, messaging, TopicFileUploaded, file)
+}
+
+// publish announces a change to a file in the background
+func publish(ctx context.Context, messaging shared.Messaging, topic string, file *File) {
+	go publishNow(context.WithoutCancel(ctx), messaging, topic, file)
+}
+
+// publishNow announces a change to a file before returning, for callers such
+// as garbage collection that may exit right after
+func publishNow(ctx context.Context, messaging shared.Messaging, topic string, file *File) {
+	if err := messaging.Publish(ctx, topic, file); err != nil {
+		logging.FromContext(ctx).Error("failed to publish file event", "error", err, "topic", topic, "file_id", file.ID)
+	}
+}
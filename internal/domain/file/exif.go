@@ -0,0 +1,238 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+)
+
+// JPEG markers and EXIF tags used when reading and rewriting image metadata
+const (
+	jpegMarkerAPP1     = 0xE1
+	jpegMarkerSOS      = 0xDA
+	exifTagOrientation = 0x0112
+	exifTagGPSInfo     = 0x8825
+)
+
+// maxJPEGHeader bounds how much of a JPEG is buffered while looking for its
+// EXIF segment, which always comes before the image data
+const maxJPEGHeader = 256 * 1024
+
+var exifPrefix = []byte("Exif\x00\x00")
+
+// readJPEGHeader reads the metadata segments at the start of a JPEG, up to the
+// start of the image data, calling visit with each segment's payload. visit
+// may modify the payload in place. The returned bytes are everything read
+// from r, including any modifications, so the caller can re-emit them ahead
+// of the rest of r. Content that isn't a well-formed JPEG is returned as read.
+func readJPEGHeader(r io.Reader, visit func(marker byte, payload []byte)) ([]byte, error) {
+	var header []byte
+	read := func(n int) ([]byte, error) {
+		buf := make([]byte, n)
+		n, err := io.ReadFull(r, buf)
+		header = append(header, buf[:n]...)
+		return buf[:n], err
+	}
+
+	soi, err := read(2)
+	if err != nil || soi[0] != 0xFF || soi[1] != 0xD8 {
+		return header, ignoreEOF(err)
+	}
+
+	for {
+		marker, err := read(2)
+		if err != nil || marker[0] != 0xFF || marker[1] == jpegMarkerSOS || isStandaloneMarker(marker[1]) {
+			return header, ignoreEOF(err)
+		}
+
+		length, err := read(2)
+		if err != nil {
+			return header, ignoreEOF(err)
+		}
+		size := int(binary.BigEndian.Uint16(length)) - 2
+		if size < 0 || len(header)+size > maxJPEGHeader {
+			return header, nil
+		}
+
+		start := len(header)
+		if _, err := read(size); err != nil {
+			return header, ignoreEOF(err)
+		}
+		visit(marker[1], header[start:])
+	}
+}
+
+func isStandaloneMarker(marker byte) bool {
+	return marker == 0x01 || (marker >= 0xD0 && marker <= 0xD9)
+}
+
+func ignoreEOF(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	return err
+}
+
+// stripJPEGLocation returns a reader over a JPEG with the GPS data in its EXIF
+// segment zeroed. The content keeps its size and everything else is untouched.
+func stripJPEGLocation(content io.Reader) (io.Reader, error) {
+	header, err := readJPEGHeader(content, func(marker byte, payload []byte) {
+		if marker == jpegMarkerAPP1 && bytes.HasPrefix(payload, exifPrefix) {
+			newTIFF(payload[len(exifPrefix):]).stripGPS()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return io.MultiReader(bytes.NewReader(header), content), nil
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG, or 1 if it
+// has none
+func jpegOrientation(data []byte) int {
+	orientation := 1
+	readJPEGHeader(bytes.NewReader(data), func(marker byte, payload []byte) { // nolint: errcheck
+		if marker == jpegMarkerAPP1 && bytes.HasPrefix(payload, exifPrefix) {
+			if o := newTIFF(payload[len(exifPrefix):]).orientation(); o >= 1 && o <= 8 {
+				orientation = o
+			}
+		}
+	})
+	return orientation
+}
+
+// tiff gives bounds-checked access to the TIFF structure inside an EXIF
+// segment
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// tiffEntry is the position of a 12-byte IFD entry
+type tiffEntry int
+
+func newTIFF(data []byte) *tiff {
+	if len(data) < 8 {
+		return &tiff{}
+	}
+	switch string(data[:2]) {
+	case "II":
+		return &tiff{data: data, order: binary.LittleEndian}
+	case "MM":
+		return &tiff{data: data, order: binary.BigEndian}
+	}
+	return &tiff{}
+}
+
+// entries returns the entries of the IFD at offset
+func (t *tiff) entries(offset uint32) []tiffEntry {
+	if t.order == nil || int64(offset)+2 > int64(len(t.data)) {
+		return nil
+	}
+	count := int(t.order.Uint16(t.data[offset:]))
+	var entries []tiffEntry
+	for i := 0; i < count; i++ {
+		pos := int(offset) + 2 + i*12
+		if pos+12 > len(t.data) {
+			break
+		}
+		entries = append(entries, tiffEntry(pos))
+	}
+	return entries
+}
+
+func (t *tiff) firstIFD() uint32 {
+	if t.order == nil {
+		return 0
+	}
+	return t.order.Uint32(t.data[4:8])
+}
+
+func (t *tiff) tag(e tiffEntry) uint16 {
+	return t.order.Uint16(t.data[e:])
+}
+
+func (t *tiff) orientation() int {
+	for _, e := range t.entries(t.firstIFD()) {
+		if t.tag(e) == exifTagOrientation {
+			return int(t.order.Uint16(t.data[e+8:]))
+		}
+	}
+	return 0
+}
+
+// stripGPS zeroes every value in the GPS IFD and empties it, so readers see
+// no location data
+func (t *tiff) stripGPS() {
+	for _, e := range t.entries(t.firstIFD()) {
+		if t.tag(e) != exifTagGPSInfo {
+			continue
+		}
+		offset := t.order.Uint32(t.data[e+8:])
+		if int64(offset) >= int64(len(t.data)) {
+			continue
+		}
+		entries := t.entries(offset)
+		for _, gps := range entries {
+			t.zeroValue(gps)
+		}
+		end := int(offset) + 2 + len(entries)*12 + 4
+		clear(t.data[offset:min(end, len(t.data))])
+	}
+}
+
+// zeroValue clears an entry's value, whether stored inline or at an offset
+func (t *tiff) zeroValue(e tiffEntry) {
+	typeSizes := map[uint16]int64{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+	size := typeSizes[t.order.Uint16(t.data[e+2:])] * int64(t.order.Uint32(t.data[e+4:]))
+	if size <= 4 {
+		clear(t.data[e+8 : e+12])
+		return
+	}
+	offset := int64(t.order.Uint32(t.data[e+8:]))
+	if offset+size <= int64(len(t.data)) {
+		clear(t.data[offset : offset+size])
+	}
+}
+
+// applyOrientation transforms img so it displays upright for the given EXIF
+// orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90° counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,193 @@
+package file
+
+import (
+	"context"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+	"time"
+)
+
+// gcBatchSize is how many files are read per query while collecting
+const gcBatchSize = 500
+
+// GCOptions controls a garbage collection run
+type GCOptions struct {
+	// GracePeriod protects objects and files younger than it, which may
+	// belong to uploads still in progress
+	GracePeriod time.Duration
+	// UnattachedAfter also collects files attached to nothing once they are
+	// this old. Zero keeps unattached files.
+	UnattachedAfter time.Duration
+	// DryRun reports what would be deleted without deleting anything
+	DryRun bool
+}
+
+// GCReport lists what a garbage collection run found. Files are listed by ID
+// and objects by storage key.
+type GCReport struct {
+	DryRun bool `json:"dryRun"`
+	// OrphanedObjects are stored objects no file refers to
+	OrphanedObjects []string `json:"orphanedObjects"`
+	// MissingContent are files whose stored object no longer exists
+	MissingContent []string `json:"missingContent"`
+	// Unattached are files older than UnattachedAfter attached to nothing
+	Unattached []string `json:"unattached"`
+	Deleted    int      `json:"deleted"`
+	Failed     int      `json:"failed"`
+}
+
+// CollectGarbage reconciles storage with the files table. It walks every file
+// and every stored object, reports objects without files, files without
+// objects and, optionally, files nothing is attached to, and deletes them
+// unless opts.DryRun is set. Files are deleted like DeleteFile would, so the
+// attachment policy and shared content are respected, except that their
+// file.deleted events are published before it returns, since the gc command
+// exits right after.
+func (s *fileService) CollectGarbage(ctx context.Context, opts GCOptions) (*GCReport, error) {
+	listable, ok := s.storage.(ListableStorage)
+	if !ok {
+		return nil, shared.NewDomainError(shared.ErrCodeInvalidInput, "Garbage collection is not supported by this storage", "")
+	}
+	logger := logging.FromContext(ctx)
+	now := time.Now()
+	cutoff := now.Add(-opts.GracePeriod)
+
+	// Every key a file, thumbnail, version or blob refers to, and the settled files
+	// whose content should exist
+	referenced := map[string]bool{}
+	settled := map[string][]string{}
+	var unattachedCandidates []string
+
+	afterID := ""
+	for {
+		files, err := s.fileRepo.ListAfter(ctx, afterID, gcBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		var old []string
+		for _, file := range files {
+			referenced[file.StorageKey] = true
+			for _, thumbnail := range file.Thumbnails {
+				referenced[thumbnail.StorageKey] = true
+			}
+			if file.Status != StatusAvailable || !file.CreatedAt.Before(cutoff) {
+				continue
+			}
+			settled[file.StorageKey] = append(settled[file.StorageKey], file.ID.String())
+			if opts.UnattachedAfter > 0 && file.CreatedAt.Before(now.Add(-opts.UnattachedAfter)) {
+				old = append(old, file.ID.String())
+			}
+		}
+
+		if len(old) > 0 && s.references != nil {
+			attached, err := s.references.AttachedFiles(ctx, old)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range old {
+				if !attached[id] {
+					unattachedCandidates = append(unattachedCandidates, id)
+				}
+			}
+		}
+
+		if len(files) < gcBatchSize {
+			break
+		}
+		afterID = files[len(files)-1].ID.String()
+	}
+
+	if s.blobRepo != nil {
+		afterChecksum := ""
+		for {
+			blobs, err := s.blobRepo.ListAfter(ctx, afterChecksum, gcBatchSize)
+			if err != nil {
+				return nil, err
+			}
+			for _, blob := range blobs {
+				referenced[blob.StorageKey] = true
+			}
+			if len(blobs) < gcBatchSize {
+				break
+			}
+			afterChecksum = blobs[len(blobs)-1].Checksum
+		}
+	}
+
+	if s.versions != nil {
+		afterID := ""
+		for {
+			versions, err := s.versions.repo.ListAfter(ctx, afterID, gcBatchSize)
+			if err != nil {
+				return nil, err
+			}
+			for _, version := range versions {
+				referenced[version.StorageKey] = true
+			}
+			if len(versions) < gcBatchSize {
+				break
+			}
+			afterID = versions[len(versions)-1].ID.String()
+		}
+	}
+
+	report := &GCReport{DryRun: opts.DryRun}
+	stored := map[string]bool{}
+	err := listable.ListObjects(ctx, func(object ObjectInfo) error {
+		stored[object.Key] = true
+		if !referenced[object.Key] && object.LastModified.Before(cutoff) {
+			report.OrphanedObjects = append(report.OrphanedObjects, object.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	missing := map[string]bool{}
+	for key, ids := range settled {
+		if !stored[key] {
+			for _, id := range ids {
+				missing[id] = true
+				report.MissingContent = append(report.MissingContent, id)
+			}
+		}
+	}
+	for _, id := range unattachedCandidates {
+		if !missing[id] {
+			report.Unattached = append(report.Unattached, id)
+		}
+	}
+
+	logger.Info("garbage collection scanned storage",
+		"orphaned_objects", len(report.OrphanedObjects),
+		"missing_content", len(report.MissingContent),
+		"unattached", len(report.Unattached),
+		"dry_run", opts.DryRun,
+	)
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, key := range report.OrphanedObjects {
+		s.collect(ctx, report, s.storage.Delete(ctx, key), "storage_key", key)
+	}
+	for _, id := range append(report.MissingContent, report.Unattached...) {
+		file, err := s.deleteFile(ctx, id)
+		if err == nil {
+			publishNow(ctx, s.messaging, TopicFileDeleted, file)
+		}
+		s.collect(ctx, report, err, "file_id", id)
+	}
+	return report, nil
+}
+
+// collect counts the outcome of one garbage collection deletion
+func (s *fileService) collect(ctx context.Context, report *GCReport, err error, key, value string) {
+	if err != nil {
+		logging.FromContext(ctx).Error("garbage collection failed to delete", "error", err, key, value)
+		report.Failed++
+		return
+	}
+	report.Deleted++
+}
@@ -1,28 +1,94 @@
 package file
 
 import (
+	"taskflow/internal/domain/shared"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// File statuses
+const (
+	// StatusPending files have a record but their content hasn't been
+	// confirmed in storage yet
+	StatusPending = "pending"
+	// StatusAvailable files have content in storage and can be used
+	StatusAvailable = "available"
+)
+
+// Scan statuses
+const (
+	// ScanQuarantined files haven't been scanned for malware yet and can't be
+	// downloaded or linked
+	ScanQuarantined = "quarantined"
+	// ScanClean files were scanned and found clean
+	ScanClean = "clean"
+	// ScanInfected files were found to contain malware and are never served
+	ScanInfected = "infected"
+)
+
 // File represents a file entity in the domain
 type File struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	Filename    string    `json:"filename" db:"filename"`
-	ContentType string    `json:"contentType" db:"content_type"`
-	Size        int64     `json:"size" db:"size"`
-	StorageKey  string    `json:"storageKey" db:"storage_key"`
-	URL         string    `json:"url,omitempty" db:"url"`
-	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+	ID            uuid.UUID   `json:"id" db:"id"`
+	Filename      string      `json:"filename" db:"filename"`
+	ContentType   string      `json:"contentType" db:"content_type"`
+	Size          int64       `json:"size" db:"size"`
+	StorageKey    string      `json:"storageKey" db:"storage_key"`
+	URL           string      `json:"url,omitempty" db:"url"`
+	ETag          string      `json:"etag,omitempty" db:"etag"`
+	Checksum      string      `json:"checksum,omitempty" db:"checksum" gorm:"size:64;index"`
+	Thumbnails    []Thumbnail `json:"thumbnails,omitempty" db:"thumbnails" gorm:"serializer:json;type:text"`
+	Status        string      `json:"status" db:"status" gorm:"size:20;default:available;index"`
+	ScanStatus    string      `json:"scanStatus" db:"scan_status" gorm:"size:20;default:quarantined;index"`
+	ScanSignature string      `json:"scanSignature,omitempty" db:"scan_signature"`
+	ScannedAt     *time.Time  `json:"scannedAt,omitempty" db:"scanned_at"`
+	OwnerID       string      `json:"ownerId,omitempty" db:"owner_id" gorm:"size:64;index"`
+	WorkspaceID   string      `json:"workspaceId,omitempty" db:"workspace_id" gorm:"size:64;index"`
+	// KeyID is the master key wrapping the data key of the file's content,
+	// empty if storage doesn't encrypt
+	KeyID string `json:"keyId,omitempty" db:"key_id" gorm:"size:64;index"`
+	// Version numbers the current content; earlier contents are kept as
+	// FileVersions. VersionCreatedAt is when the current content was
+	// uploaded, if not together with the file.
+	Version          int        `json:"version" db:"version" gorm:"default:1"`
+	VersionCreatedAt *time.Time `json:"versionCreatedAt,omitempty" db:"version_created_at"`
+	CreatedAt        time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// EntityTag returns the validator used for conditional requests: the
+// storage ETag, or the content checksum if storage didn't report one
+func (f *File) EntityTag() string {
+	if f.ETag != "" {
+		return f.ETag
+	}
+	return f.Checksum
+}
+
+// CheckDownloadable reports whether the file's content may be served or
+// linked, which requires it to have been scanned clean
+func (f *File) CheckDownloadable() error {
+	return checkScanStatus(f.ScanStatus)
+}
+
+func checkScanStatus(status string) error {
+	switch status {
+	case ScanClean:
+		return nil
+	case ScanInfected:
+		return errFileInfected
+	default:
+		return errFileQuarantined
+	}
 }
 
-// CreateFileRequest represents the request to create a file
+// CreateFileRequest represents the request to create a file.
+// Size is the size declared by the client, if known; it is only used to
+// reject oversized uploads early and the stored size is always counted.
 type CreateFileRequest struct {
 	Filename    string `json:"filename" binding:"required"`
-	ContentType string `json:"contentType" binding:"required"`
-	Size        int64  `json:"size" binding:"required,min=1"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
 }
 
 // UpdateFileRequest represents the request to update a file
@@ -42,8 +108,25 @@ type FileMetadata struct {
 	UpdatedAt   time.Time `json:"updatedAt"`
 }
 
-// UploadResponse represents the response after file upload
+// CreateUploadURLRequest represents the request for a direct-to-storage upload
+type CreateUploadURLRequest struct {
+	Filename string `json:"filename" binding:"required"`
+	Size     int64  `json:"size" binding:"required,min=1"`
+	// Method is "PUT" (default) for a presigned PUT URL or "POST" for a
+	// POST policy that also enforces size and content type at the storage
+	Method string `json:"method,omitempty"`
+}
+
+// UploadURLResponse represents the response with a presigned upload
+type UploadURLResponse struct {
+	FileID string                  `json:"fileId"`
+	Upload *shared.PresignedUpload `json:"upload"`
+}
+
+// UploadResponse represents the response after file upload. New files are
+// quarantined until scanned, so no download URL is given out.
 type UploadResponse struct {
-	FileID string `json:"fileId"`
-	URL    string `json:"url,omitempty"`
+	FileID     string `json:"fileId"`
+	Checksum   string `json:"checksum,omitempty"`
+	ScanStatus string `json:"scanStatus"`
 }
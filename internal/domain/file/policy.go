@@ -0,0 +1,149 @@
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"time"
+)
+
+// sniffLen is the number of leading bytes inspected to detect the content type
+const sniffLen = 512
+
+// knownTypes maps supported extensions to the canonical content type stored
+// for them and the sniffed types accepted as proof the content matches
+var knownTypes = map[string]struct {
+	contentType string
+	sniffed     []string
+}{
+	".jpg":  {"image/jpeg", []string{"image/jpeg"}},
+	".jpeg": {"image/jpeg", []string{"image/jpeg"}},
+	".png":  {"image/png", []string{"image/png"}},
+	".gif":  {"image/gif", []string{"image/gif"}},
+	".txt":  {"text/plain", []string{"text/plain"}},
+	".pdf":  {"application/pdf", []string{"application/pdf"}},
+	".doc":  {"application/msword", []string{"application/msword"}},
+	".docx": {"application/vnd.openxmlformats-officedocument.wordprocessingml.document", []string{"application/zip"}},
+}
+
+// defaultPresignExpiry is how long presigned upload URLs stay valid
+const defaultPresignExpiry = 15 * time.Minute
+
+// UploadPolicy limits what can be uploaded
+type UploadPolicy struct {
+	MaxSize           int64
+	AllowedExtensions map[string]bool
+	PresignExpiry     time.Duration
+}
+
+// NewUploadPolicy builds an upload policy, rejecting extensions whose content
+// cannot be verified
+func NewUploadPolicy(maxSize int64, extensions []string) (UploadPolicy, error) {
+	if maxSize <= 0 {
+		return UploadPolicy{}, fmt.Errorf("max upload size must be positive")
+	}
+
+	allowed := make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		ext = normalizeExt(ext)
+		if _, ok := knownTypes[ext]; !ok {
+			return UploadPolicy{}, fmt.Errorf("unsupported upload extension: %s", ext)
+		}
+		allowed[ext] = true
+	}
+
+	return UploadPolicy{
+		MaxSize:           maxSize,
+		AllowedExtensions: allowed,
+		PresignExpiry:     defaultPresignExpiry,
+	}, nil
+}
+
+// CheckExtension validates the filename extension and returns it normalized
+func (p UploadPolicy) CheckExtension(filename string) (string, error) {
+	ext := normalizeExt(filepath.Ext(filename))
+	if !p.AllowedExtensions[ext] {
+		return "", shared.NewDomainError(shared.ErrCodeUnsupported, "File type not allowed", ext)
+	}
+	return ext, nil
+}
+
+// ContentTypeFor returns the canonical content type for a normalized extension
+func (p UploadPolicy) ContentTypeFor(ext string) string {
+	return knownTypes[ext].contentType
+}
+
+// SniffContent reads the start of content to detect its real type and checks
+// it against the extension. It returns the canonical content type and a reader
+// that yields the complete content, including the sniffed bytes.
+func (p UploadPolicy) SniffContent(ext string, content io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, err
+	}
+	head = head[:n]
+	if n == 0 {
+		return "", nil, shared.NewValidationError("file is empty")
+	}
+
+	known := knownTypes[ext]
+	detected := detectContentType(head)
+	for _, accepted := range known.sniffed {
+		if detected == accepted {
+			return known.contentType, io.MultiReader(bytes.NewReader(head), content), nil
+		}
+	}
+
+	return "", nil, shared.NewDomainError(shared.ErrCodeUnsupported,
+		"File content does not match its extension",
+		fmt.Sprintf("extension %s, detected %s", ext, detected))
+}
+
+// detectContentType sniffs a media type without parameters, adding the OLE2
+// signature used by legacy Office documents which net/http doesn't know
+func detectContentType(head []byte) string {
+	if bytes.HasPrefix(head, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}) {
+		return "application/msword"
+	}
+	detected := http.DetectContentType(head)
+	if i := strings.IndexByte(detected, ';'); i >= 0 {
+		detected = detected[:i]
+	}
+	return detected
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// limitedReader counts bytes read and fails once more than max bytes arrive
+type limitedReader struct {
+	r        io.Reader
+	max      int64
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, errFileTooLarge
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		l.exceeded = true
+		return n, errFileTooLarge
+	}
+	return n, err
+}
+
+var errFileTooLarge = shared.NewDomainError(shared.ErrCodeTooLarge, "File too large", "")
@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"taskflow/internal/domain/shared"
+	"time"
 )
 
 // FileService defines the file service interface
@@ -11,19 +12,184 @@ type FileService interface {
 	UploadFile(ctx context.Context, req *CreateFileRequest, content io.Reader) (*UploadResponse, error)
 	GetFile(ctx context.Context, fileID string) (*File, error)
 	DownloadFile(ctx context.Context, fileID string) (io.ReadCloser, error)
+	// OpenFile and OpenFileRange read the content of a file already loaded
+	// with GetFile, so what is served matches the metadata sent with it even
+	// if the content is replaced meanwhile
+	OpenFile(ctx context.Context, file *File) (io.ReadCloser, error)
+	OpenFileRange(ctx context.Context, file *File, offset, length int64) (io.ReadCloser, error)
 	DeleteFile(ctx context.Context, fileID string) error
 	ListFiles(ctx context.Context, limit, offset int) ([]*File, error)
 	UpdateFile(ctx context.Context, fileID string, req *UpdateFileRequest) (*File, error)
+	CreateUploadURL(ctx context.Context, req *CreateUploadURLRequest) (*UploadURLResponse, error)
+	CompleteUpload(ctx context.Context, fileID string) (*File, error)
+	CleanupPendingUploads(ctx context.Context, olderThan time.Duration) (int, error)
+	// CheckLinkable reports whether a file may be attached to other records:
+	// it must exist, belong to the caller, be available and have been scanned
+	// clean
+	CheckLinkable(ctx context.Context, fileID string) error
+	CollectGarbage(ctx context.Context, opts GCOptions) (*GCReport, error)
+	// GetUsage returns the storage used by the caller and their workspace
+	GetUsage(ctx context.Context) (*UsageReport, error)
+	RewrapKeys(ctx context.Context) (int, error)
+	// ReplaceContent uploads new content for a file, keeping the current
+	// content as an earlier version
+	ReplaceContent(ctx context.Context, fileID string, req *CreateFileRequest, content io.Reader) (*File, error)
+	ListVersions(ctx context.Context, fileID string) ([]*FileVersion, error)
+	DownloadVersion(ctx context.Context, fileID string, number int) (*FileVersion, io.ReadCloser, error)
+	RestoreVersion(ctx context.Context, fileID string, number int) (*File, error)
+	// PruneVersions drops earlier versions the retention policy no longer keeps
+	PruneVersions(ctx context.Context) (int, error)
 }
 
 // Repository defines the file repository interface
 type Repository interface {
 	Create(ctx context.Context, file *File) error
 	GetByID(ctx context.Context, id string) (*File, error)
-	Update(ctx context.Context, file *File) error
+	// Update writes the named fields of a file
+	Update(ctx context.Context, file *File, fields ...string) error
+	// UpdateContent writes the named fields of a file only while its
+	// content is still version at storageKey, so a writer that read the file
+	// before its content was replaced can't undo the replacement. It reports
+	// whether the file was written.
+	UpdateContent(ctx context.Context, file *File, version int, storageKey string, fields ...string) (bool, error)
+	// UpdateStatus writes the named fields of a file only while its status
+	// is still from, and reports whether it did
+	UpdateStatus(ctx context.Context, file *File, from string, fields ...string) (bool, error)
 	Delete(ctx context.Context, id string) error
 	List(ctx context.Context, limit, offset int) ([]*File, error)
+	ListPendingBefore(ctx context.Context, before time.Time, limit int) ([]*File, error)
+	ListQuarantinedBefore(ctx context.Context, before time.Time, limit int) ([]*File, error)
+	// ListAfter returns up to limit files with IDs after afterID, in ID order
+	ListAfter(ctx context.Context, afterID string, limit int) ([]*File, error)
+	// ListWrappedAfter returns up to limit encrypted files with IDs after
+	// afterID, in ID order, whose data key is wrapped with a master key other
+	// than keyID
+	ListWrappedAfter(ctx context.Context, keyID, afterID string, limit int) ([]*File, error)
+}
+
+// VersionRepository keeps the earlier versions of files
+type VersionRepository interface {
+	Create(ctx context.Context, version *FileVersion) error
+	// Get returns version number of a file, or shared.ErrNotFound
+	Get(ctx context.Context, fileID string, number int) (*FileVersion, error)
+	Update(ctx context.Context, version *FileVersion) error
+	Delete(ctx context.Context, id string) error
+	// ListByFile returns the earlier versions of a file, newest first
+	ListByFile(ctx context.Context, fileID string) ([]*FileVersion, error)
+	// ListBefore returns up to limit versions uploaded before the given time,
+	// oldest first
+	ListBefore(ctx context.Context, before time.Time, limit int) ([]*FileVersion, error)
+	// ListAfter returns up to limit versions with IDs after afterID, in ID order
+	ListAfter(ctx context.Context, afterID string, limit int) ([]*FileVersion, error)
+	// ListWrappedAfter returns up to limit encrypted versions with IDs after
+	// afterID, in ID order, whose data key is wrapped with a master key other
+	// than keyID
+	ListWrappedAfter(ctx context.Context, keyID, afterID string, limit int) ([]*FileVersion, error)
+}
+
+// ThumbnailService generates and serves image thumbnails
+type ThumbnailService interface {
+	HandleFileScanned(ctx context.Context, data []byte) error
+	Generate(ctx context.Context, fileID string) error
+	GetThumbnail(ctx context.Context, fileID string, size int) (*Thumbnail, io.ReadCloser, error)
+}
+
+// References is implemented by domains that hold references to files. It
+// is called before a file is deleted and may refuse the deletion or drop its
+// references, depending on its policy.
+type References interface {
+	ReleaseFile(ctx context.Context, fileID string) error
+	// AttachedFiles reports which of fileIDs are referenced
+	AttachedFiles(ctx context.Context, fileIDs []string) (map[string]bool, error)
+	// ReferencedFiles returns the files the given records refer to
+	ReferencedFiles(ctx context.Context, recordIDs []string) ([]string, error)
+}
+
+// ArchiveService bundles files into zip archives
+type ArchiveService interface {
+	// Prepare resolves and checks a selection before anything is written,
+	// so problems are reported as errors rather than as a broken archive
+	Prepare(ctx context.Context, req *ArchiveRequest) (*Archive, error)
+	Write(ctx context.Context, archive *Archive, w io.Writer) error
+}
+
+// ScanService scans uploaded files for malware and releases them from
+// quarantine
+type ScanService interface {
+	HandleFileUploaded(ctx context.Context, data []byte) error
+	Scan(ctx context.Context, fileID string) error
+	// RescanQuarantined scans files still quarantined olderThan after upload,
+	// catching uploads whose event was lost
+	RescanQuarantined(ctx context.Context, olderThan time.Duration) (int, error)
+}
+
+// BlobRepository keeps the reference counts of content-addressed blobs
+type BlobRepository interface {
+	// Acquire adds a reference to the blob with the given checksum, creating
+	// it from blob if it doesn't exist yet, and returns the stored blob
+	Acquire(ctx context.Context, blob *Blob) (*Blob, error)
+	// Release drops a reference and returns how many remain, deleting the blob
+	// record at zero. It returns shared.ErrNotFound if no such blob exists.
+	Release(ctx context.Context, checksum, storageKey string) (int64, error)
+	// ListAfter returns up to limit blobs with checksums after afterChecksum,
+	// in checksum order
+	ListAfter(ctx context.Context, afterChecksum string, limit int) ([]*Blob, error)
+}
+
+// UsageRepository keeps the storage counters of users and workspaces
+type UsageRepository interface {
+	// Get returns the usage of an owner, which is zero if nothing was
+	// ever charged to it
+	Get(ctx context.Context, scope, ownerID string) (*Usage, error)
+	// Charge atomically adds bytes and files to the usage unless that would
+	// pass a non-zero limit, reporting whether it was charged
+	Charge(ctx context.Context, scope, ownerID string, bytes, files int64, limit Quota) (bool, error)
+	// Credit subtracts bytes and files from the usage, never going below zero
+	Credit(ctx context.Context, scope, ownerID string, bytes, files int64) error
+}
+
+// ResumableUploadService defines the resumable (tus) upload service interface
+type ResumableUploadService interface {
+	Create(ctx context.Context, req *CreateResumableUploadRequest) (*ResumableUpload, error)
+	Get(ctx context.Context, id string) (*ResumableUpload, error)
+	WriteChunk(ctx context.Context, id string, offset int64, content io.Reader) (*ResumableUpload, error)
+	Terminate(ctx context.Context, id string) error
+	CleanupExpired(ctx context.Context) (int, error)
+	MaxSize() int64
+}
+
+// ResumableUploadRepository persists resumable upload state so any instance
+// can continue an upload
+type ResumableUploadRepository interface {
+	Create(ctx context.Context, upload *ResumableUpload) error
+	GetByID(ctx context.Context, id string) (*ResumableUpload, error)
+	Update(ctx context.Context, upload *ResumableUpload) error
+	Delete(ctx context.Context, id string) error
+	ListExpired(ctx context.Context, before time.Time, limit int) ([]*ResumableUpload, error)
+	// Lock takes an exclusive write lease until the given time, reporting
+	// false if another writer holds an unexpired lease
+	Lock(ctx context.Context, id string, until time.Time) (bool, error)
+	ExtendLock(ctx context.Context, id string, until time.Time) error
+	Unlock(ctx context.Context, id string) error
 }
 
 // Storage defines the file storage interface (uses shared storage port)
 type Storage = shared.Storage
+
+// ListableStorage defines storage whose objects can be enumerated
+type ListableStorage = shared.ListableStorage
+
+// ObjectInfo describes a stored object
+type ObjectInfo = shared.ObjectInfo
+
+// EncryptedStorage defines storage that encrypts objects with wrapped data keys
+type EncryptedStorage = shared.EncryptedStorage
+
+// DirectUploadStorage defines storage that supports presigned uploads
+type DirectUploadStorage = shared.DirectUploadStorage
+
+// MultipartStorage defines storage that assembles objects from parts
+type MultipartStorage = shared.MultipartStorage
+
+// MalwareScanner defines the malware scanner interface (uses shared scanner port)
+type MalwareScanner = shared.MalwareScanner
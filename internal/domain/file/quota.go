@@ -0,0 +1,183 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/identity"
+	"taskflow/pkg/logging"
+	"time"
+)
+
+// Usage scopes
+const (
+	ScopeUser      = "user"
+	ScopeWorkspace = "workspace"
+)
+
+// Usage counts the bytes and files stored by a user or a workspace. Files
+// are counted at their full size even when deduplication shares their
+// content, so usage never reveals what other owners have stored.
+type Usage struct {
+	Scope     string    `json:"-" db:"scope" gorm:"primaryKey;size:20"`
+	OwnerID   string    `json:"-" db:"owner_id" gorm:"primaryKey;size:64"`
+	Bytes     int64     `json:"bytes" db:"bytes"`
+	Files     int64     `json:"files" db:"files"`
+	UpdatedAt time.Time `json:"-" db:"updated_at"`
+}
+
+// Quota limits the usage of one owner. Zero fields are unlimited.
+type Quota struct {
+	Bytes int64 `json:"bytes,omitempty"`
+	Files int64 `json:"files,omitempty"`
+}
+
+// QuotaConfig holds the quota every user and every workspace gets
+type QuotaConfig struct {
+	User      Quota
+	Workspace Quota
+}
+
+// ScopeUsage is the usage of one owner together with its quota
+type ScopeUsage struct {
+	ID string `json:"id"`
+	Usage
+	Quota Quota `json:"quota"`
+}
+
+// UsageReport is the storage used by the caller and their workspace
+type UsageReport struct {
+	User      *ScopeUsage `json:"user,omitempty"`
+	Workspace *ScopeUsage `json:"workspace,omitempty"`
+}
+
+// Quotas charges stored files to the user and the workspace that own them
+// and refuses uploads that would take either past its quota. Callers
+// without an identity are not counted. A nil *Quotas counts nothing.
+type Quotas struct {
+	repo   UsageRepository
+	config QuotaConfig
+}
+
+func NewQuotas(repo UsageRepository, config QuotaConfig) *Quotas {
+	return &Quotas{repo: repo, config: config}
+}
+
+// quotaScope is one counter a file is charged to
+type quotaScope struct {
+	scope   string
+	ownerID string
+	limit   Quota
+}
+
+func (q *Quotas) scopes(userID, workspaceID string) []quotaScope {
+	var scopes []quotaScope
+	if userID != "" {
+		scopes = append(scopes, quotaScope{ScopeUser, userID, q.config.User})
+	}
+	if workspaceID != "" {
+		scopes = append(scopes, quotaScope{ScopeWorkspace, workspaceID, q.config.Workspace})
+	}
+	return scopes
+}
+
+// Allowance returns how many more bytes the owners may store together with
+// the given number of new files, or math.MaxInt64 if that is unlimited. It
+// fails with QUOTA_EXCEEDED when the files don't fit, so uploads are refused
+// before any content is read.
+func (q *Quotas) Allowance(ctx context.Context, userID, workspaceID string, files int64) (int64, error) {
+	allowance := int64(math.MaxInt64)
+	if q == nil {
+		return allowance, nil
+	}
+	for _, scope := range q.scopes(userID, workspaceID) {
+		if scope.limit == (Quota{}) {
+			continue
+		}
+		usage, err := q.repo.Get(ctx, scope.scope, scope.ownerID)
+		if err != nil {
+			return 0, err
+		}
+		if scope.limit.Files > 0 && usage.Files+files > scope.limit.Files {
+			return 0, quotaExceeded(scope)
+		}
+		if scope.limit.Bytes > 0 {
+			remaining := scope.limit.Bytes - usage.Bytes
+			if remaining <= 0 {
+				return 0, quotaExceeded(scope)
+			}
+			allowance = min(allowance, remaining)
+		}
+	}
+	return allowance, nil
+}
+
+// Charge adds bytes and files to the owners' usage, failing with
+// QUOTA_EXCEEDED and charging nothing if that would pass a quota
+func (q *Quotas) Charge(ctx context.Context, userID, workspaceID string, bytes, files int64) error {
+	if q == nil || (bytes == 0 && files == 0) {
+		return nil
+	}
+	var charged []quotaScope
+	for _, scope := range q.scopes(userID, workspaceID) {
+		ok, err := q.repo.Charge(ctx, scope.scope, scope.ownerID, bytes, files, scope.limit)
+		if err == nil && !ok {
+			err = quotaExceeded(scope)
+		}
+		if err != nil {
+			for _, done := range charged {
+				q.credit(ctx, done, bytes, files)
+			}
+			return err
+		}
+		charged = append(charged, scope)
+	}
+	return nil
+}
+
+// Credit gives back bytes and files to the owners' usage. Failures are
+// logged: the file is gone either way.
+func (q *Quotas) Credit(ctx context.Context, userID, workspaceID string, bytes, files int64) {
+	if q == nil || (bytes == 0 && files == 0) {
+		return
+	}
+	for _, scope := range q.scopes(userID, workspaceID) {
+		q.credit(ctx, scope, bytes, files)
+	}
+}
+
+func (q *Quotas) credit(ctx context.Context, scope quotaScope, bytes, files int64) {
+	if err := q.repo.Credit(ctx, scope.scope, scope.ownerID, bytes, files); err != nil {
+		logging.FromContext(ctx).Error("failed to credit storage usage", "error", err,
+			"scope", scope.scope, "owner_id", scope.ownerID, "bytes", bytes, "files", files)
+	}
+}
+
+// Report returns the usage and quotas of the caller in ctx
+func (q *Quotas) Report(ctx context.Context) (*UsageReport, error) {
+	report := &UsageReport{}
+	if q == nil {
+		return report, nil
+	}
+	caller := identity.FromContext(ctx)
+	for _, scope := range q.scopes(caller.UserID, caller.WorkspaceID) {
+		usage, err := q.repo.Get(ctx, scope.scope, scope.ownerID)
+		if err != nil {
+			return nil, err
+		}
+		scopeUsage := &ScopeUsage{ID: scope.ownerID, Usage: *usage, Quota: scope.limit}
+		if scope.scope == ScopeUser {
+			report.User = scopeUsage
+		} else {
+			report.Workspace = scopeUsage
+		}
+	}
+	return report, nil
+}
+
+var errQuotaExceeded = shared.NewDomainError(shared.ErrCodeQuotaExceeded, "Storage quota exceeded", "")
+
+func quotaExceeded(scope quotaScope) error {
+	return shared.NewDomainError(shared.ErrCodeQuotaExceeded, "Storage quota exceeded", fmt.Sprintf("the %s quota is used up", scope.scope))
+}
@@ -0,0 +1,419 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/identity"
+	"taskflow/pkg/logging"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ResumableUpload tracks an upload that is received in several requests. Its
+// content is assembled in storage as a multipart upload: full parts are
+// uploaded as they arrive and the remainder smaller than a part is kept as a
+// separate "tail" object until more data arrives.
+type ResumableUpload struct {
+	ID          uuid.UUID             `json:"id" db:"id"`
+	Filename    string                `json:"filename" db:"filename"`
+	ContentType string                `json:"contentType" db:"content_type"`
+	Length      int64                 `json:"length" db:"length"`
+	Offset      int64                 `json:"offset" db:"upload_offset" gorm:"column:upload_offset"`
+	Metadata    string                `json:"metadata,omitempty" db:"metadata" gorm:"size:2048"`
+	StorageKey  string                `json:"-" db:"storage_key"`
+	MultipartID string                `json:"-" db:"multipart_id"`
+	Parts       []shared.UploadedPart `json:"-" db:"parts" gorm:"serializer:json;type:text"`
+	TailSize    int64                 `json:"-" db:"tail_size"`
+	FileID      *string               `json:"fileId,omitempty" db:"file_id"`
+	OwnerID     string                `json:"-" db:"owner_id" gorm:"size:64"`
+	WorkspaceID string                `json:"-" db:"workspace_id" gorm:"size:64"`
+	LockedUntil *time.Time            `json:"-" db:"locked_until"`
+	ExpiresAt   time.Time             `json:"expiresAt" db:"expires_at" gorm:"index"`
+	CreatedAt   time.Time             `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time             `json:"updatedAt" db:"updated_at"`
+}
+
+// Completed reports whether all bytes have been received and the file created
+func (u *ResumableUpload) Completed() bool {
+	return u.FileID != nil
+}
+
+func (u *ResumableUpload) tailKey() string {
+	return u.StorageKey + ".tail"
+}
+
+func (u *ResumableUpload) partsSize() int64 {
+	var total int64
+	for _, part := range u.Parts {
+		total += part.Size
+	}
+	return total
+}
+
+// CreateResumableUploadRequest represents the request to start a resumable upload
+type CreateResumableUploadRequest struct {
+	Filename string
+	Length   int64
+	Metadata string
+}
+
+// ResumableUploadConfig controls resumable uploads
+type ResumableUploadConfig struct {
+	// Expiry is how long an upload may sit idle before it is discarded
+	Expiry time.Duration
+	// LockTTL bounds how long a crashed writer can block an upload
+	LockTTL time.Duration
+}
+
+type resumableUploadService struct {
+	files      *fileService
+	fileRepo   Repository
+	uploadRepo ResumableUploadRepository
+	storage    MultipartStorage
+	messaging  shared.Messaging
+	policy     UploadPolicy
+	quotas     *Quotas
+	config     ResumableUploadConfig
+}
+
+// NewResumableUploadService creates the resumable upload service from the
+// file service's dependencies, whose storage must support multipart uploads.
+// The declared length of an upload is charged to quotas when it is created.
+func NewResumableUploadService(deps Deps, uploadRepo ResumableUploadRepository, config ResumableUploadConfig) (ResumableUploadService, error) {
+	storage, ok := deps.Storage.(MultipartStorage)
+	if !ok {
+		return nil, fmt.Errorf("file storage does not support multipart uploads")
+	}
+	return &resumableUploadService{
+		files:      newFileService(deps),
+		fileRepo:   deps.Files,
+		uploadRepo: uploadRepo,
+		storage:    storage,
+		messaging:  deps.Messaging,
+		policy:     deps.Policy,
+		quotas:     deps.Quotas,
+		config:     config,
+	}, nil
+}
+
+func (s *resumableUploadService) MaxSize() int64 {
+	return s.policy.MaxSize
+}
+
+func (s *resumableUploadService) Create(ctx context.Context, req *CreateResumableUploadRequest) (*ResumableUpload, error) {
+	ext, err := s.policy.CheckExtension(req.Filename)
+	if err != nil {
+		return nil, err
+	}
+	if req.Length <= 0 {
+		return nil, shared.NewValidationError("upload length must be positive")
+	}
+	if req.Length > s.policy.MaxSize {
+		return nil, errFileTooLarge
+	}
+
+	// The whole length is charged up front, so quota can't run out halfway
+	caller := identity.FromContext(ctx)
+	if err := s.quotas.Charge(ctx, caller.UserID, caller.WorkspaceID, req.Length, 1); err != nil {
+		return nil, err
+	}
+
+	contentType := s.policy.ContentTypeFor(ext)
+	storageKey := uuid.New().String() + ext
+	multipartID, err := s.storage.CreateMultipartUpload(ctx, storageKey, contentType)
+	if err != nil {
+		s.quotas.Credit(ctx, caller.UserID, caller.WorkspaceID, req.Length, 1)
+		return nil, err
+	}
+
+	now := time.Now()
+	upload := &ResumableUpload{
+		ID:          uuid.New(),
+		Filename:    req.Filename,
+		ContentType: contentType,
+		Length:      req.Length,
+		Metadata:    req.Metadata,
+		StorageKey:  storageKey,
+		MultipartID: multipartID,
+		OwnerID:     caller.UserID,
+		WorkspaceID: caller.WorkspaceID,
+		ExpiresAt:   now.Add(s.config.Expiry),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.uploadRepo.Create(ctx, upload); err != nil {
+		s.abort(ctx, upload)
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("resumable upload created", "upload_id", upload.ID, "length", upload.Length)
+	return upload, nil
+}
+
+func (s *resumableUploadService) Get(ctx context.Context, id string) (*ResumableUpload, error) {
+	upload, err := s.uploadRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !upload.Completed() && time.Now().After(upload.ExpiresAt) {
+		return nil, shared.NewDomainError(shared.ErrCodeGone, "Upload has expired", "")
+	}
+	return upload, nil
+}
+
+// WriteChunk appends content at offset. Data is persisted as it arrives, so a
+// chunk interrupted by a dropped connection still advances the offset by the
+// bytes received.
+func (s *resumableUploadService) WriteChunk(ctx context.Context, id string, offset int64, content io.Reader) (*ResumableUpload, error) {
+	upload, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Offset != offset || upload.Completed() {
+		return nil, shared.NewDomainError(shared.ErrCodeConflict, "Upload offset mismatch",
+			fmt.Sprintf("expected offset %d", upload.Offset))
+	}
+
+	acquired, err := s.uploadRepo.Lock(ctx, id, time.Now().Add(s.config.LockTTL))
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, shared.NewDomainError(shared.ErrCodeLocked, "Upload is being written by another request", "")
+	}
+	defer func() {
+		if err := s.uploadRepo.Unlock(context.WithoutCancel(ctx), id); err != nil {
+			logging.FromContext(ctx).Error("failed to unlock resumable upload", "error", err, "upload_id", id)
+		}
+	}()
+
+	// Re-read under the lock so we continue from the latest persisted state
+	if upload, err = s.uploadRepo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	if upload.Offset != offset {
+		return nil, shared.NewDomainError(shared.ErrCodeConflict, "Upload offset mismatch",
+			fmt.Sprintf("expected offset %d", upload.Offset))
+	}
+
+	if offset == 0 {
+		ext, err := s.policy.CheckExtension(upload.Filename)
+		if err != nil {
+			return nil, err
+		}
+		if _, content, err = s.policy.SniffContent(ext, content); err != nil {
+			return nil, err
+		}
+	}
+
+	// Start from the bytes left over from the previous chunk
+	buffer := &bytes.Buffer{}
+	if upload.TailSize > 0 {
+		tail, err := s.storage.Download(ctx, upload.tailKey())
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(buffer, tail)
+		tail.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	partSize := s.storage.MinPartSize()
+	limited := &limitedReader{r: content, max: upload.Length - upload.Offset}
+	chunk := make([]byte, 32*1024)
+	var readErr error
+	for readErr == nil {
+		var n int
+		n, readErr = limited.Read(chunk)
+		buffer.Write(chunk[:n])
+
+		if limited.exceeded {
+			return nil, shared.NewValidationError("chunk exceeds the declared upload length")
+		}
+
+		for int64(buffer.Len()) >= partSize && upload.partsSize()+int64(buffer.Len()) < upload.Length {
+			if err := s.uploadPart(ctx, upload, buffer.Next(int(partSize))); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !errors.Is(readErr, io.EOF) {
+		logging.FromContext(ctx).Warn("resumable upload chunk interrupted", "error", readErr, "upload_id", id)
+		// The client is likely gone, but the bytes received so far are still
+		// saved so the upload can resume from them
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	upload.Offset = upload.partsSize() + int64(buffer.Len())
+	upload.ExpiresAt = time.Now().Add(s.config.Expiry)
+
+	if upload.Offset == upload.Length {
+		if err := s.finish(ctx, upload, buffer.Bytes()); err != nil {
+			return nil, err
+		}
+		return upload, nil
+	}
+
+	if buffer.Len() > 0 {
+		if err := s.storage.Put(ctx, upload.tailKey(), bytes.NewReader(buffer.Bytes()), "application/octet-stream"); err != nil {
+			return nil, err
+		}
+	} else if err := s.storage.Delete(ctx, upload.tailKey()); err != nil {
+		return nil, err
+	}
+	upload.TailSize = int64(buffer.Len())
+	if err := s.uploadRepo.Update(ctx, upload); err != nil {
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// uploadPart sends one full part to storage and records it
+func (s *resumableUploadService) uploadPart(ctx context.Context, upload *ResumableUpload, data []byte) error {
+	number := len(upload.Parts) + 1
+	etag, err := s.storage.UploadPart(ctx, upload.StorageKey, upload.MultipartID, number, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	upload.Parts = append(upload.Parts, shared.UploadedPart{Number: number, ETag: etag, Size: int64(len(data))})
+	upload.Offset = upload.partsSize()
+	upload.TailSize = 0
+	if err := s.uploadRepo.Update(ctx, upload); err != nil {
+		return err
+	}
+	return s.uploadRepo.ExtendLock(ctx, upload.ID.String(), time.Now().Add(s.config.LockTTL))
+}
+
+// finish uploads the final part, assembles the object and creates the file
+// record. The assembled object is processed like uploads through the API,
+// which leaves a copy of it for the file.
+func (s *resumableUploadService) finish(ctx context.Context, upload *ResumableUpload, last []byte) error {
+	logger := logging.FromContext(ctx)
+	if len(last) > 0 {
+		number := len(upload.Parts) + 1
+		etag, err := s.storage.UploadPart(ctx, upload.StorageKey, upload.MultipartID, number, bytes.NewReader(last))
+		if err != nil {
+			return err
+		}
+		upload.Parts = append(upload.Parts, shared.UploadedPart{Number: number, ETag: etag, Size: int64(len(last))})
+	}
+
+	if err := s.storage.CompleteMultipartUpload(ctx, upload.StorageKey, upload.MultipartID, upload.Parts); err != nil {
+		return err
+	}
+	if upload.TailSize > 0 {
+		if err := s.storage.Delete(ctx, upload.tailKey()); err != nil {
+			logger.Error("failed to delete upload tail", "error", err, "upload_id", upload.ID)
+		}
+	}
+
+	stored, err := s.files.ingestObject(ctx, upload.Filename, upload.StorageKey)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.Delete(ctx, upload.StorageKey); err != nil {
+		logger.Error("failed to delete assembled upload", "error", err, "upload_id", upload.ID)
+	}
+
+	file := &File{
+		ID:          uuid.New(),
+		Filename:    upload.Filename,
+		ContentType: stored.contentType,
+		Size:        stored.size,
+		StorageKey:  stored.storageKey,
+		Checksum:    stored.checksum,
+		Status:      StatusAvailable,
+		ScanStatus:  ScanQuarantined,
+		OwnerID:     upload.OwnerID,
+		WorkspaceID: upload.WorkspaceID,
+		KeyID:       stored.keyID,
+		Version:     1,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := s.fileRepo.Create(ctx, file); err != nil {
+		if err := s.files.releaseContent(ctx, stored.checksum, stored.storageKey); err != nil {
+			logger.Error("failed to clean up stored upload", "error", err, "storage_key", stored.storageKey)
+		}
+		return err
+	}
+
+	fileID := file.ID.String()
+	upload.FileID = &fileID
+	upload.TailSize = 0
+	if err := s.uploadRepo.Update(ctx, upload); err != nil {
+		return err
+	}
+
+	logger.Info("resumable upload completed", "upload_id", upload.ID, "file_id", fileID)
+	publishUploaded(ctx, s.messaging, file)
+	return nil
+}
+
+func (s *resumableUploadService) Terminate(ctx context.Context, id string) error {
+	upload, err := s.uploadRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if upload.Completed() {
+		return shared.NewDomainError(shared.ErrCodeConflict, "Upload is already complete", "")
+	}
+
+	s.abort(ctx, upload)
+	return s.uploadRepo.Delete(ctx, id)
+}
+
+// CleanupExpired discards idle uploads whose expiry has passed
+func (s *resumableUploadService) CleanupExpired(ctx context.Context) (int, error) {
+	const batchSize = 100
+
+	removed := 0
+	for {
+		uploads, err := s.uploadRepo.ListExpired(ctx, time.Now(), batchSize)
+		if err != nil {
+			return removed, err
+		}
+
+		for _, upload := range uploads {
+			if !upload.Completed() {
+				s.abort(ctx, upload)
+			}
+			if err := s.uploadRepo.Delete(ctx, upload.ID.String()); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+
+		if len(uploads) < batchSize {
+			break
+		}
+	}
+
+	if removed > 0 {
+		logging.FromContext(ctx).Info("expired resumable uploads removed", "count", removed)
+	}
+	return removed, nil
+}
+
+// abort releases the storage and quota held by an unfinished upload
+func (s *resumableUploadService) abort(ctx context.Context, upload *ResumableUpload) {
+	logger := logging.FromContext(ctx)
+	s.quotas.Credit(ctx, upload.OwnerID, upload.WorkspaceID, upload.Length, 1)
+	if err := s.storage.AbortMultipartUpload(ctx, upload.StorageKey, upload.MultipartID); err != nil {
+		logger.Error("failed to abort multipart upload", "error", err, "upload_id", upload.ID)
+	}
+	if upload.TailSize > 0 {
+		if err := s.storage.Delete(ctx, upload.tailKey()); err != nil {
+			logger.Error("failed to delete upload tail", "error", err, "upload_id", upload.ID)
+		}
+	}
+}
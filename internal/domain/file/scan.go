@@ -0,0 +1,123 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+	"time"
+)
+
+var (
+	errFileQuarantined = shared.NewDomainError(shared.ErrCodeConflict, "File is waiting for a malware scan", "")
+	errFileInfected    = shared.NewDomainError(shared.ErrCodeForbidden, "File failed the malware scan", "")
+)
+
+type scanService struct {
+	fileRepo  Repository
+	storage   Storage
+	scanner   MalwareScanner
+	messaging shared.Messaging
+}
+
+func NewScanService(fileRepo Repository, storage Storage, scanner MalwareScanner, messaging shared.Messaging) ScanService {
+	return &scanService{
+		fileRepo:  fileRepo,
+		storage:   storage,
+		scanner:   scanner,
+		messaging: messaging,
+	}
+}
+
+// HandleFileUploaded scans the file in a file.uploaded event
+func (s *scanService) HandleFileUploaded(ctx context.Context, data []byte) error {
+	var uploaded File
+	if err := json.Unmarshal(data, &uploaded); err != nil {
+		logging.FromContext(ctx).Warn("ignoring malformed file uploaded event", "error", err)
+		return nil
+	}
+	return s.Scan(ctx, uploaded.ID.String())
+}
+
+// Scan scans a quarantined file's content and records the verdict. Files
+// already scanned are left alone, so redelivered events are harmless. Scanner
+// errors are returned so the scan is retried; the file stays quarantined
+// meanwhile.
+func (s *scanService) Scan(ctx context.Context, fileID string) error {
+	logger := logging.FromContext(ctx)
+
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if file.Status != StatusAvailable || file.ScanStatus != ScanQuarantined {
+		return nil
+	}
+
+	content, err := s.storage.Download(ctx, file.StorageKey)
+	if err != nil {
+		return err
+	}
+	result, err := s.scanner.Scan(ctx, content)
+	content.Close()
+	if err != nil {
+		logger.Error("malware scan failed", "error", err, "file_id", fileID)
+		return err
+	}
+
+	now := time.Now()
+	file.ScanStatus = ScanClean
+	if result.Infected {
+		file.ScanStatus = ScanInfected
+		file.ScanSignature = result.Signature
+	}
+	file.ScannedAt = &now
+	file.UpdatedAt = now
+	updated, err := s.fileRepo.UpdateContent(ctx, file, file.Version, file.StorageKey, "ScanStatus", "ScanSignature", "ScannedAt", "UpdatedAt")
+	if err != nil {
+		return err
+	}
+	if !updated {
+		// The content was replaced while it was scanned; the new content is
+		// scanned on its own
+		logger.Info("scanned content was replaced", "file_id", fileID)
+		return nil
+	}
+
+	if result.Infected {
+		logger.Warn("malware detected in upload", "file_id", fileID, "signature", result.Signature)
+	} else {
+		logger.Info("file scanned clean", "file_id", fileID)
+	}
+	publish(ctx, s.messaging, TopicFileScanned, file)
+	return nil
+}
+
+// RescanQuarantined scans one batch of the oldest files still quarantined.
+// Failures are logged and skipped so one bad file doesn't hold up the rest.
+func (s *scanService) RescanQuarantined(ctx context.Context, olderThan time.Duration) (int, error) {
+	const batchSize = 100
+	logger := logging.FromContext(ctx)
+
+	files, err := s.fileRepo.ListQuarantinedBefore(ctx, time.Now().Add(-olderThan), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	scanned := 0
+	for _, file := range files {
+		if err := s.Scan(ctx, file.ID.String()); err != nil {
+			logger.Error("failed to rescan quarantined file", "error", err, "file_id", file.ID)
+			continue
+		}
+		scanned++
+	}
+
+	if scanned > 0 {
+		logger.Info("quarantined files rescanned", "count", scanned)
+	}
+	return scanned, nil
+}
@@ -2,27 +2,72 @@ package file
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io"
+	"net/http"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/identity"
+	"taskflow/pkg/logging"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type fileService struct {
-	fileRepo Repository
-	storage  Storage
+	fileRepo   Repository
+	blobRepo   BlobRepository
+	storage    Storage
+	messaging  shared.Messaging
+	policy     UploadPolicy
+	references References
+	quotas     *Quotas
+	versions   *Versioning
 }
 
-func NewFileService(fileRepo Repository, storage Storage) FileService {
+// Deps are what the file service is built from. Files, Storage, Messaging
+// and Policy are required; the rest may be nil.
+type Deps struct {
+	Files     Repository
+	Storage   Storage
+	Messaging shared.Messaging
+	Policy    UploadPolicy
+	// Blobs makes uploads content-addressed: files with identical content
+	// share one stored object
+	Blobs BlobRepository
+	// References is consulted before files are deleted
+	References References
+	// Quotas counts stored files against them
+	Quotas *Quotas
+	// Versions lets file contents be replaced, keeping earlier ones
+	Versions *Versioning
+}
+
+// NewFileService creates the file service
+func NewFileService(deps Deps) FileService {
+	return newFileService(deps)
+}
+
+func newFileService(deps Deps) *fileService {
 	return &fileService{
-		fileRepo: fileRepo,
-		storage:  storage,
+		fileRepo:   deps.Files,
+		blobRepo:   deps.Blobs,
+		storage:    deps.Storage,
+		messaging:  deps.Messaging,
+		policy:     deps.Policy,
+		references: deps.References,
+		quotas:     deps.Quotas,
+		versions:   deps.Versions,
 	}
 }
 
 func (s *fileService) UploadFile(ctx context.Context, req *CreateFileRequest, content io.Reader) (*UploadResponse, error) {
-	// Upload to storage
-	storageKey, err := s.storage.Upload(ctx, req.Filename, content, req.ContentType)
+	logger := logging.FromContext(ctx)
+
+	caller := identity.FromContext(ctx)
+	stored, err := s.storeContent(ctx, req.Filename, req.Size, caller.UserID, caller.WorkspaceID, 1, content)
 	if err != nil {
 		return nil, err
 	}
@@ -31,29 +76,229 @@ func (s *fileService) UploadFile(ctx context.Context, req *CreateFileRequest, co
 	file := &File{
 		ID:          uuid.New(),
 		Filename:    req.Filename,
-		ContentType: req.ContentType,
-		Size:        req.Size,
-		StorageKey:  storageKey,
+		ContentType: stored.contentType,
+		Size:        stored.size,
+		StorageKey:  stored.storageKey,
+		Checksum:    stored.checksum,
+		Status:      StatusAvailable,
+		ScanStatus:  ScanQuarantined,
+		OwnerID:     caller.UserID,
+		WorkspaceID: caller.WorkspaceID,
+		KeyID:       stored.keyID,
+		Version:     1,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
 	// Save to repository
 	if err := s.fileRepo.Create(ctx, file); err != nil {
-		// Clean up storage if repository save fails
-		s.storage.Delete(ctx, storageKey)
+		logger.Error("failed to save file metadata", "error", err, "file_id", file.ID)
+		// Clean up storage if repository save fails; anything left behind is
+		// found by garbage collection
+		s.discardContent(ctx, stored, file.OwnerID, file.WorkspaceID, 1)
 		return nil, err
 	}
 
-	// Get URL if available
-	url, _ := s.storage.GetURL(ctx, storageKey)
+	logger.Info("file uploaded", "file_id", file.ID, "storage_key", file.StorageKey, "size", file.Size)
+	publishUploaded(ctx, s.messaging, file)
 
 	return &UploadResponse{
-		FileID: file.ID.String(),
-		URL:    url,
+		FileID:     file.ID.String(),
+		Checksum:   file.Checksum,
+		ScanStatus: file.ScanStatus,
 	}, nil
 }
 
+// storedContent is uploaded content that passed the upload policy and was
+// charged to its owners, ready to be recorded on a file
+type storedContent struct {
+	contentType string
+	size        int64
+	storageKey  string
+	checksum    string
+	keyID       string
+}
+
+// storeContent checks content named filename against the upload policy,
+// stores it and charges it to the owners as the given number of new files.
+// declared is the size announced by the client, zero if unknown.
+func (s *fileService) storeContent(ctx context.Context, filename string, declared int64, userID, workspaceID string, files int64, content io.Reader) (*storedContent, error) {
+	logger := logging.FromContext(ctx)
+
+	ext, err := s.policy.CheckExtension(filename)
+	if err != nil {
+		return nil, err
+	}
+	if declared > s.policy.MaxSize {
+		return nil, errFileTooLarge
+	}
+
+	// Refuse the upload up front if the owner's quota is used up, and
+	// otherwise stop reading once the rest of it is
+	allowance, err := s.quotas.Allowance(ctx, userID, workspaceID, files)
+	if err != nil {
+		return nil, err
+	}
+	if declared > allowance {
+		return nil, errQuotaExceeded
+	}
+
+	// Stop reading once the size limit or the rest of the quota is passed
+	stored, err := s.putContent(ctx, filename, ext, content, min(s.policy.MaxSize, allowance))
+	if err != nil {
+		if errors.Is(err, errFileTooLarge) && allowance < s.policy.MaxSize {
+			return nil, errQuotaExceeded
+		}
+		return nil, err
+	}
+
+	// Concurrent uploads may have used the allowance up in the meantime
+	if err := s.quotas.Charge(ctx, userID, workspaceID, stored.size, files); err != nil {
+		if err := s.storage.Delete(ctx, stored.storageKey); err != nil {
+			logger.Error("failed to clean up stored upload", "error", err, "storage_key", stored.storageKey)
+		}
+		return nil, err
+	}
+
+	if err := s.settleContent(ctx, stored); err != nil {
+		s.quotas.Credit(ctx, userID, workspaceID, stored.size, files)
+		return nil, err
+	}
+	return stored, nil
+}
+
+// putContent is the processing every upload goes through, whichever way it
+// arrives: the real type is detected from the content, the location is
+// stripped from photos and the content is hashed on its way into storage.
+// Uploads longer than limit fail with errFileTooLarge.
+func (s *fileService) putContent(ctx context.Context, filename, ext string, content io.Reader, limit int64) (*storedContent, error) {
+	// The client-supplied type is ignored
+	contentType, content, err := s.policy.SniffContent(ext, content)
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "image/jpeg" {
+		// Photos often carry the location they were taken at
+		if content, err = stripJPEGLocation(content); err != nil {
+			return nil, err
+		}
+	}
+
+	limited := &limitedReader{r: content, max: limit}
+	hasher := sha256.New()
+	storageKey, err := s.storage.Upload(ctx, filename, io.TeeReader(limited, hasher), contentType)
+	if err != nil {
+		if limited.exceeded {
+			return nil, errFileTooLarge
+		}
+		logging.FromContext(ctx).Error("failed to upload file to storage", "error", err, "filename", filename)
+		return nil, err
+	}
+	return &storedContent{
+		contentType: contentType,
+		size:        limited.n,
+		storageKey:  storageKey,
+		checksum:    hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+// settleContent shares just-stored content with identical blobs and records
+// the data key it is encrypted with. Nothing is left in storage if it fails.
+func (s *fileService) settleContent(ctx context.Context, stored *storedContent) error {
+	var err error
+	if s.blobRepo != nil {
+		if stored.storageKey, err = s.shareContent(ctx, stored.checksum, stored.storageKey, stored.size); err != nil {
+			return err
+		}
+	}
+
+	if stored.keyID, err = keyIDOf(ctx, s.storage, stored.storageKey); err != nil {
+		if err := s.releaseContent(ctx, stored.checksum, stored.storageKey); err != nil {
+			logging.FromContext(ctx).Error("failed to clean up stored upload", "error", err, "storage_key", stored.storageKey)
+		}
+		return err
+	}
+	return nil
+}
+
+// ingestObject runs an object clients wrote to storage without going through
+// the API, as direct and resumable uploads do, through putContent and
+// settleContent. The processed copy is stored next to the object, which the
+// caller deletes once the file refers to the copy. Quotas are left alone,
+// since those uploads are charged when they start.
+func (s *fileService) ingestObject(ctx context.Context, filename, storageKey string) (*storedContent, error) {
+	ext, err := s.policy.CheckExtension(filename)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.storage.Download(ctx, storageKey)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	stored, err := s.putContent(ctx, filename, ext, content, s.policy.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.settleContent(ctx, stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// discardContent undoes storeContent for content that won't be recorded
+func (s *fileService) discardContent(ctx context.Context, stored *storedContent, userID, workspaceID string, files int64) {
+	if err := s.releaseContent(ctx, stored.checksum, stored.storageKey); err != nil {
+		logging.FromContext(ctx).Error("failed to clean up stored upload", "error", err, "storage_key", stored.storageKey)
+	}
+	s.quotas.Credit(ctx, userID, workspaceID, stored.size, files)
+}
+
+// shareContent registers the just-uploaded object as a blob. If a blob with
+// the same checksum already exists the new object is dropped and the key of
+// the existing one is returned instead.
+func (s *fileService) shareContent(ctx context.Context, checksum, storageKey string, size int64) (string, error) {
+	logger := logging.FromContext(ctx)
+
+	blob, err := s.blobRepo.Acquire(ctx, &Blob{
+		Checksum:   checksum,
+		StorageKey: storageKey,
+		Size:       size,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	})
+	if err != nil {
+		if err := s.storage.Delete(ctx, storageKey); err != nil {
+			logger.Error("failed to clean up stored upload", "error", err, "storage_key", storageKey)
+		}
+		return "", err
+	}
+
+	if blob.StorageKey != storageKey {
+		if err := s.storage.Delete(ctx, storageKey); err != nil {
+			logger.Error("failed to delete duplicate upload", "error", err, "storage_key", storageKey)
+		}
+		logger.Info("upload deduplicated", "checksum", checksum, "storage_key", blob.StorageKey, "references", blob.RefCount)
+	}
+	return blob.StorageKey, nil
+}
+
+// releaseContent deletes a stored object, unless it is a blob that other
+// files or versions still reference
+func (s *fileService) releaseContent(ctx context.Context, checksum, storageKey string) error {
+	if s.blobRepo != nil && checksum != "" {
+		remaining, err := s.blobRepo.Release(ctx, checksum, storageKey)
+		switch {
+		case err == nil && remaining > 0:
+			return nil
+		case err != nil && !errors.Is(err, shared.ErrNotFound):
+			return err
+		}
+	}
+	return s.storage.Delete(ctx, storageKey)
+}
+
 func (s *fileService) GetFile(ctx context.Context, fileID string) (*File, error) {
 	return s.fileRepo.GetByID(ctx, fileID)
 }
@@ -64,25 +309,118 @@ func (s *fileService) DownloadFile(ctx context.Context, fileID string) (io.ReadC
 	if err != nil {
 		return nil, err
 	}
+	return s.OpenFile(ctx, file)
+}
+
+func (s *fileService) OpenFile(ctx context.Context, file *File) (io.ReadCloser, error) {
+	if err := file.CheckDownloadable(); err != nil {
+		return nil, err
+	}
 
 	// Download from storage
-	return s.storage.Download(ctx, file.StorageKey)
+	content, err := s.storage.Download(ctx, file.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files stored before checksums were recorded can't be verified
+	if file.Checksum == "" {
+		return content, nil
+	}
+	return newChecksumReader(content, file.Size, file.Checksum, logging.FromContext(ctx), file.ID.String()), nil
 }
 
-func (s *fileService) DeleteFile(ctx context.Context, fileID string) error {
-	// Get file metadata
+// OpenFileRange reads part of a file. Partial content can't be checked
+// against the checksum, so it is returned unverified.
+func (s *fileService) OpenFileRange(ctx context.Context, file *File, offset, length int64) (io.ReadCloser, error) {
+	if err := file.CheckDownloadable(); err != nil {
+		return nil, err
+	}
+	if offset < 0 || length <= 0 || offset+length > file.Size {
+		return nil, shared.NewValidationError("range is outside the file")
+	}
+
+	return s.storage.DownloadRange(ctx, file.StorageKey, offset, length)
+}
+
+func (s *fileService) CheckLinkable(ctx context.Context, fileID string) error {
 	file, err := s.fileRepo.GetByID(ctx, fileID)
 	if err != nil {
 		return err
 	}
+	if file.OwnerID != identity.FromContext(ctx).UserID {
+		return errNotFileOwner
+	}
+	if file.Status != StatusAvailable {
+		return errUploadIncomplete
+	}
+	return file.CheckDownloadable()
+}
 
-	// Delete from storage
-	if err := s.storage.Delete(ctx, file.StorageKey); err != nil {
+func (s *fileService) DeleteFile(ctx context.Context, fileID string) error {
+	file, err := s.deleteFile(ctx, fileID)
+	if err != nil {
 		return err
 	}
+	publish(ctx, s.messaging, TopicFileDeleted, file)
+	return nil
+}
+
+// deleteFile deletes a file and its content, leaving announcing it to the
+// caller
+func (s *fileService) deleteFile(ctx context.Context, fileID string) (*File, error) {
+	// Get file metadata
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
 
-	// Delete from repository
-	return s.fileRepo.Delete(ctx, fileID)
+	logger := logging.FromContext(ctx)
+
+	// Let the records attached to the file block the deletion or let go of it
+	if s.references != nil {
+		if err := s.references.ReleaseFile(ctx, fileID); err != nil {
+			return nil, err
+		}
+	}
+
+	var versions []*FileVersion
+	if s.versions != nil {
+		if versions, err = s.versions.repo.ListByFile(ctx, fileID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Delete the record first: content left behind if storage fails is an
+	// orphan garbage collection removes, while a record without content
+	// would stay visible
+	if err := s.fileRepo.Delete(ctx, fileID); err != nil {
+		logger.Error("failed to delete file metadata", "error", err, "file_id", fileID)
+		return nil, err
+	}
+	s.quotas.Credit(ctx, file.OwnerID, file.WorkspaceID, file.Size, 1)
+
+	// Delete from storage, unless other files share the content
+	if err := s.releaseContent(ctx, file.Checksum, file.StorageKey); err != nil {
+		logger.Error("failed to delete file from storage", "error", err, "file_id", fileID)
+	}
+	for _, version := range versions {
+		if err := s.dropVersion(ctx, file, version); err != nil {
+			logger.Error("failed to delete file version", "error", err, "file_id", fileID, "version", version.Number)
+		}
+	}
+	for _, thumbnail := range file.Thumbnails {
+		if err := s.storage.Delete(ctx, thumbnail.StorageKey); err != nil {
+			logger.Error("failed to delete thumbnail from storage", "error", err, "file_id", fileID)
+		}
+	}
+
+	logger.Info("file deleted", "file_id", fileID)
+	return file, nil
+}
+
+func (s *fileService) GetUsage(ctx context.Context) (*UsageReport, error) {
+	return s.quotas.Report(ctx)
 }
 
 func (s *fileService) ListFiles(ctx context.Context, limit, offset int) ([]*File, error) {
@@ -106,9 +444,212 @@ func (s *fileService) UpdateFile(ctx context.Context, fileID string, req *Update
 	file.UpdatedAt = time.Now()
 
 	// Save changes
-	if err := s.fileRepo.Update(ctx, file); err != nil {
+	if err := s.fileRepo.Update(ctx, file, "Filename", "ContentType", "UpdatedAt"); err != nil {
 		return nil, err
 	}
 
 	return file, nil
 }
+
+func (s *fileService) CreateUploadURL(ctx context.Context, req *CreateUploadURLRequest) (*UploadURLResponse, error) {
+	direct, ok := s.storage.(DirectUploadStorage)
+	if !ok {
+		return nil, shared.NewDomainError(shared.ErrCodeInvalidInput, "Direct uploads are not supported by this storage", "")
+	}
+
+	ext, err := s.policy.CheckExtension(req.Filename)
+	if err != nil {
+		return nil, err
+	}
+	if req.Size > s.policy.MaxSize {
+		return nil, errFileTooLarge
+	}
+
+	contentType := s.policy.ContentTypeFor(ext)
+	storageKey := uuid.New().String() + ext
+
+	var upload *shared.PresignedUpload
+	switch strings.ToUpper(req.Method) {
+	case "", http.MethodPut:
+		upload, err = direct.PresignPut(ctx, storageKey, contentType, s.policy.PresignExpiry)
+	case http.MethodPost:
+		upload, err = direct.PresignPost(ctx, storageKey, contentType, s.policy.MaxSize, s.policy.PresignExpiry)
+	default:
+		return nil, shared.NewValidationError("method must be PUT or POST")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	caller := identity.FromContext(ctx)
+	file := &File{
+		ID:          uuid.New(),
+		Filename:    req.Filename,
+		ContentType: contentType,
+		Size:        req.Size,
+		StorageKey:  storageKey,
+		Status:      StatusPending,
+		ScanStatus:  ScanQuarantined,
+		OwnerID:     caller.UserID,
+		WorkspaceID: caller.WorkspaceID,
+		Version:     1,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	// The declared size is charged now and corrected once the upload completes
+	if err := s.quotas.Charge(ctx, file.OwnerID, file.WorkspaceID, file.Size, 1); err != nil {
+		return nil, err
+	}
+	if err := s.fileRepo.Create(ctx, file); err != nil {
+		s.quotas.Credit(ctx, file.OwnerID, file.WorkspaceID, file.Size, 1)
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("pending upload created", "file_id", file.ID, "method", upload.Method)
+
+	return &UploadURLResponse{
+		FileID: file.ID.String(),
+		Upload: upload,
+	}, nil
+}
+
+func (s *fileService) CompleteUpload(ctx context.Context, fileID string) (*File, error) {
+	direct, ok := s.storage.(DirectUploadStorage)
+	if !ok {
+		return nil, shared.NewDomainError(shared.ErrCodeInvalidInput, "Direct uploads are not supported by this storage", "")
+	}
+
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if file.Status != StatusPending {
+		// Completing twice is harmless
+		return file, nil
+	}
+
+	info, err := direct.Stat(ctx, file.StorageKey)
+	if err != nil {
+		if errors.Is(err, shared.ErrNotFound) {
+			// A concurrent completion may have replaced it with its processed copy
+			if current, err := s.fileRepo.GetByID(ctx, fileID); err == nil && current.Status != StatusPending {
+				return current, nil
+			}
+			return nil, shared.NewDomainError(shared.ErrCodeConflict, "Upload has not been received yet", "")
+		}
+		return nil, err
+	}
+
+	if info.Size > s.policy.MaxSize {
+		// A presigned PUT cannot enforce size, so oversize objects are only
+		// caught here
+		s.discardPending(ctx, file)
+		return nil, errFileTooLarge
+	}
+
+	// The object goes through the same processing as uploads through the
+	// API; content the upload policy refuses is discarded
+	stored, err := s.ingestObject(ctx, file.Filename, file.StorageKey)
+	if err != nil {
+		var domainErr *shared.DomainError
+		if errors.As(err, &domainErr) {
+			s.discardPending(ctx, file)
+		}
+		return nil, err
+	}
+
+	// Only the completion that moves the file out of pending settles its
+	// size; concurrent ones drop their copy and return the completed file
+	declared, received := file.Size, file.StorageKey
+	file.ContentType = stored.contentType
+	file.Size = stored.size
+	file.StorageKey = stored.storageKey
+	file.Checksum = stored.checksum
+	file.KeyID = stored.keyID
+	file.Status = StatusAvailable
+	file.UpdatedAt = time.Now()
+	completed, err := s.fileRepo.UpdateStatus(ctx, file, StatusPending,
+		"ContentType", "Size", "StorageKey", "Checksum", "KeyID", "Status", "UpdatedAt")
+	if err != nil || !completed {
+		if err := s.releaseContent(ctx, stored.checksum, stored.storageKey); err != nil {
+			logging.FromContext(ctx).Error("failed to clean up stored upload", "error", err, "storage_key", stored.storageKey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return s.fileRepo.GetByID(ctx, fileID)
+	}
+	if err := s.storage.Delete(ctx, received); err != nil {
+		logging.FromContext(ctx).Error("failed to delete received upload", "error", err, "storage_key", received)
+	}
+
+	// Settle the difference between the declared and the received size
+	if err := s.chargeResize(ctx, file, declared, file.Size); err != nil {
+		file.Size = declared
+		s.discardPending(ctx, file)
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("direct upload completed", "file_id", fileID, "size", file.Size)
+	publishUploaded(ctx, s.messaging, file)
+	return file, nil
+}
+
+// chargeResize charges or credits the owners of a file whose size changes
+func (s *fileService) chargeResize(ctx context.Context, file *File, from, to int64) error {
+	if to > from {
+		return s.quotas.Charge(ctx, file.OwnerID, file.WorkspaceID, to-from, 0)
+	}
+	s.quotas.Credit(ctx, file.OwnerID, file.WorkspaceID, from-to, 0)
+	return nil
+}
+
+// discardPending deletes a direct upload that can't be accepted
+func (s *fileService) discardPending(ctx context.Context, file *File) {
+	logger := logging.FromContext(ctx)
+	if err := s.releaseContent(ctx, file.Checksum, file.StorageKey); err != nil {
+		logger.Error("failed to delete rejected upload", "error", err, "file_id", file.ID)
+	}
+	if err := s.fileRepo.Delete(ctx, file.ID.String()); err != nil {
+		logger.Error("failed to delete rejected file record", "error", err, "file_id", file.ID)
+		return
+	}
+	s.quotas.Credit(ctx, file.OwnerID, file.WorkspaceID, file.Size, 1)
+}
+
+// CleanupPendingUploads removes pending files older than olderThan together
+// with any content that was uploaded for them
+func (s *fileService) CleanupPendingUploads(ctx context.Context, olderThan time.Duration) (int, error) {
+	const batchSize = 100
+	logger := logging.FromContext(ctx)
+	cutoff := time.Now().Add(-olderThan)
+
+	removed := 0
+	for {
+		files, err := s.fileRepo.ListPendingBefore(ctx, cutoff, batchSize)
+		if err != nil {
+			return removed, err
+		}
+
+		for _, file := range files {
+			if err := s.storage.Delete(ctx, file.StorageKey); err != nil {
+				logger.Error("failed to delete abandoned upload", "error", err, "file_id", file.ID)
+				return removed, err
+			}
+			if err := s.fileRepo.Delete(ctx, file.ID.String()); err != nil {
+				return removed, err
+			}
+			s.quotas.Credit(ctx, file.OwnerID, file.WorkspaceID, file.Size, 1)
+			removed++
+		}
+
+		if len(files) < batchSize {
+			break
+		}
+	}
+
+	if removed > 0 {
+		logger.Info("abandoned pending uploads removed", "count", removed)
+	}
+	return removed, nil
+}
@@ -0,0 +1,262 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register GIF decoding
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// thumbnailTypes are the content types thumbnails are generated for
+var thumbnailTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Thumbnail is a resized copy of an image file. Size is the bounding box the
+// image was scaled to fit, in pixels.
+type Thumbnail struct {
+	Size        int    `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ContentType string `json:"contentType"`
+	StorageKey  string `json:"storageKey"`
+}
+
+// ThumbnailConfig controls thumbnail generation
+type ThumbnailConfig struct {
+	// Sizes are the bounding boxes to generate, in pixels
+	Sizes []int
+	// MaxPixels rejects images whose decoded size would exceed it
+	MaxPixels int64
+}
+
+type thumbnailService struct {
+	fileRepo Repository
+	storage  Storage
+	config   ThumbnailConfig
+}
+
+func NewThumbnailService(fileRepo Repository, storage Storage, config ThumbnailConfig) ThumbnailService {
+	sizes := append([]int(nil), config.Sizes...)
+	sort.Ints(sizes)
+	config.Sizes = sizes
+
+	return &thumbnailService{
+		fileRepo: fileRepo,
+		storage:  storage,
+		config:   config,
+	}
+}
+
+// HandleFileScanned generates thumbnails for the file in a file.scanned event
+func (s *thumbnailService) HandleFileScanned(ctx context.Context, data []byte) error {
+	var uploaded File
+	if err := json.Unmarshal(data, &uploaded); err != nil {
+		logging.FromContext(ctx).Warn("ignoring malformed file scanned event", "error", err)
+		return nil
+	}
+	return s.Generate(ctx, uploaded.ID.String())
+}
+
+// Generate creates and records the thumbnails of a clean image file. It does
+// nothing for other files or if thumbnails already exist, so redelivered
+// events are harmless. Images that can't be decoded are logged and skipped
+// rather than failing, as retrying won't help.
+func (s *thumbnailService) Generate(ctx context.Context, fileID string) error {
+	logger := logging.FromContext(ctx)
+
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !thumbnailTypes[file.ContentType] || file.Status != StatusAvailable || file.ScanStatus != ScanClean || len(file.Thumbnails) > 0 {
+		return nil
+	}
+
+	content, err := s.storage.Download(ctx, file.StorageKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(content)
+	content.Close()
+	if err != nil {
+		return err
+	}
+
+	img, err := s.decode(data, file.ContentType)
+	if err != nil {
+		logger.Warn("cannot generate thumbnails", "error", err, "file_id", fileID)
+		return nil
+	}
+
+	base := strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename))
+	var thumbnails []Thumbnail
+	for _, size := range s.config.Sizes {
+		thumbnail, encoded, err := resize(img, size)
+		if err != nil {
+			s.deleteThumbnails(ctx, thumbnails)
+			return err
+		}
+
+		name := fmt.Sprintf("%s_%d%s", base, size, extensionFor(thumbnail.ContentType))
+		thumbnail.StorageKey, err = s.storage.Upload(ctx, name, bytes.NewReader(encoded), thumbnail.ContentType)
+		if err != nil {
+			s.deleteThumbnails(ctx, thumbnails)
+			return err
+		}
+		thumbnails = append(thumbnails, *thumbnail)
+	}
+
+	file.Thumbnails = thumbnails
+	file.UpdatedAt = time.Now()
+	updated, err := s.fileRepo.UpdateContent(ctx, file, file.Version, file.StorageKey, "Thumbnails", "UpdatedAt")
+	if err != nil || !updated {
+		// Thumbnails of content replaced meanwhile are of no use
+		s.deleteThumbnails(ctx, thumbnails)
+		return err
+	}
+
+	logger.Info("thumbnails generated", "file_id", fileID, "count", len(thumbnails))
+	return nil
+}
+
+// GetThumbnail returns the smallest thumbnail at least size pixels across,
+// or the largest one if none is big enough
+func (s *thumbnailService) GetThumbnail(ctx context.Context, fileID string, size int) (*Thumbnail, io.ReadCloser, error) {
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := file.CheckDownloadable(); err != nil {
+		return nil, nil, err
+	}
+	if len(file.Thumbnails) == 0 {
+		return nil, nil, shared.NewNotFoundError("thumbnail not available")
+	}
+
+	thumbnails := append([]Thumbnail(nil), file.Thumbnails...)
+	sort.Slice(thumbnails, func(i, j int) bool { return thumbnails[i].Size < thumbnails[j].Size })
+	thumbnail := thumbnails[len(thumbnails)-1]
+	for _, candidate := range thumbnails {
+		if candidate.Size >= size {
+			thumbnail = candidate
+			break
+		}
+	}
+
+	content, err := s.storage.Download(ctx, thumbnail.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &thumbnail, content, nil
+}
+
+// decode checks the image dimensions before decoding it, so small files that
+// declare huge images can't exhaust memory, and turns JPEGs upright
+func (s *thumbnailService) decode(data []byte, contentType string) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if int64(config.Width)*int64(config.Height) > s.config.MaxPixels {
+		return nil, fmt.Errorf("image is %dx%d, above the %d pixel limit", config.Width, config.Height, s.config.MaxPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "image/jpeg" {
+		img = &orientedImage{Image: img, orientation: jpegOrientation(data)}
+	}
+	return img, nil
+}
+
+func (s *thumbnailService) deleteThumbnails(ctx context.Context, thumbnails []Thumbnail) {
+	for _, thumbnail := range thumbnails {
+		if err := s.storage.Delete(ctx, thumbnail.StorageKey); err != nil {
+			logging.FromContext(ctx).Error("failed to delete thumbnail", "error", err, "storage_key", thumbnail.StorageKey)
+		}
+	}
+}
+
+// orientedImage carries the EXIF orientation to apply once the image has been
+// scaled down, which is much cheaper than rotating the full-size image
+type orientedImage struct {
+	image.Image
+	orientation int
+}
+
+// resize scales img to fit within size x size pixels, never enlarging it, and
+// encodes the result. Opaque images are encoded as JPEG and images with
+// transparency as PNG. The output carries no metadata.
+func resize(img image.Image, size int) (*Thumbnail, []byte, error) {
+	orientation := 1
+	if oriented, ok := img.(*orientedImage); ok {
+		img, orientation = oriented.Image, oriented.orientation
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	scaled := applyOrientation(dst, orientation)
+
+	var buf bytes.Buffer
+	thumbnail := &Thumbnail{
+		Size:   size,
+		Width:  scaled.Bounds().Dx(),
+		Height: scaled.Bounds().Dy(),
+	}
+	if dst.Opaque() {
+		thumbnail.ContentType = "image/jpeg"
+		if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		thumbnail.ContentType = "image/png"
+		if err := png.Encode(&buf, scaled); err != nil {
+			return nil, nil, err
+		}
+	}
+	return thumbnail, buf.Bytes(), nil
+}
+
+func extensionFor(contentType string) string {
+	if contentType == "image/png" {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+func isNotFound(err error) bool {
+	var domainErr *shared.DomainError
+	return errors.Is(err, shared.ErrNotFound) || (errors.As(err, &domainErr) && domainErr.Code == shared.ErrCodeNotFound)
+}
@@ -0,0 +1,381 @@
+package file
+
+import (
+	"context"
+	"io"
+	"sort"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/identity"
+	"taskflow/pkg/logging"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileVersion is an earlier content of a file, kept when the content is
+// replaced or an older version restored. The current content stays on the
+// File itself and is listed as the version with Current set.
+type FileVersion struct {
+	ID            uuid.UUID `json:"-" db:"id"`
+	FileID        string    `json:"fileId" db:"file_id" gorm:"type:char(36);uniqueIndex:idx_file_version"`
+	Number        int       `json:"version" db:"number" gorm:"uniqueIndex:idx_file_version"`
+	ContentType   string    `json:"contentType" db:"content_type"`
+	Size          int64     `json:"size" db:"size"`
+	StorageKey    string    `json:"storageKey" db:"storage_key"`
+	Checksum      string    `json:"checksum,omitempty" db:"checksum" gorm:"size:64;index"`
+	ScanStatus    string    `json:"scanStatus" db:"scan_status" gorm:"size:20"`
+	ScanSignature string    `json:"scanSignature,omitempty" db:"scan_signature"`
+	KeyID         string    `json:"keyId,omitempty" db:"key_id" gorm:"size:64;index"`
+	UploadedBy    string    `json:"uploadedBy,omitempty" db:"uploaded_by" gorm:"size:64"`
+	// CreatedAt is when the content was uploaded
+	CreatedAt time.Time `json:"createdAt" db:"created_at" gorm:"index"`
+	Current   bool      `json:"current" gorm:"-"`
+}
+
+// CheckDownloadable reports whether the version's content may be served,
+// which requires it to have been scanned clean while it was current
+func (v *FileVersion) CheckDownloadable() error {
+	return checkScanStatus(v.ScanStatus)
+}
+
+// VersionPolicy decides which earlier versions of a file are kept
+type VersionPolicy struct {
+	// MaxVersions is how many earlier versions a file keeps besides its
+	// current content. Zero keeps them all.
+	MaxVersions int
+	// MaxAge drops earlier versions uploaded longer ago. Zero keeps them
+	// whatever their age.
+	MaxAge time.Duration
+}
+
+// Versioning keeps earlier contents of files. A nil *Versioning keeps
+// nothing and refuses to replace content.
+type Versioning struct {
+	repo   VersionRepository
+	policy VersionPolicy
+}
+
+func NewVersioning(repo VersionRepository, policy VersionPolicy) *Versioning {
+	return &Versioning{repo: repo, policy: policy}
+}
+
+var (
+	errVersioningDisabled = shared.NewDomainError(shared.ErrCodeInvalidInput, "File versioning is not enabled", "")
+	errNotFileOwner       = shared.NewDomainError(shared.ErrCodeForbidden, "File belongs to another user", "")
+	errUploadIncomplete   = shared.NewDomainError(shared.ErrCodeConflict, "Upload has not been completed", "")
+	errContentChanged     = shared.NewDomainError(shared.ErrCodeConflict, "File content changed meanwhile", "retry against the current version")
+)
+
+// currentVersion describes the content a file holds now
+func currentVersion(file *File) *FileVersion {
+	createdAt := file.CreatedAt
+	if file.VersionCreatedAt != nil {
+		createdAt = *file.VersionCreatedAt
+	}
+	return &FileVersion{
+		ID:            uuid.New(),
+		FileID:        file.ID.String(),
+		Number:        file.Version,
+		ContentType:   file.ContentType,
+		Size:          file.Size,
+		StorageKey:    file.StorageKey,
+		Checksum:      file.Checksum,
+		ScanStatus:    file.ScanStatus,
+		ScanSignature: file.ScanSignature,
+		KeyID:         file.KeyID,
+		UploadedBy:    file.OwnerID,
+		CreatedAt:     createdAt,
+		Current:       true,
+	}
+}
+
+// contentFields are the fields of a file setContent changes
+var contentFields = []string{
+	"ContentType", "Size", "StorageKey", "Checksum", "KeyID", "ETag", "Thumbnails",
+	"ScanStatus", "ScanSignature", "ScannedAt", "Version", "VersionCreatedAt", "UpdatedAt",
+}
+
+// setContent makes content the current content of a file. It is
+// quarantined again until scanned and its thumbnails are regenerated then.
+func setContent(file *File, content *FileVersion) {
+	createdAt := content.CreatedAt
+	file.ContentType = content.ContentType
+	file.Size = content.Size
+	file.StorageKey = content.StorageKey
+	file.Checksum = content.Checksum
+	file.KeyID = content.KeyID
+	file.ETag = ""
+	file.Thumbnails = nil
+	file.ScanStatus = ScanQuarantined
+	file.ScanSignature = ""
+	file.ScannedAt = nil
+	file.Version = content.Number
+	file.VersionCreatedAt = &createdAt
+	file.UpdatedAt = time.Now()
+}
+
+// versionedFile loads a file whose content the caller may change
+func (s *fileService) versionedFile(ctx context.Context, fileID string) (*File, error) {
+	if s.versions == nil {
+		return nil, errVersioningDisabled
+	}
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	// New contents are charged to the owner, so only they may upload them
+	if file.OwnerID != identity.FromContext(ctx).UserID {
+		return nil, errNotFileOwner
+	}
+	if file.Status != StatusAvailable {
+		return nil, errUploadIncomplete
+	}
+	return file, nil
+}
+
+// ReplaceContent uploads new content for a file, keeping the current one as
+// an earlier version. The content must match the type of the file's name
+// and counts against the owner's quota like any upload.
+func (s *fileService) ReplaceContent(ctx context.Context, fileID string, req *CreateFileRequest, content io.Reader) (*File, error) {
+	logger := logging.FromContext(ctx)
+
+	file, err := s.versionedFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.versions.repo.ListByFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.storeContent(ctx, file.Filename, req.Size, file.OwnerID, file.WorkspaceID, 0, content)
+	if err != nil {
+		return nil, err
+	}
+
+	number := file.Version
+	for _, version := range versions {
+		number = max(number, version.Number)
+	}
+	replacement := &FileVersion{
+		Number:      number + 1,
+		ContentType: stored.contentType,
+		Size:        stored.size,
+		StorageKey:  stored.storageKey,
+		Checksum:    stored.checksum,
+		KeyID:       stored.keyID,
+		CreatedAt:   time.Now(),
+	}
+
+	previous, err := s.switchContent(ctx, file, replacement)
+	if err != nil {
+		s.discardContent(ctx, stored, file.OwnerID, file.WorkspaceID, 0)
+		return nil, err
+	}
+
+	logger.Info("file content replaced", "file_id", fileID, "version", file.Version, "size", file.Size)
+	publishUploaded(ctx, s.messaging, file)
+	s.pruneVersions(ctx, file, append([]*FileVersion{previous}, versions...))
+	return file, nil
+}
+
+// ListVersions returns the current and the kept earlier versions of a
+// file, newest first
+func (s *fileService) ListVersions(ctx context.Context, fileID string) ([]*FileVersion, error) {
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	versions := []*FileVersion{currentVersion(file)}
+	if s.versions == nil {
+		return versions, nil
+	}
+	earlier, err := s.versions.repo.ListByFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	return append(versions, earlier...), nil
+}
+
+// DownloadVersion reads one version of a file, the current one included
+func (s *fileService) DownloadVersion(ctx context.Context, fileID string, number int) (*FileVersion, io.ReadCloser, error) {
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if number == file.Version {
+		content, err := s.DownloadFile(ctx, fileID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return currentVersion(file), content, nil
+	}
+	if s.versions == nil {
+		return nil, nil, shared.NewNotFoundError("version not found")
+	}
+
+	version, err := s.versions.repo.Get(ctx, fileID, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := version.CheckDownloadable(); err != nil {
+		return nil, nil, err
+	}
+	content, err := s.storage.Download(ctx, version.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return version, newChecksumReader(content, version.Size, version.Checksum, logging.FromContext(ctx), fileID), nil
+}
+
+// RestoreVersion makes an earlier version the current content of a file
+// again. The content it replaces is kept as an earlier version in turn, so
+// restoring changes no usage.
+func (s *fileService) RestoreVersion(ctx context.Context, fileID string, number int) (*File, error) {
+	logger := logging.FromContext(ctx)
+
+	file, err := s.versionedFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if number == file.Version {
+		return file, nil
+	}
+	versions, err := s.versions.repo.ListByFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	var restored *FileVersion
+	var kept []*FileVersion
+	for _, version := range versions {
+		if version.Number == number {
+			restored = version
+		} else {
+			kept = append(kept, version)
+		}
+	}
+	if restored == nil {
+		return nil, shared.NewNotFoundError("version not found")
+	}
+
+	// The restored content must not be listed twice, so its version goes
+	// before the file points at it, and comes back if that fails
+	if err := s.versions.repo.Delete(ctx, restored.ID.String()); err != nil {
+		return nil, err
+	}
+	previous, err := s.switchContent(ctx, file, restored)
+	if err != nil {
+		if err := s.versions.repo.Create(ctx, restored); err != nil {
+			logger.Error("failed to keep version after failed restore", "error", err, "file_id", fileID, "version", number)
+		}
+		return nil, err
+	}
+
+	logger.Info("file version restored", "file_id", fileID, "version", number)
+	publishUploaded(ctx, s.messaging, file)
+	s.pruneVersions(ctx, file, append([]*FileVersion{previous}, kept...))
+	return file, nil
+}
+
+// switchContent keeps the current content of a file as an earlier version
+// and makes content the current one, returning the kept version
+func (s *fileService) switchContent(ctx context.Context, file *File, content *FileVersion) (*FileVersion, error) {
+	logger := logging.FromContext(ctx)
+
+	previous := currentVersion(file)
+	previous.Current = false
+	if err := s.versions.repo.Create(ctx, previous); err != nil {
+		return nil, err
+	}
+
+	thumbnails := file.Thumbnails
+	setContent(file, content)
+	updated, err := s.fileRepo.UpdateContent(ctx, file, previous.Number, previous.StorageKey, contentFields...)
+	if err == nil && !updated {
+		err = errContentChanged
+	}
+	if err != nil {
+		logger.Error("failed to switch file content", "error", err, "file_id", file.ID)
+		if err := s.versions.repo.Delete(ctx, previous.ID.String()); err != nil {
+			logger.Error("failed to drop version after failed switch", "error", err, "file_id", file.ID)
+		}
+		return nil, err
+	}
+
+	// Thumbnails are cheap to make again from the new content
+	for _, thumbnail := range thumbnails {
+		if err := s.storage.Delete(ctx, thumbnail.StorageKey); err != nil {
+			logger.Error("failed to delete thumbnail from storage", "error", err, "file_id", file.ID)
+		}
+	}
+	return previous, nil
+}
+
+// pruneVersions drops the earlier versions of a file the policy doesn't
+// keep. versions are all of them, in any order.
+func (s *fileService) pruneVersions(ctx context.Context, file *File, versions []*FileVersion) {
+	policy := s.versions.policy
+	cutoff := time.Now().Add(-policy.MaxAge)
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Number > versions[j].Number })
+
+	for i, version := range versions {
+		if (policy.MaxVersions > 0 && i >= policy.MaxVersions) || (policy.MaxAge > 0 && version.CreatedAt.Before(cutoff)) {
+			if err := s.dropVersion(ctx, file, version); err != nil {
+				logging.FromContext(ctx).Error("failed to prune file version", "error", err, "file_id", file.ID, "version", version.Number)
+			}
+		}
+	}
+}
+
+// dropVersion deletes an earlier version and its content. file is nil if
+// the file itself is gone already.
+func (s *fileService) dropVersion(ctx context.Context, file *File, version *FileVersion) error {
+	if err := s.versions.repo.Delete(ctx, version.ID.String()); err != nil {
+		return err
+	}
+	if file != nil {
+		s.quotas.Credit(ctx, file.OwnerID, file.WorkspaceID, version.Size, 0)
+	}
+	return s.releaseContent(ctx, version.Checksum, version.StorageKey)
+}
+
+// PruneVersions drops earlier versions past the policy's MaxAge. How many
+// versions a file keeps is enforced whenever it gets a new one, but age
+// needs a sweep.
+func (s *fileService) PruneVersions(ctx context.Context) (int, error) {
+	if s.versions == nil || s.versions.policy.MaxAge <= 0 {
+		return 0, nil
+	}
+	logger := logging.FromContext(ctx)
+	cutoff := time.Now().Add(-s.versions.policy.MaxAge)
+
+	pruned := 0
+	for {
+		versions, err := s.versions.repo.ListBefore(ctx, cutoff, gcBatchSize)
+		if err != nil {
+			return pruned, err
+		}
+		failed := 0
+		for _, version := range versions {
+			file, err := s.fileRepo.GetByID(ctx, version.FileID)
+			if err != nil && !isNotFound(err) {
+				return pruned, err
+			}
+			if err := s.dropVersion(ctx, file, version); err != nil {
+				logger.Error("failed to prune file version", "error", err, "file_id", version.FileID, "version", version.Number)
+				failed++
+				continue
+			}
+			pruned++
+		}
+		// Versions that failed would come back in the next batch
+		if len(versions) < gcBatchSize || failed > 0 {
+			break
+		}
+	}
+
+	if pruned > 0 {
+		logger.Info("expired file versions pruned", "count", pruned)
+	}
+	return pruned, nil
+}
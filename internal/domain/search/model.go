@@ -0,0 +1,66 @@
+package search
+
+import "time"
+
+// Document kinds
+const (
+	KindTodo = "todo"
+	KindFile = "file"
+)
+
+// Document is the searchable text of a todo's description or of a file's
+// content
+type Document struct {
+	ID        string    `json:"id" db:"id" gorm:"primaryKey;size:64"`
+	Kind      string    `json:"kind" db:"kind" gorm:"size:10;index"`
+	RefID     string    `json:"refId" db:"ref_id" gorm:"size:36;index"`
+	Text      string    `json:"text" db:"text" gorm:"type:mediumtext;index:idx_search_documents_text,class:FULLTEXT"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// DocumentID returns the ID of the document of a todo or a file
+func DocumentID(kind, refID string) string {
+	return kind + ":" + refID
+}
+
+// Hit is a document matching a query, with a relevance score only
+// comparable within one search
+type Hit struct {
+	Document *Document
+	Score    float64
+}
+
+// Highlight fields
+const (
+	FieldDescription = "description"
+	FieldAttachment  = "attachment"
+)
+
+// Highlight is an HTML-escaped excerpt of a matching field with the matched
+// words wrapped in <mark>
+type Highlight struct {
+	Field   string `json:"field"`
+	FileID  string `json:"fileId,omitempty"`
+	Snippet string `json:"snippet"`
+}
+
+// Result is a todo matching a search
+type Result struct {
+	TodoID      string      `json:"todoId"`
+	Description string      `json:"description"`
+	Score       float64     `json:"score"`
+	Highlights  []Highlight `json:"highlights"`
+}
+
+// Config bounds text extraction
+type Config struct {
+	// MaxFileSize skips files larger than it
+	MaxFileSize int64
+	// MaxTextSize truncates the extracted text of a file, in bytes
+	MaxTextSize int
+}
+
+// TableName keeps search documents apart from other tables
+func (Document) TableName() string {
+	return "search_documents"
+}
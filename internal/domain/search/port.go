@@ -0,0 +1,63 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrUnsupported is returned by extractors for content they can't read
+var ErrUnsupported = errors.New("unsupported content type")
+
+// Service indexes todos and their attachments and searches them
+type Service interface {
+	// Search ranks todos by how well their description and the text of their
+	// attachments match the query
+	Search(ctx context.Context, query string, limit int) ([]*Result, error)
+	HandleTodoChanged(ctx context.Context, data []byte) error
+	HandleTodoDeleted(ctx context.Context, data []byte) error
+	HandleFileScanned(ctx context.Context, data []byte) error
+	HandleFileDeleted(ctx context.Context, data []byte) error
+	// Rebuild loads every stored document into the index
+	Rebuild(ctx context.Context) (int, error)
+}
+
+// DocumentRepository stores the documents the index is built from
+type DocumentRepository interface {
+	// Save creates or replaces a document
+	Save(ctx context.Context, doc *Document) error
+	// Get returns a document, or shared.ErrNotFound
+	Get(ctx context.Context, id string) (*Document, error)
+	Delete(ctx context.Context, id string) error
+	// ListAfter returns up to limit documents with IDs after afterID, in ID
+	// order
+	ListAfter(ctx context.Context, afterID string, limit int) ([]*Document, error)
+}
+
+// Index finds documents by the words they contain
+type Index interface {
+	Put(ctx context.Context, doc *Document) error
+	Delete(ctx context.Context, id string) error
+	// Search returns up to limit documents containing any of terms, best
+	// first
+	Search(ctx context.Context, terms []string, limit int) ([]*Hit, error)
+}
+
+// Extractor pulls plain text out of file content, failing with
+// ErrUnsupported for content types it doesn't read
+type Extractor interface {
+	Extract(ctx context.Context, contentType string, content io.Reader) (string, error)
+}
+
+// Files reads the content of files
+type Files interface {
+	DownloadFile(ctx context.Context, fileID string) (io.ReadCloser, error)
+}
+
+// Todos resolves search matches to todos
+type Todos interface {
+	// AttachedTodos maps each of fileIDs to the todos it is attached to
+	AttachedTodos(ctx context.Context, fileIDs []string) (map[string][]string, error)
+	// ExistingTodos reports which of todoIDs still exist
+	ExistingTodos(ctx context.Context, todoIDs []string) (map[string]bool, error)
+}
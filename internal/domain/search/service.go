@@ -0,0 +1,311 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// descriptionWeight makes a match in a todo's own description count more
+	// than one in its attachments
+	descriptionWeight = 2.0
+	// candidatesPerResult is how many index hits are fetched per requested
+	// result, as several hits may belong to the same todo
+	candidatesPerResult = 5
+	// maxResults is the most results one search returns
+	maxResults = 50
+	// rebuildBatchSize is how many documents are loaded per query while
+	// rebuilding the index
+	rebuildBatchSize = 500
+)
+
+type service struct {
+	repo      DocumentRepository
+	index     Index
+	extractor Extractor
+	files     Files
+	todos     Todos
+	config    Config
+}
+
+// NewService creates the search service. Documents are stored in repo and
+// looked up through index, which may be the same store.
+func NewService(repo DocumentRepository, index Index, extractor Extractor, files Files, todos Todos, config Config) Service {
+	return &service{
+		repo:      repo,
+		index:     index,
+		extractor: extractor,
+		files:     files,
+		todos:     todos,
+		config:    config,
+	}
+}
+
+func (s *service) Search(ctx context.Context, query string, limit int) ([]*Result, error) {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return nil, shared.NewValidationError("query has no searchable words")
+	}
+	if limit <= 0 || limit > maxResults {
+		limit = 10
+	}
+	matching := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		matching[term] = true
+	}
+
+	hits, err := s.index.Search(ctx, terms, limit*candidatesPerResult)
+	if err != nil {
+		return nil, err
+	}
+
+	results := map[string]*Result{}
+	result := func(todoID string) *Result {
+		if results[todoID] == nil {
+			results[todoID] = &Result{TodoID: todoID, Highlights: []Highlight{}}
+		}
+		return results[todoID]
+	}
+	var fileIDs []string
+	fileHits := map[string]*Hit{}
+	for _, hit := range hits {
+		switch hit.Document.Kind {
+		case KindTodo:
+			r := result(hit.Document.RefID)
+			r.Description = hit.Document.Text
+			r.Score += descriptionWeight * hit.Score
+			if snippet, ok := highlight(hit.Document.Text, matching); ok {
+				r.Highlights = append([]Highlight{{Field: FieldDescription, Snippet: snippet}}, r.Highlights...)
+			}
+		case KindFile:
+			fileIDs = append(fileIDs, hit.Document.RefID)
+			fileHits[hit.Document.RefID] = hit
+		}
+	}
+
+	if len(fileIDs) > 0 {
+		attached, err := s.todos.AttachedTodos(ctx, fileIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, fileID := range fileIDs {
+			hit := fileHits[fileID]
+			snippet, highlighted := highlight(hit.Document.Text, matching)
+			for _, todoID := range attached[fileID] {
+				r := result(todoID)
+				r.Score += hit.Score
+				if highlighted {
+					r.Highlights = append(r.Highlights, Highlight{Field: FieldAttachment, FileID: fileID, Snippet: snippet})
+				}
+			}
+		}
+	}
+	if len(results) == 0 {
+		return []*Result{}, nil
+	}
+
+	// Todos deleted together with a file leave their document behind
+	todoIDs := make([]string, 0, len(results))
+	for todoID := range results {
+		todoIDs = append(todoIDs, todoID)
+	}
+	existing, err := s.todos.ExistingTodos(ctx, todoIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make([]*Result, 0, len(results))
+	for _, r := range results {
+		if !existing[r.TodoID] {
+			s.remove(ctx, DocumentID(KindTodo, r.TodoID))
+			continue
+		}
+		ranked = append(ranked, r)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].TodoID < ranked[j].TodoID
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	// Todos found only through an attachment still show their description
+	for _, r := range ranked {
+		if r.Description != "" {
+			continue
+		}
+		doc, err := s.repo.Get(ctx, DocumentID(KindTodo, r.TodoID))
+		if err != nil && !isNotFound(err) {
+			return nil, err
+		}
+		if doc != nil {
+			r.Description = doc.Text
+		}
+	}
+	return ranked, nil
+}
+
+// todoEvent is the part of a todo event the index needs
+type todoEvent struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+// scanClean is the scan status of files found clean
+const scanClean = "clean"
+
+// fileEvent is the part of a file event the index needs
+type fileEvent struct {
+	ID          string `json:"id"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	ScanStatus  string `json:"scanStatus"`
+}
+
+// HandleTodoChanged indexes the description of a created or updated todo
+func (s *service) HandleTodoChanged(ctx context.Context, data []byte) error {
+	var event todoEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		logging.FromContext(ctx).Warn("ignoring malformed todo event", "error", err)
+		return nil
+	}
+	return s.put(ctx, &Document{
+		ID:        DocumentID(KindTodo, event.ID),
+		Kind:      KindTodo,
+		RefID:     event.ID,
+		Text:      event.Description,
+		UpdatedAt: time.Now(),
+	})
+}
+
+func (s *service) HandleTodoDeleted(ctx context.Context, data []byte) error {
+	var event todoEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		logging.FromContext(ctx).Warn("ignoring malformed todo event", "error", err)
+		return nil
+	}
+	return s.remove(ctx, DocumentID(KindTodo, event.ID))
+}
+
+// HandleFileScanned indexes the text of a file once it is scanned clean.
+// Content is only parsed after the scan so extractors never see malware.
+func (s *service) HandleFileScanned(ctx context.Context, data []byte) error {
+	logger := logging.FromContext(ctx)
+
+	var event fileEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		logger.Warn("ignoring malformed file scanned event", "error", err)
+		return nil
+	}
+	id := DocumentID(KindFile, event.ID)
+	if event.ScanStatus != scanClean {
+		return s.remove(ctx, id)
+	}
+	if s.config.MaxFileSize > 0 && event.Size > s.config.MaxFileSize {
+		logger.Info("file too large to index", "file_id", event.ID, "size", event.Size)
+		return s.remove(ctx, id)
+	}
+
+	content, err := s.files.DownloadFile(ctx, event.ID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	text, err := s.extractor.Extract(ctx, event.ContentType, content)
+	content.Close()
+	if errors.Is(err, ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		// Malformed documents won't parse on a retry either
+		logger.Warn("cannot extract file text", "error", err, "file_id", event.ID)
+		return s.remove(ctx, id)
+	}
+
+	if err := s.put(ctx, &Document{
+		ID:        id,
+		Kind:      KindFile,
+		RefID:     event.ID,
+		Text:      truncate(text, s.config.MaxTextSize),
+		UpdatedAt: time.Now(),
+	}); err != nil {
+		return err
+	}
+	logger.Info("file text indexed", "file_id", event.ID, "length", len(text))
+	return nil
+}
+
+func (s *service) HandleFileDeleted(ctx context.Context, data []byte) error {
+	var event fileEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		logging.FromContext(ctx).Warn("ignoring malformed file event", "error", err)
+		return nil
+	}
+	return s.remove(ctx, DocumentID(KindFile, event.ID))
+}
+
+func (s *service) Rebuild(ctx context.Context) (int, error) {
+	loaded := 0
+	afterID := ""
+	for {
+		docs, err := s.repo.ListAfter(ctx, afterID, rebuildBatchSize)
+		if err != nil {
+			return loaded, err
+		}
+		for _, doc := range docs {
+			if err := s.index.Put(ctx, doc); err != nil {
+				return loaded, err
+			}
+			loaded++
+		}
+		if len(docs) < rebuildBatchSize {
+			break
+		}
+		afterID = docs[len(docs)-1].ID
+	}
+	logging.FromContext(ctx).Info("search index rebuilt", "documents", loaded)
+	return loaded, nil
+}
+
+func (s *service) put(ctx context.Context, doc *Document) error {
+	if err := s.repo.Save(ctx, doc); err != nil {
+		return err
+	}
+	return s.index.Put(ctx, doc)
+}
+
+func (s *service) remove(ctx context.Context, id string) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		logging.FromContext(ctx).Error("failed to delete search document", "error", err, "document_id", id)
+		return err
+	}
+	return s.index.Delete(ctx, id)
+}
+
+// truncate cuts text to at most max bytes without splitting a character.
+// Zero keeps all of it.
+func truncate(text string, max int) string {
+	if max <= 0 || len(text) <= max {
+		return text
+	}
+	for max > 0 && !utf8.RuneStart(text[max]) {
+		max--
+	}
+	return text[:max]
+}
+
+func isNotFound(err error) bool {
+	var domainErr *shared.DomainError
+	return errors.Is(err, shared.ErrNotFound) || (errors.As(err, &domainErr) && domainErr.Code == shared.ErrCodeNotFound)
+}
@@ -0,0 +1,153 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// snippetContext is how many bytes of text a highlight shows on each side of
+// the first match
+const snippetContext = 60
+
+// stopWords are too common to be worth indexing
+var stopWords = map[string]bool{
+	"an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "in": true, "is": true, "it": true, "of": true, "on": true,
+	"or": true, "that": true, "the": true, "this": true, "to": true, "was": true, "with": true,
+}
+
+// word is an indexed word of a text and where it is
+type word struct {
+	start, end int
+	term       string
+}
+
+// words splits text into lowercased runs of letters and digits, dropping
+// stop words and single characters
+func words(text string) []word {
+	var found []word
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			found = appendWord(found, text, start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		found = appendWord(found, text, start, len(text))
+	}
+	return found
+}
+
+func appendWord(found []word, text string, start, end int) []word {
+	term := strings.ToLower(text[start:end])
+	if utf8.RuneCountInString(term) < 2 || stopWords[term] {
+		return found
+	}
+	return append(found, word{start: start, end: end, term: term})
+}
+
+// Tokenize returns the indexed words of text, in order and repeated as often
+// as they occur. Indexes use it so documents and queries agree on words.
+func Tokenize(text string) []string {
+	found := words(text)
+	terms := make([]string, len(found))
+	for i, w := range found {
+		terms[i] = w.term
+	}
+	return terms
+}
+
+// Terms returns the distinct indexed words of a query
+func Terms(query string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, term := range Tokenize(query) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// highlight returns an excerpt of text around its first word in terms, with
+// every matching word in it marked, or false if no word matches
+func highlight(text string, terms map[string]bool) (string, bool) {
+	found := words(text)
+	first := -1
+	for i, w := range found {
+		if terms[w.term] {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	// Widen the excerpt to whole words around the match
+	from := max(found[first].start-snippetContext, 0)
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from++
+	}
+	if from > 0 {
+		if space := strings.IndexFunc(text[from:found[first].start], unicode.IsSpace); space >= 0 {
+			from += space + 1
+		}
+	}
+	to := min(found[first].end+snippetContext, len(text))
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to--
+	}
+	if to < len(text) {
+		if space := strings.LastIndexFunc(text[found[first].end:to], unicode.IsSpace); space >= 0 {
+			to = found[first].end + space
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("… ")
+	}
+	pos := from
+	for _, w := range found[first:] {
+		if w.end > to {
+			break
+		}
+		if !terms[w.term] {
+			continue
+		}
+		b.WriteString(escape(text[pos:w.start]))
+		b.WriteString("<mark>" + html.EscapeString(text[w.start:w.end]) + "</mark>")
+		pos = w.end
+	}
+	b.WriteString(escape(text[pos:to]))
+	if to < len(text) {
+		b.WriteString(" …")
+	}
+	return b.String(), true
+}
+
+// escape HTML-escapes text for a snippet, collapsing runs of whitespace
+func escape(text string) string {
+	collapsed := strings.Join(strings.Fields(text), " ")
+	if collapsed != "" && unicode.IsSpace(rune(text[0])) {
+		collapsed = " " + collapsed
+	}
+	if collapsed != "" && unicode.IsSpace(rune(text[len(text)-1])) {
+		collapsed += " "
+	}
+	if collapsed == "" && text != "" {
+		collapsed = " "
+	}
+	return html.EscapeString(collapsed)
+}
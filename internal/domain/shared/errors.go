@@ -36,14 +36,19 @@ func NewDomainError(code, message, details string) *DomainError {
 
 // Common error codes
 const (
-	ErrCodeNotFound     = "NOT_FOUND"
-	ErrCodeInvalidInput = "INVALID_INPUT"
-	ErrCodeUnauthorized = "UNAUTHORIZED"
-	ErrCodeForbidden    = "FORBIDDEN"
-	ErrCodeConflict     = "CONFLICT"
-	ErrCodeInternal     = "INTERNAL_ERROR"
-	ErrCodeValidation   = "VALIDATION_FAILED"
-	ErrCodeTimeout      = "TIMEOUT"
+	ErrCodeNotFound      = "NOT_FOUND"
+	ErrCodeInvalidInput  = "INVALID_INPUT"
+	ErrCodeUnauthorized  = "UNAUTHORIZED"
+	ErrCodeForbidden     = "FORBIDDEN"
+	ErrCodeConflict      = "CONFLICT"
+	ErrCodeInternal      = "INTERNAL_ERROR"
+	ErrCodeValidation    = "VALIDATION_FAILED"
+	ErrCodeTimeout       = "TIMEOUT"
+	ErrCodeTooLarge      = "PAYLOAD_TOO_LARGE"
+	ErrCodeUnsupported   = "UNSUPPORTED_MEDIA_TYPE"
+	ErrCodeGone          = "GONE"
+	ErrCodeLocked        = "LOCKED"
+	ErrCodeQuotaExceeded = "QUOTA_EXCEEDED"
 )
 
 // Helper functions for common errors
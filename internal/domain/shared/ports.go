@@ -3,6 +3,7 @@ package shared
 import (
 	"context"
 	"io"
+	"time"
 )
 
 // Cache defines the interface for caching operations
@@ -17,12 +18,139 @@ type Cache interface {
 type Storage interface {
 	Upload(ctx context.Context, filename string, content io.Reader, contentType string) (string, error)
 	Download(ctx context.Context, fileID string) (io.ReadCloser, error)
+	// DownloadRange reads length bytes starting at offset
+	DownloadRange(ctx context.Context, fileID string, offset, length int64) (io.ReadCloser, error)
 	Delete(ctx context.Context, fileID string) error
 	GetURL(ctx context.Context, fileID string) (string, error)
 }
 
+// ObjectInfo describes an object held in storage
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	ContentType  string
+	LastModified time.Time
+}
+
+// PresignedUpload describes a request a client can send directly to storage.
+// For PUT uploads Headers must be sent as-is; for POST uploads Fields must be
+// sent as form fields before the file.
+type PresignedUpload struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// ListableStorage is storage whose objects can be enumerated
+type ListableStorage interface {
+	Storage
+	// ListObjects calls fn for every stored object, stopping at the first
+	// error fn returns
+	ListObjects(ctx context.Context, fn func(ObjectInfo) error) error
+}
+
+// DirectUploadStorage is implemented by storage adapters that can let clients
+// upload straight to the backing store instead of through the API
+type DirectUploadStorage interface {
+	Storage
+	PresignPut(ctx context.Context, key string, contentType string, expires time.Duration) (*PresignedUpload, error)
+	PresignPost(ctx context.Context, key string, contentType string, maxSize int64, expires time.Duration) (*PresignedUpload, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+}
+
+// UploadedPart is a part of a multipart upload already held by storage
+type UploadedPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// MultipartStorage is implemented by storage adapters that can assemble an
+// object from separately uploaded parts. Every part except the last must be
+// at least MinPartSize bytes.
+type MultipartStorage interface {
+	Storage
+	MinPartSize() int64
+	CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error)
+	UploadPart(ctx context.Context, key string, uploadID string, number int, content io.ReadSeeker) (string, error)
+	CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []UploadedPart) error
+	AbortMultipartUpload(ctx context.Context, key string, uploadID string) error
+	// Put stores content under an exact key
+	Put(ctx context.Context, key string, content io.ReadSeeker, contentType string) error
+}
+
+// EncryptedStorage is storage that encrypts every object with its own data
+// key, wrapped by a master key
+type EncryptedStorage interface {
+	Storage
+	// CurrentKeyID is the master key new data keys are wrapped with
+	CurrentKeyID() string
+	// KeyID returns the master key wrapping the data key of an object, or ""
+	// if the object was stored unencrypted
+	KeyID(ctx context.Context, key string) (string, error)
+	// Rewrap wraps the data key of an object with the current master key and
+	// returns its ID. The object itself is not rewritten.
+	Rewrap(ctx context.Context, key string) (string, error)
+}
+
+// DataKey is the wrapped data key an encrypted object was sealed with
+type DataKey struct {
+	StorageKey string `db:"storage_key" gorm:"primaryKey;size:255"`
+	KeyID      string `db:"key_id" gorm:"size:64;index"`
+	WrappedKey []byte `db:"wrapped_key" gorm:"type:varbinary(255)"`
+	// Size is the plaintext size, checked when reading so a truncated object
+	// can't pass for a shorter one
+	Size      int64     `db:"size"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// DataKeyRepository stores the data keys of encrypted objects
+type DataKeyRepository interface {
+	// Save creates or replaces the data key of an object
+	Save(ctx context.Context, key *DataKey) error
+	// Get returns ErrNotFound for objects without a data key
+	Get(ctx context.Context, storageKey string) (*DataKey, error)
+	Delete(ctx context.Context, storageKey string) error
+}
+
+// KeyManager wraps data keys with master keys it never hands out
+type KeyManager interface {
+	// CurrentKeyID is the master key Wrap uses
+	CurrentKeyID() string
+	Wrap(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// ScanResult is the verdict of a malware scan
+type ScanResult struct {
+	Infected bool
+	// Signature names the detected malware when Infected is true
+	Signature string
+}
+
+// MalwareScanner defines the interface for scanning content for malware
+type MalwareScanner interface {
+	Scan(ctx context.Context, content io.Reader) (*ScanResult, error)
+}
+
 // Messaging defines the interface for message publishing
 type Messaging interface {
 	Publish(ctx context.Context, topic string, message interface{}) error
 	PublishWithKey(ctx context.Context, topic string, key string, message interface{}) error
 }
+
+// MessageHandler processes one consumed message. data is the JSON published
+// with the message. Returning an error leaves the message unacknowledged.
+type MessageHandler func(ctx context.Context, data []byte) error
+
+// Subscriber defines the interface for consuming published messages
+type Subscriber interface {
+	// Subscribe delivers messages on topic to handler as a member of the
+	// consumer group, so each message is handled once per group. It blocks
+	// until ctx is cancelled.
+	Subscribe(ctx context.Context, topic string, group string, handler MessageHandler) error
+}
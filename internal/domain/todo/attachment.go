@@ -0,0 +1,180 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxAttachments is the most files that can be attached to one todo
+const maxAttachments = 50
+
+// Attachment links a file to a todo
+type Attachment struct {
+	TodoID    uuid.UUID `json:"todoId" db:"todo_id" gorm:"type:char(36);primaryKey"`
+	FileID    string    `json:"fileId" db:"file_id" gorm:"type:char(36);primaryKey;index"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// AttachRequest represents the request to attach a file to a todo
+type AttachRequest struct {
+	FileID string `json:"fileId" binding:"required,uuid"`
+}
+
+// FileDeletePolicy decides what happens to todos when a file attached to them
+// is deleted
+type FileDeletePolicy string
+
+const (
+	// FileDeleteBlock refuses to delete files that are attached to todos
+	FileDeleteBlock FileDeletePolicy = "block"
+	// FileDeleteDetach removes the attachments and keeps the todos
+	FileDeleteDetach FileDeletePolicy = "detach"
+	// FileDeleteCascade deletes the todos along with the file
+	FileDeleteCascade FileDeletePolicy = "cascade"
+)
+
+// ParseFileDeletePolicy validates a policy name
+func ParseFileDeletePolicy(name string) (FileDeletePolicy, error) {
+	switch policy := FileDeletePolicy(name); policy {
+	case FileDeleteBlock, FileDeleteDetach, FileDeleteCascade:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid file delete policy: %s", name)
+}
+
+type fileReferences struct {
+	todos       *todoService
+	todoRepo    Repository
+	attachments AttachmentRepository
+	policy      FileDeletePolicy
+}
+
+// NewFileReferences returns the FileReferences that apply policy to the todos
+// a file is attached to when the file is deleted. Todos deleted with a file
+// are deleted like DeleteTodo does, so deps are those of the todo service;
+// Files isn't needed.
+func NewFileReferences(deps Deps, policy FileDeletePolicy) FileReferences {
+	return &fileReferences{
+		todos:       newTodoService(deps),
+		todoRepo:    deps.Todos,
+		attachments: deps.Attachments,
+		policy:      policy,
+	}
+}
+
+func (r *fileReferences) ReleaseFile(ctx context.Context, fileID string) error {
+	attached, err := r.attachments.ListByFile(ctx, fileID)
+	if err != nil || len(attached) == 0 {
+		return err
+	}
+
+	logger := logging.FromContext(ctx)
+	switch r.policy {
+	case FileDeleteDetach:
+		if err := r.attachments.DeleteByFile(ctx, fileID); err != nil {
+			return err
+		}
+		logger.Info("file detached from todos", "file_id", fileID, "count", len(attached))
+	case FileDeleteCascade:
+		if err := r.cascade(ctx, attached); err != nil {
+			return err
+		}
+		logger.Info("todos deleted with their attached file", "file_id", fileID, "count", len(attached))
+	default:
+		return shared.NewDomainError(shared.ErrCodeConflict,
+			fmt.Sprintf("File is attached to %d todo(s)", len(attached)),
+			"detach it from every todo before deleting it")
+	}
+	return nil
+}
+
+// cascade deletes the todos a file is attached to, subtasks before their
+// parents. It refuses before deleting any of them when one has subtasks the
+// file isn't attached to.
+func (r *fileReferences) cascade(ctx context.Context, attached []*Attachment) error {
+	ids := make([]uuid.UUID, len(attached))
+	pending := make(map[uuid.UUID]bool, len(attached))
+	for i, attachment := range attached {
+		ids[i] = attachment.TodoID
+		pending[attachment.TodoID] = true
+	}
+	children, err := r.todoRepo.ListChildren(ctx, ids)
+	if err != nil {
+		return err
+	}
+	waiting := make(map[uuid.UUID]int)
+	for _, child := range children {
+		if !pending[child.ID] {
+			return errHasSubtasks
+		}
+		waiting[*child.ParentID]++
+	}
+
+	for len(pending) > 0 {
+		for id := range pending {
+			if waiting[id] > 0 {
+				continue
+			}
+			todo, err := r.todoRepo.GetByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			if err := r.todos.deleteTodo(ctx, todo); err != nil {
+				return err
+			}
+			delete(pending, id)
+			if todo.ParentID != nil {
+				waiting[*todo.ParentID]--
+			}
+		}
+	}
+	return nil
+}
+
+func (r *fileReferences) AttachedFiles(ctx context.Context, fileIDs []string) (map[string]bool, error) {
+	attachments, err := r.attachments.ListByFiles(ctx, fileIDs)
+	if err != nil {
+		return nil, err
+	}
+	attached := make(map[string]bool, len(attachments))
+	for _, attachment := range attachments {
+		attached[attachment.FileID] = true
+	}
+	return attached, nil
+}
+
+// ReferencedFiles returns the files attached to the given todos, in the
+// order of the todos and of their attachments. Unknown todos are not found.
+func (r *fileReferences) ReferencedFiles(ctx context.Context, todoIDs []string) ([]string, error) {
+	ids := make([]uuid.UUID, 0, len(todoIDs))
+	for _, raw := range todoIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, shared.NewValidationError("invalid todo ID: " + raw)
+		}
+		if _, err := r.todoRepo.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	attachments, err := r.attachments.ListByTodos(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byTodo := map[uuid.UUID][]string{}
+	for _, attachment := range attachments {
+		byTodo[attachment.TodoID] = append(byTodo[attachment.TodoID], attachment.FileID)
+	}
+	var fileIDs []string
+	for _, id := range ids {
+		fileIDs = append(fileIDs, byTodo[id]...)
+		delete(byTodo, id)
+	}
+	return fileIDs, nil
+}
@@ -0,0 +1,139 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxChecklistItems is the most checklist items one todo can have
+	maxChecklistItems = 100
+	// maxChecklistText is the longest checklist item, in characters
+	maxChecklistText = 500
+)
+
+// ChecklistItem is a step of a todo too small to be a subtask
+type ChecklistItem struct {
+	ID        uuid.UUID `json:"id" db:"id" gorm:"type:char(36);primaryKey"`
+	TodoID    uuid.UUID `json:"todoId" db:"todo_id" gorm:"type:char(36);index:idx_checklist_position"`
+	Text      string    `json:"text" db:"text" gorm:"size:500"`
+	Done      bool      `json:"done" db:"done"`
+	Position  int       `json:"position" db:"position" gorm:"index:idx_checklist_position"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// ChecklistItemRequest adds an item to a checklist
+type ChecklistItemRequest struct {
+	Text string `json:"text" binding:"required"`
+}
+
+// UpdateChecklistItemRequest changes a checklist item
+type UpdateChecklistItemRequest struct {
+	Text *string `json:"text,omitempty"`
+	Done *bool   `json:"done,omitempty"`
+}
+
+// errChecklistsDisabled is returned for checklists when the service has no
+// checklist repository
+var errChecklistsDisabled = shared.NewDomainError(shared.ErrCodeInvalidInput, "Checklists are not supported", "")
+
+func checkChecklistText(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", shared.NewValidationError("checklist items must have text")
+	}
+	if utf8.RuneCountInString(text) > maxChecklistText {
+		return "", shared.NewValidationError(fmt.Sprintf("checklist items must be at most %d characters", maxChecklistText))
+	}
+	return text, nil
+}
+
+func (s *todoService) AddChecklistItem(ctx context.Context, id uuid.UUID, req *ChecklistItemRequest) (*ChecklistItem, error) {
+	if s.checklists == nil {
+		return nil, errChecklistsDisabled
+	}
+	if _, err := s.todoRepo.GetByID(ctx, id); err != nil {
+		return nil, fmt.Errorf("todo not found: %w", err)
+	}
+	text, err := checkChecklistText(req.Text)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.checklists.ListByTodos(ctx, []uuid.UUID{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(items) >= maxChecklistItems {
+		return nil, shared.NewValidationError(fmt.Sprintf("at most %d checklist items can be added", maxChecklistItems))
+	}
+
+	position := 0
+	if len(items) > 0 {
+		position = items[len(items)-1].Position + 1
+	}
+	now := time.Now()
+	item := &ChecklistItem{ID: uuid.New(), TodoID: id, Text: text, Position: position, CreatedAt: now, UpdatedAt: now}
+	if err := s.checklists.Create(ctx, item); err != nil {
+		return nil, fmt.Errorf("failed to add checklist item: %w", err)
+	}
+
+	logging.FromContext(ctx).Info("checklist item added", "todo_id", id, "item_id", item.ID)
+	return item, nil
+}
+
+func (s *todoService) UpdateChecklistItem(ctx context.Context, id, itemID uuid.UUID, req *UpdateChecklistItemRequest) (*ChecklistItem, error) {
+	item, err := s.getChecklistItem(ctx, id, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Text != nil {
+		if item.Text, err = checkChecklistText(*req.Text); err != nil {
+			return nil, err
+		}
+	}
+	if req.Done != nil {
+		item.Done = *req.Done
+	}
+	item.UpdatedAt = time.Now()
+
+	if err := s.checklists.Update(ctx, item); err != nil {
+		return nil, fmt.Errorf("failed to update checklist item: %w", err)
+	}
+	return item, nil
+}
+
+func (s *todoService) DeleteChecklistItem(ctx context.Context, id, itemID uuid.UUID) error {
+	if _, err := s.getChecklistItem(ctx, id, itemID); err != nil {
+		return err
+	}
+	if err := s.checklists.Delete(ctx, itemID); err != nil {
+		return fmt.Errorf("failed to delete checklist item: %w", err)
+	}
+
+	logging.FromContext(ctx).Info("checklist item deleted", "todo_id", id, "item_id", itemID)
+	return nil
+}
+
+// getChecklistItem returns an item of a todo's checklist. Items of other
+// todos are not found.
+func (s *todoService) getChecklistItem(ctx context.Context, id, itemID uuid.UUID) (*ChecklistItem, error) {
+	if s.checklists == nil {
+		return nil, errChecklistsDisabled
+	}
+	item, err := s.checklists.Get(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item.TodoID != id {
+		return nil, shared.NewNotFoundError("checklist item not found")
+	}
+	return item, nil
+}
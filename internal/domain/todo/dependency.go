@@ -0,0 +1,339 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxPlanTodos is the most todos that can be planned at once
+const maxPlanTodos = 200
+
+// Dependency records that a todo can't start until another is done
+type Dependency struct {
+	TodoID      uuid.UUID `json:"todoId" db:"todo_id" gorm:"type:char(36);primaryKey"`
+	BlockedByID uuid.UUID `json:"blockedById" db:"blocked_by_id" gorm:"type:char(36);primaryKey;index"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
+
+// DependencyRequest makes a todo wait for another
+type DependencyRequest struct {
+	BlockedBy string `json:"blockedBy" binding:"required,uuid"`
+}
+
+// DueDateWarning flags a todo that is due before a todo blocking it
+type DueDateWarning struct {
+	TodoID         uuid.UUID `json:"todoId"`
+	DueDate        time.Time `json:"dueDate"`
+	BlockedByID    uuid.UUID `json:"blockedById"`
+	BlockerDueDate time.Time `json:"blockerDueDate"`
+	Message        string    `json:"message"`
+}
+
+// DependencyGraph is every todo a todo waits for or holds up, directly or
+// through others
+type DependencyGraph struct {
+	TodoID uuid.UUID `json:"todoId"`
+	// BlockedBy and Blocks are the todo's direct dependencies
+	BlockedBy []uuid.UUID `json:"blockedBy"`
+	Blocks    []uuid.UUID `json:"blocks"`
+	// Blocked is whether a todo it waits for isn't done
+	Blocked  bool             `json:"blocked"`
+	Todos    []*TodoItem      `json:"todos"`
+	Edges    []*Dependency    `json:"edges"`
+	Warnings []DueDateWarning `json:"warnings"`
+}
+
+// PlanRequest names the todos to plan
+type PlanRequest struct {
+	TodoIDs []string `json:"todoIds" binding:"required,min=1,dive,uuid"`
+}
+
+// Plan orders a set of todos by their dependencies on each other
+type Plan struct {
+	// Order lists the todos so that each comes after the todos blocking it,
+	// earliest due first where dependencies allow
+	Order []uuid.UUID `json:"order"`
+	// CriticalPath is the longest chain of todos each blocking the next
+	CriticalPath []uuid.UUID      `json:"criticalPath"`
+	Warnings     []DueDateWarning `json:"warnings"`
+}
+
+// errDependenciesDisabled is returned for dependencies when the service has
+// no dependency repository
+var errDependenciesDisabled = shared.NewDomainError(shared.ErrCodeInvalidInput, "Dependencies are not supported", "")
+
+// errPlanCycle is returned for a plan whose todos wait for each other in a
+// cycle, which only dependencies recorded around AddDependency can form
+var errPlanCycle = shared.NewDomainError(shared.ErrCodeConflict, "Dependencies between the planned todos form a cycle", "")
+
+// errBlocked is returned when a todo would start while a todo it waits for
+// isn't done
+var errBlocked = shared.NewDomainError(shared.ErrCodeConflict, "Todo is blocked by todos that aren't done", "")
+
+func dueDateWarning(todo, blocker *TodoItem) (DueDateWarning, bool) {
+	if !todo.DueDate.Before(blocker.DueDate) {
+		return DueDateWarning{}, false
+	}
+	return DueDateWarning{
+		TodoID:         todo.ID,
+		DueDate:        todo.DueDate,
+		BlockedByID:    blocker.ID,
+		BlockerDueDate: blocker.DueDate,
+		Message:        fmt.Sprintf("todo is due before %s, which blocks it", blocker.ID),
+	}, true
+}
+
+// checkUnblocked rejects starting a todo while a todo it waits for isn't done
+func (s *todoService) checkUnblocked(ctx context.Context, todo *TodoItem) error {
+	if s.dependencies == nil {
+		return nil
+	}
+	dependencies, err := s.dependencies.ListBlockers(ctx, []uuid.UUID{todo.ID})
+	if err != nil {
+		return err
+	}
+	for _, dependency := range dependencies {
+		blocker, err := s.todoRepo.GetByID(ctx, dependency.BlockedByID)
+		if err != nil {
+			return err
+		}
+		if blocker.Status != StatusDone {
+			return errBlocked
+		}
+	}
+	return nil
+}
+
+func (s *todoService) AddDependency(ctx context.Context, id uuid.UUID, req *DependencyRequest) (*Dependency, []DueDateWarning, error) {
+	if s.dependencies == nil {
+		return nil, nil, errDependenciesDisabled
+	}
+	todo, err := s.todoRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("todo not found: %w", err)
+	}
+	blockerID, err := uuid.Parse(req.BlockedBy)
+	if err != nil {
+		return nil, nil, shared.NewValidationError("blockedBy must be a UUID")
+	}
+	blocker, err := s.todoRepo.GetByID(ctx, blockerID)
+	var domainErr *shared.DomainError
+	if errors.Is(err, shared.ErrNotFound) || (errors.As(err, &domainErr) && domainErr.Code == shared.ErrCodeNotFound) {
+		return nil, nil, shared.NewValidationError("blockedBy does not refer to an existing todo")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if blockerID == id {
+		return nil, nil, shared.NewValidationError("a todo can't block itself")
+	}
+	dependency := &Dependency{TodoID: id, BlockedByID: blockerID, CreatedAt: time.Now()}
+	if err := s.dependencies.Add(ctx, dependency); err != nil {
+		if errors.Is(err, ErrDependencyCycle) {
+			return nil, nil, shared.NewValidationError(fmt.Sprintf("dependency would create a cycle: %s already waits for %s", blockerID, id))
+		}
+		return nil, nil, fmt.Errorf("failed to add dependency: %w", err)
+	}
+
+	warnings := []DueDateWarning{}
+	if warning, ok := dueDateWarning(todo, blocker); ok {
+		warnings = append(warnings, warning)
+	}
+	logging.FromContext(ctx).Info("dependency added", "todo_id", id, "blocked_by_id", blockerID)
+	return dependency, warnings, nil
+}
+
+func (s *todoService) RemoveDependency(ctx context.Context, id, blockerID uuid.UUID) error {
+	if s.dependencies == nil {
+		return errDependenciesDisabled
+	}
+	if _, err := s.todoRepo.GetByID(ctx, id); err != nil {
+		return fmt.Errorf("todo not found: %w", err)
+	}
+	if err := s.dependencies.Remove(ctx, id, blockerID); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("dependency removed", "todo_id", id, "blocked_by_id", blockerID)
+	return nil
+}
+
+func (s *todoService) GetDependencies(ctx context.Context, id uuid.UUID) (*DependencyGraph, error) {
+	if s.dependencies == nil {
+		return nil, errDependenciesDisabled
+	}
+	root, err := s.todoRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("todo not found: %w", err)
+	}
+
+	graph := &DependencyGraph{TodoID: id, BlockedBy: []uuid.UUID{}, Blocks: []uuid.UUID{}, Edges: []*Dependency{}, Warnings: []DueDateWarning{}}
+	todos := map[uuid.UUID]*TodoItem{id: root}
+	edges := map[Dependency]bool{}
+
+	// Walk up to everything the todo waits for, then down to everything it
+	// holds up
+	walks := []struct {
+		list func(ctx context.Context, ids []uuid.UUID) ([]*Dependency, error)
+		next func(dependency *Dependency) uuid.UUID
+	}{
+		{s.dependencies.ListBlockers, func(d *Dependency) uuid.UUID { return d.BlockedByID }},
+		{s.dependencies.ListBlocked, func(d *Dependency) uuid.UUID { return d.TodoID }},
+	}
+	for _, walk := range walks {
+		seen := map[uuid.UUID]bool{id: true}
+		for level := []uuid.UUID{id}; len(level) > 0; {
+			dependencies, err := walk.list(ctx, level)
+			if err != nil {
+				return nil, err
+			}
+			level = nil
+			for _, dependency := range dependencies {
+				if key := (Dependency{TodoID: dependency.TodoID, BlockedByID: dependency.BlockedByID}); !edges[key] {
+					edges[key] = true
+					graph.Edges = append(graph.Edges, dependency)
+				}
+				if next := walk.next(dependency); !seen[next] {
+					seen[next] = true
+					level = append(level, next)
+				}
+			}
+		}
+	}
+
+	for _, edge := range graph.Edges {
+		for _, todoID := range []uuid.UUID{edge.TodoID, edge.BlockedByID} {
+			if _, ok := todos[todoID]; !ok {
+				if todos[todoID], err = s.todoRepo.GetByID(ctx, todoID); err != nil {
+					return nil, fmt.Errorf("failed to load todo %s: %w", todoID, err)
+				}
+			}
+		}
+		if edge.TodoID == id {
+			graph.BlockedBy = append(graph.BlockedBy, edge.BlockedByID)
+			graph.Blocked = graph.Blocked || todos[edge.BlockedByID].Status != StatusDone
+		}
+		if edge.BlockedByID == id {
+			graph.Blocks = append(graph.Blocks, edge.TodoID)
+		}
+		if warning, ok := dueDateWarning(todos[edge.TodoID], todos[edge.BlockedByID]); ok {
+			graph.Warnings = append(graph.Warnings, warning)
+		}
+	}
+
+	for _, todo := range todos {
+		graph.Todos = append(graph.Todos, todo)
+	}
+	sortByDueDate(graph.Todos)
+	return graph, nil
+}
+
+func (s *todoService) PlanTodos(ctx context.Context, req *PlanRequest) (*Plan, error) {
+	if s.dependencies == nil {
+		return nil, errDependenciesDisabled
+	}
+	ids := uniqueStrings(req.TodoIDs)
+	if len(ids) > maxPlanTodos {
+		return nil, shared.NewValidationError(fmt.Sprintf("at most %d todos can be planned at once", maxPlanTodos))
+	}
+
+	todos := make(map[uuid.UUID]*TodoItem, len(ids))
+	var list []*TodoItem
+	var todoIDs []uuid.UUID
+	for _, raw := range ids {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, shared.NewValidationError("todoIds must be UUIDs")
+		}
+		todo, err := s.todoRepo.GetByID(ctx, id)
+		var domainErr *shared.DomainError
+		if errors.Is(err, shared.ErrNotFound) || (errors.As(err, &domainErr) && domainErr.Code == shared.ErrCodeNotFound) {
+			return nil, shared.NewValidationError(fmt.Sprintf("todo %s does not exist", raw))
+		}
+		if err != nil {
+			return nil, err
+		}
+		todos[id] = todo
+		list = append(list, todo)
+		todoIDs = append(todoIDs, id)
+	}
+	sortByDueDate(list)
+
+	// Only dependencies between the planned todos count
+	dependencies, err := s.dependencies.ListBlockers(ctx, todoIDs)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{Order: []uuid.UUID{}, CriticalPath: []uuid.UUID{}, Warnings: []DueDateWarning{}}
+	waiting := make(map[uuid.UUID]int)
+	blocks := make(map[uuid.UUID][]uuid.UUID)
+	for _, dependency := range dependencies {
+		if _, ok := todos[dependency.BlockedByID]; !ok {
+			continue
+		}
+		waiting[dependency.TodoID]++
+		blocks[dependency.BlockedByID] = append(blocks[dependency.BlockedByID], dependency.TodoID)
+		if warning, ok := dueDateWarning(todos[dependency.TodoID], todos[dependency.BlockedByID]); ok {
+			plan.Warnings = append(plan.Warnings, warning)
+		}
+	}
+
+	// Kahn's algorithm, taking the earliest due of the todos that are ready.
+	// chain counts the todos on the longest chain ending at each todo.
+	chain := make(map[uuid.UUID]int)
+	previous := make(map[uuid.UUID]uuid.UUID)
+	var ready []*TodoItem
+	for _, todo := range list {
+		if waiting[todo.ID] == 0 {
+			ready = append(ready, todo)
+		}
+	}
+	for len(ready) > 0 {
+		sortByDueDate(ready)
+		todo := ready[0]
+		ready = ready[1:]
+		plan.Order = append(plan.Order, todo.ID)
+		chain[todo.ID]++
+		for _, blockedID := range blocks[todo.ID] {
+			if chain[todo.ID] > chain[blockedID] {
+				chain[blockedID] = chain[todo.ID]
+				previous[blockedID] = todo.ID
+			}
+			if waiting[blockedID]--; waiting[blockedID] == 0 {
+				ready = append(ready, todos[blockedID])
+			}
+		}
+	}
+	if len(plan.Order) != len(list) {
+		return nil, errPlanCycle
+	}
+
+	var last uuid.UUID
+	for _, id := range plan.Order {
+		if chain[id] > chain[last] {
+			last = id
+		}
+	}
+	for id, ok := last, true; ok; id, ok = previous[id] {
+		plan.CriticalPath = append([]uuid.UUID{id}, plan.CriticalPath...)
+	}
+	return plan, nil
+}
+
+// sortByDueDate sorts todos earliest due first, then oldest first
+func sortByDueDate(todos []*TodoItem) {
+	sort.SliceStable(todos, func(i, j int) bool {
+		if !todos[i].DueDate.Equal(todos[j].DueDate) {
+			return todos[i].DueDate.Before(todos[j].DueDate)
+		}
+		return todos[i].CreatedAt.Before(todos[j].CreatedAt)
+	})
+}
@@ -0,0 +1,44 @@
+package todo
+
+import (
+	"context"
+	"taskflow/pkg/logging"
+)
+
+// Todo topics are published with the TodoItem
+const (
+	TopicTodoCreated = "todo.created"
+	TopicTodoUpdated = "todo.updated"
+	TopicTodoDeleted = "todo.deleted"
+)
+
+// TopicTodoAssigned is published with an AssignedEvent when a user is
+// assigned to a todo
+const TopicTodoAssigned = "todo.assigned"
+
+// AssignedEvent tells who was assigned to a todo, and by whom. The todo
+// carries its other assignees and its watchers.
+type AssignedEvent struct {
+	Todo       *TodoItem `json:"todo"`
+	Assignee   string    `json:"assignee"`
+	AssignedBy string    `json:"assignedBy,omitempty"`
+}
+
+// publish announces a change to a todo, keeping the trace but not the
+// request deadline
+func publish(ctx context.Context, messaging Messaging, topic string, todo *TodoItem) {
+	publishEvent(ctx, messaging, topic, todo, todo)
+}
+
+// publishEvent announces a change to a todo with any message
+func publishEvent(ctx context.Context, messaging Messaging, topic string, todo *TodoItem, message interface{}) {
+	logger := logging.FromContext(ctx)
+	publishCtx := context.WithoutCancel(ctx)
+	go func() {
+		if err := messaging.Publish(publishCtx, topic, message); err != nil {
+			logger.Error("failed to publish todo event", "error", err, "topic", topic, "todo_id", todo.ID)
+		} else {
+			logger.Info("todo event published", "topic", topic, "todo_id", todo.ID)
+		}
+	}()
+}
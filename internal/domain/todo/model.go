@@ -1,6 +1,7 @@
 package todo
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,19 +11,115 @@ type TodoItem struct {
 	ID          uuid.UUID `json:"id" db:"id"`
 	Description string    `json:"description" db:"description"`
 	DueDate     time.Time `json:"dueDate" db:"due_date"`
-	FileID      *string   `json:"fileId,omitempty" db:"file_id"`
-	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+	Priority    Priority  `json:"priority" db:"priority" gorm:"size:10;default:medium;index"`
+	Status      Status    `json:"status" db:"status" gorm:"size:20;default:open;index;index:idx_todo_rank"`
+	// Rank orders the todos of a status column
+	Rank string `json:"rank,omitempty" db:"rank" gorm:"size:64;index:idx_todo_rank"`
+	// ParentID is the todo this is a subtask of
+	ParentID *uuid.UUID `json:"parentId,omitempty" db:"parent_id" gorm:"type:char(36);index"`
+	// ProjectID is the project the todo is in, the same as its parent's
+	ProjectID   *uuid.UUID `json:"projectId,omitempty" db:"project_id" gorm:"type:char(36);index"`
+	CompletedAt *time.Time `json:"completedAt,omitempty" db:"completed_at"`
+	// RecurrenceID is the series the todo is an occurrence of, and
+	// Occurrence its number in the series
+	RecurrenceID *uuid.UUID       `json:"recurrenceId,omitempty" db:"recurrence_id" gorm:"type:char(36);index"`
+	Occurrence   int              `json:"occurrence,omitempty" db:"occurrence"`
+	FileIDs      []string         `json:"fileIds,omitempty" gorm:"-"`
+	Tags         []string         `json:"tags,omitempty" gorm:"-"`
+	Assignees    []string         `json:"assignees,omitempty" gorm:"-"`
+	Watchers     []string         `json:"watchers,omitempty" gorm:"-"`
+	Checklist    []*ChecklistItem `json:"checklist,omitempty" gorm:"-"`
+	Progress     *Progress        `json:"progress,omitempty" gorm:"-"`
+	Recurrence   *Recurrence      `json:"recurrence,omitempty" gorm:"-"`
+	CreatedAt    time.Time        `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time        `json:"updatedAt" db:"updated_at"`
+}
+
+// Status is where a todo is in its life
+type Status string
+
+const (
+	StatusOpen       Status = "open"
+	StatusInProgress Status = "in_progress"
+	StatusDone       Status = "done"
+)
+
+// Priority ranks how urgent a todo is
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+	PriorityUrgent Priority = "urgent"
+)
+
+// ParsePriority validates a priority name
+func ParsePriority(name string) (Priority, error) {
+	switch priority := Priority(name); priority {
+	case PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent:
+		return priority, nil
+	}
+	return "", fmt.Errorf("invalid priority: %s", name)
 }
 
 type CreateTodoRequest struct {
 	Description string    `json:"description" binding:"required"`
 	DueDate     time.Time `json:"dueDate" binding:"required"`
-	FileID      *string   `json:"fileId,omitempty"`
+	// Priority defaults to medium
+	Priority Priority `json:"priority,omitempty" binding:"omitempty,oneof=low medium high urgent"`
+	// ParentID makes the todo a subtask
+	ParentID *string `json:"parentId,omitempty" binding:"omitempty,uuid"`
+	// ProjectID puts the todo in a project; subtasks go in their parent's
+	ProjectID *string  `json:"projectId,omitempty" binding:"omitempty,uuid"`
+	Tags      []string `json:"tags,omitempty"`
+	FileIDs   []string `json:"fileIds,omitempty"`
+	// Recurrence makes the todo the first occurrence of a series
+	Recurrence *RecurrenceRequest `json:"recurrence,omitempty"`
+	// FileID is the single attachment accepted before FileIDs existed
+	FileID *string `json:"fileId,omitempty"`
 }
 
 type UpdateTodoRequest struct {
 	Description *string    `json:"description,omitempty"`
 	DueDate     *time.Time `json:"dueDate,omitempty"`
-	FileID      *string    `json:"fileId,omitempty"`
+	Priority    *Priority  `json:"priority,omitempty" binding:"omitempty,oneof=low medium high urgent"`
+	Status      *Status    `json:"status,omitempty" binding:"omitempty,oneof=open in_progress done"`
+	// ParentID moves the todo under another; empty makes it top-level
+	ParentID *string `json:"parentId,omitempty"`
+	// ProjectID moves the todo and its subtasks to another project; empty
+	// takes them out of any
+	ProjectID *string `json:"projectId,omitempty"`
+	// Tags replaces all of the todo's tags
+	Tags *[]string `json:"tags,omitempty"`
+	// Scope is whether a recurring todo's later occurrences change too,
+	// this by default
+	Scope Scope `json:"scope,omitempty" binding:"omitempty,oneof=this future"`
+	// Recurrence starts a series at the todo, or with scope future changes
+	// or ends its series
+	Recurrence *RecurrenceRequest `json:"recurrence,omitempty"`
+}
+
+// ListFilter narrows a todo listing. Empty fields don't filter.
+type ListFilter struct {
+	// Tags are tag names from the caller's workspace catalogue
+	Tags []string
+	// AnyTag lists todos with any of Tags instead of all of them
+	AnyTag bool
+	// Priorities lists todos with any of them
+	Priorities []Priority
+	// Statuses lists todos with any of them
+	Statuses []Status
+	// OrderByRank lists todos by rank, unranked ones last by creation,
+	// instead of newest first
+	OrderByRank bool
+	// ProjectID lists only the todos of a project, even an archived one
+	ProjectID *uuid.UUID
+	// Assignee lists the todos assigned to a user; "me" is the caller
+	Assignee string
+	// IncludeArchived also lists the todos of archived projects, which are
+	// hidden otherwise
+	IncludeArchived bool
+	// WorkspaceID is the catalogue Tags are looked up in, set by the service
+	WorkspaceID string
 }
@@ -0,0 +1,209 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/identity"
+	"taskflow/pkg/logging"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxUserID is the longest user ID, as forwarded by the gateway
+	maxUserID = 64
+	// maxAssignees and maxWatchers are the most users a todo can have in
+	// each role
+	maxAssignees = 20
+	maxWatchers  = 100
+	// me stands for the caller wherever a user ID is expected
+	me = "me"
+)
+
+// Role is how a user takes part in a todo
+type Role string
+
+const (
+	RoleAssignee Role = "assignee"
+	RoleWatcher  Role = "watcher"
+)
+
+// Participant is a user assigned to or watching a todo
+type Participant struct {
+	TodoID    uuid.UUID `json:"todoId" db:"todo_id" gorm:"type:char(36);primaryKey"`
+	UserID    string    `json:"userId" db:"user_id" gorm:"size:64;primaryKey;index:idx_participant_user"`
+	Role      Role      `json:"role" db:"role" gorm:"size:10;primaryKey;index:idx_participant_user"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// ParticipantRequest names the user to assign or add as a watcher. It is
+// the caller when empty or "me".
+type ParticipantRequest struct {
+	UserID string `json:"userId,omitempty"`
+}
+
+// errParticipantsDisabled is returned for assignees and watchers when the
+// service has no participant repository
+var errParticipantsDisabled = shared.NewDomainError(shared.ErrCodeInvalidInput, "Assignees and watchers are not supported", "")
+
+// resolveUser validates a user ID, replacing "me" with the caller
+func resolveUser(ctx context.Context, userID string) (string, error) {
+	userID = strings.TrimSpace(userID)
+	if userID == me {
+		if userID = identity.FromContext(ctx).UserID; userID == "" {
+			return "", shared.NewValidationError(`"me" needs an identified caller`)
+		}
+	}
+	if userID == "" {
+		return "", shared.NewValidationError("userId must not be empty")
+	}
+	if len(userID) > maxUserID {
+		return "", shared.NewValidationError(fmt.Sprintf("user IDs must be at most %d characters", maxUserID))
+	}
+	return userID, nil
+}
+
+// checkUser rejects users that aren't in the caller's workspace
+func (s *todoService) checkUser(ctx context.Context, userID string) error {
+	if s.users == nil {
+		return nil
+	}
+	exists, err := s.users.Exists(ctx, identity.FromContext(ctx).WorkspaceID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+	if !exists {
+		return shared.NewValidationError(fmt.Sprintf("user %s does not exist", userID))
+	}
+	return nil
+}
+
+// loadParticipants fills in the assignees and watchers of todos
+func (s *todoService) loadParticipants(ctx context.Context, todos ...*TodoItem) error {
+	if s.participants == nil || len(todos) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(todos))
+	byID := make(map[uuid.UUID]*TodoItem, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+		byID[todo.ID] = todo
+		todo.Assignees, todo.Watchers = nil, nil
+	}
+
+	participants, err := s.participants.ListByTodos(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, participant := range participants {
+		todo, ok := byID[participant.TodoID]
+		if !ok {
+			continue
+		}
+		switch participant.Role {
+		case RoleAssignee:
+			todo.Assignees = append(todo.Assignees, participant.UserID)
+		case RoleWatcher:
+			todo.Watchers = append(todo.Watchers, participant.UserID)
+		}
+	}
+	return nil
+}
+
+// addParticipant gives a user a role on a todo. Assigning a user publishes
+// TopicTodoAssigned.
+func (s *todoService) addParticipant(ctx context.Context, id uuid.UUID, role Role, req *ParticipantRequest) (*Participant, error) {
+	if s.participants == nil {
+		return nil, errParticipantsDisabled
+	}
+	logger := logging.FromContext(ctx)
+
+	todo, err := s.todoRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("todo not found: %w", err)
+	}
+	requested := req.UserID
+	if strings.TrimSpace(requested) == "" {
+		requested = me
+	}
+	userID, err := resolveUser(ctx, requested)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadParticipants(ctx, todo); err != nil {
+		return nil, err
+	}
+	existing, limit := todo.Watchers, maxWatchers
+	if role == RoleAssignee {
+		existing, limit = todo.Assignees, maxAssignees
+	}
+	for _, participant := range existing {
+		if participant == userID {
+			return &Participant{TodoID: id, UserID: userID, Role: role}, nil
+		}
+	}
+	if len(existing) >= limit {
+		return nil, shared.NewValidationError(fmt.Sprintf("a todo can have at most %d %ss", limit, role))
+	}
+	if err := s.checkUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	participant := &Participant{TodoID: id, UserID: userID, Role: role, CreatedAt: time.Now()}
+	if err := s.participants.Add(ctx, participant); err != nil {
+		logger.Error("failed to add participant", "error", err, "todo_id", id, "user_id", userID, "role", role)
+		return nil, fmt.Errorf("failed to add %s: %w", role, err)
+	}
+	logger.Info("participant added", "todo_id", id, "user_id", userID, "role", role)
+
+	if role == RoleAssignee {
+		if err := s.load(ctx, todo); err != nil {
+			return nil, fmt.Errorf("failed to load todo: %w", err)
+		}
+		publishEvent(ctx, s.messaging, TopicTodoAssigned, todo, &AssignedEvent{
+			Todo:       todo,
+			Assignee:   userID,
+			AssignedBy: identity.FromContext(ctx).UserID,
+		})
+	}
+	return participant, nil
+}
+
+// removeParticipant takes a role on a todo away from a user
+func (s *todoService) removeParticipant(ctx context.Context, id uuid.UUID, role Role, userID string) error {
+	if s.participants == nil {
+		return errParticipantsDisabled
+	}
+	if _, err := s.todoRepo.GetByID(ctx, id); err != nil {
+		return fmt.Errorf("todo not found: %w", err)
+	}
+	userID, err := resolveUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.participants.Remove(ctx, id, userID, role); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("participant removed", "todo_id", id, "user_id", userID, "role", role)
+	return nil
+}
+
+func (s *todoService) AssignTodo(ctx context.Context, id uuid.UUID, req *ParticipantRequest) (*Participant, error) {
+	return s.addParticipant(ctx, id, RoleAssignee, req)
+}
+
+func (s *todoService) UnassignTodo(ctx context.Context, id uuid.UUID, userID string) error {
+	return s.removeParticipant(ctx, id, RoleAssignee, userID)
+}
+
+func (s *todoService) WatchTodo(ctx context.Context, id uuid.UUID, req *ParticipantRequest) (*Participant, error) {
+	return s.addParticipant(ctx, id, RoleWatcher, req)
+}
+
+func (s *todoService) UnwatchTodo(ctx context.Context, id uuid.UUID, userID string) error {
+	return s.removeParticipant(ctx, id, RoleWatcher, userID)
+}
@@ -2,7 +2,9 @@ package todo
 
 import (
 	"context"
+	"errors"
 	"taskflow/internal/domain/shared"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -11,18 +13,212 @@ import (
 type TodoService interface {
 	CreateTodo(ctx context.Context, req *CreateTodoRequest) (*TodoItem, error)
 	GetTodo(ctx context.Context, id uuid.UUID) (*TodoItem, error)
-	ListTodos(ctx context.Context, limit, offset int) ([]*TodoItem, error)
+	ListTodos(ctx context.Context, filter ListFilter, limit, offset int) ([]*TodoItem, error)
 	UpdateTodo(ctx context.Context, id uuid.UUID, req *UpdateTodoRequest) (*TodoItem, error)
 	DeleteTodo(ctx context.Context, id uuid.UUID) error
+	AttachFile(ctx context.Context, id uuid.UUID, fileID string) (*Attachment, error)
+	DetachFile(ctx context.Context, id uuid.UUID, fileID string) error
+	ListAttachments(ctx context.Context, id uuid.UUID) ([]*Attachment, error)
+	ListSubtasks(ctx context.Context, id uuid.UUID) ([]*TodoItem, error)
+	AddChecklistItem(ctx context.Context, id uuid.UUID, req *ChecklistItemRequest) (*ChecklistItem, error)
+	UpdateChecklistItem(ctx context.Context, id, itemID uuid.UUID, req *UpdateChecklistItemRequest) (*ChecklistItem, error)
+	DeleteChecklistItem(ctx context.Context, id, itemID uuid.UUID) error
+	// AddDependency makes a todo wait for another, warning when it is due
+	// before the todo it waits for
+	AddDependency(ctx context.Context, id uuid.UUID, req *DependencyRequest) (*Dependency, []DueDateWarning, error)
+	RemoveDependency(ctx context.Context, id, blockerID uuid.UUID) error
+	GetDependencies(ctx context.Context, id uuid.UUID) (*DependencyGraph, error)
+	PlanTodos(ctx context.Context, req *PlanRequest) (*Plan, error)
+	// MaterializeRecurrences creates the next occurrence of recurring todos
+	// whose latest occurrence is past due, returning how many it created
+	MaterializeRecurrences(ctx context.Context) (int, error)
+	// MoveTodo ranks a todo between others of a status column, moving it to
+	// the column first
+	MoveTodo(ctx context.Context, id uuid.UUID, req *MoveTodoRequest) (*TodoItem, error)
+	// RebalanceRanks respaces the ranks of columns that have run short of
+	// room, returning how many todos were reranked
+	RebalanceRanks(ctx context.Context) (int, error)
+	// AssignTodo assigns a user, the caller by default, to a todo
+	AssignTodo(ctx context.Context, id uuid.UUID, req *ParticipantRequest) (*Participant, error)
+	UnassignTodo(ctx context.Context, id uuid.UUID, userID string) error
+	// WatchTodo makes a user, the caller by default, watch a todo
+	WatchTodo(ctx context.Context, id uuid.UUID, req *ParticipantRequest) (*Participant, error)
+	UnwatchTodo(ctx context.Context, id uuid.UUID, userID string) error
 }
 
 // Repository defines the todo repository interface
 type Repository interface {
 	Create(ctx context.Context, todo *TodoItem) error
 	GetByID(ctx context.Context, id uuid.UUID) (*TodoItem, error)
-	List(ctx context.Context, limit, offset int) ([]*TodoItem, error)
+	List(ctx context.Context, filter ListFilter, limit, offset int) ([]*TodoItem, error)
+	// Update writes a todo except for its rank, which only SetRanks writes so
+	// a todo read before a column was respaced can't put its old rank back
 	Update(ctx context.Context, todo *TodoItem) error
+	// Delete removes a todo along with its tags
 	Delete(ctx context.Context, id uuid.UUID) error
+	// ListChildren returns the direct subtasks of todos, oldest first
+	ListChildren(ctx context.Context, parentIDs []uuid.UUID) ([]*TodoItem, error)
+	// AdjacentRank returns the rank of the todo of a column ranked right
+	// after rank, or right before it when before is set, skipping exclude
+	// and unranked todos. An empty rank is the start of the column, or its
+	// end when before is set. It returns "" when there is no such todo.
+	AdjacentRank(ctx context.Context, status Status, rank string, before bool, exclude uuid.UUID) (string, error)
+	// ListColumn returns the IDs and ranks of a column's todos by rank,
+	// unranked ones last, oldest first
+	ListColumn(ctx context.Context, status Status) ([]*TodoItem, error)
+	// SetRanks writes the ranks of todos by ID, leaving the rest of them
+	SetRanks(ctx context.Context, ranks map[uuid.UUID]string) error
+}
+
+// ChecklistRepository stores the checklist items of todos
+type ChecklistRepository interface {
+	Create(ctx context.Context, item *ChecklistItem) error
+	Get(ctx context.Context, id uuid.UUID) (*ChecklistItem, error)
+	Update(ctx context.Context, item *ChecklistItem) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	// ListByTodos returns the checklist items of todos by position
+	ListByTodos(ctx context.Context, todoIDs []uuid.UUID) ([]*ChecklistItem, error)
+	DeleteByTodo(ctx context.Context, todoID uuid.UUID) error
+}
+
+// TagService manages the tag catalogue of the caller's workspace
+type TagService interface {
+	ListTags(ctx context.Context) ([]*Tag, error)
+	CreateTag(ctx context.Context, req *TagRequest) (*Tag, error)
+	// RenameTag renames a tag on every todo that has it
+	RenameTag(ctx context.Context, id uuid.UUID, req *TagRequest) (*Tag, error)
+	// MergeTag moves a tag's todos to another tag and deletes it
+	MergeTag(ctx context.Context, id uuid.UUID, req *MergeTagRequest) (*Tag, error)
+	// DeleteTag removes a tag from every todo and the catalogue
+	DeleteTag(ctx context.Context, id uuid.UUID) error
+}
+
+// ProjectService manages the projects of the caller's workspace
+type ProjectService interface {
+	// ListProjects returns the projects in order, with their todo counts
+	ListProjects(ctx context.Context, includeArchived bool) ([]*Project, error)
+	CreateProject(ctx context.Context, req *ProjectRequest) (*Project, error)
+	GetProject(ctx context.Context, id uuid.UUID) (*Project, error)
+	UpdateProject(ctx context.Context, id uuid.UUID, req *UpdateProjectRequest) (*Project, error)
+	ReorderProjects(ctx context.Context, req *ReorderProjectsRequest) ([]*Project, error)
+	// DeleteProject removes a project without todos
+	DeleteProject(ctx context.Context, id uuid.UUID) error
+}
+
+// ProjectRepository stores the projects of workspaces
+type ProjectRepository interface {
+	Create(ctx context.Context, project *Project) error
+	// Get returns a project with its todo counts
+	Get(ctx context.Context, id uuid.UUID) (*Project, error)
+	// List returns a workspace's projects by position, with their todo
+	// counts
+	List(ctx context.Context, workspaceID string, includeArchived bool) ([]*Project, error)
+	Update(ctx context.Context, project *Project) error
+	// Reorder sets the positions of projects to their order in ids
+	Reorder(ctx context.Context, ids []uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// TagRepository stores workspace tag catalogues and the tags of todos
+type TagRepository interface {
+	// Ensure returns the named tags of a workspace by name, creating missing
+	// ones
+	Ensure(ctx context.Context, workspaceID string, names []string) ([]*Tag, error)
+	// Get returns a tag with its todo count
+	Get(ctx context.Context, id uuid.UUID) (*Tag, error)
+	GetByNames(ctx context.Context, workspaceID string, names []string) ([]*Tag, error)
+	// List returns a workspace's tags by name, with their todo counts
+	List(ctx context.Context, workspaceID string) ([]*Tag, error)
+	Update(ctx context.Context, tag *Tag) error
+	// Merge moves the todos of one tag to another and deletes the first
+	Merge(ctx context.Context, fromID, intoID uuid.UUID) error
+	// Delete removes a tag and its links to todos
+	Delete(ctx context.Context, id uuid.UUID) error
+	// SetTodoTags replaces the tags of a todo
+	SetTodoTags(ctx context.Context, todoID uuid.UUID, tagIDs []uuid.UUID) error
+	// ListByTodos returns the tags of todos, with their names, by name
+	ListByTodos(ctx context.Context, todoIDs []uuid.UUID) ([]*TodoTag, error)
+}
+
+// ErrDependencyCycle is returned by DependencyRepository.Add for a
+// dependency whose blocker already waits for the todo
+var ErrDependencyCycle = errors.New("dependency would create a cycle")
+
+// DependencyRepository stores which todos wait for which
+type DependencyRepository interface {
+	// Add records a dependency, doing nothing if it already exists. It
+	// returns ErrDependencyCycle if the blocker already waits for the todo,
+	// directly or through others, checked atomically with the insert.
+	Add(ctx context.Context, dependency *Dependency) error
+	// Remove deletes a dependency, returning a not found error if it didn't
+	// exist
+	Remove(ctx context.Context, todoID, blockedByID uuid.UUID) error
+	// ListBlockers returns the dependencies of todos on others
+	ListBlockers(ctx context.Context, todoIDs []uuid.UUID) ([]*Dependency, error)
+	// ListBlocked returns the dependencies of others on todos
+	ListBlocked(ctx context.Context, blockedByIDs []uuid.UUID) ([]*Dependency, error)
+	// DeleteByTodo removes the dependencies of a todo and on it
+	DeleteByTodo(ctx context.Context, todoID uuid.UUID) error
+}
+
+// RecurrenceRepository stores the series recurring todos belong to
+type RecurrenceRepository interface {
+	Create(ctx context.Context, recurrence *Recurrence) error
+	Get(ctx context.Context, id uuid.UUID) (*Recurrence, error)
+	ListByIDs(ctx context.Context, ids []uuid.UUID) ([]*Recurrence, error)
+	Update(ctx context.Context, recurrence *Recurrence) error
+	// Advance records the latest occurrence of a series, unless it changed
+	// since it was read with fromSequence and fromDue, and reports whether
+	// it did
+	Advance(ctx context.Context, recurrence *Recurrence, fromSequence int, fromDue time.Time) (bool, error)
+	// ListDue returns up to limit series that haven't ended and whose latest
+	// occurrence was due before before
+	ListDue(ctx context.Context, before time.Time, limit int) ([]*Recurrence, error)
+}
+
+// ParticipantRepository stores who is assigned to and watching todos
+type ParticipantRepository interface {
+	// Add records a participant, doing nothing if it already exists
+	Add(ctx context.Context, participant *Participant) error
+	// Remove deletes a participant, returning a not found error if it didn't
+	// exist
+	Remove(ctx context.Context, todoID uuid.UUID, userID string, role Role) error
+	// ListByTodos returns the participants of todos, oldest first
+	ListByTodos(ctx context.Context, todoIDs []uuid.UUID) ([]*Participant, error)
+	DeleteByTodo(ctx context.Context, todoID uuid.UUID) error
+}
+
+// UserDirectory knows the users of workspaces
+type UserDirectory interface {
+	// Exists reports whether a user belongs to a workspace
+	Exists(ctx context.Context, workspaceID string, userID string) (bool, error)
+}
+
+// AttachmentRepository defines the todo attachment repository interface
+type AttachmentRepository interface {
+	// Add attaches a file, doing nothing if it is already attached
+	Add(ctx context.Context, attachment *Attachment) error
+	// Remove detaches a file, returning a not found error if it wasn't attached
+	Remove(ctx context.Context, todoID uuid.UUID, fileID string) error
+	ListByTodos(ctx context.Context, todoIDs []uuid.UUID) ([]*Attachment, error)
+	ListByFile(ctx context.Context, fileID string) ([]*Attachment, error)
+	ListByFiles(ctx context.Context, fileIDs []string) ([]*Attachment, error)
+	DeleteByTodo(ctx context.Context, todoID uuid.UUID) error
+	DeleteByFile(ctx context.Context, fileID string) error
+}
+
+// FileReferences is called before a file is deleted to block the deletion
+// or release the todos' references to it, and reports which files todos
+// refer to
+type FileReferences interface {
+	ReleaseFile(ctx context.Context, fileID string) error
+	AttachedFiles(ctx context.Context, fileIDs []string) (map[string]bool, error)
+	ReferencedFiles(ctx context.Context, todoIDs []string) ([]string, error)
+}
+
+// FileChecker verifies that a file may be referenced by a todo
+type FileChecker interface {
+	CheckLinkable(ctx context.Context, fileID string) error
 }
 
 // Messaging defines the messaging interface (uses shared messaging port)
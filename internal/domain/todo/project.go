@@ -0,0 +1,354 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/identity"
+	"taskflow/pkg/logging"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxProjectName is the longest project name, in characters
+	maxProjectName = 100
+	// maxProjectDescription is the longest project description, in
+	// characters
+	maxProjectDescription = 2000
+)
+
+// projectColor is a color as #rrggbb
+var projectColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Project groups todos. Projects belong to a workspace but, like every
+// todo, their todos don't. Archived projects keep their todos but hide them
+// from default listings.
+type Project struct {
+	ID          uuid.UUID `json:"id" db:"id" gorm:"type:char(36);primaryKey"`
+	WorkspaceID string    `json:"-" db:"workspace_id" gorm:"size:64;index"`
+	Name        string    `json:"name" db:"name" gorm:"size:100"`
+	Description string    `json:"description,omitempty" db:"description" gorm:"type:text"`
+	Color       string    `json:"color,omitempty" db:"color" gorm:"size:7"`
+	Archived    bool      `json:"archived" db:"archived" gorm:"index"`
+	// Position orders the projects of a workspace
+	Position int `json:"position" db:"position"`
+	// TodoCount and DoneCount are how many todos the project has and how
+	// many of them are done, filled in by reads
+	TodoCount int64     `json:"todoCount" db:"todo_count" gorm:"->;-:migration"`
+	DoneCount int64     `json:"doneCount" db:"done_count" gorm:"->;-:migration"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// ProjectRequest creates a project
+type ProjectRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description,omitempty"`
+	// Color is #rrggbb
+	Color string `json:"color,omitempty"`
+}
+
+// UpdateProjectRequest changes the given fields of a project
+type UpdateProjectRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	// Color is #rrggbb, or empty for none
+	Color    *string `json:"color,omitempty"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+// ReorderProjectsRequest lists projects in their new order. Projects it
+// leaves out follow them in their current order.
+type ReorderProjectsRequest struct {
+	ProjectIDs []string `json:"projectIds" binding:"required,min=1,dive,uuid"`
+}
+
+// errProjectsDisabled is returned for projects when the todo service has no
+// project repository
+var errProjectsDisabled = shared.NewDomainError(shared.ErrCodeInvalidInput, "Projects are not supported", "")
+
+func checkProjectName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", shared.NewValidationError("project name must not be empty")
+	}
+	if utf8.RuneCountInString(name) > maxProjectName {
+		return "", shared.NewValidationError(fmt.Sprintf("project names must be at most %d characters", maxProjectName))
+	}
+	return name, nil
+}
+
+func checkProjectDescription(description string) (string, error) {
+	description = strings.TrimSpace(description)
+	if utf8.RuneCountInString(description) > maxProjectDescription {
+		return "", shared.NewValidationError(fmt.Sprintf("project descriptions must be at most %d characters", maxProjectDescription))
+	}
+	return description, nil
+}
+
+func checkProjectColor(color string) (string, error) {
+	if color == "" {
+		return "", nil
+	}
+	if !projectColor.MatchString(color) {
+		return "", shared.NewValidationError("color must be #rrggbb")
+	}
+	return strings.ToLower(color), nil
+}
+
+// getProject returns a project of the caller's workspace. Projects of other
+// workspaces are not found.
+func getProject(ctx context.Context, projects ProjectRepository, id uuid.UUID) (*Project, error) {
+	project, err := projects.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if project.WorkspaceID != identity.FromContext(ctx).WorkspaceID {
+		return nil, shared.NewNotFoundError("project not found")
+	}
+	return project, nil
+}
+
+type projectService struct {
+	projects ProjectRepository
+}
+
+// NewProjectService creates the service managing the projects of
+// workspaces
+func NewProjectService(projects ProjectRepository) ProjectService {
+	return &projectService{projects: projects}
+}
+
+func (s *projectService) ListProjects(ctx context.Context, includeArchived bool) ([]*Project, error) {
+	return s.projects.List(ctx, identity.FromContext(ctx).WorkspaceID, includeArchived)
+}
+
+func (s *projectService) GetProject(ctx context.Context, id uuid.UUID) (*Project, error) {
+	return getProject(ctx, s.projects, id)
+}
+
+func (s *projectService) CreateProject(ctx context.Context, req *ProjectRequest) (*Project, error) {
+	name, err := checkProjectName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	description, err := checkProjectDescription(req.Description)
+	if err != nil {
+		return nil, err
+	}
+	color, err := checkProjectColor(req.Color)
+	if err != nil {
+		return nil, err
+	}
+
+	// New projects go last
+	workspaceID := identity.FromContext(ctx).WorkspaceID
+	existing, err := s.projects.List(ctx, workspaceID, true)
+	if err != nil {
+		return nil, err
+	}
+	position := 0
+	for _, project := range existing {
+		if project.Position >= position {
+			position = project.Position + 1
+		}
+	}
+
+	now := time.Now()
+	project := &Project{
+		ID:          uuid.New(),
+		WorkspaceID: workspaceID,
+		Name:        name,
+		Description: description,
+		Color:       color,
+		Position:    position,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.projects.Create(ctx, project); err != nil {
+		return nil, fmt.Errorf("failed to create project: %w", err)
+	}
+	logging.FromContext(ctx).Info("project created", "project_id", project.ID)
+	return project, nil
+}
+
+func (s *projectService) UpdateProject(ctx context.Context, id uuid.UUID, req *UpdateProjectRequest) (*Project, error) {
+	project, err := getProject(ctx, s.projects, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		if project.Name, err = checkProjectName(*req.Name); err != nil {
+			return nil, err
+		}
+	}
+	if req.Description != nil {
+		if project.Description, err = checkProjectDescription(*req.Description); err != nil {
+			return nil, err
+		}
+	}
+	if req.Color != nil {
+		if project.Color, err = checkProjectColor(*req.Color); err != nil {
+			return nil, err
+		}
+	}
+	if req.Archived != nil {
+		project.Archived = *req.Archived
+	}
+
+	project.UpdatedAt = time.Now()
+	if err := s.projects.Update(ctx, project); err != nil {
+		return nil, fmt.Errorf("failed to update project: %w", err)
+	}
+	logging.FromContext(ctx).Info("project updated", "project_id", id, "archived", project.Archived)
+	return project, nil
+}
+
+func (s *projectService) ReorderProjects(ctx context.Context, req *ReorderProjectsRequest) ([]*Project, error) {
+	workspaceID := identity.FromContext(ctx).WorkspaceID
+	projects, err := s.projects.List(ctx, workspaceID, true)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*Project, len(projects))
+	for _, project := range projects {
+		byID[project.ID] = project
+	}
+
+	listed := make(map[uuid.UUID]bool, len(req.ProjectIDs))
+	var order []uuid.UUID
+	for _, projectID := range req.ProjectIDs {
+		id, err := uuid.Parse(projectID)
+		if err != nil {
+			return nil, shared.NewValidationError("projectIds must be UUIDs")
+		}
+		if byID[id] == nil {
+			return nil, shared.NewValidationError(fmt.Sprintf("project %s does not exist", id))
+		}
+		if listed[id] {
+			return nil, shared.NewValidationError(fmt.Sprintf("project %s is listed twice", id))
+		}
+		listed[id] = true
+		order = append(order, id)
+	}
+	for _, project := range projects {
+		if !listed[project.ID] {
+			order = append(order, project.ID)
+		}
+	}
+
+	if err := s.projects.Reorder(ctx, order); err != nil {
+		return nil, fmt.Errorf("failed to reorder projects: %w", err)
+	}
+	logging.FromContext(ctx).Info("projects reordered", "count", len(order))
+	return s.projects.List(ctx, workspaceID, true)
+}
+
+func (s *projectService) DeleteProject(ctx context.Context, id uuid.UUID) error {
+	project, err := getProject(ctx, s.projects, id)
+	if err != nil {
+		return err
+	}
+	if project.TodoCount > 0 {
+		return shared.NewDomainError(shared.ErrCodeConflict, "Project has todos", "move or delete its todos first, or archive it")
+	}
+	if err := s.projects.Delete(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete project: %w", err)
+	}
+	logging.FromContext(ctx).Info("project deleted", "project_id", id)
+	return nil
+}
+
+// checkProject returns the project a todo is put in: one of the caller's
+// workspace that isn't archived, or none for an empty ID
+func (s *todoService) checkProject(ctx context.Context, projectID string) (*uuid.UUID, error) {
+	if projectID == "" {
+		return nil, nil
+	}
+	if s.projects == nil {
+		return nil, errProjectsDisabled
+	}
+	id, err := uuid.Parse(projectID)
+	if err != nil {
+		return nil, shared.NewValidationError("projectId must be a UUID")
+	}
+	project, err := getProject(ctx, s.projects, id)
+	var domainErr *shared.DomainError
+	if errors.Is(err, shared.ErrNotFound) || (errors.As(err, &domainErr) && domainErr.Code == shared.ErrCodeNotFound) {
+		return nil, shared.NewValidationError("projectId does not refer to an existing project")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if project.Archived {
+		return nil, shared.NewDomainError(shared.ErrCodeConflict, "Project is archived", "unarchive it to add todos")
+	}
+	return &id, nil
+}
+
+func sameProject(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// updateProject moves a todo to the project requested, or to its parent's
+// after it moved under another todo. Subtasks are always in their parent's
+// project, so the todo's subtasks move with it.
+func (s *todoService) updateProject(ctx context.Context, todo *TodoItem, req *UpdateTodoRequest) error {
+	if req.ProjectID == nil && req.ParentID == nil {
+		return nil
+	}
+	var requested *uuid.UUID
+	if req.ProjectID != nil {
+		if id, err := uuid.Parse(*req.ProjectID); err == nil && sameProject(&id, todo.ProjectID) {
+			requested = todo.ProjectID
+		} else {
+			var err error
+			if requested, err = s.checkProject(ctx, *req.ProjectID); err != nil {
+				return err
+			}
+		}
+	}
+
+	target := todo.ProjectID
+	if todo.ParentID != nil {
+		parent, err := s.todoRepo.GetByID(ctx, *todo.ParentID)
+		if err != nil {
+			return fmt.Errorf("failed to get parent: %w", err)
+		}
+		if req.ProjectID != nil && !sameProject(requested, parent.ProjectID) {
+			return shared.NewValidationError("subtasks are in their parent's project")
+		}
+		target = parent.ProjectID
+	} else if req.ProjectID != nil {
+		target = requested
+	}
+	if sameProject(target, todo.ProjectID) {
+		return nil
+	}
+
+	levels, err := s.descendants(ctx, todo)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, level := range levels {
+		for _, subtask := range level {
+			subtask.ProjectID = target
+			subtask.UpdatedAt = now
+			if err := s.todoRepo.Update(ctx, subtask); err != nil {
+				return fmt.Errorf("failed to move subtask: %w", err)
+			}
+		}
+	}
+	todo.ProjectID = target
+	return nil
+}
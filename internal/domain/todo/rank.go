@@ -0,0 +1,332 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/logging"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ranks order the todos of a status column. They are base-36 fractions
+// compared as strings, so a todo can always be ranked between two others
+// and moving it only rewrites its own rank.
+const (
+	rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+	// maxRankLength is the longest rank handed out; a column whose ranks
+	// would grow longer is rebalanced first
+	maxRankLength = 48
+	// rankSlack is how much longer than a fresh rank a column's ranks may
+	// grow before the periodic rebalance respaces them
+	rankSlack = 6
+)
+
+// columns are the statuses todos are ranked within
+var columns = []Status{StatusOpen, StatusInProgress, StatusDone}
+
+// MoveTodoRequest places a todo in a column between two others. After is
+// the todo it comes right after and Before the one it comes right before;
+// without either it goes last.
+type MoveTodoRequest struct {
+	// Status moves the todo to another column
+	Status *Status `json:"status,omitempty" binding:"omitempty,oneof=open in_progress done"`
+	After  *string `json:"after,omitempty" binding:"omitempty,uuid"`
+	Before *string `json:"before,omitempty" binding:"omitempty,uuid"`
+}
+
+// errRebalance means a column's ranks leave no room and must be respaced
+var errRebalance = errors.New("column needs rebalancing")
+
+// rankBetween returns a rank after lower and before upper. An empty lower is
+// the start of the column and an empty upper its end.
+func rankBetween(lower, upper string) (string, error) {
+	if upper != "" && lower >= upper {
+		return "", errRebalance
+	}
+	var rank string
+	if upper == "" {
+		rank = rankAfter(lower)
+	} else {
+		rank = rankMidpoint(lower, upper)
+	}
+	if len(rank) > maxRankLength {
+		return "", errRebalance
+	}
+	return rank, nil
+}
+
+// rankAfter returns a short rank after lower by bumping its last digit
+// that can be bumped, so ranks appended to a column stay short
+func rankAfter(lower string) string {
+	for i := len(lower) - 1; i >= 0; i-- {
+		if digit := strings.IndexByte(rankDigits, lower[i]); digit < len(rankDigits)-1 {
+			return lower[:i] + string(rankDigits[digit+1])
+		}
+	}
+	return lower + string(rankDigits[len(rankDigits)/2])
+}
+
+// rankMidpoint returns a rank between lower and upper, which must be
+// ordered and not end in a zero digit. An empty lower is zero.
+func rankMidpoint(lower, upper string) string {
+	// Keep the prefix they share
+	n := 0
+	for n < len(upper) && rankDigit(lower, n) == upper[n] {
+		n++
+	}
+	if n > 0 {
+		rest := ""
+		if n < len(lower) {
+			rest = lower[n:]
+		}
+		return upper[:n] + rankMidpoint(rest, upper[n:])
+	}
+
+	low := strings.IndexByte(rankDigits, rankDigit(lower, 0))
+	high := strings.IndexByte(rankDigits, upper[0])
+	if high-low > 1 {
+		return string(rankDigits[(low+high+1)/2])
+	}
+	// The first digits are adjacent, so go a digit deeper
+	if len(upper) > 1 {
+		return upper[:1]
+	}
+	rest := ""
+	if len(lower) > 1 {
+		rest = lower[1:]
+	}
+	return string(rankDigits[low]) + rankAfter(rest)
+}
+
+// rankDigit returns the ith digit of rank, zero past its end
+func rankDigit(rank string, i int) byte {
+	if i < len(rank) {
+		return rank[i]
+	}
+	return rankDigits[0]
+}
+
+// rankWidth is the length of evenly spaced ranks for n todos, leaving room
+// for a couple of digits' worth of moves between each two
+func rankWidth(n int) int {
+	width, space := 3, uint64(36*36*36)
+	for space/uint64(n+1) < 36*36 {
+		width++
+		space *= 36
+	}
+	return width
+}
+
+// spacedRanks returns n evenly spaced ranks in order
+func spacedRanks(n int) []string {
+	width := rankWidth(n)
+	space := uint64(1)
+	for i := 0; i < width; i++ {
+		space *= 36
+	}
+	step := space / uint64(n+1)
+
+	ranks := make([]string, n)
+	for i := range ranks {
+		digits := make([]byte, width)
+		value := uint64(i+1) * step
+		for j := width - 1; j >= 0; j-- {
+			digits[j] = rankDigits[value%36]
+			value /= 36
+		}
+		// Trailing zeros don't change the order and would break midpoints
+		ranks[i] = strings.TrimRight(string(digits), "0")
+	}
+	return ranks
+}
+
+// rebalance respaces the ranks of a column evenly, keeping its order.
+// Unranked todos go last, oldest first.
+func (s *todoService) rebalance(ctx context.Context, status Status) (int, error) {
+	todos, err := s.todoRepo.ListColumn(ctx, status)
+	if err != nil {
+		return 0, err
+	}
+	ranks := spacedRanks(len(todos))
+	changed := make(map[uuid.UUID]string)
+	for i, todo := range todos {
+		if todo.Rank != ranks[i] {
+			changed[todo.ID] = ranks[i]
+		}
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	if err := s.todoRepo.SetRanks(ctx, changed); err != nil {
+		return 0, err
+	}
+	logging.FromContext(ctx).Info("column rebalanced", "status", status, "todos", len(todos), "reranked", len(changed))
+	return len(changed), nil
+}
+
+// rankLast returns a rank at the end of a column, ignoring exclude
+func (s *todoService) rankLast(ctx context.Context, status Status, exclude uuid.UUID) (string, error) {
+	for attempt := 0; ; attempt++ {
+		last, err := s.todoRepo.AdjacentRank(ctx, status, "", true, exclude)
+		if err != nil {
+			return "", err
+		}
+		rank, err := rankBetween(last, "")
+		if errors.Is(err, errRebalance) && attempt == 0 {
+			if _, err := s.rebalance(ctx, status); err != nil {
+				return "", err
+			}
+			continue
+		}
+		return rank, err
+	}
+}
+
+// anchor returns a todo a moved todo is placed next to
+func (s *todoService) anchor(ctx context.Context, id uuid.UUID, anchorID string, status Status) (*TodoItem, error) {
+	anchorUUID, err := uuid.Parse(anchorID)
+	if err != nil {
+		return nil, shared.NewValidationError("anchors must be UUIDs")
+	}
+	if anchorUUID == id {
+		return nil, shared.NewValidationError("a todo can't be moved next to itself")
+	}
+	anchor, err := s.todoRepo.GetByID(ctx, anchorUUID)
+	var domainErr *shared.DomainError
+	if errors.Is(err, shared.ErrNotFound) || (errors.As(err, &domainErr) && domainErr.Code == shared.ErrCodeNotFound) {
+		return nil, shared.NewValidationError(fmt.Sprintf("anchor %s does not exist", anchorUUID))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if anchor.Status != status {
+		return nil, shared.NewValidationError(fmt.Sprintf("anchor %s is not in the %s column", anchorUUID, status))
+	}
+	return anchor, nil
+}
+
+// moveRank returns the rank a move places a todo at
+func (s *todoService) moveRank(ctx context.Context, id uuid.UUID, status Status, req *MoveTodoRequest) (string, error) {
+	var after, before *TodoItem
+	var err error
+	if req.After != nil {
+		if after, err = s.anchor(ctx, id, *req.After, status); err != nil {
+			return "", err
+		}
+	}
+	if req.Before != nil {
+		if before, err = s.anchor(ctx, id, *req.Before, status); err != nil {
+			return "", err
+		}
+	}
+	if (after != nil && after.Rank == "") || (before != nil && before.Rank == "") {
+		return "", errRebalance
+	}
+
+	var lower, upper string
+	switch {
+	case after != nil && before != nil:
+		if after.Rank > before.Rank {
+			return "", shared.NewValidationError("after must come before before")
+		}
+		lower, upper = after.Rank, before.Rank
+	case after != nil:
+		lower = after.Rank
+		if upper, err = s.todoRepo.AdjacentRank(ctx, status, lower, false, id); err != nil {
+			return "", err
+		}
+	case before != nil:
+		upper = before.Rank
+		if lower, err = s.todoRepo.AdjacentRank(ctx, status, upper, true, id); err != nil {
+			return "", err
+		}
+	default:
+		if lower, err = s.todoRepo.AdjacentRank(ctx, status, "", true, id); err != nil {
+			return "", err
+		}
+	}
+	return rankBetween(lower, upper)
+}
+
+func (s *todoService) MoveTodo(ctx context.Context, id uuid.UUID, req *MoveTodoRequest) (*TodoItem, error) {
+	logger := logging.FromContext(ctx)
+
+	todo, err := s.todoRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("todo not found: %w", err)
+	}
+	status := todo.Status
+	if req.Status != nil {
+		status = *req.Status
+	}
+
+	var rank string
+	for attempt := 0; ; attempt++ {
+		rank, err = s.moveRank(ctx, id, status, req)
+		if errors.Is(err, errRebalance) && attempt == 0 {
+			if _, err := s.rebalance(ctx, status); err != nil {
+				return nil, fmt.Errorf("failed to rebalance column: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	wasDone := todo.Status == StatusDone
+	if status != todo.Status {
+		if err := s.updateStatus(ctx, todo, &UpdateTodoRequest{Status: &status}); err != nil {
+			return nil, err
+		}
+	}
+	todo.Rank = rank
+	todo.UpdatedAt = time.Now()
+	if err := s.todoRepo.Update(ctx, todo); err != nil {
+		logger.Error("failed to move todo", "error", err, "todo_id", id)
+		return nil, fmt.Errorf("failed to move todo: %w", err)
+	}
+	if err := s.todoRepo.SetRanks(ctx, map[uuid.UUID]string{id: rank}); err != nil {
+		logger.Error("failed to move todo", "error", err, "todo_id", id)
+		return nil, fmt.Errorf("failed to move todo: %w", err)
+	}
+	if !wasDone && todo.Status == StatusDone {
+		s.completeOccurrence(ctx, todo)
+	}
+	if err := s.load(ctx, todo); err != nil {
+		return nil, fmt.Errorf("failed to load todo: %w", err)
+	}
+
+	logger.Info("todo moved", "todo_id", id, "status", todo.Status, "rank", rank)
+	publish(ctx, s.messaging, TopicTodoUpdated, todo)
+	return todo, nil
+}
+
+// RebalanceRanks respaces the columns whose ranks have grown long or that
+// have unranked todos
+func (s *todoService) RebalanceRanks(ctx context.Context) (int, error) {
+	reranked := 0
+	for _, status := range columns {
+		todos, err := s.todoRepo.ListColumn(ctx, status)
+		if err != nil {
+			return reranked, err
+		}
+		limit := rankWidth(len(todos)) + rankSlack
+		for _, todo := range todos {
+			if todo.Rank == "" || len(todo.Rank) > limit {
+				n, err := s.rebalance(ctx, status)
+				if err != nil {
+					return reranked, err
+				}
+				reranked += n
+				break
+			}
+		}
+	}
+	return reranked, nil
+}
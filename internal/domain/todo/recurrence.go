@@ -0,0 +1,374 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/identity"
+	"taskflow/pkg/logging"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecurrenceRequest sets how a todo repeats
+type RecurrenceRequest struct {
+	// Rule is an RFC 5545 RRULE value such as "FREQ=WEEKLY;BYDAY=MO". On
+	// update an empty rule ends the series.
+	Rule string `json:"rule"`
+	// TimeZone is the IANA time zone occurrences keep their wall-clock time
+	// in, UTC by default
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// Scope is which occurrences of a recurring todo an update applies to
+type Scope string
+
+const (
+	// ScopeThis changes only the occurrence updated
+	ScopeThis Scope = "this"
+	// ScopeFuture also changes the occurrences created after it
+	ScopeFuture Scope = "future"
+)
+
+// Recurrence is a series of todos repeating by a rule. Each occurrence is a
+// todo of its own, created from the series when the latest one is done or
+// its due date passes.
+type Recurrence struct {
+	ID       uuid.UUID `json:"id" db:"id" gorm:"type:char(36);primaryKey"`
+	Rule     string    `json:"rule" db:"rule" gorm:"size:255"`
+	TimeZone string    `json:"timeZone" db:"time_zone" gorm:"size:64"`
+	// Start is the due date of the first occurrence
+	Start time.Time `json:"start" db:"start"`
+	// Sequence and LastDue are the number and scheduled due date of the
+	// latest occurrence
+	Sequence int       `json:"sequence" db:"sequence"`
+	LastDue  time.Time `json:"lastDue" db:"last_due" gorm:"index:idx_recurrence_due"`
+	Ended    bool      `json:"ended" db:"ended" gorm:"index:idx_recurrence_due"`
+	// Description, Priority, Tags, ParentID and ProjectID are given to new
+	// occurrences
+	Description string     `json:"-" db:"description" gorm:"type:text"`
+	Priority    Priority   `json:"-" db:"priority" gorm:"size:10"`
+	Tags        []string   `json:"-" db:"tags" gorm:"serializer:json;type:text"`
+	ParentID    *uuid.UUID `json:"-" db:"parent_id" gorm:"type:char(36)"`
+	ProjectID   *uuid.UUID `json:"-" db:"project_id" gorm:"type:char(36)"`
+	// UserID and WorkspaceID are who occurrences are created for
+	UserID      string    `json:"-" db:"user_id" gorm:"size:64"`
+	WorkspaceID string    `json:"-" db:"workspace_id" gorm:"size:64"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// errRecurrenceDisabled is returned for recurring todos when the service has
+// no recurrence repository
+var errRecurrenceDisabled = shared.NewDomainError(shared.ErrCodeInvalidInput, "Recurring todos are not supported", "")
+
+// parseRecurrence validates a rule and time zone
+func parseRecurrence(rule, timeZone string) (*Rule, *time.Location, error) {
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, nil, shared.NewValidationError(fmt.Sprintf("unknown time zone: %s", timeZone))
+	}
+	parsed, err := ParseRule(rule, loc)
+	if err != nil {
+		return nil, nil, shared.NewValidationError(err.Error())
+	}
+	return parsed, loc, nil
+}
+
+// newRecurrence starts a series at todo, which becomes its first occurrence
+func (s *todoService) newRecurrence(ctx context.Context, req *RecurrenceRequest, todo *TodoItem, tags []string) (*Recurrence, error) {
+	if s.recurrences == nil {
+		return nil, errRecurrenceDisabled
+	}
+	rule, loc, err := parseRecurrence(req.Rule, req.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	caller := identity.FromContext(ctx)
+	now := time.Now()
+	recurrence := &Recurrence{
+		ID:          uuid.New(),
+		Rule:        rule.String(),
+		TimeZone:    loc.String(),
+		Start:       todo.DueDate,
+		Sequence:    1,
+		LastDue:     todo.DueDate,
+		Ended:       rule.Count == 1,
+		Description: todo.Description,
+		Priority:    todo.Priority,
+		Tags:        tags,
+		ParentID:    todo.ParentID,
+		ProjectID:   todo.ProjectID,
+		UserID:      caller.UserID,
+		WorkspaceID: caller.WorkspaceID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.recurrences.Create(ctx, recurrence); err != nil {
+		return nil, fmt.Errorf("failed to create recurrence: %w", err)
+	}
+	todo.RecurrenceID = &recurrence.ID
+	todo.Occurrence = 1
+	todo.Recurrence = recurrence
+	return recurrence, nil
+}
+
+// loadRecurrences fills in the series of recurring todos
+func (s *todoService) loadRecurrences(ctx context.Context, todos ...*TodoItem) error {
+	if s.recurrences == nil {
+		return nil
+	}
+	var ids []uuid.UUID
+	for _, todo := range todos {
+		todo.Recurrence = nil
+		if todo.RecurrenceID != nil {
+			ids = append(ids, *todo.RecurrenceID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	recurrences, err := s.recurrences.ListByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]*Recurrence, len(recurrences))
+	for _, recurrence := range recurrences {
+		byID[recurrence.ID] = recurrence
+	}
+	for _, todo := range todos {
+		if todo.RecurrenceID != nil {
+			todo.Recurrence = byID[*todo.RecurrenceID]
+		}
+	}
+	return nil
+}
+
+// updateSeries applies an update of a todo to its series. Updates scoped to
+// the future become the series' template, and rescheduling restarts the
+// series at the todo; the todo starts a series when given a rule.
+func (s *todoService) updateSeries(ctx context.Context, todo *TodoItem, req *UpdateTodoRequest, tags []string) error {
+	if todo.RecurrenceID == nil {
+		if req.Recurrence != nil && strings.TrimSpace(req.Recurrence.Rule) != "" {
+			if req.Tags == nil && s.tags != nil {
+				if err := s.loadTags(ctx, todo); err != nil {
+					return err
+				}
+				tags = todo.Tags
+			}
+			_, err := s.newRecurrence(ctx, req.Recurrence, todo, tags)
+			return err
+		}
+		if req.Scope == ScopeFuture {
+			return shared.NewValidationError("scope future only applies to recurring todos")
+		}
+		return nil
+	}
+	if req.Scope != ScopeFuture {
+		if req.Recurrence != nil {
+			return shared.NewValidationError("changing the recurrence applies to all future occurrences; set scope to future")
+		}
+		return nil
+	}
+	if s.recurrences == nil {
+		return errRecurrenceDisabled
+	}
+
+	recurrence, err := s.recurrences.Get(ctx, *todo.RecurrenceID)
+	if err != nil {
+		return err
+	}
+	recurrence.Description = todo.Description
+	recurrence.Priority = todo.Priority
+	recurrence.ParentID = todo.ParentID
+	recurrence.ProjectID = todo.ProjectID
+	if req.Tags != nil {
+		recurrence.Tags = tags
+	}
+
+	if req.Recurrence != nil || req.DueDate != nil {
+		if todo.Occurrence != recurrence.Sequence {
+			return shared.NewDomainError(shared.ErrCodeConflict, "Only the latest occurrence can reschedule the series", "")
+		}
+		if req.Recurrence != nil && strings.TrimSpace(req.Recurrence.Rule) == "" {
+			recurrence.Ended = true
+		} else {
+			rule, timeZone := recurrence.Rule, recurrence.TimeZone
+			if req.Recurrence != nil {
+				rule, timeZone = req.Recurrence.Rule, req.Recurrence.TimeZone
+			}
+			parsed, loc, err := parseRecurrence(rule, timeZone)
+			if err != nil {
+				return err
+			}
+			// A kept rule keeps its remaining number of occurrences
+			if req.Recurrence == nil && parsed.Count > 0 {
+				parsed.Count -= recurrence.Sequence - 1
+			}
+			recurrence.Rule = parsed.String()
+			recurrence.TimeZone = loc.String()
+			recurrence.Start = todo.DueDate
+			recurrence.Sequence = 1
+			recurrence.LastDue = todo.DueDate
+			recurrence.Ended = parsed.Count == 1
+			todo.Occurrence = 1
+		}
+	}
+
+	recurrence.UpdatedAt = time.Now()
+	if err := s.recurrences.Update(ctx, recurrence); err != nil {
+		return fmt.Errorf("failed to update recurrence: %w", err)
+	}
+	return nil
+}
+
+// completeOccurrence creates the next occurrence of a series once its
+// latest one is done
+func (s *todoService) completeOccurrence(ctx context.Context, todo *TodoItem) {
+	if s.recurrences == nil || todo.RecurrenceID == nil {
+		return
+	}
+	logger := logging.FromContext(ctx)
+	recurrence, err := s.recurrences.Get(ctx, *todo.RecurrenceID)
+	if err != nil {
+		logger.Error("failed to get recurrence", "error", err, "todo_id", todo.ID)
+		return
+	}
+	if recurrence.Ended || todo.Occurrence != recurrence.Sequence {
+		return
+	}
+	if _, err := s.materialize(ctx, recurrence, time.Now()); err != nil {
+		logger.Error("failed to create next occurrence", "error", err, "todo_id", todo.ID, "recurrence_id", recurrence.ID)
+	}
+}
+
+// materialize creates the next occurrence of a series that is due after
+// both its latest occurrence and now, skipping the ones missed in between.
+// It returns nil when the series has ended or another instance advanced it
+// first.
+func (s *todoService) materialize(ctx context.Context, recurrence *Recurrence, now time.Time) (*TodoItem, error) {
+	logger := logging.FromContext(ctx)
+	after := recurrence.LastDue
+	if now.After(after) {
+		after = now
+	}
+	advanced := *recurrence
+	advanced.UpdatedAt = now
+
+	var due time.Time
+	var sequence int
+	rule, loc, err := parseRecurrence(recurrence.Rule, recurrence.TimeZone)
+	ok := err == nil
+	if ok {
+		due, sequence, ok = rule.Next(recurrence.Start.In(loc), after)
+	} else {
+		logger.Error("ending series with an invalid rule", "error", err, "recurrence_id", recurrence.ID)
+	}
+	if !ok {
+		advanced.Ended = true
+		_, err := s.recurrences.Advance(ctx, &advanced, recurrence.Sequence, recurrence.LastDue)
+		return nil, err
+	}
+
+	// Occurrences belong to whoever started the series, and their tags to
+	// its workspace
+	ctx = identity.WithIdentity(ctx, identity.Identity{UserID: recurrence.UserID, WorkspaceID: recurrence.WorkspaceID})
+	todo := &TodoItem{
+		ID:           uuid.New(),
+		Description:  recurrence.Description,
+		DueDate:      due,
+		Priority:     recurrence.Priority,
+		Status:       StatusOpen,
+		RecurrenceID: &recurrence.ID,
+		Occurrence:   sequence,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if recurrence.ParentID != nil {
+		// Open occurrences can't be under a done parent, so they move to the
+		// top level once it is
+		if parent, err := s.todoRepo.GetByID(ctx, *recurrence.ParentID); err == nil && parent.Status != StatusDone {
+			todo.ParentID = recurrence.ParentID
+			todo.ProjectID = parent.ProjectID
+		}
+	}
+	if todo.ParentID == nil && recurrence.ProjectID != nil && s.projects != nil {
+		// Occurrences of a deleted project go in none
+		if _, err := s.projects.Get(ctx, *recurrence.ProjectID); err == nil {
+			todo.ProjectID = recurrence.ProjectID
+		}
+	}
+	if todo.Rank, err = s.rankLast(ctx, todo.Status, todo.ID); err != nil {
+		return nil, fmt.Errorf("failed to rank occurrence: %w", err)
+	}
+	if err := s.todoRepo.Create(ctx, todo); err != nil {
+		return nil, fmt.Errorf("failed to create occurrence: %w", err)
+	}
+
+	advanced.Sequence = sequence
+	advanced.LastDue = due
+	advanced.Ended = rule.Count > 0 && sequence >= rule.Count
+	claimed, err := s.recurrences.Advance(ctx, &advanced, recurrence.Sequence, recurrence.LastDue)
+	if err != nil || !claimed {
+		if err := s.todoRepo.Delete(ctx, todo.ID); err != nil {
+			logger.Error("failed to delete duplicate occurrence", "error", err, "todo_id", todo.ID)
+		}
+		return nil, err
+	}
+
+	if len(recurrence.Tags) > 0 && s.tags != nil {
+		if err := s.setTags(ctx, todo, recurrence.Tags); err != nil {
+			logger.Error("failed to tag occurrence", "error", err, "todo_id", todo.ID)
+		}
+	}
+	todo.Recurrence = &advanced
+	logger.Info("occurrence created", "todo_id", todo.ID, "recurrence_id", recurrence.ID, "occurrence", sequence)
+	publish(ctx, s.messaging, TopicTodoCreated, todo)
+	return todo, nil
+}
+
+// MaterializeRecurrences creates the next occurrence of every series whose
+// latest occurrence's due date has passed
+func (s *todoService) MaterializeRecurrences(ctx context.Context) (int, error) {
+	const batchSize = 100
+	if s.recurrences == nil {
+		return 0, nil
+	}
+	logger := logging.FromContext(ctx)
+	now := time.Now()
+
+	created := 0
+	for {
+		recurrences, err := s.recurrences.ListDue(ctx, now, batchSize)
+		if err != nil {
+			return created, err
+		}
+
+		for _, recurrence := range recurrences {
+			todo, err := s.materialize(ctx, recurrence, now)
+			if err != nil {
+				logger.Error("failed to create next occurrence", "error", err, "recurrence_id", recurrence.ID)
+				return created, err
+			}
+			if todo != nil {
+				created++
+			}
+		}
+
+		if len(recurrences) < batchSize {
+			break
+		}
+	}
+
+	if created > 0 {
+		logger.Info("recurring todos created", "count", created)
+	}
+	return created, nil
+}
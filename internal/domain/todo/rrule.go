@@ -0,0 +1,301 @@
+package todo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRulePeriods bounds how many days, weeks, months or years a rule is
+// followed looking for an occurrence, so rules that rarely or never match
+// end instead of looping
+const maxRulePeriods = 50_000
+
+// Frequency is how often a rule repeats
+type Frequency string
+
+const (
+	FreqDaily   Frequency = "DAILY"
+	FreqWeekly  Frequency = "WEEKLY"
+	FreqMonthly Frequency = "MONTHLY"
+	FreqYearly  Frequency = "YEARLY"
+)
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// ByDay is a BYDAY entry. N picks the Nth such weekday of the month, from
+// the end when negative; zero is every one.
+type ByDay struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// Rule is a recurrence rule, the subset of RFC 5545 RRULE with FREQ,
+// INTERVAL, BYDAY, COUNT and UNTIL. Occurrences keep the wall-clock time of
+// the first one in its time zone, across DST changes.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []ByDay
+	// Count is how many occurrences there are, counting the first; zero is
+	// unbounded
+	Count int
+	// Until is the last time an occurrence can be at; zero is unbounded
+	Until time.Time
+}
+
+// ParseRule parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,FR". A
+// floating or date-only UNTIL is in loc, and a date covers the whole day.
+func ParseRule(text string, loc *time.Location) (*Rule, error) {
+	text = strings.TrimPrefix(strings.TrimSpace(text), "RRULE:")
+	if text == "" {
+		return nil, fmt.Errorf("recurrence rule is empty")
+	}
+
+	rule := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(text, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid recurrence rule part: %q", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("recurrence rule has %s twice", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			switch freq := Frequency(value); freq {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = freq
+			default:
+				return nil, fmt.Errorf("unsupported recurrence frequency: %s", value)
+			}
+		case "INTERVAL":
+			if rule.Interval, err = strconv.Atoi(value); err != nil || rule.Interval < 1 || rule.Interval > 1000 {
+				return nil, fmt.Errorf("recurrence interval must be between 1 and 1000")
+			}
+		case "COUNT":
+			if rule.Count, err = strconv.Atoi(value); err != nil || rule.Count < 1 {
+				return nil, fmt.Errorf("recurrence count must be positive")
+			}
+		case "UNTIL":
+			if rule.Until, err = parseUntil(value, loc); err != nil {
+				return nil, err
+			}
+		case "BYDAY":
+			if rule.ByDay, err = parseByDay(value); err != nil {
+				return nil, err
+			}
+		case "WKST":
+			// Weeks start on Monday, which is all WKST changes for the
+			// supported rules
+			if value != "MO" {
+				return nil, fmt.Errorf("unsupported recurrence week start: %s", value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part: %s", name)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("recurrence rule needs a FREQ")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, fmt.Errorf("recurrence rule can't have both COUNT and UNTIL")
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != FreqMonthly {
+			return nil, fmt.Errorf("numbered BYDAY is only supported in monthly rules")
+		}
+	}
+	if len(rule.ByDay) > 0 && rule.Freq == FreqYearly {
+		return nil, fmt.Errorf("BYDAY is not supported in yearly rules")
+	}
+	return rule, nil
+}
+
+func parseUntil(value string, loc *time.Location) (time.Time, error) {
+	if until, err := time.Parse("20060102T150405Z", value); err == nil {
+		return until, nil
+	}
+	if until, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return until, nil
+	}
+	if until, err := time.ParseInLocation("20060102", value, loc); err == nil {
+		return until.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid recurrence UNTIL: %s", value)
+}
+
+func parseByDay(value string) ([]ByDay, error) {
+	var days []ByDay
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) < 2 {
+			return nil, fmt.Errorf("invalid recurrence BYDAY: %s", entry)
+		}
+		weekday, ok := weekdays[entry[len(entry)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid recurrence BYDAY: %s", entry)
+		}
+		day := ByDay{Weekday: weekday}
+		if prefix := entry[:len(entry)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("invalid recurrence BYDAY: %s", entry)
+			}
+			day.N = n
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// String formats the rule as an RRULE value
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = strings.ToUpper(day.Weekday.String()[:2])
+			if day.N != 0 {
+				days[i] = strconv.Itoa(day.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence after after of the rule whose first
+// occurrence is start, with its number counting start as 1. ok is false once
+// the rule has no more occurrences.
+func (r *Rule) Next(start, after time.Time) (next time.Time, sequence int, ok bool) {
+	sequence = 1
+	if start.After(after) {
+		return start, sequence, true
+	}
+	for period := 0; period < maxRulePeriods; period++ {
+		for _, candidate := range r.period(start, period) {
+			if !candidate.After(start) {
+				continue
+			}
+			if !r.Until.IsZero() && candidate.After(r.Until) {
+				return time.Time{}, 0, false
+			}
+			sequence++
+			if r.Count > 0 && sequence > r.Count {
+				return time.Time{}, 0, false
+			}
+			if candidate.After(after) {
+				return candidate, sequence, true
+			}
+		}
+	}
+	return time.Time{}, 0, false
+}
+
+// period returns the candidate occurrences in the nth period of the rule, in
+// order. Period 0 is the one start is in.
+func (r *Rule) period(start time.Time, n int) []time.Time {
+	year, month, day := start.Date()
+	hour, minute, second := start.Clock()
+	loc := start.Location()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, loc)
+	}
+	step := n * r.Interval
+
+	var candidates []time.Time
+	switch r.Freq {
+	case FreqDaily:
+		candidate := at(year, month, day+step)
+		if r.matchesWeekday(candidate.Weekday()) {
+			candidates = append(candidates, candidate)
+		}
+	case FreqWeekly:
+		monday := day - (int(start.Weekday())+6)%7 + 7*step
+		if len(r.ByDay) == 0 {
+			return []time.Time{at(year, month, day+7*step)}
+		}
+		for _, byDay := range r.ByDay {
+			candidates = append(candidates, at(year, month, monday+(int(byDay.Weekday)+6)%7))
+		}
+	case FreqMonthly:
+		first := time.Date(year, month+time.Month(step), 1, 0, 0, 0, 0, loc)
+		days := daysIn(first.Year(), first.Month())
+		if len(r.ByDay) == 0 {
+			if day <= days {
+				candidates = append(candidates, at(first.Year(), first.Month(), day))
+			}
+			break
+		}
+		for _, byDay := range r.ByDay {
+			offset := (int(byDay.Weekday) - int(first.Weekday()) + 7) % 7
+			var matches []int
+			for d := 1 + offset; d <= days; d += 7 {
+				matches = append(matches, d)
+			}
+			switch {
+			case byDay.N == 0:
+			case byDay.N > 0 && byDay.N <= len(matches):
+				matches = matches[byDay.N-1 : byDay.N]
+			case byDay.N < 0 && -byDay.N <= len(matches):
+				matches = matches[len(matches)+byDay.N : len(matches)+byDay.N+1]
+			default:
+				matches = nil
+			}
+			for _, d := range matches {
+				candidates = append(candidates, at(first.Year(), first.Month(), d))
+			}
+		}
+	case FreqYearly:
+		if day <= daysIn(year+step, month) {
+			candidates = append(candidates, at(year+step, month, day))
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	unique := candidates[:0]
+	for i, candidate := range candidates {
+		if i == 0 || !candidate.Equal(candidates[i-1]) {
+			unique = append(unique, candidate)
+		}
+	}
+	return unique
+}
+
+func (r *Rule) matchesWeekday(weekday time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, day := range r.ByDay {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,58 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"taskflow/internal/domain/shared"
+
+	"github.com/google/uuid"
+)
+
+// SearchTodos resolves search matches to todos
+type SearchTodos struct {
+	todoRepo    Repository
+	attachments AttachmentRepository
+}
+
+// NewSearchTodos creates the todo lookups the search service needs
+func NewSearchTodos(todoRepo Repository, attachments AttachmentRepository) *SearchTodos {
+	return &SearchTodos{todoRepo: todoRepo, attachments: attachments}
+}
+
+// AttachedTodos maps each of fileIDs to the IDs of the todos it is attached
+// to
+func (s *SearchTodos) AttachedTodos(ctx context.Context, fileIDs []string) (map[string][]string, error) {
+	attached := map[string][]string{}
+	if s.attachments == nil || len(fileIDs) == 0 {
+		return attached, nil
+	}
+	attachments, err := s.attachments.ListByFiles(ctx, fileIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, attachment := range attachments {
+		attached[attachment.FileID] = append(attached[attachment.FileID], attachment.TodoID.String())
+	}
+	return attached, nil
+}
+
+// ExistingTodos reports which of todoIDs still exist
+func (s *SearchTodos) ExistingTodos(ctx context.Context, todoIDs []string) (map[string]bool, error) {
+	existing := make(map[string]bool, len(todoIDs))
+	for _, raw := range todoIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			continue
+		}
+		_, err = s.todoRepo.GetByID(ctx, id)
+		var domainErr *shared.DomainError
+		if errors.As(err, &domainErr) && domainErr.Code == shared.ErrCodeNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		existing[raw] = true
+	}
+	return existing, nil
+}
@@ -2,31 +2,204 @@ package todo
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log/slog"
 	"time"
 	"taskflow/internal/domain/shared"
+	"taskflow/pkg/identity"
+	"taskflow/pkg/logging"
 
 	"github.com/google/uuid"
 )
 
 type todoService struct {
-	todoRepo  Repository
-	messaging Messaging
-	cache     Cache
-	logger    *slog.Logger
+	todoRepo     Repository
+	attachments  AttachmentRepository
+	messaging    Messaging
+	cache        Cache
+	files        FileChecker
+	tags         TagRepository
+	checklists   ChecklistRepository
+	dependencies DependencyRepository
+	recurrences  RecurrenceRepository
+	projects     ProjectRepository
+	participants ParticipantRepository
+	users        UserDirectory
 }
 
-func NewTodoService(todoRepo Repository, messaging Messaging, cache Cache) TodoService {
+// errHasSubtasks is returned when deleting a todo would orphan its subtasks
+var errHasSubtasks = shared.NewDomainError(shared.ErrCodeConflict, "Todo has subtasks", "delete or move its subtasks first")
+
+// errTagsDisabled is returned for tags when the service has no tag repository
+var errTagsDisabled = shared.NewDomainError(shared.ErrCodeInvalidInput, "Tags are not supported", "")
+
+// Deps are what the todo service is built from. Todos, Attachments,
+// Messaging, Cache and Files are required; the rest may be nil, turning off
+// what they back.
+type Deps struct {
+	Todos       Repository
+	Attachments AttachmentRepository
+	Messaging   Messaging
+	Cache       Cache
+	Files       FileChecker
+	// Tags lets todos be tagged
+	Tags TagRepository
+	// Checklists gives todos checklists
+	Checklists ChecklistRepository
+	// Dependencies lets todos depend on each other
+	Dependencies DependencyRepository
+	// Recurrences lets todos repeat
+	Recurrences RecurrenceRepository
+	// Projects lets todos be put in projects
+	Projects ProjectRepository
+	// Participants lets todos be assigned and watched
+	Participants ParticipantRepository
+	// Users checks user IDs against a directory
+	Users UserDirectory
+}
+
+// NewTodoService creates the todo service
+func NewTodoService(deps Deps) TodoService {
+	return newTodoService(deps)
+}
+
+func newTodoService(deps Deps) *todoService {
 	return &todoService{
-		todoRepo:  todoRepo,
-		messaging: messaging,
-		cache:     cache,
-		logger:    slog.Default(),
+		todoRepo:     deps.Todos,
+		attachments:  deps.Attachments,
+		messaging:    deps.Messaging,
+		cache:        deps.Cache,
+		files:        deps.Files,
+		tags:         deps.Tags,
+		checklists:   deps.Checklists,
+		dependencies: deps.Dependencies,
+		recurrences:  deps.Recurrences,
+		projects:     deps.Projects,
+		participants: deps.Participants,
+		users:        deps.Users,
+	}
+}
+
+// checkFile rejects references to files that don't exist, belong to someone
+// else or can't be linked yet, such as files waiting for their malware scan
+func (s *todoService) checkFile(ctx context.Context, fileID string) error {
+	if _, err := uuid.Parse(fileID); err != nil {
+		return shared.NewValidationError("fileId must be a UUID")
+	}
+	err := s.files.CheckLinkable(ctx, fileID)
+	var domainErr *shared.DomainError
+	if errors.Is(err, shared.ErrNotFound) || (errors.As(err, &domainErr) && domainErr.Code == shared.ErrCodeNotFound) {
+		return shared.NewValidationError("fileId does not refer to an existing file")
+	}
+	return err
+}
+
+// loadAttachments fills in the attached file IDs of todos
+func (s *todoService) loadAttachments(ctx context.Context, todos ...*TodoItem) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(todos))
+	byID := make(map[uuid.UUID]*TodoItem, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+		byID[todo.ID] = todo
+		todo.FileIDs = nil
+	}
+
+	attachments, err := s.attachments.ListByTodos(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		if todo, ok := byID[attachment.TodoID]; ok {
+			todo.FileIDs = append(todo.FileIDs, attachment.FileID)
+		}
+	}
+	return nil
+}
+
+// loadTags fills in the tag names of todos
+func (s *todoService) loadTags(ctx context.Context, todos ...*TodoItem) error {
+	if s.tags == nil || len(todos) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(todos))
+	byID := make(map[uuid.UUID]*TodoItem, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+		byID[todo.ID] = todo
+		todo.Tags = nil
+	}
+
+	tags, err := s.tags.ListByTodos(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if todo, ok := byID[tag.TodoID]; ok {
+			todo.Tags = append(todo.Tags, tag.Name)
+		}
+	}
+	return nil
+}
+
+// load fills in what todos refer to
+func (s *todoService) load(ctx context.Context, todos ...*TodoItem) error {
+	if err := s.loadAttachments(ctx, todos...); err != nil {
+		return err
+	}
+	if err := s.loadTags(ctx, todos...); err != nil {
+		return err
+	}
+	if err := s.loadRecurrences(ctx, todos...); err != nil {
+		return err
 	}
+	if err := s.loadParticipants(ctx, todos...); err != nil {
+		return err
+	}
+	return s.loadProgress(ctx, todos...)
+}
+
+// checkTags normalizes the tag names given for a todo
+func (s *todoService) checkTags(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if s.tags == nil {
+		return nil, errTagsDisabled
+	}
+	tags, err := normalizeTags(names)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > maxTags {
+		return nil, shared.NewValidationError(fmt.Sprintf("at most %d tags can be set", maxTags))
+	}
+	return tags, nil
+}
+
+// setTags replaces the tags of a todo, adding new names to the caller's
+// workspace catalogue
+func (s *todoService) setTags(ctx context.Context, todo *TodoItem, names []string) error {
+	var ids []uuid.UUID
+	todo.Tags = nil
+	if len(names) > 0 {
+		tags, err := s.tags.Ensure(ctx, identity.FromContext(ctx).WorkspaceID, names)
+		if err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			ids = append(ids, tag.ID)
+			todo.Tags = append(todo.Tags, tag.Name)
+		}
+	}
+	return s.tags.SetTodoTags(ctx, todo.ID, ids)
 }
 
 func (s *todoService) CreateTodo(ctx context.Context, req *CreateTodoRequest) (*TodoItem, error) {
+	logger := logging.FromContext(ctx)
+
 	if req.Description == "" {
 		return nil, shared.NewValidationError("description is required")
 	}
@@ -35,64 +208,178 @@ func (s *todoService) CreateTodo(ctx context.Context, req *CreateTodoRequest) (*
 		return nil, shared.NewValidationError("due date must be in the future")
 	}
 
+	fileIDs := req.FileIDs
+	if req.FileID != nil {
+		fileIDs = append(fileIDs, *req.FileID)
+	}
+	fileIDs = uniqueStrings(fileIDs)
+	if len(fileIDs) > maxAttachments {
+		return nil, shared.NewValidationError(fmt.Sprintf("at most %d files can be attached", maxAttachments))
+	}
+	for _, fileID := range fileIDs {
+		if err := s.checkFile(ctx, fileID); err != nil {
+			return nil, err
+		}
+	}
+	tags, err := s.checkTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	priority := PriorityMedium
+	if req.Priority != "" {
+		if priority, err = ParsePriority(string(req.Priority)); err != nil {
+			return nil, shared.NewValidationError(err.Error())
+		}
+	}
+
 	todo := &TodoItem{
 		ID:          uuid.New(),
 		Description: req.Description,
 		DueDate:     req.DueDate,
-		FileID:      req.FileID,
+		Priority:    priority,
+		Status:      StatusOpen,
+		FileIDs:     fileIDs,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
+	if req.ParentID != nil {
+		if todo.ParentID, err = parseParentID(*req.ParentID); err != nil {
+			return nil, err
+		}
+		if todo.ParentID != nil {
+			if err := s.checkParent(ctx, todo, *todo.ParentID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if req.ProjectID != nil {
+		if todo.ProjectID, err = s.checkProject(ctx, *req.ProjectID); err != nil {
+			return nil, err
+		}
+	}
+	if todo.ParentID != nil {
+		parent, err := s.todoRepo.GetByID(ctx, *todo.ParentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parent: %w", err)
+		}
+		if req.ProjectID != nil && !sameProject(todo.ProjectID, parent.ProjectID) {
+			return nil, shared.NewValidationError("subtasks are in their parent's project")
+		}
+		todo.ProjectID = parent.ProjectID
+	}
+	if req.Recurrence != nil {
+		if _, err := s.newRecurrence(ctx, req.Recurrence, todo, tags); err != nil {
+			return nil, err
+		}
+	}
+
+	if todo.Rank, err = s.rankLast(ctx, todo.Status, todo.ID); err != nil {
+		return nil, fmt.Errorf("failed to rank todo: %w", err)
+	}
 
 	if err := s.todoRepo.Create(ctx, todo); err != nil {
-		s.logger.Error("failed to create todo", "error", err, "todo_id", todo.ID)
+		logger.Error("failed to create todo", "error", err, "todo_id", todo.ID)
 		return nil, fmt.Errorf("failed to create todo: %w", err)
 	}
+	todo.Progress = &Progress{}
 
-	s.logger.Info("todo created successfully", "todo_id", todo.ID, "description", todo.Description)
-
-	// Publish to messaging system
-	go func() {
-		if err := s.messaging.Publish(context.Background(), "todo.created", todo); err != nil {
-			s.logger.Error("failed to publish todo created event", "error", err, "todo_id", todo.ID)
-		} else {
-			s.logger.Info("todo created event published", "todo_id", todo.ID)
+	for _, fileID := range fileIDs {
+		attachment := &Attachment{TodoID: todo.ID, FileID: fileID, CreatedAt: todo.CreatedAt}
+		if err := s.attachments.Add(ctx, attachment); err != nil {
+			logger.Error("failed to attach file", "error", err, "todo_id", todo.ID, "file_id", fileID)
+			return nil, fmt.Errorf("failed to attach file: %w", err)
 		}
-	}()
+	}
+	if len(tags) > 0 {
+		if err := s.setTags(ctx, todo, tags); err != nil {
+			logger.Error("failed to tag todo", "error", err, "todo_id", todo.ID)
+			return nil, fmt.Errorf("failed to tag todo: %w", err)
+		}
+	}
+
+	logger.Info("todo created successfully", "todo_id", todo.ID, "description", todo.Description)
+
+	publish(ctx, s.messaging, TopicTodoCreated, todo)
 
 	return todo, nil
 }
 
 func (s *todoService) GetTodo(ctx context.Context, id uuid.UUID) (*TodoItem, error) {
+	logger := logging.FromContext(ctx)
+
 	todo, err := s.todoRepo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to get todo", "error", err, "todo_id", id)
+		logger.Error("failed to get todo", "error", err, "todo_id", id)
 		return nil, fmt.Errorf("failed to get todo: %w", err)
 	}
+	if err := s.load(ctx, todo); err != nil {
+		return nil, fmt.Errorf("failed to load todo: %w", err)
+	}
 
-	s.logger.Info("todo retrieved", "todo_id", id)
+	logger.Info("todo retrieved", "todo_id", id)
 	return todo, nil
 }
 
-func (s *todoService) ListTodos(ctx context.Context, limit, offset int) ([]*TodoItem, error) {
+func (s *todoService) ListTodos(ctx context.Context, filter ListFilter, limit, offset int) ([]*TodoItem, error) {
+	logger := logging.FromContext(ctx)
+
 	if limit <= 0 || limit > 100 {
 		limit = 10
 	}
 	if offset < 0 {
 		offset = 0
 	}
+	for _, priority := range filter.Priorities {
+		if _, err := ParsePriority(string(priority)); err != nil {
+			return nil, shared.NewValidationError(err.Error())
+		}
+	}
+	for _, status := range filter.Statuses {
+		switch status {
+		case StatusOpen, StatusInProgress, StatusDone:
+		default:
+			return nil, shared.NewValidationError(fmt.Sprintf("invalid status: %s", status))
+		}
+	}
+	tags, err := normalizeTags(filter.Tags)
+	if err != nil {
+		return nil, err
+	}
+	filter.Tags = tags
+	filter.WorkspaceID = identity.FromContext(ctx).WorkspaceID
+	if filter.Assignee != "" {
+		if s.participants == nil {
+			return nil, errParticipantsDisabled
+		}
+		if filter.Assignee, err = resolveUser(ctx, filter.Assignee); err != nil {
+			return nil, err
+		}
+	}
+	if filter.ProjectID != nil {
+		if s.projects == nil {
+			return nil, errProjectsDisabled
+		}
+		if _, err := getProject(ctx, s.projects, *filter.ProjectID); err != nil {
+			return nil, err
+		}
+	}
 
-	todos, err := s.todoRepo.List(ctx, limit, offset)
+	todos, err := s.todoRepo.List(ctx, filter, limit, offset)
 	if err != nil {
-		s.logger.Error("failed to list todos", "error", err, "limit", limit, "offset", offset)
+		logger.Error("failed to list todos", "error", err, "limit", limit, "offset", offset)
 		return nil, fmt.Errorf("failed to list todos: %w", err)
 	}
+	if err := s.load(ctx, todos...); err != nil {
+		return nil, fmt.Errorf("failed to load todos: %w", err)
+	}
 
-	s.logger.Info("todos listed", "count", len(todos), "limit", limit, "offset", offset)
+	logger.Info("todos listed", "count", len(todos), "limit", limit, "offset", offset)
 	return todos, nil
 }
 
 func (s *todoService) UpdateTodo(ctx context.Context, id uuid.UUID, req *UpdateTodoRequest) (*TodoItem, error) {
+	logger := logging.FromContext(ctx)
+
 	// Get existing todo
 	existing, err := s.todoRepo.GetByID(ctx, id)
 	if err != nil {
@@ -105,32 +392,177 @@ func (s *todoService) UpdateTodo(ctx context.Context, id uuid.UUID, req *UpdateT
 	if req.DueDate != nil {
 		existing.DueDate = *req.DueDate
 	}
-	if req.FileID != nil {
-		existing.FileID = req.FileID
+	if req.Priority != nil {
+		if existing.Priority, err = ParsePriority(string(*req.Priority)); err != nil {
+			return nil, shared.NewValidationError(err.Error())
+		}
+	}
+	var tags []string
+	if req.Tags != nil {
+		if tags, err = s.checkTags(*req.Tags); err != nil {
+			return nil, err
+		}
+	}
+	wasDone, column := existing.Status == StatusDone, existing.Status
+	if err := s.updateStatus(ctx, existing, req); err != nil {
+		return nil, err
+	}
+	if existing.Status != column {
+		if existing.Rank, err = s.rankLast(ctx, existing.Status, existing.ID); err != nil {
+			return nil, fmt.Errorf("failed to rank todo: %w", err)
+		}
+	}
+	if err := s.updateProject(ctx, existing, req); err != nil {
+		return nil, err
+	}
+	if err := s.updateSeries(ctx, existing, req, tags); err != nil {
+		return nil, err
 	}
 
 	existing.UpdatedAt = time.Now()
 
 	if err := s.todoRepo.Update(ctx, existing); err != nil {
-		s.logger.Error("failed to update todo", "error", err, "todo_id", id)
+		logger.Error("failed to update todo", "error", err, "todo_id", id)
 		return nil, fmt.Errorf("failed to update todo: %w", err)
 	}
+	if existing.Status != column {
+		if err := s.todoRepo.SetRanks(ctx, map[uuid.UUID]string{id: existing.Rank}); err != nil {
+			logger.Error("failed to rank todo", "error", err, "todo_id", id)
+			return nil, fmt.Errorf("failed to rank todo: %w", err)
+		}
+	}
+	if req.Tags != nil && s.tags != nil {
+		if err := s.setTags(ctx, existing, tags); err != nil {
+			logger.Error("failed to tag todo", "error", err, "todo_id", id)
+			return nil, fmt.Errorf("failed to tag todo: %w", err)
+		}
+	}
+	if !wasDone && existing.Status == StatusDone {
+		s.completeOccurrence(ctx, existing)
+	}
+	if err := s.load(ctx, existing); err != nil {
+		return nil, fmt.Errorf("failed to load todo: %w", err)
+	}
 
-	s.logger.Info("todo updated", "todo_id", id)
+	logger.Info("todo updated", "todo_id", id)
+	publish(ctx, s.messaging, TopicTodoUpdated, existing)
 	return existing, nil
 }
 
 func (s *todoService) DeleteTodo(ctx context.Context, id uuid.UUID) error {
 	// Check if todo exists
-	if _, err := s.todoRepo.GetByID(ctx, id); err != nil {
+	existing, err := s.todoRepo.GetByID(ctx, id)
+	if err != nil {
 		return fmt.Errorf("todo not found: %w", err)
 	}
+	subtasks, err := s.todoRepo.ListChildren(ctx, []uuid.UUID{id})
+	if err != nil {
+		return fmt.Errorf("failed to delete todo: %w", err)
+	}
+	if len(subtasks) > 0 {
+		return errHasSubtasks
+	}
+	return s.deleteTodo(ctx, existing)
+}
 
+// deleteTodo deletes a todo without subtasks along with everything that
+// refers to it
+func (s *todoService) deleteTodo(ctx context.Context, existing *TodoItem) error {
+	logger := logging.FromContext(ctx)
+	id := existing.ID
+
+	if err := s.attachments.DeleteByTodo(ctx, id); err != nil {
+		logger.Error("failed to delete todo attachments", "error", err, "todo_id", id)
+		return fmt.Errorf("failed to delete todo: %w", err)
+	}
+	if s.checklists != nil {
+		if err := s.checklists.DeleteByTodo(ctx, id); err != nil {
+			logger.Error("failed to delete todo checklist", "error", err, "todo_id", id)
+			return fmt.Errorf("failed to delete todo: %w", err)
+		}
+	}
+	if s.dependencies != nil {
+		if err := s.dependencies.DeleteByTodo(ctx, id); err != nil {
+			logger.Error("failed to delete todo dependencies", "error", err, "todo_id", id)
+			return fmt.Errorf("failed to delete todo: %w", err)
+		}
+	}
+	if s.participants != nil {
+		if err := s.participants.DeleteByTodo(ctx, id); err != nil {
+			logger.Error("failed to delete todo participants", "error", err, "todo_id", id)
+			return fmt.Errorf("failed to delete todo: %w", err)
+		}
+	}
 	if err := s.todoRepo.Delete(ctx, id); err != nil {
-		s.logger.Error("failed to delete todo", "error", err, "todo_id", id)
+		logger.Error("failed to delete todo", "error", err, "todo_id", id)
 		return fmt.Errorf("failed to delete todo: %w", err)
 	}
 
-	s.logger.Info("todo deleted", "todo_id", id)
+	logger.Info("todo deleted", "todo_id", id)
+	publish(ctx, s.messaging, TopicTodoDeleted, existing)
 	return nil
 }
+
+func (s *todoService) AttachFile(ctx context.Context, id uuid.UUID, fileID string) (*Attachment, error) {
+	logger := logging.FromContext(ctx)
+
+	todo, err := s.todoRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("todo not found: %w", err)
+	}
+	if err := s.loadAttachments(ctx, todo); err != nil {
+		return nil, err
+	}
+	for _, attached := range todo.FileIDs {
+		if attached == fileID {
+			return &Attachment{TodoID: id, FileID: fileID}, nil
+		}
+	}
+	if len(todo.FileIDs) >= maxAttachments {
+		return nil, shared.NewValidationError(fmt.Sprintf("at most %d files can be attached", maxAttachments))
+	}
+	if err := s.checkFile(ctx, fileID); err != nil {
+		return nil, err
+	}
+
+	attachment := &Attachment{TodoID: id, FileID: fileID, CreatedAt: time.Now()}
+	if err := s.attachments.Add(ctx, attachment); err != nil {
+		logger.Error("failed to attach file", "error", err, "todo_id", id, "file_id", fileID)
+		return nil, fmt.Errorf("failed to attach file: %w", err)
+	}
+
+	logger.Info("file attached", "todo_id", id, "file_id", fileID)
+	return attachment, nil
+}
+
+func (s *todoService) DetachFile(ctx context.Context, id uuid.UUID, fileID string) error {
+	if _, err := s.todoRepo.GetByID(ctx, id); err != nil {
+		return fmt.Errorf("todo not found: %w", err)
+	}
+	if err := s.attachments.Remove(ctx, id, fileID); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("file detached", "todo_id", id, "file_id", fileID)
+	return nil
+}
+
+func (s *todoService) ListAttachments(ctx context.Context, id uuid.UUID) ([]*Attachment, error) {
+	if _, err := s.todoRepo.GetByID(ctx, id); err != nil {
+		return nil, fmt.Errorf("todo not found: %w", err)
+	}
+	return s.attachments.ListByTodos(ctx, []uuid.UUID{id})
+}
+
+// uniqueStrings returns values without duplicates, keeping the first of each
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := values[:0:0]
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,277 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"taskflow/internal/domain/shared"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxDepth is the most levels a todo tree can have, counting the top-level
+// todo
+const maxDepth = 5
+
+// Progress rolls up how much of a todo is done
+type Progress struct {
+	// Percent averages the todo's checklist items and direct subtasks, each
+	// subtask counting with its own progress. Done todos are 100.
+	Percent        int `json:"percent"`
+	Subtasks       int `json:"subtasks"`
+	SubtasksDone   int `json:"subtasksDone"`
+	ChecklistItems int `json:"checklistItems"`
+	ChecklistDone  int `json:"checklistDone"`
+}
+
+// errOpenSubtasks is returned when a todo with open subtasks would be done
+var errOpenSubtasks = shared.NewDomainError(shared.ErrCodeConflict, "Todo has subtasks that aren't done", "")
+
+// errParentDone is returned when an open todo would be under a done one
+var errParentDone = shared.NewDomainError(shared.ErrCodeConflict, "Parent todo is done", "")
+
+// parseParentID parses the parent ID given for a todo. Empty means none.
+func parseParentID(parentID string) (*uuid.UUID, error) {
+	if parentID == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(parentID)
+	if err != nil {
+		return nil, shared.NewValidationError("parentId must be a UUID")
+	}
+	return &id, nil
+}
+
+// checkParent rejects placing a todo under a parent that doesn't exist, is
+// the todo or one of its subtasks, would make the tree too deep or is done
+// while the todo isn't
+func (s *todoService) checkParent(ctx context.Context, todo *TodoItem, parentID uuid.UUID) error {
+	parent, err := s.todoRepo.GetByID(ctx, parentID)
+	var domainErr *shared.DomainError
+	if errors.Is(err, shared.ErrNotFound) || (errors.As(err, &domainErr) && domainErr.Code == shared.ErrCodeNotFound) {
+		return shared.NewValidationError("parentId does not refer to an existing todo")
+	}
+	if err != nil {
+		return err
+	}
+
+	// Walk up from the parent: meeting the todo means it would be its own
+	// ancestor
+	depth := 1
+	for ancestor := parent; ; depth++ {
+		if ancestor.ID == todo.ID {
+			return shared.NewValidationError("a todo can't be a subtask of itself or its subtasks")
+		}
+		if ancestor.ParentID == nil {
+			break
+		}
+		if depth >= maxDepth {
+			return shared.NewValidationError(fmt.Sprintf("todos can be nested at most %d levels deep", maxDepth))
+		}
+		if ancestor, err = s.todoRepo.GetByID(ctx, *ancestor.ParentID); err != nil {
+			return err
+		}
+	}
+
+	levels, err := s.descendants(ctx, todo)
+	if err != nil {
+		return err
+	}
+	if depth+1+len(levels) > maxDepth {
+		return shared.NewValidationError(fmt.Sprintf("todos can be nested at most %d levels deep", maxDepth))
+	}
+
+	if parent.Status == StatusDone && todo.Status != StatusDone {
+		return errParentDone
+	}
+	return nil
+}
+
+// updateStatus applies the status and parent changes of req to todo. A todo
+// can only be started once the todos blocking it are done, only be done once
+// its subtasks are, and only be open under an open parent.
+func (s *todoService) updateStatus(ctx context.Context, todo *TodoItem, req *UpdateTodoRequest) error {
+	reopened := false
+	if req.Status != nil && *req.Status != todo.Status {
+		switch *req.Status {
+		case StatusOpen:
+			reopened = todo.Status == StatusDone
+			todo.CompletedAt = nil
+		case StatusInProgress:
+			if err := s.checkUnblocked(ctx, todo); err != nil {
+				return err
+			}
+			reopened = todo.Status == StatusDone
+			todo.CompletedAt = nil
+		case StatusDone:
+			if err := s.checkUnblocked(ctx, todo); err != nil {
+				return err
+			}
+			subtasks, err := s.todoRepo.ListChildren(ctx, []uuid.UUID{todo.ID})
+			if err != nil {
+				return err
+			}
+			for _, subtask := range subtasks {
+				if subtask.Status != StatusDone {
+					return errOpenSubtasks
+				}
+			}
+			now := time.Now()
+			todo.CompletedAt = &now
+		default:
+			return shared.NewValidationError(fmt.Sprintf("invalid status: %s", *req.Status))
+		}
+		todo.Status = *req.Status
+	}
+
+	if req.ParentID != nil {
+		parentID, err := parseParentID(*req.ParentID)
+		if err != nil {
+			return err
+		}
+		if parentID != nil {
+			if err := s.checkParent(ctx, todo, *parentID); err != nil {
+				return err
+			}
+		}
+		todo.ParentID = parentID
+	} else if reopened && todo.ParentID != nil {
+		parent, err := s.todoRepo.GetByID(ctx, *todo.ParentID)
+		if err != nil {
+			return err
+		}
+		if parent.Status == StatusDone {
+			return errParentDone
+		}
+	}
+	return nil
+}
+
+// descendants returns the subtasks of todos level by level, stopping after
+// maxDepth levels
+func (s *todoService) descendants(ctx context.Context, todos ...*TodoItem) ([][]*TodoItem, error) {
+	var levels [][]*TodoItem
+	level := todos
+	for len(levels) < maxDepth {
+		ids := make([]uuid.UUID, len(level))
+		for i, todo := range level {
+			ids[i] = todo.ID
+		}
+		if len(ids) == 0 {
+			break
+		}
+		children, err := s.todoRepo.ListChildren(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		if len(children) == 0 {
+			break
+		}
+		levels = append(levels, children)
+		level = children
+	}
+	return levels, nil
+}
+
+// loadProgress fills in the checklists and progress of todos
+func (s *todoService) loadProgress(ctx context.Context, todos ...*TodoItem) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	levels, err := s.descendants(ctx, todos...)
+	if err != nil {
+		return err
+	}
+
+	// todos may include each other's subtasks, so those are seen twice
+	byID := make(map[uuid.UUID]*TodoItem)
+	children := make(map[uuid.UUID][]*TodoItem)
+	seen := make(map[uuid.UUID]bool)
+	for _, todo := range todos {
+		byID[todo.ID] = todo
+	}
+	for _, level := range levels {
+		for _, child := range level {
+			if seen[child.ID] {
+				continue
+			}
+			seen[child.ID] = true
+			if _, ok := byID[child.ID]; !ok {
+				byID[child.ID] = child
+			}
+			children[*child.ParentID] = append(children[*child.ParentID], child)
+		}
+	}
+
+	checklists := make(map[uuid.UUID][]*ChecklistItem)
+	if s.checklists != nil {
+		ids := make([]uuid.UUID, 0, len(byID))
+		for id := range byID {
+			ids = append(ids, id)
+		}
+		items, err := s.checklists.ListByTodos(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			checklists[item.TodoID] = append(checklists[item.TodoID], item)
+		}
+	}
+
+	var fraction func(todo *TodoItem) float64
+	fraction = func(todo *TodoItem) float64 {
+		if todo.Status == StatusDone {
+			return 1
+		}
+		var done float64
+		units := len(checklists[todo.ID]) + len(children[todo.ID])
+		for _, item := range checklists[todo.ID] {
+			if item.Done {
+				done++
+			}
+		}
+		for _, child := range children[todo.ID] {
+			done += fraction(child)
+		}
+		if units == 0 {
+			return 0
+		}
+		return done / float64(units)
+	}
+
+	for _, todo := range todos {
+		progress := &Progress{
+			Percent:        int(fraction(todo) * 100),
+			Subtasks:       len(children[todo.ID]),
+			ChecklistItems: len(checklists[todo.ID]),
+		}
+		for _, child := range children[todo.ID] {
+			if child.Status == StatusDone {
+				progress.SubtasksDone++
+			}
+		}
+		for _, item := range checklists[todo.ID] {
+			if item.Done {
+				progress.ChecklistDone++
+			}
+		}
+		todo.Checklist = checklists[todo.ID]
+		todo.Progress = progress
+	}
+	return nil
+}
+
+func (s *todoService) ListSubtasks(ctx context.Context, id uuid.UUID) ([]*TodoItem, error) {
+	if _, err := s.todoRepo.GetByID(ctx, id); err != nil {
+		return nil, fmt.Errorf("todo not found: %w", err)
+	}
+	subtasks, err := s.todoRepo.ListChildren(ctx, []uuid.UUID{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subtasks: %w", err)
+	}
+	if err := s.load(ctx, subtasks...); err != nil {
+		return nil, fmt.Errorf("failed to load todos: %w", err)
+	}
+	return subtasks, nil
+}
@@ -0,0 +1,179 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/identity"
+	"taskflow/pkg/logging"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxTags is the most tags one todo can have
+	maxTags = 20
+	// maxTagLength is the longest tag name, in characters
+	maxTagLength = 50
+)
+
+// Tag is a label in a workspace's catalogue. Todos refer to tags by ID, so
+// renaming a tag renames it on every todo. Todos aren't scoped to a
+// workspace, so one todo can have tags from several catalogues.
+type Tag struct {
+	ID          uuid.UUID `json:"id" db:"id" gorm:"type:char(36);primaryKey"`
+	WorkspaceID string    `json:"-" db:"workspace_id" gorm:"size:64;uniqueIndex:idx_tag_name"`
+	Name        string    `json:"name" db:"name" gorm:"size:50;uniqueIndex:idx_tag_name"`
+	// TodoCount is how many todos have the tag, filled in by listings
+	TodoCount int64     `json:"todoCount" db:"todo_count" gorm:"->;-:migration"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// TodoTag links a tag to a todo
+type TodoTag struct {
+	TodoID uuid.UUID `json:"todoId" db:"todo_id" gorm:"type:char(36);primaryKey"`
+	TagID  uuid.UUID `json:"tagId" db:"tag_id" gorm:"type:char(36);primaryKey;index"`
+	// Name is the tag's name, filled in by listings
+	Name string `json:"name" db:"name" gorm:"->;-:migration"`
+}
+
+// TagRequest names a tag
+type TagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// MergeTagRequest selects the tag another is merged into
+type MergeTagRequest struct {
+	Into string `json:"into" binding:"required,uuid"`
+}
+
+// normalizeTag returns the catalogue form of a tag name: lowercased with
+// whitespace collapsed
+func normalizeTag(name string) (string, error) {
+	normalized := strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if normalized == "" {
+		return "", shared.NewValidationError("tag names must not be empty")
+	}
+	if utf8.RuneCountInString(normalized) > maxTagLength {
+		return "", shared.NewValidationError(fmt.Sprintf("tag names must be at most %d characters", maxTagLength))
+	}
+	return normalized, nil
+}
+
+// normalizeTags normalizes tag names and drops duplicates
+func normalizeTags(names []string) ([]string, error) {
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		tag, err := normalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, tag)
+	}
+	return uniqueStrings(normalized), nil
+}
+
+type tagService struct {
+	tags TagRepository
+}
+
+// NewTagService creates the service managing the tag catalogues of
+// workspaces
+func NewTagService(tags TagRepository) TagService {
+	return &tagService{tags: tags}
+}
+
+func (s *tagService) ListTags(ctx context.Context) ([]*Tag, error) {
+	return s.tags.List(ctx, identity.FromContext(ctx).WorkspaceID)
+}
+
+func (s *tagService) CreateTag(ctx context.Context, req *TagRequest) (*Tag, error) {
+	name, err := normalizeTag(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.tags.Ensure(ctx, identity.FromContext(ctx).WorkspaceID, []string{name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tag: %w", err)
+	}
+	return tags[0], nil
+}
+
+func (s *tagService) RenameTag(ctx context.Context, id uuid.UUID, req *TagRequest) (*Tag, error) {
+	tag, err := s.getTag(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	name, err := normalizeTag(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if name == tag.Name {
+		return tag, nil
+	}
+	existing, err := s.tags.GetByNames(ctx, tag.WorkspaceID, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, shared.NewDomainError(shared.ErrCodeConflict, "Tag already exists", "merge the tags instead")
+	}
+
+	logging.FromContext(ctx).Info("tag renamed", "tag_id", id, "from", tag.Name, "to", name)
+	tag.Name = name
+	if err := s.tags.Update(ctx, tag); err != nil {
+		return nil, fmt.Errorf("failed to rename tag: %w", err)
+	}
+	return tag, nil
+}
+
+func (s *tagService) MergeTag(ctx context.Context, id uuid.UUID, req *MergeTagRequest) (*Tag, error) {
+	intoID, err := uuid.Parse(req.Into)
+	if err != nil {
+		return nil, shared.NewValidationError("into must be a UUID")
+	}
+	if intoID == id {
+		return nil, shared.NewValidationError("a tag can't be merged into itself")
+	}
+	tag, err := s.getTag(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	into, err := s.getTag(ctx, intoID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.tags.Merge(ctx, tag.ID, into.ID); err != nil {
+		return nil, fmt.Errorf("failed to merge tags: %w", err)
+	}
+	logging.FromContext(ctx).Info("tags merged", "tag_id", id, "into", intoID)
+	return s.tags.Get(ctx, into.ID)
+}
+
+func (s *tagService) DeleteTag(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.getTag(ctx, id); err != nil {
+		return err
+	}
+	if err := s.tags.Delete(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+	logging.FromContext(ctx).Info("tag deleted", "tag_id", id)
+	return nil
+}
+
+// getTag returns a tag of the caller's workspace. Tags of other workspaces
+// are not found.
+func (s *tagService) getTag(ctx context.Context, id uuid.UUID) (*Tag, error) {
+	tag, err := s.tags.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag.WorkspaceID != identity.FromContext(ctx).WorkspaceID {
+		return nil, shared.NewNotFoundError("tag not found")
+	}
+	return tag, nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -13,6 +15,23 @@ type Config struct {
 	S3Config    S3Config
 	Environment string
 	LogLevel    string
+	LogFormat   string
+	RedactKeys  []string
+	Tracing     TracingConfig
+	Health      HealthConfig
+	Upload      UploadConfig
+	Thumbnails  ThumbnailConfig
+	Scanner     ScannerConfig
+	Attachments AttachmentConfig
+	GC          GCConfig
+	Quota       QuotaConfig
+	Encryption  EncryptionConfig
+	Versions    VersionConfig
+	Archive     ArchiveConfig
+	Search      SearchConfig
+	Recurrence  RecurrenceConfig
+	Ranks       RankConfig
+	Users       UsersConfig
 }
 
 type S3Config struct {
@@ -23,6 +42,131 @@ type S3Config struct {
 	Endpoint        string
 }
 
+type TracingConfig struct {
+	Exporter     string // none, stdout or otlp
+	OTLPEndpoint string
+	ServiceName  string
+	SampleRatio  float64
+}
+
+type HealthConfig struct {
+	CheckTimeout        time.Duration
+	CacheTTL            time.Duration
+	ShutdownDrainDelay  time.Duration
+	ConsumerStreams     []string
+	MaxConsumerLag      int64
+	ConsumerLagOptional bool
+}
+
+type UploadConfig struct {
+	MaxSize           int64
+	AllowedExtensions []string
+	PresignExpiry     time.Duration
+	PendingTTL        time.Duration
+	CleanupInterval   time.Duration
+	ResumableExpiry   time.Duration
+	ResumableLockTTL  time.Duration
+	// Deduplicate stores identical content once, shared by reference count
+	Deduplicate bool
+}
+
+type ThumbnailConfig struct {
+	Sizes     []int
+	MaxPixels int64
+}
+
+type ScannerConfig struct {
+	Backend      string // none or clamd
+	ClamdAddress string
+	Timeout      time.Duration
+	// RescanAfter is how long a file may stay quarantined before the sweep
+	// scans it again
+	RescanAfter   time.Duration
+	SweepInterval time.Duration
+}
+
+type AttachmentConfig struct {
+	// FileDeletePolicy is block, detach or cascade
+	FileDeletePolicy string
+}
+
+type GCConfig struct {
+	Interval time.Duration
+	// GracePeriod protects objects and files younger than it
+	GracePeriod time.Duration
+	// UnattachedAfter also collects files attached to no todo once this
+	// old; zero keeps them
+	UnattachedAfter time.Duration
+	// DryRun makes scheduled runs only report what they would delete
+	DryRun bool
+}
+
+// QuotaConfig limits what each user and workspace may store. Zero is
+// unlimited.
+type QuotaConfig struct {
+	UserBytes      int64
+	UserFiles      int64
+	WorkspaceBytes int64
+	WorkspaceFiles int64
+}
+
+type EncryptionConfig struct {
+	// KeyFile holds the master keys; empty stores files unencrypted
+	KeyFile string
+	// RewrapInterval is how often data keys wrapped with an older master
+	// key are moved to the current one
+	RewrapInterval time.Duration
+}
+
+// VersionConfig is the retention policy for earlier file versions
+type VersionConfig struct {
+	// MaxCount is how many earlier versions each file keeps; zero keeps all
+	MaxCount int
+	// MaxAge drops earlier versions uploaded longer ago; zero keeps them
+	MaxAge time.Duration
+	// PruneInterval is how often versions past MaxAge are swept
+	PruneInterval time.Duration
+}
+
+// ArchiveConfig bounds zip archives of files. Zero is unlimited.
+type ArchiveConfig struct {
+	MaxFiles int
+	// MaxSize is the total size of the archived files before compression
+	MaxSize int64
+}
+
+// SearchConfig selects the search index and bounds attachment text
+// extraction
+type SearchConfig struct {
+	// Backend is "memory" for an index held by each instance or "mysql" for
+	// the database's full-text index
+	Backend string
+	// MaxFileSize skips attachments larger than it; zero indexes all
+	MaxFileSize int64
+	// MaxTextSize truncates the text indexed per attachment, in bytes
+	MaxTextSize int
+}
+
+// RecurrenceConfig sets how often recurring todos are checked for past due
+// occurrences
+type RecurrenceConfig struct {
+	Interval time.Duration
+}
+
+// RankConfig sets how often board columns are checked for ranks that have
+// run short of room
+type RankConfig struct {
+	RebalanceInterval time.Duration
+}
+
+// UsersConfig sets where the user IDs todos are assigned to are checked
+type UsersConfig struct {
+	Backend string // none or http
+	// URL is the users service, asked for /users/{id}
+	URL     string
+	Timeout time.Duration
+}
+
 func Load() *Config {
 	cfg := &Config{
 		Port:        getEnv("PORT", "8080"),
@@ -30,6 +174,8 @@ func Load() *Config {
 		RedisURL:    getEnv("REDIS_URL", "localhost:6379"),
 		Environment: getEnv("ENVIRONMENT", "development"),
 		LogLevel:    getEnv("LOG_LEVEL", "info"),
+		LogFormat:   getEnv("LOG_FORMAT", "json"),
+		RedactKeys:  getEnvList("LOG_REDACT_KEYS", nil),
 		S3Config: S3Config{
 			Region:          getEnv("AWS_REGION", "us-east-1"),
 			Bucket:          getEnv("S3_BUCKET", "todo-files"),
@@ -37,6 +183,87 @@ func Load() *Config {
 			SecretAccessKey: getEnv("AWS_SECRET_ACCESS_KEY", "test"),
 			Endpoint:        getEnv("S3_ENDPOINT", "http://localhost:4566"),
 		},
+		Tracing: TracingConfig{
+			Exporter:     getEnv("TRACING_EXPORTER", "none"),
+			OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "localhost:4318"),
+			ServiceName:  getEnv("OTEL_SERVICE_NAME", "taskflow"),
+			SampleRatio:  getEnvFloat("TRACING_SAMPLE_RATIO", 1.0),
+		},
+		Health: HealthConfig{
+			CheckTimeout:        getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			CacheTTL:            getEnvDuration("HEALTH_CACHE_TTL", 5*time.Second),
+			ShutdownDrainDelay:  getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+			ConsumerStreams:     getEnvList("HEALTH_CONSUMER_STREAMS", []string{"todo.created", "file.uploaded", "file.scanned"}),
+			MaxConsumerLag:      getEnvInt64("HEALTH_MAX_CONSUMER_LAG", 1000),
+			ConsumerLagOptional: getEnvBool("HEALTH_CONSUMER_LAG_OPTIONAL", false),
+		},
+		Upload: UploadConfig{
+			MaxSize: getEnvInt64("UPLOAD_MAX_SIZE", 10*1024*1024),
+			AllowedExtensions: getEnvList("UPLOAD_ALLOWED_EXTENSIONS", []string{
+				".jpg", ".jpeg", ".png", ".gif", ".txt", ".pdf", ".doc", ".docx",
+			}),
+			PresignExpiry:    getEnvDuration("UPLOAD_PRESIGN_EXPIRY", 15*time.Minute),
+			PendingTTL:       getEnvDuration("UPLOAD_PENDING_TTL", 24*time.Hour),
+			CleanupInterval:  getEnvDuration("UPLOAD_CLEANUP_INTERVAL", time.Hour),
+			ResumableExpiry:  getEnvDuration("UPLOAD_RESUMABLE_EXPIRY", 24*time.Hour),
+			ResumableLockTTL: getEnvDuration("UPLOAD_RESUMABLE_LOCK_TTL", time.Minute),
+			Deduplicate:      getEnvBool("UPLOAD_DEDUPLICATE", false),
+		},
+		Thumbnails: ThumbnailConfig{
+			Sizes:     getEnvIntList("THUMBNAIL_SIZES", []int{128, 256, 512}),
+			MaxPixels: getEnvInt64("THUMBNAIL_MAX_PIXELS", 50_000_000),
+		},
+		Scanner: ScannerConfig{
+			Backend:       getEnv("SCANNER", "none"),
+			ClamdAddress:  getEnv("CLAMD_ADDRESS", "localhost:3310"),
+			Timeout:       getEnvDuration("SCANNER_TIMEOUT", 2*time.Minute),
+			RescanAfter:   getEnvDuration("SCANNER_RESCAN_AFTER", 10*time.Minute),
+			SweepInterval: getEnvDuration("SCANNER_SWEEP_INTERVAL", 5*time.Minute),
+		},
+		Attachments: AttachmentConfig{
+			FileDeletePolicy: getEnv("ATTACHMENT_FILE_DELETE_POLICY", "block"),
+		},
+		GC: GCConfig{
+			Interval:        getEnvDuration("GC_INTERVAL", 24*time.Hour),
+			GracePeriod:     getEnvDuration("GC_GRACE_PERIOD", 48*time.Hour),
+			UnattachedAfter: getEnvDuration("GC_UNATTACHED_AFTER", 0),
+			DryRun:          getEnvBool("GC_DRY_RUN", true),
+		},
+		Quota: QuotaConfig{
+			UserBytes:      getEnvInt64("QUOTA_USER_BYTES", 0),
+			UserFiles:      getEnvInt64("QUOTA_USER_FILES", 0),
+			WorkspaceBytes: getEnvInt64("QUOTA_WORKSPACE_BYTES", 0),
+			WorkspaceFiles: getEnvInt64("QUOTA_WORKSPACE_FILES", 0),
+		},
+		Encryption: EncryptionConfig{
+			KeyFile:        getEnv("ENCRYPTION_KEY_FILE", ""),
+			RewrapInterval: getEnvDuration("ENCRYPTION_REWRAP_INTERVAL", time.Hour),
+		},
+		Versions: VersionConfig{
+			MaxCount:      int(getEnvInt64("VERSION_MAX_COUNT", 10)),
+			MaxAge:        getEnvDuration("VERSION_MAX_AGE", 0),
+			PruneInterval: getEnvDuration("VERSION_PRUNE_INTERVAL", 24*time.Hour),
+		},
+		Archive: ArchiveConfig{
+			MaxFiles: int(getEnvInt64("ARCHIVE_MAX_FILES", 100)),
+			MaxSize:  getEnvInt64("ARCHIVE_MAX_SIZE", 1024*1024*1024),
+		},
+		Search: SearchConfig{
+			Backend:     getEnv("SEARCH_BACKEND", "memory"),
+			MaxFileSize: getEnvInt64("SEARCH_MAX_FILE_SIZE", 20*1024*1024),
+			MaxTextSize: int(getEnvInt64("SEARCH_MAX_TEXT_SIZE", 1024*1024)),
+		},
+		Recurrence: RecurrenceConfig{
+			Interval: getEnvDuration("RECURRENCE_INTERVAL", time.Minute),
+		},
+		Ranks: RankConfig{
+			RebalanceInterval: getEnvDuration("RANK_REBALANCE_INTERVAL", time.Hour),
+		},
+		Users: UsersConfig{
+			Backend: getEnv("USERS_BACKEND", "none"),
+			URL:     getEnv("USERS_URL", ""),
+			Timeout: getEnvDuration("USERS_TIMEOUT", 2*time.Second),
+		},
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -88,6 +315,141 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid log level: %s", c.LogLevel)
 	}
 
+	// Validate log format
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		return fmt.Errorf("invalid log format: %s", c.LogFormat)
+	}
+
+	// Validate tracing
+	validExporters := map[string]bool{
+		"none":   true,
+		"stdout": true,
+		"otlp":   true,
+	}
+	if !validExporters[c.Tracing.Exporter] {
+		return fmt.Errorf("invalid tracing exporter: %s", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("invalid tracing sample ratio: %v", c.Tracing.SampleRatio)
+	}
+
+	// Validate uploads
+	if c.Upload.MaxSize <= 0 {
+		return fmt.Errorf("invalid upload max size: %d", c.Upload.MaxSize)
+	}
+	if len(c.Upload.AllowedExtensions) == 0 {
+		return fmt.Errorf("at least one upload extension must be allowed")
+	}
+	if c.Upload.PresignExpiry <= 0 || c.Upload.PendingTTL < c.Upload.PresignExpiry {
+		return fmt.Errorf("pending upload TTL must be at least the presign expiry")
+	}
+	if c.Upload.CleanupInterval <= 0 {
+		return fmt.Errorf("invalid upload cleanup interval: %s", c.Upload.CleanupInterval)
+	}
+	if c.Upload.ResumableExpiry <= 0 || c.Upload.ResumableLockTTL <= 0 {
+		return fmt.Errorf("resumable upload expiry and lock TTL must be positive")
+	}
+
+	// Validate thumbnails
+	if len(c.Thumbnails.Sizes) == 0 || c.Thumbnails.MaxPixels <= 0 {
+		return fmt.Errorf("thumbnail sizes and max pixels are required")
+	}
+	for _, size := range c.Thumbnails.Sizes {
+		if size <= 0 || size > 4096 {
+			return fmt.Errorf("invalid thumbnail size: %d", size)
+		}
+	}
+
+	// Validate malware scanning
+	if c.Scanner.Backend != "none" && c.Scanner.Backend != "clamd" {
+		return fmt.Errorf("invalid scanner: %s", c.Scanner.Backend)
+	}
+	if c.Scanner.Backend == "clamd" && c.Scanner.ClamdAddress == "" {
+		return fmt.Errorf("clamd address is required")
+	}
+	if c.Scanner.Timeout <= 0 || c.Scanner.RescanAfter <= 0 || c.Scanner.SweepInterval <= 0 {
+		return fmt.Errorf("scanner timeout, rescan delay and sweep interval must be positive")
+	}
+
+	// Validate attachments
+	validDeletePolicies := map[string]bool{
+		"block":   true,
+		"detach":  true,
+		"cascade": true,
+	}
+	if !validDeletePolicies[c.Attachments.FileDeletePolicy] {
+		return fmt.Errorf("invalid attachment file delete policy: %s", c.Attachments.FileDeletePolicy)
+	}
+
+	// Validate garbage collection. Resumable uploads keep their buffered
+	// tail as an object no file refers to until they expire.
+	if c.GC.Interval <= 0 || c.GC.UnattachedAfter < 0 {
+		return fmt.Errorf("invalid garbage collection interval or unattached age")
+	}
+	if c.GC.GracePeriod < c.Upload.ResumableExpiry {
+		return fmt.Errorf("garbage collection grace period must be at least the resumable upload expiry")
+	}
+
+	// Validate quotas
+	if c.Quota.UserBytes < 0 || c.Quota.UserFiles < 0 || c.Quota.WorkspaceBytes < 0 || c.Quota.WorkspaceFiles < 0 {
+		return fmt.Errorf("quotas must not be negative")
+	}
+
+	// Validate encryption
+	if c.Encryption.KeyFile != "" && c.Encryption.RewrapInterval <= 0 {
+		return fmt.Errorf("invalid encryption rewrap interval: %s", c.Encryption.RewrapInterval)
+	}
+
+	// Validate version retention
+	if c.Versions.MaxCount < 0 || c.Versions.MaxAge < 0 {
+		return fmt.Errorf("version retention must not be negative")
+	}
+	if c.Versions.MaxAge > 0 && c.Versions.PruneInterval <= 0 {
+		return fmt.Errorf("invalid version prune interval: %s", c.Versions.PruneInterval)
+	}
+
+	// Validate archive limits
+	if c.Archive.MaxFiles < 0 || c.Archive.MaxSize < 0 {
+		return fmt.Errorf("archive limits must not be negative")
+	}
+
+	// Validate search
+	if c.Search.Backend != "memory" && c.Search.Backend != "mysql" {
+		return fmt.Errorf("invalid search backend: %s", c.Search.Backend)
+	}
+	if c.Search.MaxFileSize < 0 || c.Search.MaxTextSize < 0 {
+		return fmt.Errorf("search limits must not be negative")
+	}
+
+	// Validate recurrence
+	if c.Recurrence.Interval <= 0 {
+		return fmt.Errorf("invalid recurrence interval: %s", c.Recurrence.Interval)
+	}
+
+	// Validate rank rebalancing
+	if c.Ranks.RebalanceInterval <= 0 {
+		return fmt.Errorf("invalid rank rebalance interval: %s", c.Ranks.RebalanceInterval)
+	}
+
+	// Validate the user directory
+	if c.Users.Backend != "none" && c.Users.Backend != "http" {
+		return fmt.Errorf("invalid users backend: %s", c.Users.Backend)
+	}
+	if c.Users.Backend == "http" && c.Users.URL == "" {
+		return fmt.Errorf("users service URL is required")
+	}
+	if c.Users.Timeout <= 0 {
+		return fmt.Errorf("invalid users timeout: %s", c.Users.Timeout)
+	}
+
+	// Validate health checks
+	if c.Health.CheckTimeout <= 0 {
+		return fmt.Errorf("invalid health check timeout: %s", c.Health.CheckTimeout)
+	}
+	if c.Health.CacheTTL < 0 || c.Health.ShutdownDrainDelay < 0 {
+		return fmt.Errorf("health cache TTL and shutdown drain delay must not be negative")
+	}
+
 	return nil
 }
 
@@ -97,3 +459,69 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvIntList(key string, defaultValue []int) []int {
+	items := getEnvList(key, nil)
+	if len(items) == 0 {
+		return defaultValue
+	}
+	list := make([]int, 0, len(items))
+	for _, item := range items {
+		i, err := strconv.Atoi(item)
+		if err != nil {
+			return defaultValue
+		}
+		list = append(list, i)
+	}
+	return list
+}
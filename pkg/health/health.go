@@ -0,0 +1,195 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status values reported by checks and by the overall report
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// ErrShuttingDown is reported by readiness once graceful shutdown has started
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// CheckFunc verifies a single dependency and returns an error when it is unhealthy
+type CheckFunc func(ctx context.Context) error
+
+// CheckOptions controls how a registered check is run
+type CheckOptions struct {
+	// Timeout bounds a single run of the check
+	Timeout time.Duration
+	// Optional checks are reported but never fail readiness
+	Optional bool
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Optional  bool      `json:"optional,omitempty"`
+	LatencyMs int64     `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Report is the detailed response returned by the readiness probe
+type Report struct {
+	Status    string            `json:"status"`
+	Checks    map[string]Result `json:"checks,omitempty"`
+	CheckedAt time.Time         `json:"checkedAt"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+	opts CheckOptions
+}
+
+// Config represents checker registry configuration
+type Config struct {
+	// DefaultTimeout is used for checks registered without a timeout
+	DefaultTimeout time.Duration
+	// CacheTTL is how long a report is reused before checks run again
+	CacheTTL time.Duration
+}
+
+// DefaultConfig returns a default registry configuration
+func DefaultConfig() Config {
+	return Config{
+		DefaultTimeout: 2 * time.Second,
+		CacheTTL:       5 * time.Second,
+	}
+}
+
+// Registry holds dependency checks and caches their results
+type Registry struct {
+	config       Config
+	mu           sync.Mutex
+	checks       []check
+	cached       *Report
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry creates an empty checker registry
+func NewRegistry(config Config) *Registry {
+	if config.DefaultTimeout <= 0 {
+		config.DefaultTimeout = DefaultConfig().DefaultTimeout
+	}
+	return &Registry{config: config}
+}
+
+// Register adds a named check to the registry
+func (r *Registry) Register(name string, fn CheckFunc, opts CheckOptions) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = r.config.DefaultTimeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, check{name: name, fn: fn, opts: opts})
+	r.cached = nil
+}
+
+// SetShuttingDown flips readiness to failing so load balancers stop routing
+// new traffic while in-flight requests drain
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether graceful shutdown has started
+func (r *Registry) ShuttingDown() bool {
+	return r.shuttingDown.Load()
+}
+
+// Readiness runs all checks (or returns a cached report) and aggregates them
+func (r *Registry) Readiness(ctx context.Context) *Report {
+	if r.ShuttingDown() {
+		return &Report{
+			Status: StatusDown,
+			Checks: map[string]Result{
+				"shutdown": {Status: StatusDown, Error: ErrShuttingDown.Error(), CheckedAt: time.Now()},
+			},
+			CheckedAt: time.Now(),
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cached != nil && time.Since(r.cached.CheckedAt) < r.config.CacheTTL {
+		return r.cached
+	}
+
+	// Checks are bounded by their own timeouts; a cancelled probe must not
+	// poison the cached report
+	report := r.run(context.WithoutCancel(ctx))
+	r.cached = report
+	return report
+}
+
+func (r *Registry) run(ctx context.Context) *Report {
+	results := make([]Result, len(r.checks))
+
+	var wg sync.WaitGroup
+	for i, c := range r.checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := &Report{
+		Status:    StatusUp,
+		Checks:    make(map[string]Result, len(results)),
+		CheckedAt: time.Now(),
+	}
+	for i, c := range r.checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status == StatusDown && !c.opts.Optional {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, c check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				errCh <- errors.New("check panicked")
+			}
+		}()
+		errCh <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{
+		Status:    StatusUp,
+		Optional:  c.opts.Optional,
+		LatencyMs: time.Since(start).Milliseconds(),
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
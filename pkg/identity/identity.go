@@ -0,0 +1,26 @@
+// Package identity carries the caller's identity through request contexts.
+// Authentication happens upstream; the API gateway forwards the
+// authenticated user and workspace in the X-User-ID and X-Tenant-ID headers.
+package identity
+
+import "context"
+
+// Identity is the caller of a request. Both fields are empty for anonymous
+// requests.
+type Identity struct {
+	UserID      string
+	WorkspaceID string
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying id
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity stored in ctx, or the anonymous identity
+func FromContext(ctx context.Context) Identity {
+	id, _ := ctx.Value(identityKey{}).(Identity)
+	return id
+}
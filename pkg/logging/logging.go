@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Config represents logger configuration
+type Config struct {
+	Level  string // debug, info, warn or error
+	Format string // json or text
+	// RedactKeys lists attribute keys whose values are never written.
+	// Matching is case-insensitive and also applies to nested groups.
+	RedactKeys []string
+	Output     io.Writer
+}
+
+// DefaultRedactKeys are attribute keys that commonly carry secrets
+var DefaultRedactKeys = []string{
+	"password",
+	"secret",
+	"token",
+	"authorization",
+	"cookie",
+	"api_key",
+	"access_key",
+	"secret_access_key",
+}
+
+const redacted = "[REDACTED]"
+
+// New builds a slog logger from config
+func New(cfg Config) *slog.Logger {
+	output := cfg.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	redact := make(map[string]bool, len(cfg.RedactKeys))
+	for _, key := range cfg.RedactKeys {
+		redact[strings.ToLower(key)] = true
+	}
+
+	opts := &slog.HandlerOptions{
+		Level: ParseLevel(cfg.Level),
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if redact[strings.ToLower(a.Key)] {
+				return slog.String(a.Key, redacted)
+			}
+			return a
+		},
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(cfg.Format, "text") {
+		handler = slog.NewTextHandler(output, opts)
+	} else {
+		handler = slog.NewJSONHandler(output, opts)
+	}
+	return slog.New(handler)
+}
+
+// ParseLevel converts a config log level to a slog level, defaulting to info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Lookup returns the request-scoped logger stored in ctx, if any
+func Lookup(ctx context.Context) (*slog.Logger, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	logger, ok := ctx.Value(loggerKey{}).(*slog.Logger)
+	return logger, ok
+}
+
+// FromContext returns the request-scoped logger stored in ctx, falling back
+// to the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := Lookup(ctx); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger has the given attributes added
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
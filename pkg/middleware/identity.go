@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"taskflow/pkg/identity"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Identity stores the caller identity forwarded by the gateway in the
+// request context, where services read it with identity.FromContext
+func Identity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := identity.Identity{
+			UserID:      c.GetHeader("X-User-ID"),
+			WorkspaceID: c.GetHeader("X-Tenant-ID"),
+		}
+		c.Request = c.Request.WithContext(identity.WithIdentity(c.Request.Context(), id))
+		c.Next()
+	}
+}
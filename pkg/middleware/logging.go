@@ -4,6 +4,9 @@ import (
 	"log/slog"
 	"time"
 
+	"taskflow/pkg/logging"
+	"taskflow/pkg/tracing"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -19,7 +22,7 @@ type RequestLoggerConfig struct {
 func DefaultRequestLoggerConfig() RequestLoggerConfig {
 	return RequestLoggerConfig{
 		Logger:     slog.Default(),
-		SkipPaths:  []string{"/health", "/metrics"},
+		SkipPaths:  []string{"/health", "/livez", "/readyz", "/metrics"},
 		SkipFields: []string{},
 	}
 }
@@ -41,6 +44,19 @@ func RequestLogger(config RequestLoggerConfig) gin.HandlerFunc {
 		c.Set("request_id", requestID)
 		c.Header("X-Request-ID", requestID)
 
+		// Store a request-scoped logger so services log with the same identifiers
+		logger := config.Logger.With("request_id", requestID)
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+			logger = logger.With("trace_id", traceID)
+		}
+		if userID := c.GetHeader("X-User-ID"); userID != "" {
+			logger = logger.With("user_id", userID)
+		}
+		if tenantID := c.GetHeader("X-Tenant-ID"); tenantID != "" {
+			logger = logger.With("tenant_id", tenantID)
+		}
+		c.Request = c.Request.WithContext(logging.WithLogger(c.Request.Context(), logger))
+
 		// Start timer
 		start := time.Now()
 		path := c.Request.URL.Path
@@ -59,7 +75,6 @@ func RequestLogger(config RequestLoggerConfig) gin.HandlerFunc {
 
 		// Build log fields
 		fields := []interface{}{
-			"request_id", requestID,
 			"method", method,
 			"path", path,
 			"status", status,
@@ -83,7 +98,7 @@ func RequestLogger(config RequestLoggerConfig) gin.HandlerFunc {
 		}
 
 		// Log the request
-		config.Logger.Info("request completed", fields...)
+		logger.Info("request completed", fields...)
 	}
 }
 
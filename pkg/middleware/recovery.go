@@ -8,6 +8,7 @@ import (
 	"os"
 	"runtime/debug"
 	"strings"
+	"taskflow/pkg/logging"
 
 	"github.com/gin-gonic/gin"
 )
@@ -61,15 +62,16 @@ func Recovery(config RecoveryConfig) gin.HandlerFunc {
 					if config.StackAll {
 						fields = append(fields, "stack", string(stack))
 					} else {
-						fields = append(fields, "stack", string(stack[:config.StackSize]))
+						fields = append(fields, "stack", string(stack[:min(len(stack), config.StackSize)]))
 					}
 				}
 
 				// Log the panic
+				logger := requestLogger(c, config.Logger)
 				if brokenPipe {
-					config.Logger.Error("broken pipe", fields...)
+					logger.Error("broken pipe", fields...)
 				} else {
-					config.Logger.Error("panic recovered", fields...)
+					logger.Error("panic recovered", fields...)
 				}
 
 				// If the connection is dead, we can't write a status to it
@@ -86,3 +88,15 @@ func Recovery(config RecoveryConfig) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// requestLogger returns the request-scoped logger when one was stored by
+// RequestLogger, otherwise the configured fallback
+func requestLogger(c *gin.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := logging.Lookup(c.Request.Context()); ok {
+		return logger
+	}
+	if fallback == nil {
+		return slog.Default()
+	}
+	return fallback
+}
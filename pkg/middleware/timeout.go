@@ -1,18 +1,36 @@
 package middleware
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// RouteTimeout overrides the timeout behaviour for a single route
+type RouteTimeout struct {
+	// Timeout replaces the default timeout when greater than zero
+	Timeout time.Duration
+	// Streaming routes write straight to the client instead of being
+	// buffered, so large downloads don't sit in memory. Their deadline is
+	// still applied to the request context but no timeout response can be
+	// sent once the handler has started writing.
+	Streaming bool
+}
+
 // TimeoutConfig represents timeout middleware configuration
 type TimeoutConfig struct {
 	Timeout time.Duration
 	Logger  *slog.Logger
+	// Routes maps a gin route pattern (as returned by c.FullPath, e.g.
+	// "/upload" or "/files/:id/download") to its override
+	Routes map[string]RouteTimeout
 }
 
 // DefaultTimeoutConfig returns a default timeout configuration
@@ -20,55 +38,154 @@ func DefaultTimeoutConfig() TimeoutConfig {
 	return TimeoutConfig{
 		Timeout: 30 * time.Second,
 		Logger:  slog.Default(),
+		Routes: map[string]RouteTimeout{
+			"/upload":                               {Timeout: 5 * time.Minute},
+			"/files/:id/content":                    {Timeout: 5 * time.Minute},
+			"/files/tus/:id":                        {Timeout: 5 * time.Minute},
+			"/files/:id/download":                   {Timeout: 30 * time.Minute, Streaming: true},
+			"/files/:id/versions/:version/download": {Timeout: 30 * time.Minute, Streaming: true},
+			"/files/archive":                        {Timeout: 30 * time.Minute, Streaming: true},
+		},
 	}
 }
 
-// Timeout returns a timeout middleware
+// Timeout returns a timeout middleware.
+//
+// The handler chain runs on the request goroutine with a deadline on the
+// request context, and its response is buffered. When the chain returns, the
+// middleware writes exactly one response: the buffered one, or a timeout
+// response if the deadline passed first. Because nothing runs concurrently
+// with the handler there is no race on the response writer, and panics
+// propagate to Recovery as usual. Handlers are expected to honour the request
+// context; all repository, cache and storage adapters do.
 func Timeout(config TimeoutConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Create context with timeout
-		ctx, cancel := context.WithTimeout(c.Request.Context(), config.Timeout)
-		defer cancel()
+		route, timeout := config.Routes[c.FullPath()], config.Timeout
+		if route.Timeout > 0 {
+			timeout = route.Timeout
+		}
 
-		// Replace request context
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
 		c.Request = c.Request.WithContext(ctx)
 
-		// Channel to signal completion
-		done := make(chan struct{})
-
-		// Start goroutine to handle the request
-		go func() {
-			defer func() {
-				if r := recover(); r != nil {
-					config.Logger.Error("panic in timeout middleware", "error", r)
-				}
-				close(done)
-			}()
+		if route.Streaming {
 			c.Next()
-		}()
-
-		// Wait for completion or timeout
-		select {
-		case <-done:
-			// Request completed normally
 			return
-		case <-ctx.Done():
-			// Timeout occurred
-			config.Logger.Warn("request timeout",
+		}
+
+		original := c.Writer
+		buffer := newBufferedWriter(original)
+		c.Writer = buffer
+
+		// Restore the real writer even if a handler panics, so Recovery
+		// writes its 500 to the client rather than to a discarded buffer
+		defer func() { c.Writer = original }()
+
+		c.Next()
+
+		c.Writer = original
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			requestLogger(c, config.Logger).Warn("request timeout",
 				"path", c.Request.URL.Path,
 				"method", c.Request.Method,
-				"timeout", config.Timeout,
+				"timeout", timeout,
 			)
 
-			// Check if response was already sent
-			if !c.Writer.Written() {
-				c.JSON(http.StatusRequestTimeout, gin.H{
-					"error":   "Request timeout",
-					"timeout": config.Timeout.String(),
-				})
-			}
-
+			c.JSON(http.StatusRequestTimeout, gin.H{
+				"error":   "Request timeout",
+				"timeout": timeout.String(),
+			})
 			c.Abort()
+			return
 		}
+
+		buffer.flushTo(original)
+	}
+}
+
+// bufferedWriter collects a handler's status, headers and body so they can be
+// written to the client in one go, or dropped in favour of a timeout response
+type bufferedWriter struct {
+	gin.ResponseWriter
+	header http.Header
+	body   bytes.Buffer
+	status int
+	wrote  bool
+}
+
+func newBufferedWriter(w gin.ResponseWriter) *bufferedWriter {
+	return &bufferedWriter{
+		ResponseWriter: w,
+		header:         w.Header().Clone(),
+		status:         http.StatusOK,
+	}
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 && !w.wrote {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.wrote = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.wrote = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.wrote = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.wrote {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.wrote
+}
+
+// Flush is a no-op: buffered responses are only sent once the handler is done
+func (w *bufferedWriter) Flush() {}
+
+func (w *bufferedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("hijacking is not supported on buffered routes")
+}
+
+func (w *bufferedWriter) Pusher() http.Pusher {
+	return nil
+}
+
+// flushTo writes the buffered response to the real writer
+func (w *bufferedWriter) flushTo(dst gin.ResponseWriter) {
+	header := dst.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range w.header {
+		header[key] = values
+	}
+
+	dst.WriteHeader(w.status)
+	if w.body.Len() > 0 {
+		dst.Write(w.body.Bytes()) // nolint: errcheck
+	} else {
+		dst.WriteHeaderNow()
 	}
 }
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingConfig represents tracing middleware configuration
+type TracingConfig struct {
+	TracerName string
+	SkipPaths  []string
+}
+
+// DefaultTracingConfig returns a default tracing configuration
+func DefaultTracingConfig() TracingConfig {
+	return TracingConfig{
+		TracerName: "taskflow/http",
+		SkipPaths:  []string{"/health", "/livez", "/readyz", "/metrics"},
+	}
+}
+
+// Tracing returns a middleware that starts a server span for each request,
+// continuing any trace context sent by the caller
+func Tracing(config TracingConfig) gin.HandlerFunc {
+	tracer := otel.Tracer(config.TracerName)
+
+	return func(c *gin.Context) {
+		if shouldSkipPath(c.Request.URL.Path, config.SkipPaths) {
+			c.Next()
+			return
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+
+		ctx, span := tracer.Start(ctx, fmt.Sprintf("%s %s", c.Request.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+				attribute.String("client.address", c.ClientIP()),
+				attribute.String("user_agent.original", c.Request.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
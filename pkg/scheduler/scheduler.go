@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Job is a unit of periodic background work
+type Job func(ctx context.Context) error
+
+// Every runs job every interval until ctx is cancelled. Runs never overlap:
+// a slow run delays the next one. Errors are logged and do not stop the loop.
+func Every(ctx context.Context, name string, interval time.Duration, job Job) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		logger := slog.Default().With("job", name)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				start := time.Now()
+				if err := job(ctx); err != nil && ctx.Err() == nil {
+					logger.Error("scheduled job failed", "error", err, "duration", time.Since(start))
+					continue
+				}
+				logger.Debug("scheduled job finished", "duration", time.Since(start))
+			}
+		}
+	}()
+}
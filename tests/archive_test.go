@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+	"taskflow/pkg/identity"
+)
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected a complete zip, got %v", err)
+	}
+	entries := map[string]string{}
+	for _, entry := range reader.File {
+		rc, err := entry.Open()
+		if err != nil {
+			t.Fatalf("expected entry %s to open, got %v", entry.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("expected entry %s to read, got %v", entry.Name, err)
+		}
+		entries[entry.Name] = string(content)
+	}
+	return entries
+}
+
+func TestArchive_FilesAndTodoAttachmentsWithManifest(t *testing.T) {
+	fixture := newAttachmentFixture(t, todo.FileDeleteBlock)
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+	first, second := fixture.upload(t, ctx, "notes.txt"), fixture.upload(t, ctx, "NOTES.txt")
+	created, err := fixture.todoService.CreateTodo(ctx, &todo.CreateTodoRequest{
+		Description: "Review documents",
+		DueDate:     time.Now().Add(time.Hour),
+		FileIDs:     []string{first, second},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	escaping := fixture.upload(t, ctx, "../escape.txt")
+	pending, _ := fixture.fileService.UploadFile(ctx, &file.CreateFileRequest{Filename: "pending.txt"}, bytes.NewReader([]byte("unscanned")))
+
+	service := file.NewArchiveService(fixture.fileService, fixture.references, file.ArchiveLimits{})
+	archive, err := service.Prepare(ctx, &file.ArchiveRequest{
+		FileIDs: []string{first, escaping, pending.FileID},
+		TodoIDs: []string{created.ID.String()},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var out bytes.Buffer
+	if err := service.Write(ctx, archive, &out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	entries := readZip(t, out.Bytes())
+	if len(entries) != 4 || entries["notes.txt"] != "content of notes.txt" || entries["NOTES (2).txt"] != "content of NOTES.txt" {
+		t.Fatalf("expected each file once under a unique name plus the manifest, got %v", entries)
+	}
+	if _, ok := entries["_escape.txt"]; !ok {
+		t.Errorf("expected path separators to be replaced in entry names, got %v", entries)
+	}
+	var manifest file.Archive
+	if err := json.Unmarshal([]byte(entries["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("expected a JSON manifest, got %v", err)
+	}
+	if len(manifest.Entries) != 3 || manifest.Entries[0].FileID != first || manifest.Entries[0].Checksum == "" {
+		t.Errorf("unexpected manifest entries: %+v", manifest.Entries)
+	}
+	if len(manifest.Skipped) != 1 || manifest.Skipped[0].FileID != pending.FileID {
+		t.Errorf("expected the quarantined file to be listed as skipped, got %+v", manifest.Skipped)
+	}
+}
+
+func TestArchive_RefusesOversizedAndUnknownSelections(t *testing.T) {
+	fixture := newAttachmentFixture(t, todo.FileDeleteBlock)
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+	first, second := fixture.upload(t, ctx, "a.txt"), fixture.upload(t, ctx, "b.txt")
+
+	service := file.NewArchiveService(fixture.fileService, fixture.references, file.ArchiveLimits{MaxFiles: 1})
+	_, err := service.Prepare(ctx, &file.ArchiveRequest{FileIDs: []string{first, second}})
+	if domainCode(err) != shared.ErrCodeTooLarge {
+		t.Errorf("expected too many files to be refused, got %v", err)
+	}
+
+	service = file.NewArchiveService(fixture.fileService, fixture.references, file.ArchiveLimits{MaxSize: 20})
+	_, err = service.Prepare(ctx, &file.ArchiveRequest{FileIDs: []string{first, second}})
+	if domainCode(err) != shared.ErrCodeTooLarge {
+		t.Errorf("expected too many bytes to be refused, got %v", err)
+	}
+
+	_, err = service.Prepare(ctx, &file.ArchiveRequest{TodoIDs: []string{"7d444840-9dc0-11d1-b245-5ffdce74fad2"}})
+	if domainCode(err) != shared.ErrCodeNotFound {
+		t.Errorf("expected an unknown todo to be not found, got %v", err)
+	}
+	if _, err := service.Prepare(ctx, &file.ArchiveRequest{}); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected an empty selection to be refused, got %v", err)
+	}
+}
+
+func TestArchive_StopsWhenTheClientGoesAway(t *testing.T) {
+	fixture := newAttachmentFixture(t, todo.FileDeleteBlock)
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+	first := fixture.upload(t, ctx, "a.txt")
+
+	service := file.NewArchiveService(fixture.fileService, fixture.references, file.ArchiveLimits{})
+	archive, err := service.Prepare(ctx, &file.ArchiveRequest{FileIDs: []string{first}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	var out bytes.Buffer
+	if err := service.Write(cancelled, archive, &out); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the archive to stop on cancellation, got %v", err)
+	}
+	if _, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len())); err == nil {
+		t.Error("expected a cancelled archive not to be complete")
+	}
+}
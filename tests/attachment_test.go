@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+	"taskflow/pkg/identity"
+)
+
+// attachmentFixture wires real todo and file services over in-memory stores
+type attachmentFixture struct {
+	todoService  todo.TodoService
+	fileService  file.FileService
+	fileRepo     *mockFileRepo
+	attachments  *mockAttachmentRepo
+	checklists   *mockChecklistRepo
+	dependencies *mockDependencyRepo
+	participants *mockParticipantRepo
+	references   todo.FileReferences
+	deleted      chan *todo.TodoItem
+}
+
+func newAttachmentFixture(t *testing.T, policy todo.FileDeletePolicy) *attachmentFixture {
+	t.Helper()
+	todoRepo, _ := newMemTodoRepo()
+	f := &attachmentFixture{
+		fileRepo:     newMockFileRepo(),
+		attachments:  newMockAttachmentRepo(),
+		checklists:   newMockChecklistRepo(),
+		dependencies: &mockDependencyRepo{},
+		participants: &mockParticipantRepo{},
+		deleted:      make(chan *todo.TodoItem, 10),
+	}
+	deps := todo.Deps{
+		Todos:       todoRepo,
+		Attachments: f.attachments,
+		Messaging: &mockMessaging{PublishFn: func(ctx context.Context, topic string, message interface{}) error {
+			if topic == todo.TopicTodoDeleted {
+				f.deleted <- message.(*todo.TodoItem)
+			}
+			return nil
+		}},
+		Cache:        &mockCache{},
+		Checklists:   f.checklists,
+		Dependencies: f.dependencies,
+		Participants: f.participants,
+	}
+	uploadPolicy, _ := file.NewUploadPolicy(1024, []string{".txt"})
+	f.references = todo.NewFileReferences(deps, policy)
+	f.fileService = file.NewFileService(file.Deps{Files: f.fileRepo, Storage: newMockStorage(), Messaging: &mockMessaging{}, Policy: uploadPolicy, References: f.references})
+	deps.Files = f.fileService
+	f.todoService = todo.NewTodoService(deps)
+	return f
+}
+
+// upload stores a clean file owned by the caller in ctx
+func (f *attachmentFixture) upload(t *testing.T, ctx context.Context, name string) string {
+	t.Helper()
+	resp, err := f.fileService.UploadFile(ctx, &file.CreateFileRequest{Filename: name}, strings.NewReader("content of "+name))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	markClean(f.fileRepo, resp.FileID)
+	return resp.FileID
+}
+
+func TestAttachments_AttachListAndDetach(t *testing.T) {
+	fixture := newAttachmentFixture(t, todo.FileDeleteBlock)
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+	first, second := fixture.upload(t, ctx, "a.txt"), fixture.upload(t, ctx, "b.txt")
+
+	created, err := fixture.todoService.CreateTodo(ctx, &todo.CreateTodoRequest{
+		Description: "Review documents",
+		DueDate:     time.Now().Add(time.Hour),
+		FileIDs:     []string{first, first},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(created.FileIDs) != 1 {
+		t.Fatalf("expected duplicate file IDs to be attached once, got %v", created.FileIDs)
+	}
+
+	if _, err := fixture.todoService.AttachFile(ctx, created.ID, second); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	attachments, _ := fixture.todoService.ListAttachments(ctx, created.ID)
+	if len(attachments) != 2 {
+		t.Fatalf("expected 2 attachments, got %d", len(attachments))
+	}
+
+	if err := fixture.todoService.DetachFile(ctx, created.ID, first); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := fixture.todoService.DetachFile(ctx, created.ID, first); domainCode(err) != shared.ErrCodeNotFound {
+		t.Errorf("expected detaching twice to be not found, got %v", err)
+	}
+	got, _ := fixture.todoService.GetTodo(ctx, created.ID)
+	if len(got.FileIDs) != 1 || got.FileIDs[0] != second {
+		t.Errorf("expected only %s to remain attached, got %v", second, got.FileIDs)
+	}
+}
+
+func TestAttachments_RejectMissingAndForeignFiles(t *testing.T) {
+	fixture := newAttachmentFixture(t, todo.FileDeleteBlock)
+	alice := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+	bob := identity.WithIdentity(context.Background(), identity.Identity{UserID: "bob"})
+	fileID := fixture.upload(t, alice, "a.txt")
+
+	created, err := fixture.todoService.CreateTodo(bob, &todo.CreateTodoRequest{Description: "Mine", DueDate: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := fixture.todoService.AttachFile(bob, created.ID, fileID); domainCode(err) != shared.ErrCodeForbidden {
+		t.Errorf("expected another user's file to be refused, got %v", err)
+	}
+	if _, err := fixture.todoService.AttachFile(bob, created.ID, "1b4e28ba-2fa1-11d2-883f-0016d3cca427"); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected a missing file to be a validation error, got %v", err)
+	}
+}
+
+func TestAttachments_FileDeletePolicies(t *testing.T) {
+	tests := []struct {
+		policy      todo.FileDeletePolicy
+		deleteError string
+		todoKept    bool
+	}{
+		{todo.FileDeleteBlock, shared.ErrCodeConflict, true},
+		{todo.FileDeleteDetach, "", true},
+		{todo.FileDeleteCascade, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			fixture := newAttachmentFixture(t, tt.policy)
+			ctx := context.Background()
+			fileID := fixture.upload(t, ctx, "a.txt")
+			created, err := fixture.todoService.CreateTodo(ctx, &todo.CreateTodoRequest{
+				Description: "Review",
+				DueDate:     time.Now().Add(time.Hour),
+				FileIDs:     []string{fileID},
+			})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			err = fixture.fileService.DeleteFile(ctx, fileID)
+			if domainCode(err) != tt.deleteError {
+				t.Fatalf("expected delete error %q, got %v", tt.deleteError, err)
+			}
+
+			got, err := fixture.todoService.GetTodo(ctx, created.ID)
+			if kept := err == nil; kept != tt.todoKept {
+				t.Fatalf("expected todo kept=%v, got error %v", tt.todoKept, err)
+			}
+			if tt.policy == todo.FileDeleteDetach && len(got.FileIDs) != 0 {
+				t.Errorf("expected the file to be detached, got %v", got.FileIDs)
+			}
+			if tt.policy != todo.FileDeleteBlock && len(fixture.attachments.attachments) != 0 {
+				t.Errorf("expected no dangling attachments, got %d", len(fixture.attachments.attachments))
+			}
+		})
+	}
+}
+
+func TestAttachments_CascadeDeletesTodosLikeDeleteTodo(t *testing.T) {
+	fixture := newAttachmentFixture(t, todo.FileDeleteCascade)
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+	fileID := fixture.upload(t, ctx, "a.txt")
+	create := func(req *todo.CreateTodoRequest) *todo.TodoItem {
+		req.DueDate = time.Now().Add(time.Hour)
+		created, err := fixture.todoService.CreateTodo(ctx, req)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return created
+	}
+	attached := create(&todo.CreateTodoRequest{Description: "Review", FileIDs: []string{fileID}})
+	blocker := create(&todo.CreateTodoRequest{Description: "Draft"})
+	if _, err := fixture.todoService.AddChecklistItem(ctx, attached.ID, &todo.ChecklistItemRequest{Text: "Read it"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, _, err := fixture.todoService.AddDependency(ctx, attached.ID, &todo.DependencyRequest{BlockedBy: blocker.ID.String()}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := fixture.todoService.WatchTodo(ctx, attached.ID, &todo.ParticipantRequest{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// A todo with subtasks keeps the file
+	parentID := attached.ID.String()
+	subtask := create(&todo.CreateTodoRequest{Description: "Summarize", ParentID: &parentID})
+	if err := fixture.fileService.DeleteFile(ctx, fileID); domainCode(err) != shared.ErrCodeConflict {
+		t.Fatalf("expected a todo with subtasks to hold the file, got %v", err)
+	}
+	if err := fixture.todoService.DeleteTodo(ctx, subtask.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	<-fixture.deleted
+
+	if err := fixture.fileService.DeleteFile(ctx, fileID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := fixture.todoService.GetTodo(ctx, attached.ID); err == nil {
+		t.Errorf("expected the attached todo to be deleted")
+	}
+	if len(fixture.checklists.items) != 0 || len(fixture.dependencies.dependencies) != 0 || len(fixture.participants.participants) != 0 {
+		t.Errorf("expected no checklist, dependencies or watchers left, got %d, %d and %d",
+			len(fixture.checklists.items), len(fixture.dependencies.dependencies), len(fixture.participants.participants))
+	}
+	select {
+	case deleted := <-fixture.deleted:
+		if deleted.ID != attached.ID {
+			t.Errorf("expected a todo.deleted event for the attached todo, got %s", deleted.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a todo.deleted event")
+	}
+}
@@ -12,7 +12,7 @@ import (
 
 type benchMockTodoRepo struct {
 	CreateFn func(ctx context.Context, todoItem *todo.TodoItem) error
-	ListFn   func(ctx context.Context, limit, offset int) ([]*todo.TodoItem, error)
+	ListFn   func(ctx context.Context, filter todo.ListFilter, limit, offset int) ([]*todo.TodoItem, error)
 }
 
 func (m *benchMockTodoRepo) Create(ctx context.Context, todoItem *todo.TodoItem) error {
@@ -21,11 +21,23 @@ func (m *benchMockTodoRepo) Create(ctx context.Context, todoItem *todo.TodoItem)
 func (m *benchMockTodoRepo) GetByID(ctx context.Context, id uuid.UUID) (*todo.TodoItem, error) {
 	return nil, nil
 }
-func (m *benchMockTodoRepo) List(ctx context.Context, limit, offset int) ([]*todo.TodoItem, error) {
-	return m.ListFn(ctx, limit, offset)
+func (m *benchMockTodoRepo) List(ctx context.Context, filter todo.ListFilter, limit, offset int) ([]*todo.TodoItem, error) {
+	return m.ListFn(ctx, filter, limit, offset)
 }
 func (m *benchMockTodoRepo) Update(ctx context.Context, todoItem *todo.TodoItem) error { return nil }
 func (m *benchMockTodoRepo) Delete(ctx context.Context, id uuid.UUID) error            { return nil }
+func (m *benchMockTodoRepo) ListChildren(ctx context.Context, parentIDs []uuid.UUID) ([]*todo.TodoItem, error) {
+	return nil, nil
+}
+func (m *benchMockTodoRepo) AdjacentRank(ctx context.Context, status todo.Status, rank string, before bool, exclude uuid.UUID) (string, error) {
+	return "", nil
+}
+func (m *benchMockTodoRepo) ListColumn(ctx context.Context, status todo.Status) ([]*todo.TodoItem, error) {
+	return nil, nil
+}
+func (m *benchMockTodoRepo) SetRanks(ctx context.Context, ranks map[uuid.UUID]string) error {
+	return nil
+}
 
 type benchMockMessaging struct{}
 
@@ -52,7 +64,7 @@ func BenchmarkCreateTodo(b *testing.B) {
 	}
 	messaging := &benchMockMessaging{}
 	cache := &benchMockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	req := &todo.CreateTodoRequest{
 		Description: "Benchmark todo",
@@ -70,17 +82,17 @@ func BenchmarkCreateTodo(b *testing.B) {
 
 func BenchmarkListTodos(b *testing.B) {
 	todoRepo := &benchMockTodoRepo{
-		ListFn: func(ctx context.Context, limit, offset int) ([]*todo.TodoItem, error) {
+		ListFn: func(ctx context.Context, filter todo.ListFilter, limit, offset int) ([]*todo.TodoItem, error) {
 			return []*todo.TodoItem{{Description: "Benchmark todo"}}, nil
 		},
 	}
 	messaging := &benchMockMessaging{}
 	cache := &benchMockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, err := service.ListTodos(context.Background(), 10, 0)
+		_, err := service.ListTodos(context.Background(), todo.ListFilter{}, 10, 0)
 		if err != nil {
 			b.Fatalf("unexpected error: %v", err)
 		}
@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+
+	"github.com/google/uuid"
+)
+
+// mockDependencyRepo keeps dependencies in memory, in insertion order
+type mockDependencyRepo struct {
+	dependencies []*todo.Dependency
+}
+
+func (m *mockDependencyRepo) Add(ctx context.Context, dependency *todo.Dependency) error {
+	seen := map[uuid.UUID]bool{dependency.BlockedByID: true}
+	for level := []uuid.UUID{dependency.BlockedByID}; len(level) > 0; {
+		blockers, _ := m.ListBlockers(ctx, level)
+		level = nil
+		for _, blocker := range blockers {
+			if blocker.BlockedByID == dependency.TodoID {
+				return todo.ErrDependencyCycle
+			}
+			if !seen[blocker.BlockedByID] {
+				seen[blocker.BlockedByID] = true
+				level = append(level, blocker.BlockedByID)
+			}
+		}
+	}
+	for _, existing := range m.dependencies {
+		if existing.TodoID == dependency.TodoID && existing.BlockedByID == dependency.BlockedByID {
+			return nil
+		}
+	}
+	m.dependencies = append(m.dependencies, dependency)
+	return nil
+}
+
+func (m *mockDependencyRepo) Remove(ctx context.Context, todoID, blockedByID uuid.UUID) error {
+	for i, dependency := range m.dependencies {
+		if dependency.TodoID == todoID && dependency.BlockedByID == blockedByID {
+			m.dependencies = append(m.dependencies[:i], m.dependencies[i+1:]...)
+			return nil
+		}
+	}
+	return shared.NewNotFoundError("todo does not depend on this todo")
+}
+
+func (m *mockDependencyRepo) ListBlockers(ctx context.Context, todoIDs []uuid.UUID) ([]*todo.Dependency, error) {
+	return m.list(todoIDs, func(d *todo.Dependency) uuid.UUID { return d.TodoID }), nil
+}
+
+func (m *mockDependencyRepo) ListBlocked(ctx context.Context, blockedByIDs []uuid.UUID) ([]*todo.Dependency, error) {
+	return m.list(blockedByIDs, func(d *todo.Dependency) uuid.UUID { return d.BlockedByID }), nil
+}
+
+func (m *mockDependencyRepo) list(ids []uuid.UUID, key func(*todo.Dependency) uuid.UUID) []*todo.Dependency {
+	var dependencies []*todo.Dependency
+	for _, dependency := range m.dependencies {
+		for _, id := range ids {
+			if key(dependency) == id {
+				dependencies = append(dependencies, dependency)
+			}
+		}
+	}
+	return dependencies
+}
+
+func (m *mockDependencyRepo) DeleteByTodo(ctx context.Context, todoID uuid.UUID) error {
+	kept := m.dependencies[:0]
+	for _, dependency := range m.dependencies {
+		if dependency.TodoID != todoID && dependency.BlockedByID != todoID {
+			kept = append(kept, dependency)
+		}
+	}
+	m.dependencies = kept
+	return nil
+}
+
+func newDependencyService() (todo.TodoService, *mockDependencyRepo) {
+	todoRepo, _ := newMemTodoRepo()
+	dependencies := &mockDependencyRepo{}
+	return todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: &mockMessaging{}, Cache: &mockCache{}, Files: &mockFileChecker{}, Dependencies: dependencies}), dependencies
+}
+
+// createDue creates a todo due in the given number of days
+func createDue(t *testing.T, service todo.TodoService, description string, days int) *todo.TodoItem {
+	t.Helper()
+	created, err := service.CreateTodo(context.Background(), &todo.CreateTodoRequest{
+		Description: description,
+		DueDate:     time.Now().Add(time.Duration(days) * 24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return created
+}
+
+// blockedBy makes todo wait for blocker and returns the warnings
+func blockedBy(t *testing.T, service todo.TodoService, todoItem, blocker *todo.TodoItem) []todo.DueDateWarning {
+	t.Helper()
+	_, warnings, err := service.AddDependency(context.Background(), todoItem.ID, &todo.DependencyRequest{BlockedBy: blocker.ID.String()})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return warnings
+}
+
+func TestDependencies_RejectCyclesAndWarnAboutDueDates(t *testing.T) {
+	service, _ := newDependencyService()
+	ctx := context.Background()
+	design := createDue(t, service, "Design", 2)
+	build := createDue(t, service, "Build", 5)
+	ship := createDue(t, service, "Ship", 3)
+
+	if warnings := blockedBy(t, service, build, design); len(warnings) != 0 {
+		t.Errorf("expected no warning for a todo due after its blocker, got %+v", warnings)
+	}
+	warnings := blockedBy(t, service, ship, build)
+	if len(warnings) != 1 || warnings[0].TodoID != ship.ID || warnings[0].BlockedByID != build.ID {
+		t.Errorf("expected a warning that ship is due before build, got %+v", warnings)
+	}
+
+	for _, tt := range []struct{ todo, blocker *todo.TodoItem }{{design, ship}, {design, design}} {
+		_, _, err := service.AddDependency(ctx, tt.todo.ID, &todo.DependencyRequest{BlockedBy: tt.blocker.ID.String()})
+		if domainCode(err) != shared.ErrCodeValidation {
+			t.Errorf("expected %s waiting for %s to be refused as a cycle, got %v", tt.todo.Description, tt.blocker.Description, err)
+		}
+	}
+
+	graph, err := service.GetDependencies(ctx, build.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(graph.BlockedBy) != 1 || graph.BlockedBy[0] != design.ID || len(graph.Blocks) != 1 || graph.Blocks[0] != ship.ID {
+		t.Errorf("expected build to wait for design and hold up ship, got %+v", graph)
+	}
+	if !graph.Blocked || len(graph.Todos) != 3 || len(graph.Edges) != 2 || len(graph.Warnings) != 1 {
+		t.Errorf("expected the whole blocked graph with one warning, got %+v", graph)
+	}
+
+	inProgress := todo.StatusInProgress
+	if _, err := service.UpdateTodo(ctx, build.ID, &todo.UpdateTodoRequest{Status: &inProgress}); domainCode(err) != shared.ErrCodeConflict {
+		t.Errorf("expected starting a blocked todo to conflict, got %v", err)
+	}
+	done := todo.StatusDone
+	service.UpdateTodo(ctx, design.ID, &todo.UpdateTodoRequest{Status: &done})
+	if _, err := service.UpdateTodo(ctx, build.ID, &todo.UpdateTodoRequest{Status: &inProgress}); err != nil {
+		t.Errorf("expected the todo to start once its blocker is done, got %v", err)
+	}
+}
+
+func TestDependencies_PlanOrdersByDependenciesAndDueDates(t *testing.T) {
+	service, dependencies := newDependencyService()
+	ctx := context.Background()
+	// a -> b -> d and a -> c, with c due first and e unrelated but due last
+	a := createDue(t, service, "a", 4)
+	b := createDue(t, service, "b", 6)
+	c := createDue(t, service, "c", 1)
+	d := createDue(t, service, "d", 7)
+	e := createDue(t, service, "e", 9)
+	outside := createDue(t, service, "outside", 10)
+	blockedBy(t, service, b, a)
+	blockedBy(t, service, c, a)
+	blockedBy(t, service, d, b)
+	blockedBy(t, service, a, outside)
+
+	plan, err := service.PlanTodos(ctx, &todo.PlanRequest{TodoIDs: []string{e.ID.String(), d.ID.String(), c.ID.String(), b.ID.String(), a.ID.String()}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := []uuid.UUID{a.ID, c.ID, b.ID, d.ID, e.ID}
+	for i := range want {
+		if i >= len(plan.Order) || plan.Order[i] != want[i] {
+			t.Fatalf("expected order a c b d e, got %v", plan.Order)
+		}
+	}
+	if len(plan.CriticalPath) != 3 || plan.CriticalPath[0] != a.ID || plan.CriticalPath[1] != b.ID || plan.CriticalPath[2] != d.ID {
+		t.Errorf("expected the critical path a b d, got %v", plan.CriticalPath)
+	}
+	if len(plan.Warnings) != 1 || plan.Warnings[0].TodoID != c.ID {
+		t.Errorf("expected only c to be due before its blocker, got %+v", plan.Warnings)
+	}
+
+	// A cycle recorded around AddDependency makes the plan a conflict
+	dependencies.dependencies = append(dependencies.dependencies, &todo.Dependency{TodoID: a.ID, BlockedByID: d.ID})
+	if _, err := service.PlanTodos(ctx, &todo.PlanRequest{TodoIDs: []string{a.ID.String(), b.ID.String(), d.ID.String()}}); domainCode(err) != shared.ErrCodeConflict {
+		t.Errorf("expected a cyclic plan to be a conflict, got %v", err)
+	}
+	dependencies.dependencies = dependencies.dependencies[:len(dependencies.dependencies)-1]
+
+	if err := service.DeleteTodo(ctx, b.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(dependencies.dependencies) != 2 {
+		t.Errorf("expected the deleted todo's dependencies to go, got %d left", len(dependencies.dependencies))
+	}
+}
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"taskflow/adapter/http/handlers"
+	"taskflow/internal/domain/file"
+	"taskflow/pkg/identity"
+	"taskflow/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newDownloadRouter(t *testing.T, content string) (*gin.Engine, string) {
+	t.Helper()
+	service, repo, _ := newTestFileService(t, 1024)
+	resp, err := service.UploadFile(context.Background(), &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	markClean(repo, resp.FileID)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/files/:id/download", handlers.NewFileHandler(service, nil, nil).DownloadFile)
+	return r, "/files/" + resp.FileID + "/download"
+}
+
+func download(r *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestDownload_FullContentWithValidators(t *testing.T) {
+	r, path := newDownloadRouter(t, "0123456789")
+
+	w := download(r, path, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Fatalf("unexpected response: %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Accept-Ranges") != "bytes" || w.Header().Get("ETag") == "" {
+		t.Errorf("expected Accept-Ranges and ETag headers, got %v", w.Header())
+	}
+}
+
+func TestDownload_RangeRequests(t *testing.T) {
+	r, path := newDownloadRouter(t, "0123456789")
+	etag := download(r, path, nil).Header().Get("ETag")
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"closed range", map[string]string{"Range": "bytes=2-5"}, http.StatusPartialContent, "2345", "bytes 2-5/10"},
+		{"open range", map[string]string{"Range": "bytes=7-"}, http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"suffix range", map[string]string{"Range": "bytes=-3"}, http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"end past size", map[string]string{"Range": "bytes=8-100"}, http.StatusPartialContent, "89", "bytes 8-9/10"},
+		{"unsatisfiable", map[string]string{"Range": "bytes=10-"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		{"multiple ranges ignored", map[string]string{"Range": "bytes=0-1,4-5"}, http.StatusOK, "0123456789", ""},
+		{"matching If-Range", map[string]string{"Range": "bytes=0-1", "If-Range": etag}, http.StatusPartialContent, "01", "bytes 0-1/10"},
+		{"stale If-Range", map[string]string{"Range": "bytes=0-1", "If-Range": `"stale"`}, http.StatusOK, "0123456789", ""},
+		{"If-None-Match", map[string]string{"If-None-Match": etag}, http.StatusNotModified, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := download(r, path, tt.headers)
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, w.Code)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, w.Body.String())
+			}
+			if w.Header().Get("Content-Range") != tt.contentRange {
+				t.Errorf("expected Content-Range %q, got %q", tt.contentRange, w.Header().Get("Content-Range"))
+			}
+		})
+	}
+}
+
+func TestDownload_CorruptContentIsCutShort(t *testing.T) {
+	service, repo, storage := newTestFileService(t, 1024)
+	resp, err := service.UploadFile(context.Background(), &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader("meeting notes"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	markClean(repo, resp.FileID)
+	storage.objects[repo.files[resp.FileID].StorageKey] = []byte("meeting notez")
+
+	// Downloads stream past the timeout middleware, so the body is cut short
+	// as it is sent rather than after it was buffered whole
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Timeout(middleware.DefaultTimeoutConfig()))
+	r.GET("/files/:id/download", handlers.NewFileHandler(service, nil, nil).DownloadFile)
+
+	w := download(r, "/files/"+resp.FileID+"/download", nil)
+	if w.Header().Get("Content-Length") != "13" || w.Body.Len() >= 13 {
+		t.Errorf("expected a body shorter than its Content-Length, got %q for %s", w.Body.String(), w.Header().Get("Content-Length"))
+	}
+}
+
+// replacingFileService replaces a file's content right after the handler has
+// read its metadata
+type replacingFileService struct {
+	file.FileService
+	replace func()
+}
+
+func (s *replacingFileService) GetFile(ctx context.Context, fileID string) (*file.File, error) {
+	loaded, err := s.FileService.GetFile(ctx, fileID)
+	s.replace()
+	return loaded, err
+}
+
+func TestDownload_ServesTheContentItsHeadersDescribe(t *testing.T) {
+	f := newVersionFixture(t, file.VersionPolicy{})
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+	resp, err := f.service.UploadFile(ctx, &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader("first draft"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	markClean(f.repo, resp.FileID)
+	etag := download(newReplacingRouter(f.service, func() {}), "/files/"+resp.FileID+"/download", nil).Header().Get("ETag")
+
+	r := newReplacingRouter(f.service, func() { f.replace(t, ctx, resp.FileID, "second draft, longer") })
+	w := download(r, "/files/"+resp.FileID+"/download", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "first draft" {
+		t.Fatalf("expected the content the headers were read with, got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") != etag || w.Header().Get("Content-Length") != "11" {
+		t.Errorf("expected the headers of the first draft, got %v", w.Header())
+	}
+}
+
+func newReplacingRouter(service file.FileService, replace func()) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/files/:id/download", handlers.NewFileHandler(&replacingFileService{FileService: service, replace: replace}, nil, nil).DownloadFile)
+	return r
+}
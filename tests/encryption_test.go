@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"taskflow/adapter/kms"
+	"taskflow/adapter/storage"
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/identity"
+)
+
+type mockDataKeyRepo struct {
+	keys map[string]*shared.DataKey
+}
+
+func newMockDataKeyRepo() *mockDataKeyRepo {
+	return &mockDataKeyRepo{keys: map[string]*shared.DataKey{}}
+}
+
+func (m *mockDataKeyRepo) Save(ctx context.Context, key *shared.DataKey) error {
+	stored := *key
+	m.keys[key.StorageKey] = &stored
+	return nil
+}
+func (m *mockDataKeyRepo) Get(ctx context.Context, storageKey string) (*shared.DataKey, error) {
+	key, ok := m.keys[storageKey]
+	if !ok {
+		return nil, shared.ErrNotFound
+	}
+	stored := *key
+	return &stored, nil
+}
+func (m *mockDataKeyRepo) Delete(ctx context.Context, storageKey string) error {
+	delete(m.keys, storageKey)
+	return nil
+}
+
+// writeKeyFile writes a master key file holding the given key IDs, each
+// with a key derived from its ID
+func writeKeyFile(t *testing.T, current string, ids ...string) string {
+	t.Helper()
+	keys := map[string]string{}
+	for _, id := range ids {
+		keys[id] = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte(id[:1]), 32))
+	}
+	data, _ := json.Marshal(map[string]interface{}{"currentKeyId": current, "keys": keys})
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	return path
+}
+
+func newKeyManager(t *testing.T, current string, ids ...string) shared.KeyManager {
+	t.Helper()
+	keys, err := kms.NewLocalKeyManager(writeKeyFile(t, current, ids...))
+	if err != nil {
+		t.Fatalf("failed to load key file: %v", err)
+	}
+	return keys
+}
+
+// readContent reads a whole download
+func readContent(rc io.ReadCloser, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func TestEncryptedStorage_RoundTripsAndDetectsTampering(t *testing.T) {
+	inner, dataKeys := newMockStorage(), newMockDataKeyRepo()
+	encrypted := storage.NewEncryptedStorage(inner, dataKeys, newKeyManager(t, "a1", "a1"))
+	ctx := context.Background()
+
+	// Spans three chunks, the last one partial
+	content := []byte(strings.Repeat("confidential customer data ", 6000))
+	key, err := encrypted.Upload(ctx, "data.txt", bytes.NewReader(content), "text/plain")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if bytes.Contains(inner.objects[key], []byte("confidential")) {
+		t.Fatalf("stored object must not contain plaintext")
+	}
+	if dataKeys.keys[key].KeyID != "a1" || dataKeys.keys[key].Size != int64(len(content)) {
+		t.Errorf("unexpected data key record: %+v", dataKeys.keys[key])
+	}
+
+	if got, err := readContent(encrypted.Download(ctx, key)); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("expected decrypted content to match (%v)", err)
+	}
+	offset, length := int64(65000), int64(70000)
+	if got, err := readContent(encrypted.DownloadRange(ctx, key, offset, length)); err != nil || !bytes.Equal(got, content[offset:offset+length]) {
+		t.Errorf("expected range across chunks to match (%v)", err)
+	}
+
+	inner.objects[key][70000] ^= 1
+	if _, err := readContent(encrypted.Download(ctx, key)); err == nil {
+		t.Errorf("expected tampered content to fail")
+	}
+
+	inner.objects[key] = inner.objects[key][:65536+16]
+	if _, err := readContent(encrypted.Download(ctx, key)); err == nil {
+		t.Errorf("expected truncated content to fail")
+	}
+
+	// Objects stored before encryption was enabled are read as they are
+	inner.objects["legacy"] = []byte("plain old content")
+	if got, _ := readContent(encrypted.Download(ctx, "legacy")); string(got) != "plain old content" {
+		t.Errorf("expected unencrypted object to be served, got %q", got)
+	}
+
+	if err := encrypted.Delete(ctx, key); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, ok := dataKeys.keys[key]; ok {
+		t.Errorf("expected data key to be deleted with the object")
+	}
+}
+
+func TestEncryptedStorage_ResumableUploadAcrossParts(t *testing.T) {
+	inner, dataKeys := newMockMultipartStorage(16), newMockDataKeyRepo()
+	encrypted := storage.NewEncryptedStorage(inner, dataKeys, newKeyManager(t, "a1", "a1")).(file.MultipartStorage)
+	policy, _ := file.NewUploadPolicy(1<<20, []string{".pdf"})
+	repo := newMockFileRepo()
+	service, err := file.NewResumableUploadService(file.Deps{Files: repo, Storage: encrypted, Messaging: &mockMessaging{}, Policy: policy},
+		newMockResumableUploadRepo(), file.ResumableUploadConfig{Expiry: time.Hour, LockTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("unexpected service error: %v", err)
+	}
+	ctx := context.Background()
+
+	content := append([]byte("%PDF-1.7\n"), bytes.Repeat([]byte("page "), 40000)...)
+	upload, err := service.Create(ctx, &file.CreateResumableUploadRequest{Filename: "report.pdf", Length: int64(len(content))})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for offset := 0; offset < len(content); offset += 50000 {
+		end := min(offset+50000, len(content))
+		if upload, err = service.WriteChunk(ctx, upload.ID.String(), int64(offset), bytes.NewReader(content[offset:end])); err != nil {
+			t.Fatalf("expected chunk at %d to be written, got %v", offset, err)
+		}
+	}
+
+	stored := repo.files[*upload.FileID]
+	if stored.KeyID != "a1" {
+		t.Errorf("expected key ID to be recorded on the file, got %q", stored.KeyID)
+	}
+	if got, err := readContent(encrypted.Download(ctx, stored.StorageKey)); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("expected assembled content to decrypt (%v)", err)
+	}
+}
+
+func TestRewrapKeys_MovesFilesToTheCurrentMasterKey(t *testing.T) {
+	inner, dataKeys := newMockStorage(), newMockDataKeyRepo()
+	repo := newMockFileRepo()
+	policy, _ := file.NewUploadPolicy(1024, []string{".txt"})
+	newService := func(keys shared.KeyManager) file.FileService {
+		return file.NewFileService(file.Deps{Files: repo, Storage: storage.NewEncryptedStorage(inner, dataKeys, keys), Messaging: &mockMessaging{}, Policy: policy})
+	}
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+
+	before := newService(newKeyManager(t, "a1", "a1"))
+	resp, err := before.UploadFile(ctx, &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader("meeting notes"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	markClean(repo, resp.FileID)
+	if repo.files[resp.FileID].KeyID != "a1" {
+		t.Fatalf("expected file to record its master key, got %q", repo.files[resp.FileID].KeyID)
+	}
+
+	rotated := newService(newKeyManager(t, "b2", "a1", "b2"))
+	rewrapped, err := rotated.RewrapKeys(ctx)
+	if err != nil || rewrapped != 1 {
+		t.Fatalf("expected 1 file rewrapped, got %d (%v)", rewrapped, err)
+	}
+	if repo.files[resp.FileID].KeyID != "b2" {
+		t.Errorf("expected file to move to the new master key, got %q", repo.files[resp.FileID].KeyID)
+	}
+	if again, _ := rotated.RewrapKeys(ctx); again != 0 {
+		t.Errorf("expected nothing left to rewrap, got %d", again)
+	}
+
+	// The old master key can now be retired
+	retired := newService(newKeyManager(t, "b2", "b2"))
+	if got, err := readContent(retired.DownloadFile(ctx, resp.FileID)); err != nil || string(got) != "meeting notes" {
+		t.Errorf("expected content to decrypt with the new master key, got %q (%v)", got, err)
+	}
+}
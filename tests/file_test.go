@@ -0,0 +1,451 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/shared"
+)
+
+// --- Mock Repositories ---
+type mockFileRepo struct {
+	files map[string]*file.File
+}
+
+func newMockFileRepo() *mockFileRepo {
+	return &mockFileRepo{files: map[string]*file.File{}}
+}
+
+func (m *mockFileRepo) Create(ctx context.Context, f *file.File) error {
+	stored := *f
+	m.files[f.ID.String()] = &stored
+	return nil
+}
+
+// GetByID returns a copy, so a file read before another write is stale like
+// a row read from the database
+func (m *mockFileRepo) GetByID(ctx context.Context, id string) (*file.File, error) {
+	f, ok := m.files[id]
+	if !ok {
+		return nil, shared.NewNotFoundError("file not found")
+	}
+	read := *f
+	return &read, nil
+}
+func (m *mockFileRepo) Update(ctx context.Context, f *file.File, fields ...string) error {
+	stored, ok := m.files[f.ID.String()]
+	if !ok {
+		return nil
+	}
+	from, to := reflect.ValueOf(f).Elem(), reflect.ValueOf(stored).Elem()
+	for _, field := range fields {
+		to.FieldByName(field).Set(from.FieldByName(field))
+	}
+	return nil
+}
+func (m *mockFileRepo) UpdateContent(ctx context.Context, f *file.File, version int, storageKey string, fields ...string) (bool, error) {
+	stored, ok := m.files[f.ID.String()]
+	if !ok || stored.Version != version || stored.StorageKey != storageKey {
+		return false, nil
+	}
+	return true, m.Update(ctx, f, fields...)
+}
+func (m *mockFileRepo) UpdateStatus(ctx context.Context, f *file.File, from string, fields ...string) (bool, error) {
+	stored, ok := m.files[f.ID.String()]
+	if !ok || stored.Status != from {
+		return false, nil
+	}
+	return true, m.Update(ctx, f, fields...)
+}
+func (m *mockFileRepo) Delete(ctx context.Context, id string) error {
+	delete(m.files, id)
+	return nil
+}
+func (m *mockFileRepo) List(ctx context.Context, limit, offset int) ([]*file.File, error) {
+	var files []*file.File
+	for _, f := range m.files {
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func (m *mockFileRepo) ListPendingBefore(ctx context.Context, before time.Time, limit int) ([]*file.File, error) {
+	var files []*file.File
+	for _, f := range m.files {
+		if f.Status == file.StatusPending && f.CreatedAt.Before(before) && len(files) < limit {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+func (m *mockFileRepo) ListQuarantinedBefore(ctx context.Context, before time.Time, limit int) ([]*file.File, error) {
+	var files []*file.File
+	for _, f := range m.files {
+		if f.Status == file.StatusAvailable && f.ScanStatus == file.ScanQuarantined && f.CreatedAt.Before(before) && len(files) < limit {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+func (m *mockFileRepo) ListAfter(ctx context.Context, afterID string, limit int) ([]*file.File, error) {
+	var files []*file.File
+	for id, f := range m.files {
+		if id > afterID {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ID.String() < files[j].ID.String() })
+	if len(files) > limit {
+		files = files[:limit]
+	}
+	return files, nil
+}
+
+func (m *mockFileRepo) ListWrappedAfter(ctx context.Context, keyID, afterID string, limit int) ([]*file.File, error) {
+	all, err := m.ListAfter(ctx, afterID, len(m.files))
+	if err != nil {
+		return nil, err
+	}
+	var files []*file.File
+	for _, f := range all {
+		if f.KeyID != "" && f.KeyID != keyID && len(files) < limit {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// markClean releases a file from quarantine as a clean malware scan would
+func markClean(repo *mockFileRepo, fileID string) {
+	repo.files[fileID].ScanStatus = file.ScanClean
+}
+
+type mockBlobRepo struct {
+	blobs map[string]*file.Blob
+}
+
+func newMockBlobRepo() *mockBlobRepo {
+	return &mockBlobRepo{blobs: map[string]*file.Blob{}}
+}
+
+func (m *mockBlobRepo) Acquire(ctx context.Context, blob *file.Blob) (*file.Blob, error) {
+	existing, ok := m.blobs[blob.Checksum]
+	if !ok {
+		existing = blob
+		m.blobs[blob.Checksum] = existing
+	}
+	existing.RefCount++
+	stored := *existing
+	return &stored, nil
+}
+func (m *mockBlobRepo) Release(ctx context.Context, checksum, storageKey string) (int64, error) {
+	blob, ok := m.blobs[checksum]
+	if !ok || blob.StorageKey != storageKey {
+		return 0, shared.ErrNotFound
+	}
+	blob.RefCount--
+	if blob.RefCount == 0 {
+		delete(m.blobs, checksum)
+	}
+	return blob.RefCount, nil
+}
+func (m *mockBlobRepo) ListAfter(ctx context.Context, afterChecksum string, limit int) ([]*file.Blob, error) {
+	var blobs []*file.Blob
+	for checksum, blob := range m.blobs {
+		if checksum > afterChecksum {
+			blobs = append(blobs, blob)
+		}
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Checksum < blobs[j].Checksum })
+	if len(blobs) > limit {
+		blobs = blobs[:limit]
+	}
+	return blobs, nil
+}
+
+type mockStorage struct {
+	objects map[string][]byte
+	// modified holds LastModified times; unset objects are listed as zero time
+	modified map[string]time.Time
+	uploads  int
+}
+
+func newMockStorage() *mockStorage {
+	return &mockStorage{objects: map[string][]byte{}}
+}
+
+func (m *mockStorage) Upload(ctx context.Context, filename string, content io.Reader, contentType string) (string, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+	m.uploads++
+	key := fmt.Sprintf("key-%d-%s", m.uploads, filename)
+	m.objects[key] = data
+	return key, nil
+}
+func (m *mockStorage) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+func (m *mockStorage) DownloadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	data, ok := m.objects[key]
+	if !ok || offset+length > int64(len(data)) {
+		return nil, errors.New("invalid range")
+	}
+	return io.NopCloser(bytes.NewReader(data[offset : offset+length])), nil
+}
+func (m *mockStorage) Delete(ctx context.Context, key string) error {
+	delete(m.objects, key)
+	return nil
+}
+func (m *mockStorage) GetURL(ctx context.Context, key string) (string, error) {
+	return "http://storage/" + key, nil
+}
+
+func (m *mockStorage) PresignPut(ctx context.Context, key string, contentType string, expires time.Duration) (*shared.PresignedUpload, error) {
+	return &shared.PresignedUpload{Method: "PUT", URL: "http://storage/" + key, ExpiresAt: time.Now().Add(expires)}, nil
+}
+func (m *mockStorage) PresignPost(ctx context.Context, key string, contentType string, maxSize int64, expires time.Duration) (*shared.PresignedUpload, error) {
+	return &shared.PresignedUpload{Method: "POST", URL: "http://storage", Fields: map[string]string{"key": key}, ExpiresAt: time.Now().Add(expires)}, nil
+}
+func (m *mockStorage) Stat(ctx context.Context, key string) (*shared.ObjectInfo, error) {
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, shared.ErrNotFound
+	}
+	return &shared.ObjectInfo{Key: key, Size: int64(len(data)), ETag: "etag-" + key}, nil
+}
+
+func (m *mockStorage) ListObjects(ctx context.Context, fn func(shared.ObjectInfo) error) error {
+	for key, data := range m.objects {
+		if err := fn(shared.ObjectInfo{Key: key, Size: int64(len(data)), LastModified: m.modified[key]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func newTestFileService(t *testing.T, maxSize int64) (file.FileService, *mockFileRepo, *mockStorage) {
+	t.Helper()
+	policy, err := file.NewUploadPolicy(maxSize, []string{".png", ".txt", ".pdf"})
+	if err != nil {
+		t.Fatalf("unexpected policy error: %v", err)
+	}
+	repo := newMockFileRepo()
+	storage := newMockStorage()
+	return file.NewFileService(file.Deps{Files: repo, Storage: storage, Messaging: &mockMessaging{}, Policy: policy}), repo, storage
+}
+
+func domainCode(err error) string {
+	var domainErr *shared.DomainError
+	if errors.As(err, &domainErr) {
+		return domainErr.Code
+	}
+	return ""
+}
+
+// --- Tests ---
+func TestUploadFile_RecordsCountedSizeAndSniffedType(t *testing.T) {
+	service, repo, _ := newTestFileService(t, 1024)
+	content := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{0}, 100)...)
+
+	req := &file.CreateFileRequest{Filename: "photo.PNG", ContentType: "text/html", Size: 1}
+	resp, err := service.UploadFile(context.Background(), req, bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	stored := repo.files[resp.FileID]
+	if stored.Size != int64(len(content)) {
+		t.Errorf("expected size %d, got %d", len(content), stored.Size)
+	}
+	if stored.ContentType != "image/png" {
+		t.Errorf("expected sniffed content type image/png, got %q", stored.ContentType)
+	}
+}
+
+func TestUploadFile_RejectsContentMismatch(t *testing.T) {
+	service, _, storage := newTestFileService(t, 1024)
+
+	req := &file.CreateFileRequest{Filename: "report.pdf"}
+	_, err := service.UploadFile(context.Background(), req, strings.NewReader("just some text"))
+	if domainCode(err) != shared.ErrCodeUnsupported {
+		t.Fatalf("expected unsupported media type error, got %v", err)
+	}
+	if len(storage.objects) != 0 {
+		t.Errorf("nothing should be stored for rejected uploads")
+	}
+}
+
+func TestUploadFile_RejectsDisallowedExtension(t *testing.T) {
+	service, _, _ := newTestFileService(t, 1024)
+
+	req := &file.CreateFileRequest{Filename: "script.exe"}
+	_, err := service.UploadFile(context.Background(), req, strings.NewReader("MZ"))
+	if domainCode(err) != shared.ErrCodeUnsupported {
+		t.Fatalf("expected unsupported media type error, got %v", err)
+	}
+}
+
+func TestUploadFile_EnforcesMaxSizeWhileStreaming(t *testing.T) {
+	service, repo, _ := newTestFileService(t, 64)
+
+	req := &file.CreateFileRequest{Filename: "notes.txt"}
+	_, err := service.UploadFile(context.Background(), req, strings.NewReader(strings.Repeat("a", 65)))
+	if domainCode(err) != shared.ErrCodeTooLarge {
+		t.Fatalf("expected too large error, got %v", err)
+	}
+	if len(repo.files) != 0 {
+		t.Errorf("no file record should be created for oversized uploads")
+	}
+}
+
+func TestNewUploadPolicy_RejectsUnknownExtension(t *testing.T) {
+	if _, err := file.NewUploadPolicy(1024, []string{".exe"}); err == nil {
+		t.Errorf("expected error for extension without content detection")
+	}
+}
+
+func TestCompleteUpload_RecordsStoredObject(t *testing.T) {
+	service, repo, storage := newTestFileService(t, 1024)
+
+	resp, err := service.CreateUploadURL(context.Background(), &file.CreateUploadURLRequest{Filename: "scan.pdf", Size: 10})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	pending := repo.files[resp.FileID]
+	if pending.Status != file.StatusPending {
+		t.Fatalf("expected pending status, got %q", pending.Status)
+	}
+
+	if _, err := service.CompleteUpload(context.Background(), resp.FileID); domainCode(err) != shared.ErrCodeConflict {
+		t.Fatalf("expected conflict before the object exists, got %v", err)
+	}
+
+	received := pending.StorageKey
+	storage.objects[received] = []byte("%PDF-1.7 content")
+	completed, err := service.CompleteUpload(context.Background(), resp.FileID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	sum := sha256.Sum256([]byte("%PDF-1.7 content"))
+	if completed.Status != file.StatusAvailable || completed.Size != 16 || completed.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected completed file: %+v", completed)
+	}
+	if _, ok := storage.objects[received]; ok || len(storage.objects) != 1 {
+		t.Errorf("expected the received object to be replaced by its processed copy")
+	}
+}
+
+func TestCompleteUpload_RejectsContentNotMatchingExtension(t *testing.T) {
+	service, repo, storage := newTestFileService(t, 1024)
+
+	resp, _ := service.CreateUploadURL(context.Background(), &file.CreateUploadURLRequest{Filename: "scan.pdf", Size: 10})
+	storage.objects[repo.files[resp.FileID].StorageKey] = append([]byte{}, pngHeader...)
+	if _, err := service.CompleteUpload(context.Background(), resp.FileID); domainCode(err) != shared.ErrCodeUnsupported {
+		t.Fatalf("expected unsupported media type, got %v", err)
+	}
+	if _, ok := repo.files[resp.FileID]; ok || len(storage.objects) != 0 {
+		t.Errorf("expected the rejected upload to be discarded")
+	}
+}
+
+func TestCleanupPendingUploads_RemovesOnlyExpired(t *testing.T) {
+	service, repo, _ := newTestFileService(t, 1024)
+
+	old, _ := service.CreateUploadURL(context.Background(), &file.CreateUploadURLRequest{Filename: "old.txt", Size: 1})
+	fresh, _ := service.CreateUploadURL(context.Background(), &file.CreateUploadURLRequest{Filename: "new.txt", Size: 1})
+	repo.files[old.FileID].CreatedAt = time.Now().Add(-48 * time.Hour)
+
+	removed, err := service.CleanupPendingUploads(context.Background(), 24*time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 removed upload, got %d", removed)
+	}
+	if _, ok := repo.files[fresh.FileID]; !ok {
+		t.Errorf("fresh pending upload must be kept")
+	}
+}
+
+func TestUploadFile_RecordsChecksumAndVerifiesDownload(t *testing.T) {
+	service, repo, storage := newTestFileService(t, 1024)
+	content := []byte("meeting notes")
+	sum := sha256.Sum256(content)
+
+	resp, err := service.UploadFile(context.Background(), &file.CreateFileRequest{Filename: "notes.txt"}, bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	stored := repo.files[resp.FileID]
+	if stored.Checksum != hex.EncodeToString(sum[:]) || resp.Checksum != stored.Checksum {
+		t.Fatalf("expected SHA-256 checksum to be recorded, got %q", stored.Checksum)
+	}
+	markClean(repo, resp.FileID)
+
+	reader, _ := service.DownloadFile(context.Background(), resp.FileID)
+	if data, err := io.ReadAll(reader); err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("expected verified content, got %q (%v)", data, err)
+	}
+
+	storage.objects[stored.StorageKey] = []byte("meeting notez")
+	reader, _ = service.DownloadFile(context.Background(), resp.FileID)
+	data, err := io.ReadAll(reader)
+	if err == nil {
+		t.Fatalf("expected corrupted content to fail verification")
+	}
+	if len(data) == len(content) {
+		t.Errorf("corrupted content must not be returned complete")
+	}
+}
+
+func TestUploadFile_DeduplicatesIdenticalContent(t *testing.T) {
+	policy, _ := file.NewUploadPolicy(1024, []string{".txt"})
+	repo, blobs, storage := newMockFileRepo(), newMockBlobRepo(), newMockStorage()
+	service := file.NewFileService(file.Deps{Files: repo, Blobs: blobs, Storage: storage, Messaging: &mockMessaging{}, Policy: policy})
+	ctx := context.Background()
+
+	first, _ := service.UploadFile(ctx, &file.CreateFileRequest{Filename: "a.txt"}, strings.NewReader("same content"))
+	second, _ := service.UploadFile(ctx, &file.CreateFileRequest{Filename: "b.txt"}, strings.NewReader("same content"))
+
+	key := repo.files[first.FileID].StorageKey
+	if repo.files[second.FileID].StorageKey != key {
+		t.Fatalf("expected identical uploads to share a storage key")
+	}
+	if len(storage.objects) != 1 {
+		t.Fatalf("expected one stored object, got %d", len(storage.objects))
+	}
+
+	if err := service.DeleteFile(ctx, first.FileID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, ok := storage.objects[key]; !ok {
+		t.Fatalf("shared object must be kept while referenced")
+	}
+	if err := service.DeleteFile(ctx, second.FileID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, ok := storage.objects[key]; ok {
+		t.Errorf("object must be deleted with its last reference")
+	}
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/todo"
+
+	"github.com/google/uuid"
+)
+
+// gcFixture uploads files through a file service whose deletes follow the
+// detach policy, and ages everything past the grace period
+type gcFixture struct {
+	service     file.FileService
+	repo        *mockFileRepo
+	storage     *mockStorage
+	attachments *mockAttachmentRepo
+	// deleted are the files announced deleted
+	deleted []*file.File
+}
+
+func newGCFixture(t *testing.T) *gcFixture {
+	t.Helper()
+	todoRepo, _ := newMemTodoRepo()
+	attachments := newMockAttachmentRepo()
+	policy, _ := file.NewUploadPolicy(1024, []string{".txt"})
+	repo, storage := newMockFileRepo(), newMockStorage()
+	storage.modified = map[string]time.Time{}
+	fixture := &gcFixture{repo: repo, storage: storage, attachments: attachments}
+	messaging := &mockMessaging{PublishFn: func(ctx context.Context, topic string, message interface{}) error {
+		if topic == file.TopicFileDeleted {
+			fixture.deleted = append(fixture.deleted, message.(*file.File))
+		}
+		return nil
+	}}
+	fixture.service = file.NewFileService(file.Deps{Files: repo, Blobs: newMockBlobRepo(), Storage: storage, Messaging: messaging, Policy: policy, References: todo.NewFileReferences(todo.Deps{Todos: todoRepo, Attachments: attachments}, todo.FileDeleteDetach)})
+	return fixture
+}
+
+// upload stores a file created age ago
+func (f *gcFixture) upload(t *testing.T, name string, age time.Duration) *file.File {
+	t.Helper()
+	resp, err := f.service.UploadFile(context.Background(), &file.CreateFileRequest{Filename: name}, strings.NewReader("content of "+name))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	stored := f.repo.files[resp.FileID]
+	stored.CreatedAt = time.Now().Add(-age)
+	f.storage.modified[stored.StorageKey] = stored.CreatedAt
+	return stored
+}
+
+func TestCollectGarbage_DeletesOrphansAndMissingContent(t *testing.T) {
+	fixture := newGCFixture(t)
+	ctx := context.Background()
+
+	kept := fixture.upload(t, "kept.txt", 72*time.Hour)
+	lost := fixture.upload(t, "lost.txt", 72*time.Hour)
+	delete(fixture.storage.objects, lost.StorageKey)
+	fixture.storage.objects["orphan"] = []byte("left behind")
+	fixture.storage.objects["in-flight"] = []byte("still uploading")
+	fixture.storage.modified["in-flight"] = time.Now()
+
+	report, err := fixture.service.CollectGarbage(ctx, file.GCOptions{GracePeriod: 48 * time.Hour})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(report.OrphanedObjects) != 1 || report.OrphanedObjects[0] != "orphan" {
+		t.Errorf("expected only the old orphan to be reported, got %v", report.OrphanedObjects)
+	}
+	if len(report.MissingContent) != 1 || report.MissingContent[0] != lost.ID.String() {
+		t.Errorf("expected the file without content to be reported, got %v", report.MissingContent)
+	}
+	if report.Deleted != 2 || report.Failed != 0 {
+		t.Errorf("expected 2 deletions, got %+v", report)
+	}
+	// The gc command exits right after, so the deletion is already announced
+	if len(fixture.deleted) != 1 || fixture.deleted[0].ID != lost.ID {
+		t.Errorf("expected the deleted file to be announced before returning, got %v", fixture.deleted)
+	}
+
+	if _, ok := fixture.storage.objects["orphan"]; ok {
+		t.Errorf("expected orphaned object to be deleted")
+	}
+	if _, ok := fixture.storage.objects["in-flight"]; !ok {
+		t.Errorf("expected object within the grace period to be kept")
+	}
+	if _, ok := fixture.repo.files[lost.ID.String()]; ok {
+		t.Errorf("expected file without content to be deleted")
+	}
+	if _, ok := fixture.storage.objects[kept.StorageKey]; !ok {
+		t.Errorf("expected referenced object to be kept")
+	}
+}
+
+func TestCollectGarbage_UnattachedFilesOnlyWhenEnabled(t *testing.T) {
+	fixture := newGCFixture(t)
+	ctx := context.Background()
+
+	attached := fixture.upload(t, "attached.txt", 60*24*time.Hour)
+	unattached := fixture.upload(t, "unattached.txt", 60*24*time.Hour)
+	recent := fixture.upload(t, "recent.txt", 72*time.Hour)
+	fixture.attachments.Add(ctx, &todo.Attachment{TodoID: uuid.New(), FileID: attached.ID.String()})
+
+	report, err := fixture.service.CollectGarbage(ctx, file.GCOptions{GracePeriod: 48 * time.Hour})
+	if err != nil || len(report.Unattached) != 0 {
+		t.Fatalf("expected unattached files to be kept by default, got %+v (%v)", report, err)
+	}
+
+	report, err = fixture.service.CollectGarbage(ctx, file.GCOptions{GracePeriod: 48 * time.Hour, UnattachedAfter: 30 * 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(report.Unattached) != 1 || report.Unattached[0] != unattached.ID.String() {
+		t.Errorf("expected only the old unattached file, got %v", report.Unattached)
+	}
+	for _, f := range []*file.File{attached, recent} {
+		if _, ok := fixture.repo.files[f.ID.String()]; !ok {
+			t.Errorf("expected %s to be kept", f.Filename)
+		}
+	}
+}
+
+func TestCollectGarbage_DryRunDeletesNothing(t *testing.T) {
+	fixture := newGCFixture(t)
+	lost := fixture.upload(t, "lost.txt", 72*time.Hour)
+	delete(fixture.storage.objects, lost.StorageKey)
+	fixture.storage.objects["orphan"] = []byte("left behind")
+
+	report, err := fixture.service.CollectGarbage(context.Background(), file.GCOptions{GracePeriod: 48 * time.Hour, DryRun: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !report.DryRun || len(report.OrphanedObjects) != 1 || len(report.MissingContent) != 1 || report.Deleted != 0 {
+		t.Errorf("expected findings without deletions, got %+v", report)
+	}
+	if _, ok := fixture.storage.objects["orphan"]; !ok {
+		t.Errorf("dry run must not delete objects")
+	}
+	if _, ok := fixture.repo.files[lost.ID.String()]; !ok {
+		t.Errorf("dry run must not delete files")
+	}
+}
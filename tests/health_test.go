@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"taskflow/pkg/health"
+)
+
+func TestHealthRegistry_AllUp(t *testing.T) {
+	registry := health.NewRegistry(health.DefaultConfig())
+	registry.Register("db", func(ctx context.Context) error { return nil }, health.CheckOptions{})
+
+	report := registry.Readiness(context.Background())
+	if report.Status != health.StatusUp {
+		t.Fatalf("expected status up, got %s", report.Status)
+	}
+	if report.Checks["db"].Status != health.StatusUp {
+		t.Errorf("expected db check up, got %s", report.Checks["db"].Status)
+	}
+}
+
+func TestHealthRegistry_FailingAndOptionalChecks(t *testing.T) {
+	registry := health.NewRegistry(health.DefaultConfig())
+	registry.Register("lag", func(ctx context.Context) error { return errors.New("lagging") }, health.CheckOptions{Optional: true})
+
+	report := registry.Readiness(context.Background())
+	if report.Status != health.StatusUp {
+		t.Fatalf("optional check must not fail readiness, got %s", report.Status)
+	}
+	if report.Checks["lag"].Error != "lagging" {
+		t.Errorf("expected lag error to be reported, got %q", report.Checks["lag"].Error)
+	}
+
+	registry.Register("db", func(ctx context.Context) error { return errors.New("down") }, health.CheckOptions{})
+	report = registry.Readiness(context.Background())
+	if report.Status != health.StatusDown {
+		t.Fatalf("expected status down, got %s", report.Status)
+	}
+}
+
+func TestHealthRegistry_CheckTimeout(t *testing.T) {
+	registry := health.NewRegistry(health.DefaultConfig())
+	registry.Register("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}, health.CheckOptions{Timeout: 20 * time.Millisecond})
+
+	start := time.Now()
+	report := registry.Readiness(context.Background())
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("check timeout was not enforced")
+	}
+	if report.Checks["slow"].Status != health.StatusDown {
+		t.Errorf("expected slow check down, got %s", report.Checks["slow"].Status)
+	}
+}
+
+func TestHealthRegistry_CachesResults(t *testing.T) {
+	var calls int32
+	registry := health.NewRegistry(health.Config{DefaultTimeout: time.Second, CacheTTL: time.Minute})
+	registry.Register("db", func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}, health.CheckOptions{})
+
+	registry.Readiness(context.Background())
+	registry.Readiness(context.Background())
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected check to run once, ran %d times", n)
+	}
+}
+
+func TestHealthRegistry_ShuttingDown(t *testing.T) {
+	registry := health.NewRegistry(health.DefaultConfig())
+	registry.Register("db", func(ctx context.Context) error { return nil }, health.CheckOptions{})
+	registry.SetShuttingDown()
+
+	report := registry.Readiness(context.Background())
+	if report.Status != health.StatusDown {
+		t.Fatalf("expected readiness down during shutdown, got %s", report.Status)
+	}
+}
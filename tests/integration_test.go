@@ -11,12 +11,12 @@ import (
 )
 
 type TodoResponse struct {
-	ID          string  `json:"id"`
-	Description string  `json:"description"`
-	DueDate     string  `json:"dueDate"`
-	FileID      *string `json:"fileId"`
-	CreatedAt   string  `json:"createdAt"`
-	UpdatedAt   string  `json:"updatedAt"`
+	ID          string   `json:"id"`
+	Description string   `json:"description"`
+	DueDate     string   `json:"dueDate"`
+	FileIDs     []string `json:"fileIds"`
+	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt"`
 }
 
 type ListTodosResponse struct {
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"taskflow/pkg/logging"
+)
+
+func TestLogging_RedactsSensitiveKeys(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(logging.Config{
+		Level:      "info",
+		Format:     "json",
+		RedactKeys: logging.DefaultRedactKeys,
+		Output:     &buf,
+	})
+
+	logger.Info("login", "user", "alice", "Password", "hunter2")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected JSON output, got %q", buf.String())
+	}
+	if entry["Password"] != "[REDACTED]" {
+		t.Errorf("expected password to be redacted, got %v", entry["Password"])
+	}
+	if entry["user"] != "alice" {
+		t.Errorf("expected user to be kept, got %v", entry["user"])
+	}
+}
+
+func TestLogging_LevelIsApplied(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(logging.Config{Level: "warn", Format: "text", Output: &buf})
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	if strings.Contains(buf.String(), "hidden") {
+		t.Errorf("info message should be filtered at warn level")
+	}
+	if !strings.Contains(buf.String(), "shown") {
+		t.Errorf("warn message should be written")
+	}
+}
+
+func TestLogging_ContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(logging.Config{Format: "json", Output: &buf})
+
+	ctx := logging.WithLogger(context.Background(), logger.With("request_id", "req-1"))
+	logging.FromContext(ctx).Info("handled")
+
+	if !strings.Contains(buf.String(), `"request_id":"req-1"`) {
+		t.Errorf("expected request_id in output, got %q", buf.String())
+	}
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+	"taskflow/pkg/identity"
+
+	"github.com/google/uuid"
+)
+
+// mockParticipantRepo keeps participants in memory
+type mockParticipantRepo struct {
+	participants []*todo.Participant
+}
+
+func (m *mockParticipantRepo) Add(ctx context.Context, participant *todo.Participant) error {
+	for _, existing := range m.participants {
+		if *existing == *participant {
+			return nil
+		}
+	}
+	m.participants = append(m.participants, participant)
+	return nil
+}
+
+func (m *mockParticipantRepo) Remove(ctx context.Context, todoID uuid.UUID, userID string, role todo.Role) error {
+	for i, participant := range m.participants {
+		if participant.TodoID == todoID && participant.UserID == userID && participant.Role == role {
+			m.participants = append(m.participants[:i], m.participants[i+1:]...)
+			return nil
+		}
+	}
+	return shared.NewNotFoundError("participant not found")
+}
+
+func (m *mockParticipantRepo) ListByTodos(ctx context.Context, todoIDs []uuid.UUID) ([]*todo.Participant, error) {
+	var participants []*todo.Participant
+	for _, participant := range m.participants {
+		for _, id := range todoIDs {
+			if participant.TodoID == id {
+				participants = append(participants, participant)
+			}
+		}
+	}
+	return participants, nil
+}
+
+func (m *mockParticipantRepo) DeleteByTodo(ctx context.Context, todoID uuid.UUID) error {
+	kept := m.participants[:0]
+	for _, participant := range m.participants {
+		if participant.TodoID != todoID {
+			kept = append(kept, participant)
+		}
+	}
+	m.participants = kept
+	return nil
+}
+
+// mockUserDirectory knows the users of each workspace
+type mockUserDirectory map[string][]string
+
+func (m mockUserDirectory) Exists(ctx context.Context, workspaceID string, userID string) (bool, error) {
+	for _, user := range m[workspaceID] {
+		if user == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestParticipants_AssignAndWatch(t *testing.T) {
+	todoRepo, _ := newMemTodoRepo()
+	assigned := make(chan *todo.AssignedEvent, 10)
+	messaging := &mockMessaging{PublishFn: func(ctx context.Context, topic string, message interface{}) error {
+		if topic == todo.TopicTodoAssigned {
+			assigned <- message.(*todo.AssignedEvent)
+		}
+		return nil
+	}}
+	users := mockUserDirectory{"acme": {"alice", "bob"}}
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: &mockCache{}, Files: &mockFileChecker{}, Participants: &mockParticipantRepo{}, Users: users})
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice", WorkspaceID: "acme"})
+	created, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: "Review", DueDate: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	participant, err := service.AssignTodo(ctx, created.ID, &todo.ParticipantRequest{UserID: "bob"})
+	if err != nil || participant.UserID != "bob" || participant.Role != todo.RoleAssignee {
+		t.Fatalf("expected bob to be assigned, got %+v (%v)", participant, err)
+	}
+	select {
+	case event := <-assigned:
+		if event.Assignee != "bob" || event.AssignedBy != "alice" || event.Todo.ID != created.ID || len(event.Todo.Assignees) != 1 {
+			t.Errorf("expected an event for bob assigned by alice, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a todo.assigned event")
+	}
+	if _, err := service.AssignTodo(ctx, created.ID, &todo.ParticipantRequest{UserID: "bob"}); err != nil {
+		t.Errorf("expected assigning again to succeed, got %v", err)
+	}
+	select {
+	case event := <-assigned:
+		t.Errorf("expected no event for an existing assignee, got %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := service.AssignTodo(ctx, created.ID, &todo.ParticipantRequest{UserID: "mallory"}); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected an unknown user to be refused, got %v", err)
+	}
+
+	if watcher, err := service.WatchTodo(ctx, created.ID, &todo.ParticipantRequest{}); err != nil || watcher.UserID != "alice" {
+		t.Fatalf("expected the caller to watch the todo, got %+v (%v)", watcher, err)
+	}
+	got, _ := service.GetTodo(ctx, created.ID)
+	if len(got.Assignees) != 1 || got.Assignees[0] != "bob" || len(got.Watchers) != 1 || got.Watchers[0] != "alice" {
+		t.Errorf("expected bob assigned and alice watching, got %v and %v", got.Assignees, got.Watchers)
+	}
+
+	if err := service.UnwatchTodo(ctx, created.ID, "me"); err != nil {
+		t.Errorf("expected the caller to stop watching, got %v", err)
+	}
+	if err := service.UnassignTodo(ctx, created.ID, "bob"); err != nil {
+		t.Errorf("expected bob to be unassigned, got %v", err)
+	}
+	if err := service.UnassignTodo(ctx, created.ID, "bob"); domainCode(err) != shared.ErrCodeNotFound {
+		t.Errorf("expected unassigning twice to be not found, got %v", err)
+	}
+	if _, err := service.WatchTodo(context.Background(), created.ID, &todo.ParticipantRequest{}); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected an anonymous caller to need a user ID, got %v", err)
+	}
+}
+
+func TestParticipants_ListAssignedToMe(t *testing.T) {
+	var listed todo.ListFilter
+	todoRepo := &mockTodoRepo{ListFn: func(ctx context.Context, filter todo.ListFilter, limit, offset int) ([]*todo.TodoItem, error) {
+		listed = filter
+		return nil, nil
+	}}
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: &mockMessaging{}, Cache: &mockCache{}, Files: &mockFileChecker{}, Participants: &mockParticipantRepo{}})
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice", WorkspaceID: "acme"})
+
+	if _, err := service.ListTodos(ctx, todo.ListFilter{Assignee: "me"}, 10, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if listed.Assignee != "alice" {
+		t.Errorf("expected me to stand for the caller, got %q", listed.Assignee)
+	}
+	if _, err := service.ListTodos(context.Background(), todo.ListFilter{Assignee: "me"}, 10, 0); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected me to need an identified caller, got %v", err)
+	}
+
+	disabled := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: &mockMessaging{}, Cache: &mockCache{}, Files: &mockFileChecker{}})
+	if _, err := disabled.ListTodos(ctx, todo.ListFilter{Assignee: "me"}, 10, 0); domainCode(err) != shared.ErrCodeInvalidInput {
+		t.Errorf("expected assignees to be unsupported without a repository, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+	"taskflow/pkg/identity"
+
+	"github.com/google/uuid"
+)
+
+// mockProjectRepo keeps projects in memory and counts the todos of a todo
+// map
+type mockProjectRepo struct {
+	projects map[uuid.UUID]*todo.Project
+	todos    map[uuid.UUID]*todo.TodoItem
+}
+
+func (m *mockProjectRepo) count(project *todo.Project) *todo.Project {
+	counted := *project
+	for _, todoItem := range m.todos {
+		if todoItem.ProjectID != nil && *todoItem.ProjectID == project.ID {
+			counted.TodoCount++
+			if todoItem.Status == todo.StatusDone {
+				counted.DoneCount++
+			}
+		}
+	}
+	return &counted
+}
+
+func (m *mockProjectRepo) Create(ctx context.Context, project *todo.Project) error {
+	stored := *project
+	m.projects[project.ID] = &stored
+	return nil
+}
+
+func (m *mockProjectRepo) Get(ctx context.Context, id uuid.UUID) (*todo.Project, error) {
+	if project, ok := m.projects[id]; ok {
+		return m.count(project), nil
+	}
+	return nil, shared.NewNotFoundError("project not found")
+}
+
+func (m *mockProjectRepo) List(ctx context.Context, workspaceID string, includeArchived bool) ([]*todo.Project, error) {
+	var projects []*todo.Project
+	for _, project := range m.projects {
+		if project.WorkspaceID == workspaceID && (includeArchived || !project.Archived) {
+			projects = append(projects, m.count(project))
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Position < projects[j].Position })
+	return projects, nil
+}
+
+func (m *mockProjectRepo) Update(ctx context.Context, project *todo.Project) error {
+	stored := *project
+	stored.Position = m.projects[project.ID].Position
+	m.projects[project.ID] = &stored
+	return nil
+}
+
+func (m *mockProjectRepo) Reorder(ctx context.Context, ids []uuid.UUID) error {
+	for position, id := range ids {
+		m.projects[id].Position = position
+	}
+	return nil
+}
+
+func (m *mockProjectRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	delete(m.projects, id)
+	return nil
+}
+
+func newProjectServices() (todo.TodoService, todo.ProjectService) {
+	todoRepo, todos := newMemTodoRepo()
+	projects := &mockProjectRepo{projects: map[uuid.UUID]*todo.Project{}, todos: todos}
+	return todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: &mockMessaging{}, Cache: &mockCache{}, Files: &mockFileChecker{}, Projects: projects}), todo.NewProjectService(projects)
+}
+
+func createProject(t *testing.T, ctx context.Context, projects todo.ProjectService, name string) *todo.Project {
+	t.Helper()
+	project, err := projects.CreateProject(ctx, &todo.ProjectRequest{Name: name, Color: "#3366FF"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return project
+}
+
+func TestProjects_OrderCountsArchiveAndDelete(t *testing.T) {
+	service, projects := newProjectServices()
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice", WorkspaceID: "acme"})
+	home := createProject(t, ctx, projects, " Home ")
+	work := createProject(t, ctx, projects, "Work")
+	errands := createProject(t, ctx, projects, "Errands")
+	if home.Name != "Home" || home.Color != "#3366ff" || errands.Position != 2 {
+		t.Errorf("expected normalized projects in creation order, got %+v and %+v", home, errands)
+	}
+	if _, err := projects.CreateProject(ctx, &todo.ProjectRequest{Name: "Bad", Color: "blue"}); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected an invalid color to be refused, got %v", err)
+	}
+
+	ordered, err := projects.ReorderProjects(ctx, &todo.ReorderProjectsRequest{ProjectIDs: []string{errands.ID.String(), home.ID.String()}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(ordered) != 3 || ordered[0].ID != errands.ID || ordered[1].ID != home.ID || ordered[2].ID != work.ID {
+		t.Errorf("expected errands, home, work, got %+v", ordered)
+	}
+
+	projectID := work.ID.String()
+	report, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: "Report", DueDate: time.Now().Add(time.Hour), ProjectID: &projectID})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: "Slides", DueDate: time.Now().Add(time.Hour), ProjectID: &projectID})
+	done := todo.StatusDone
+	service.UpdateTodo(ctx, report.ID, &todo.UpdateTodoRequest{Status: &done})
+	got, err := projects.GetProject(ctx, work.ID)
+	if err != nil || got.TodoCount != 2 || got.DoneCount != 1 {
+		t.Errorf("expected 2 todos with 1 done, got %+v (%v)", got, err)
+	}
+
+	if err := projects.DeleteProject(ctx, work.ID); domainCode(err) != shared.ErrCodeConflict {
+		t.Errorf("expected deleting a project with todos to conflict, got %v", err)
+	}
+	archived := true
+	if _, err := projects.UpdateProject(ctx, work.ID, &todo.UpdateProjectRequest{Archived: &archived}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if listed, _ := projects.ListProjects(ctx, false); len(listed) != 2 {
+		t.Errorf("expected the archived project to be hidden, got %d projects", len(listed))
+	}
+	if listed, _ := projects.ListProjects(ctx, true); len(listed) != 3 {
+		t.Errorf("expected archived projects when asked for, got %d projects", len(listed))
+	}
+	if _, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: "Late", DueDate: time.Now().Add(time.Hour), ProjectID: &projectID}); domainCode(err) != shared.ErrCodeConflict {
+		t.Errorf("expected adding to an archived project to conflict, got %v", err)
+	}
+
+	other := identity.WithIdentity(context.Background(), identity.Identity{UserID: "bob", WorkspaceID: "globex"})
+	if _, err := projects.GetProject(other, home.ID); domainCode(err) != shared.ErrCodeNotFound {
+		t.Errorf("expected another workspace's project not to be found, got %v", err)
+	}
+	if err := projects.DeleteProject(ctx, home.ID); err != nil {
+		t.Errorf("expected an empty project to be deleted, got %v", err)
+	}
+}
+
+func TestProjects_SubtasksMoveWithTheirParent(t *testing.T) {
+	service, projects := newProjectServices()
+	ctx := context.Background()
+	home := createProject(t, ctx, projects, "Home")
+	work := createProject(t, ctx, projects, "Work")
+
+	homeID, workID := home.ID.String(), work.ID.String()
+	parent, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: "Move house", DueDate: time.Now().Add(time.Hour), ProjectID: &homeID})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	parentID := parent.ID.String()
+	child, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: "Pack", DueDate: time.Now().Add(time.Hour), ParentID: &parentID})
+	if err != nil || child.ProjectID == nil || *child.ProjectID != home.ID {
+		t.Fatalf("expected the subtask in its parent's project, got %+v (%v)", child, err)
+	}
+	if _, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: "Clean", DueDate: time.Now().Add(time.Hour), ParentID: &parentID, ProjectID: &workID}); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected a subtask in another project than its parent to be refused, got %v", err)
+	}
+
+	if _, err := service.UpdateTodo(ctx, parent.ID, &todo.UpdateTodoRequest{ProjectID: &workID}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got, _ := service.GetTodo(ctx, child.ID); got.ProjectID == nil || *got.ProjectID != work.ID {
+		t.Errorf("expected the subtask to move with its parent, got %v", got.ProjectID)
+	}
+	if _, err := service.UpdateTodo(ctx, child.ID, &todo.UpdateTodoRequest{ProjectID: &homeID}); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected moving only a subtask to be refused, got %v", err)
+	}
+
+	none := ""
+	moved, err := service.UpdateTodo(ctx, parent.ID, &todo.UpdateTodoRequest{ProjectID: &none})
+	if err != nil || moved.ProjectID != nil {
+		t.Fatalf("expected the todo to leave its project, got %+v (%v)", moved, err)
+	}
+	if got, _ := projects.GetProject(ctx, work.ID); got.TodoCount != 0 {
+		t.Errorf("expected the project to be empty, got %d todos", got.TodoCount)
+	}
+}
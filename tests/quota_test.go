@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/identity"
+)
+
+type mockUsageRepo struct {
+	usage map[string]*file.Usage
+}
+
+func newMockUsageRepo() *mockUsageRepo {
+	return &mockUsageRepo{usage: map[string]*file.Usage{}}
+}
+
+func (m *mockUsageRepo) Get(ctx context.Context, scope, ownerID string) (*file.Usage, error) {
+	if usage, ok := m.usage[scope+"/"+ownerID]; ok {
+		stored := *usage
+		return &stored, nil
+	}
+	return &file.Usage{Scope: scope, OwnerID: ownerID}, nil
+}
+
+func (m *mockUsageRepo) Charge(ctx context.Context, scope, ownerID string, bytes, files int64, limit file.Quota) (bool, error) {
+	usage, ok := m.usage[scope+"/"+ownerID]
+	if !ok {
+		usage = &file.Usage{Scope: scope, OwnerID: ownerID}
+		m.usage[scope+"/"+ownerID] = usage
+	}
+	if (limit.Bytes > 0 && usage.Bytes+bytes > limit.Bytes) || (limit.Files > 0 && usage.Files+files > limit.Files) {
+		return false, nil
+	}
+	usage.Bytes += bytes
+	usage.Files += files
+	return true, nil
+}
+
+func (m *mockUsageRepo) Credit(ctx context.Context, scope, ownerID string, bytes, files int64) error {
+	if usage, ok := m.usage[scope+"/"+ownerID]; ok {
+		usage.Bytes = max(usage.Bytes-bytes, 0)
+		usage.Files = max(usage.Files-files, 0)
+	}
+	return nil
+}
+
+// unreadable fails the test if an upload reads any content
+type unreadable struct{ t *testing.T }
+
+func (r unreadable) Read(p []byte) (int, error) {
+	r.t.Error("content must not be read once the quota is used up")
+	return 0, errors.New("unexpected read")
+}
+
+func newQuotaFileService(t *testing.T, config file.QuotaConfig) (file.FileService, *mockUsageRepo, *mockStorage) {
+	t.Helper()
+	policy, _ := file.NewUploadPolicy(1024, []string{".txt"})
+	usage, storage := newMockUsageRepo(), newMockStorage()
+	service := file.NewFileService(file.Deps{Files: newMockFileRepo(), Blobs: newMockBlobRepo(), Storage: storage, Messaging: &mockMessaging{}, Policy: policy, Quotas: file.NewQuotas(usage, config)})
+	return service, usage, storage
+}
+
+func TestQuota_RefusesUploadsPastTheQuota(t *testing.T) {
+	service, _, storage := newQuotaFileService(t, file.QuotaConfig{
+		User:      file.Quota{Bytes: 20},
+		Workspace: file.Quota{Files: 2},
+	})
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice", WorkspaceID: "acme"})
+
+	if _, err := service.UploadFile(ctx, &file.CreateFileRequest{Filename: "a.txt"}, strings.NewReader("twelve bytes")); err != nil {
+		t.Fatalf("expected upload within quota to succeed, got %v", err)
+	}
+
+	_, err := service.UploadFile(ctx, &file.CreateFileRequest{Filename: "b.txt"}, strings.NewReader("another twelve"))
+	if domainCode(err) != shared.ErrCodeQuotaExceeded {
+		t.Fatalf("expected byte quota to be exceeded, got %v", err)
+	}
+	if len(storage.objects) != 1 {
+		t.Errorf("expected the refused upload not to be kept, got %d objects", len(storage.objects))
+	}
+
+	if _, err := service.UploadFile(ctx, &file.CreateFileRequest{Filename: "c.txt"}, strings.NewReader("small")); err != nil {
+		t.Fatalf("expected upload within the remaining quota to succeed, got %v", err)
+	}
+
+	// The workspace file count is now used up
+	other := identity.WithIdentity(context.Background(), identity.Identity{UserID: "bob", WorkspaceID: "acme"})
+	_, err = service.UploadFile(other, &file.CreateFileRequest{Filename: "d.txt"}, unreadable{t})
+	if domainCode(err) != shared.ErrCodeQuotaExceeded {
+		t.Fatalf("expected workspace quota to be exceeded, got %v", err)
+	}
+}
+
+func TestQuota_CountersFollowDeletesAndDeduplication(t *testing.T) {
+	service, usage, _ := newQuotaFileService(t, file.QuotaConfig{})
+	alice := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice", WorkspaceID: "acme"})
+	bob := identity.WithIdentity(context.Background(), identity.Identity{UserID: "bob", WorkspaceID: "acme"})
+
+	first, _ := service.UploadFile(alice, &file.CreateFileRequest{Filename: "a.txt"}, strings.NewReader("same content"))
+	service.UploadFile(bob, &file.CreateFileRequest{Filename: "b.txt"}, strings.NewReader("same content"))
+
+	// Shared content still counts in full for each owner
+	report, err := service.GetUsage(bob)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if report.User.ID != "bob" || report.User.Bytes != 12 || report.User.Files != 1 {
+		t.Errorf("unexpected user usage: %+v", report.User)
+	}
+	if report.Workspace.ID != "acme" || report.Workspace.Bytes != 24 || report.Workspace.Files != 2 {
+		t.Errorf("unexpected workspace usage: %+v", report.Workspace)
+	}
+
+	if err := service.DeleteFile(alice, first.FileID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := usage.usage["user/alice"]; got.Bytes != 0 || got.Files != 0 {
+		t.Errorf("expected delete to credit the owner, got %+v", got)
+	}
+	if got := usage.usage["workspace/acme"]; got.Bytes != 12 || got.Files != 1 {
+		t.Errorf("expected delete to credit the workspace, got %+v", got)
+	}
+}
+
+func TestQuota_DirectUploadSettlesReceivedSize(t *testing.T) {
+	service, usage, storage := newQuotaFileService(t, file.QuotaConfig{User: file.Quota{Bytes: 100}})
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+
+	resp, err := service.CreateUploadURL(ctx, &file.CreateUploadURLRequest{Filename: "notes.txt", Size: 10})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := usage.usage["user/alice"]; got.Bytes != 10 || got.Files != 1 {
+		t.Fatalf("expected the declared size to be charged, got %+v", got)
+	}
+
+	pending, _ := service.GetFile(ctx, resp.FileID)
+	storage.objects[pending.StorageKey] = []byte(strings.Repeat("x", 150))
+	if _, err := service.CompleteUpload(ctx, resp.FileID); domainCode(err) != shared.ErrCodeQuotaExceeded {
+		t.Fatalf("expected an upload larger than the quota to be refused, got %v", err)
+	}
+	if got := usage.usage["user/alice"]; got.Bytes != 0 || got.Files != 0 {
+		t.Errorf("expected the refused upload to be credited, got %+v", got)
+	}
+	if _, ok := storage.objects[pending.StorageKey]; ok {
+		t.Errorf("expected the refused upload to be deleted")
+	}
+}
+
+// racingStorage completes the upload again while the first completion is
+// storing its processed copy, as a concurrent request would
+type racingStorage struct {
+	*mockStorage
+	complete func()
+}
+
+func (r *racingStorage) Upload(ctx context.Context, filename string, content io.Reader, contentType string) (string, error) {
+	if complete := r.complete; complete != nil {
+		r.complete = nil
+		complete()
+	}
+	return r.mockStorage.Upload(ctx, filename, content, contentType)
+}
+
+func TestQuota_ConcurrentCompletionsChargeOnce(t *testing.T) {
+	policy, _ := file.NewUploadPolicy(1024, []string{".txt"})
+	usage, storage := newMockUsageRepo(), &racingStorage{mockStorage: newMockStorage()}
+	service := file.NewFileService(file.Deps{Files: newMockFileRepo(), Blobs: newMockBlobRepo(), Storage: storage, Messaging: &mockMessaging{}, Policy: policy, Quotas: file.NewQuotas(usage, file.QuotaConfig{})})
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+
+	resp, err := service.CreateUploadURL(ctx, &file.CreateUploadURLRequest{Filename: "notes.txt", Size: 10})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	pending, _ := service.GetFile(ctx, resp.FileID)
+	storage.objects[pending.StorageKey] = []byte(strings.Repeat("x", 30))
+
+	var inner *file.File
+	storage.complete = func() {
+		if inner, err = service.CompleteUpload(ctx, resp.FileID); err != nil {
+			t.Errorf("expected the inner completion to succeed, got %v", err)
+		}
+	}
+	outer, err := service.CompleteUpload(ctx, resp.FileID)
+	if err != nil {
+		t.Fatalf("expected the outer completion to succeed, got %v", err)
+	}
+	if inner == nil || inner.Size != 30 || outer.Size != 30 || outer.Status != file.StatusAvailable {
+		t.Errorf("expected both completions to return the completed file, got %+v and %+v", inner, outer)
+	}
+	if got := usage.usage["user/alice"]; got.Bytes != 30 || got.Files != 1 {
+		t.Errorf("expected the received size to be charged once, got %+v", got)
+	}
+	if len(storage.objects) != 1 {
+		t.Errorf("expected only the completed file's content to be kept, got %d objects", len(storage.objects))
+	}
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+
+	"github.com/google/uuid"
+)
+
+// column returns the descriptions of a status column's todos by rank
+func column(todos map[uuid.UUID]*todo.TodoItem, status todo.Status) []string {
+	var ranked []*todo.TodoItem
+	for _, todoItem := range todos {
+		if todoItem.Status == status {
+			ranked = append(ranked, todoItem)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].Rank < ranked[j].Rank })
+	descriptions := make([]string, len(ranked))
+	for i, todoItem := range ranked {
+		descriptions[i] = todoItem.Description
+	}
+	return descriptions
+}
+
+func sameOrder(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRanks_MoveWithinAndBetweenColumns(t *testing.T) {
+	todoRepo, todos := newMemTodoRepo()
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: &mockMessaging{}, Cache: &mockCache{}, Files: &mockFileChecker{}})
+	ctx := context.Background()
+	create := func(description string) *todo.TodoItem {
+		created, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: description, DueDate: time.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return created
+	}
+	a, b, c := create("a"), create("b"), create("c")
+	if got := column(todos, todo.StatusOpen); !sameOrder(got, "a", "b", "c") {
+		t.Fatalf("expected new todos to go last, got %v", got)
+	}
+
+	after := a.ID.String()
+	if _, err := service.MoveTodo(ctx, c.ID, &todo.MoveTodoRequest{After: &after}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := column(todos, todo.StatusOpen); !sameOrder(got, "a", "c", "b") {
+		t.Errorf("expected c to move after a, got %v", got)
+	}
+	before := a.ID.String()
+	if _, err := service.MoveTodo(ctx, b.ID, &todo.MoveTodoRequest{Before: &before}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := column(todos, todo.StatusOpen); !sameOrder(got, "b", "a", "c") {
+		t.Errorf("expected b to move to the top, got %v", got)
+	}
+
+	inProgress := todo.StatusInProgress
+	moved, err := service.MoveTodo(ctx, a.ID, &todo.MoveTodoRequest{Status: &inProgress})
+	if err != nil || moved.Status != todo.StatusInProgress {
+		t.Fatalf("expected a to move to the in progress column, got %+v (%v)", moved, err)
+	}
+	after = a.ID.String()
+	if _, err := service.MoveTodo(ctx, c.ID, &todo.MoveTodoRequest{After: &after}); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected an anchor from another column to be refused, got %v", err)
+	}
+	if _, err := service.MoveTodo(ctx, c.ID, &todo.MoveTodoRequest{Status: &inProgress, After: &after}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := column(todos, todo.StatusInProgress); !sameOrder(got, "a", "c") {
+		t.Errorf("expected c after a in progress, got %v", got)
+	}
+
+	// Changing the status by update puts the todo last in its new column
+	done := todo.StatusDone
+	service.UpdateTodo(ctx, c.ID, &todo.UpdateTodoRequest{Status: &done})
+	service.UpdateTodo(ctx, a.ID, &todo.UpdateTodoRequest{Status: &done})
+	if got := column(todos, todo.StatusDone); !sameOrder(got, "c", "a") {
+		t.Errorf("expected the done column in completion order, got %v", got)
+	}
+}
+
+func TestRanks_RebalanceKeepsOrder(t *testing.T) {
+	todoRepo, todos := newMemTodoRepo()
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: &mockMessaging{}, Cache: &mockCache{}, Files: &mockFileChecker{}})
+	ctx := context.Background()
+	var created []*todo.TodoItem
+	for _, description := range []string{"first", "second", "third"} {
+		item, _ := service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: description, DueDate: time.Now().Add(time.Hour)})
+		created = append(created, item)
+	}
+
+	// Moving todos into the same gap again and again runs out of room, which
+	// rebalances the column instead of failing
+	first := created[0].ID.String()
+	next, moving := created[1], created[2]
+	for i := 0; i < 200; i++ {
+		before := next.ID.String()
+		if _, err := service.MoveTodo(ctx, moving.ID, &todo.MoveTodoRequest{After: &first, Before: &before}); err != nil {
+			t.Fatalf("move %d: expected no error, got %v", i, err)
+		}
+		next, moving = moving, next
+	}
+	for _, item := range todos {
+		if len(item.Rank) > 48 {
+			t.Errorf("expected ranks to stay short, got %q", item.Rank)
+		}
+	}
+	if got := column(todos, todo.StatusOpen); !sameOrder(got, "first", "second", "third") {
+		t.Errorf("expected the last move to win, got %v", got)
+	}
+
+	// Todos from before ranks existed are ranked last by the periodic job
+	legacy := &todo.TodoItem{ID: uuid.New(), Description: "legacy", Status: todo.StatusOpen, CreatedAt: time.Now().Add(-time.Hour)}
+	todos[legacy.ID] = legacy
+	reranked, err := service.RebalanceRanks(ctx)
+	if err != nil || reranked == 0 || legacy.Rank == "" {
+		t.Fatalf("expected the column to be rebalanced, got %d (%v)", reranked, err)
+	}
+	if got := column(todos, todo.StatusOpen); !sameOrder(got, "first", "second", "third", "legacy") {
+		t.Errorf("expected the order to be kept with legacy todos last, got %v", got)
+	}
+	if reranked, _ := service.RebalanceRanks(ctx); reranked != 0 {
+		t.Errorf("expected a balanced column to be left alone, got %d reranked", reranked)
+	}
+}
+
+func TestRanks_StaleUpdateKeepsRebalancedRank(t *testing.T) {
+	todoRepo, todos := newMemTodoRepo()
+	var service todo.TodoService
+	ctx := context.Background()
+	legacy := &todo.TodoItem{ID: uuid.New(), Description: "legacy", Status: todo.StatusOpen, DueDate: time.Now().Add(time.Hour), CreatedAt: time.Now().Add(-time.Hour)}
+	todos[legacy.ID] = legacy
+
+	// The column is respaced right after the update reads the todo
+	read, rebalanced := todoRepo.GetByIDFn, false
+	todoRepo.GetByIDFn = func(ctx context.Context, id uuid.UUID) (*todo.TodoItem, error) {
+		todoItem, err := read(ctx, id)
+		if id == legacy.ID && !rebalanced {
+			rebalanced = true
+			if _, err := service.RebalanceRanks(ctx); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		return todoItem, err
+	}
+	service = todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: &mockMessaging{}, Cache: &mockCache{}, Files: &mockFileChecker{}})
+
+	description := "renamed"
+	if _, err := service.UpdateTodo(ctx, legacy.ID, &todo.UpdateTodoRequest{Description: &description}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stored := todos[legacy.ID]; stored.Description != "renamed" || stored.Rank == "" {
+		t.Errorf("expected the update to keep the rank given meanwhile, got %+v", stored)
+	}
+}
@@ -0,0 +1,277 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+
+	"github.com/google/uuid"
+)
+
+// mockRecurrenceRepo keeps copies of series in memory, so changes only land
+// through the repository
+type mockRecurrenceRepo struct {
+	recurrences map[uuid.UUID]todo.Recurrence
+}
+
+func (m *mockRecurrenceRepo) Create(ctx context.Context, recurrence *todo.Recurrence) error {
+	m.recurrences[recurrence.ID] = *recurrence
+	return nil
+}
+
+func (m *mockRecurrenceRepo) Get(ctx context.Context, id uuid.UUID) (*todo.Recurrence, error) {
+	recurrence, ok := m.recurrences[id]
+	if !ok {
+		return nil, shared.NewNotFoundError("recurrence not found")
+	}
+	return &recurrence, nil
+}
+
+func (m *mockRecurrenceRepo) ListByIDs(ctx context.Context, ids []uuid.UUID) ([]*todo.Recurrence, error) {
+	var recurrences []*todo.Recurrence
+	for _, id := range ids {
+		if recurrence, ok := m.recurrences[id]; ok {
+			recurrences = append(recurrences, &recurrence)
+		}
+	}
+	return recurrences, nil
+}
+
+func (m *mockRecurrenceRepo) Update(ctx context.Context, recurrence *todo.Recurrence) error {
+	m.recurrences[recurrence.ID] = *recurrence
+	return nil
+}
+
+func (m *mockRecurrenceRepo) Advance(ctx context.Context, recurrence *todo.Recurrence, fromSequence int, fromDue time.Time) (bool, error) {
+	stored := m.recurrences[recurrence.ID]
+	if stored.Sequence != fromSequence || !stored.LastDue.Equal(fromDue) {
+		return false, nil
+	}
+	stored.Sequence, stored.LastDue, stored.Ended = recurrence.Sequence, recurrence.LastDue, recurrence.Ended
+	m.recurrences[recurrence.ID] = stored
+	return true, nil
+}
+
+func (m *mockRecurrenceRepo) ListDue(ctx context.Context, before time.Time, limit int) ([]*todo.Recurrence, error) {
+	var recurrences []*todo.Recurrence
+	for _, recurrence := range m.recurrences {
+		if !recurrence.Ended && !recurrence.LastDue.After(before) && len(recurrences) < limit {
+			recurrences = append(recurrences, &recurrence)
+		}
+	}
+	return recurrences, nil
+}
+
+func newRecurrenceService() (todo.TodoService, map[uuid.UUID]*todo.TodoItem, *mockRecurrenceRepo) {
+	todoRepo, todos := newMemTodoRepo()
+	recurrences := &mockRecurrenceRepo{recurrences: map[uuid.UUID]todo.Recurrence{}}
+	return todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: &mockMessaging{}, Cache: &mockCache{}, Files: &mockFileChecker{}, Recurrences: recurrences}), todos, recurrences
+}
+
+// occurrences returns the todos of a series by occurrence number
+func occurrences(todos map[uuid.UUID]*todo.TodoItem, recurrenceID uuid.UUID) map[int]*todo.TodoItem {
+	found := map[int]*todo.TodoItem{}
+	for _, todoItem := range todos {
+		if todoItem.RecurrenceID != nil && *todoItem.RecurrenceID == recurrenceID {
+			found[todoItem.Occurrence] = todoItem
+		}
+	}
+	return found
+}
+
+func TestRecurrenceRule_Occurrences(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		want  []string
+	}{
+		{
+			name:  "weekly on weekdays",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,WE",
+			start: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC),
+			want:  []string{"2026-03-04 09:00", "2026-03-09 09:00", "2026-03-11 09:00"},
+		},
+		{
+			name:  "last friday of the month",
+			rule:  "RRULE:FREQ=MONTHLY;BYDAY=-1FR",
+			start: time.Date(2026, 1, 30, 17, 0, 0, 0, time.UTC),
+			want:  []string{"2026-02-27 17:00", "2026-03-27 17:00", "2026-04-24 17:00"},
+		},
+		{
+			name:  "monthly on the 31st skips short months",
+			rule:  "FREQ=MONTHLY",
+			start: time.Date(2026, 1, 31, 8, 0, 0, 0, time.UTC),
+			want:  []string{"2026-03-31 08:00", "2026-05-31 08:00"},
+		},
+		{
+			name:  "daily keeps local time across DST",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: time.Date(2026, 3, 7, 9, 0, 0, 0, newYork),
+			want:  []string{"2026-03-08 09:00", "2026-03-09 09:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := todo.ParseRule(tt.rule, tt.start.Location())
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			after := tt.start
+			var got []string
+			for i := 0; i < 10; i++ {
+				next, _, ok := rule.Next(tt.start, after)
+				if !ok || len(got) == len(tt.want) {
+					break
+				}
+				got = append(got, next.Format("2006-01-02 15:04"))
+				after = next
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+
+	// The 9:00 occurrences are 14:00 UTC before the switch and 13:00 after
+	rule, _ := todo.ParseRule("FREQ=DAILY", newYork)
+	start := time.Date(2026, 3, 7, 9, 0, 0, 0, newYork)
+	next, sequence, _ := rule.Next(start, start)
+	if next.UTC().Hour() != 13 || sequence != 2 {
+		t.Errorf("expected the second occurrence at 13:00 UTC, got %s (#%d)", next.UTC(), sequence)
+	}
+
+	for _, invalid := range []string{"", "FREQ=HOURLY", "FREQ=DAILY;COUNT=2;UNTIL=20270101", "FREQ=WEEKLY;BYDAY=2MO", "FREQ=DAILY;INTERVAL=0"} {
+		if _, err := todo.ParseRule(invalid, time.UTC); err == nil {
+			t.Errorf("expected %q to be refused", invalid)
+		}
+	}
+}
+
+func TestRecurringTodos_NextOccurrenceOnCompletionAndWhenPastDue(t *testing.T) {
+	service, todos, recurrences := newRecurrenceService()
+	ctx := context.Background()
+	due := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	first, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{
+		Description: "Water plants",
+		DueDate:     due,
+		Recurrence:  &todo.RecurrenceRequest{Rule: "FREQ=DAILY;COUNT=3", TimeZone: "Europe/Berlin"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if first.Occurrence != 1 || first.Recurrence == nil || first.Recurrence.TimeZone != "Europe/Berlin" {
+		t.Fatalf("expected the first occurrence of a series, got %+v", first)
+	}
+
+	done := todo.StatusDone
+	for occurrence := 1; occurrence <= 3; occurrence++ {
+		current := occurrences(todos, *first.RecurrenceID)[occurrence]
+		if current == nil {
+			t.Fatalf("expected occurrence %d to exist", occurrence)
+		}
+		if current.Description != "Water plants" || !current.DueDate.Equal(due.In(berlin).AddDate(0, 0, occurrence-1)) {
+			t.Errorf("expected occurrence %d a day after the last, got %+v", occurrence, current)
+		}
+		if _, err := service.UpdateTodo(ctx, current.ID, &todo.UpdateTodoRequest{Status: &done}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if len(todos) != 3 || !recurrences.recurrences[*first.RecurrenceID].Ended {
+		t.Errorf("expected the series to end after 3 occurrences, got %d todos", len(todos))
+	}
+
+	// A series whose latest occurrence is long past due skips to the next
+	// upcoming one
+	review, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{
+		Description: "Review",
+		DueDate:     due,
+		Recurrence:  &todo.RecurrenceRequest{Rule: "FREQ=DAILY"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	series := recurrences.recurrences[*review.RecurrenceID]
+	series.Start = due.Add(-72 * time.Hour)
+	series.LastDue = series.Start
+	recurrences.recurrences[series.ID] = series
+
+	created, err := service.MaterializeRecurrences(ctx)
+	if err != nil || created != 1 {
+		t.Fatalf("expected one occurrence to be created, got %d (%v)", created, err)
+	}
+	next := occurrences(todos, series.ID)[4]
+	if next == nil || !next.DueDate.Equal(due) {
+		t.Errorf("expected occurrence 4 as the next upcoming one, got %+v", occurrences(todos, series.ID))
+	}
+	if created, _ := service.MaterializeRecurrences(ctx); created != 0 {
+		t.Errorf("expected nothing more to be due, got %d created", created)
+	}
+}
+
+func TestRecurringTodos_UpdateScopes(t *testing.T) {
+	service, todos, recurrences := newRecurrenceService()
+	ctx := context.Background()
+	due := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	first, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: "Standup", DueDate: due})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	started, err := service.UpdateTodo(ctx, first.ID, &todo.UpdateTodoRequest{Recurrence: &todo.RecurrenceRequest{Rule: "FREQ=WEEKLY"}})
+	if err != nil || started.RecurrenceID == nil || started.Occurrence != 1 {
+		t.Fatalf("expected the todo to start a series, got %+v (%v)", started, err)
+	}
+	if _, err := service.UpdateTodo(ctx, first.ID, &todo.UpdateTodoRequest{Recurrence: &todo.RecurrenceRequest{Rule: "FREQ=DAILY"}}); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected changing the rule without scope future to be refused, got %v", err)
+	}
+
+	renamed := "Standup (moved room)"
+	high := todo.PriorityHigh
+	if _, err := service.UpdateTodo(ctx, first.ID, &todo.UpdateTodoRequest{Description: &renamed}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := service.UpdateTodo(ctx, first.ID, &todo.UpdateTodoRequest{Priority: &high, Scope: todo.ScopeFuture}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	done := todo.StatusDone
+	service.UpdateTodo(ctx, first.ID, &todo.UpdateTodoRequest{Status: &done})
+
+	second := occurrences(todos, *started.RecurrenceID)[2]
+	if second == nil || second.Priority != todo.PriorityHigh || second.Description != renamed || !second.DueDate.Equal(due.AddDate(0, 0, 7)) {
+		t.Fatalf("expected the next week's occurrence with the future changes, got %+v", second)
+	}
+	if recurrences.recurrences[*started.RecurrenceID].Description != renamed {
+		t.Errorf("expected the future update to take the occurrence's description")
+	}
+
+	moved := due.Add(48 * time.Hour)
+	if _, err := service.UpdateTodo(ctx, first.ID, &todo.UpdateTodoRequest{DueDate: &moved, Scope: todo.ScopeFuture}); domainCode(err) != shared.ErrCodeConflict {
+		t.Errorf("expected an earlier occurrence not to reschedule the series, got %v", err)
+	}
+	if _, err := service.UpdateTodo(ctx, second.ID, &todo.UpdateTodoRequest{Recurrence: &todo.RecurrenceRequest{}, Scope: todo.ScopeFuture}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	service.UpdateTodo(ctx, second.ID, &todo.UpdateTodoRequest{Status: &done})
+	if len(occurrences(todos, *started.RecurrenceID)) != 2 {
+		t.Errorf("expected an ended series to stop creating occurrences")
+	}
+}
@@ -0,0 +1,356 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"taskflow/adapter/http/handlers"
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/shared"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Mock Repositories ---
+type mockResumableUploadRepo struct {
+	uploads map[string]file.ResumableUpload
+}
+
+func newMockResumableUploadRepo() *mockResumableUploadRepo {
+	return &mockResumableUploadRepo{uploads: map[string]file.ResumableUpload{}}
+}
+
+// store keeps a copy, so state only changes when the service persists it
+func (m *mockResumableUploadRepo) store(upload *file.ResumableUpload) {
+	stored := *upload
+	stored.Parts = append([]shared.UploadedPart(nil), upload.Parts...)
+	if existing, ok := m.uploads[upload.ID.String()]; ok {
+		stored.LockedUntil = existing.LockedUntil
+	}
+	m.uploads[upload.ID.String()] = stored
+}
+
+func (m *mockResumableUploadRepo) Create(ctx context.Context, upload *file.ResumableUpload) error {
+	m.store(upload)
+	return nil
+}
+func (m *mockResumableUploadRepo) GetByID(ctx context.Context, id string) (*file.ResumableUpload, error) {
+	upload, ok := m.uploads[id]
+	if !ok {
+		return nil, shared.NewNotFoundError("upload not found")
+	}
+	upload.Parts = append([]shared.UploadedPart(nil), upload.Parts...)
+	return &upload, nil
+}
+func (m *mockResumableUploadRepo) Update(ctx context.Context, upload *file.ResumableUpload) error {
+	m.store(upload)
+	return nil
+}
+func (m *mockResumableUploadRepo) Delete(ctx context.Context, id string) error {
+	delete(m.uploads, id)
+	return nil
+}
+func (m *mockResumableUploadRepo) ListExpired(ctx context.Context, before time.Time, limit int) ([]*file.ResumableUpload, error) {
+	var uploads []*file.ResumableUpload
+	for id, upload := range m.uploads {
+		if upload.ExpiresAt.Before(before) && len(uploads) < limit {
+			u, _ := m.GetByID(ctx, id)
+			uploads = append(uploads, u)
+		}
+	}
+	return uploads, nil
+}
+func (m *mockResumableUploadRepo) Lock(ctx context.Context, id string, until time.Time) (bool, error) {
+	upload := m.uploads[id]
+	if upload.LockedUntil != nil && upload.LockedUntil.After(time.Now()) {
+		return false, nil
+	}
+	upload.LockedUntil = &until
+	m.uploads[id] = upload
+	return true, nil
+}
+func (m *mockResumableUploadRepo) ExtendLock(ctx context.Context, id string, until time.Time) error {
+	upload := m.uploads[id]
+	upload.LockedUntil = &until
+	m.uploads[id] = upload
+	return nil
+}
+func (m *mockResumableUploadRepo) Unlock(ctx context.Context, id string) error {
+	upload := m.uploads[id]
+	upload.LockedUntil = nil
+	m.uploads[id] = upload
+	return nil
+}
+
+// mockMultipartStorage assembles multipart uploads in memory
+type mockMultipartStorage struct {
+	*mockStorage
+	partSize int64
+	parts    map[string]map[int][]byte
+}
+
+func newMockMultipartStorage(partSize int64) *mockMultipartStorage {
+	return &mockMultipartStorage{
+		mockStorage: newMockStorage(),
+		partSize:    partSize,
+		parts:       map[string]map[int][]byte{},
+	}
+}
+
+func (m *mockMultipartStorage) MinPartSize() int64 {
+	return m.partSize
+}
+func (m *mockMultipartStorage) CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	uploadID := "mp-" + key
+	m.parts[uploadID] = map[int][]byte{}
+	return uploadID, nil
+}
+func (m *mockMultipartStorage) UploadPart(ctx context.Context, key string, uploadID string, number int, content io.ReadSeeker) (string, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+	m.parts[uploadID][number] = data
+	return "etag", nil
+}
+func (m *mockMultipartStorage) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []shared.UploadedPart) error {
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	var data []byte
+	for _, part := range parts {
+		data = append(data, m.parts[uploadID][part.Number]...)
+	}
+	m.objects[key] = data
+	delete(m.parts, uploadID)
+	return nil
+}
+func (m *mockMultipartStorage) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	delete(m.parts, uploadID)
+	return nil
+}
+func (m *mockMultipartStorage) Put(ctx context.Context, key string, content io.ReadSeeker, contentType string) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	m.objects[key] = data
+	return nil
+}
+
+type resumableFixture struct {
+	t          *testing.T
+	fileRepo   *mockFileRepo
+	uploadRepo *mockResumableUploadRepo
+	blobRepo   *mockBlobRepo
+	storage    *mockMultipartStorage
+	policy     file.UploadPolicy
+}
+
+func newResumableFixture(t *testing.T) *resumableFixture {
+	t.Helper()
+	policy, err := file.NewUploadPolicy(1024, []string{".png", ".pdf"})
+	if err != nil {
+		t.Fatalf("unexpected policy error: %v", err)
+	}
+	return &resumableFixture{
+		t:          t,
+		fileRepo:   newMockFileRepo(),
+		uploadRepo: newMockResumableUploadRepo(),
+		blobRepo:   newMockBlobRepo(),
+		storage:    newMockMultipartStorage(16),
+		policy:     policy,
+	}
+}
+
+// service builds a fresh service over the shared state, like a new pod would
+func (f *resumableFixture) service() file.ResumableUploadService {
+	service, err := file.NewResumableUploadService(
+		file.Deps{Files: f.fileRepo, Blobs: f.blobRepo, Storage: f.storage, Messaging: &mockMessaging{}, Policy: f.policy},
+		f.uploadRepo, file.ResumableUploadConfig{Expiry: time.Hour, LockTTL: time.Minute})
+	if err != nil {
+		f.t.Fatalf("unexpected service error: %v", err)
+	}
+	return service
+}
+
+// --- Tests ---
+func TestResumableUpload_ResumesAcrossInstancesAndCreatesFile(t *testing.T) {
+	fixture := newResumableFixture(t)
+	ctx := context.Background()
+	content := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{7}, 24)...)
+
+	upload, err := fixture.service().Create(ctx, &file.CreateResumableUploadRequest{Filename: "scan.png", Length: int64(len(content))})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	id := upload.ID.String()
+
+	if upload, err = fixture.service().WriteChunk(ctx, id, 0, bytes.NewReader(content[:10])); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if upload, err = fixture.service().WriteChunk(ctx, id, 10, bytes.NewReader(content[10:30])); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if upload.Offset != 30 || len(upload.Parts) != 1 {
+		t.Fatalf("expected offset 30 with one part, got offset %d with %d parts", upload.Offset, len(upload.Parts))
+	}
+
+	// A different instance picks up the persisted offset
+	resumed, err := fixture.service().Get(ctx, id)
+	if err != nil || resumed.Offset != 30 {
+		t.Fatalf("expected persisted offset 30, got %+v (%v)", resumed, err)
+	}
+
+	completed, err := fixture.service().WriteChunk(ctx, id, 30, bytes.NewReader(content[30:]))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !completed.Completed() {
+		t.Fatalf("expected upload to be completed")
+	}
+
+	stored := fixture.fileRepo.files[*completed.FileID]
+	if stored == nil || stored.Size != int64(len(content)) || stored.ContentType != "image/png" {
+		t.Fatalf("unexpected file record: %+v", stored)
+	}
+	if !bytes.Equal(fixture.storage.objects[stored.StorageKey], content) {
+		t.Errorf("assembled object does not match uploaded content")
+	}
+	if _, ok := fixture.storage.objects[stored.StorageKey+".tail"]; ok {
+		t.Errorf("tail object should be removed once the upload completes")
+	}
+}
+
+func TestResumableUpload_ProcessedLikeOtherUploads(t *testing.T) {
+	fixture := newResumableFixture(t)
+	fixture.policy, _ = file.NewUploadPolicy(1<<20, []string{".jpg"})
+	ctx := context.Background()
+	photo := exifJPEG(t, 40, 20, 1)
+
+	upload := func() *file.File {
+		t.Helper()
+		upload, err := fixture.service().Create(ctx, &file.CreateResumableUploadRequest{Filename: "photo.jpg", Length: int64(len(photo))})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if upload, err = fixture.service().WriteChunk(ctx, upload.ID.String(), 0, bytes.NewReader(photo)); err != nil || !upload.Completed() {
+			t.Fatalf("expected upload to complete, got %v", err)
+		}
+		return fixture.fileRepo.files[*upload.FileID]
+	}
+	first, second := upload(), upload()
+
+	stored := fixture.storage.objects[first.StorageKey]
+	if len(stored) != len(photo) || bytes.Contains(stored, gpsLatitude) {
+		t.Errorf("expected GPS location to be stripped from the resumable upload")
+	}
+	sum := sha256.Sum256(stored)
+	if first.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected checksum of the stored content, got %q", first.Checksum)
+	}
+	if second.StorageKey != first.StorageKey || len(fixture.storage.objects) != 1 {
+		t.Errorf("expected identical uploads to share one object, got %d objects", len(fixture.storage.objects))
+	}
+}
+
+func TestResumableUpload_RejectsWrongOffsetAndConcurrentWriters(t *testing.T) {
+	fixture := newResumableFixture(t)
+	ctx := context.Background()
+
+	upload, _ := fixture.service().Create(ctx, &file.CreateResumableUploadRequest{Filename: "scan.png", Length: 100})
+	id := upload.ID.String()
+
+	if _, err := fixture.service().WriteChunk(ctx, id, 5, bytes.NewReader(pngHeader)); domainCode(err) != shared.ErrCodeConflict {
+		t.Fatalf("expected conflict for wrong offset, got %v", err)
+	}
+
+	fixture.uploadRepo.Lock(ctx, id, time.Now().Add(time.Minute))
+	if _, err := fixture.service().WriteChunk(ctx, id, 0, bytes.NewReader(pngHeader)); domainCode(err) != shared.ErrCodeLocked {
+		t.Fatalf("expected locked error while another writer holds the lease, got %v", err)
+	}
+}
+
+func TestResumableUpload_RejectsDataBeyondLength(t *testing.T) {
+	fixture := newResumableFixture(t)
+	ctx := context.Background()
+
+	upload, _ := fixture.service().Create(ctx, &file.CreateResumableUploadRequest{Filename: "scan.png", Length: 10})
+	_, err := fixture.service().WriteChunk(ctx, upload.ID.String(), 0, bytes.NewReader(append(pngHeader, 1, 2, 3)))
+	if domainCode(err) != shared.ErrCodeValidation {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
+
+func TestResumableUpload_CleanupExpiredAbortsUploads(t *testing.T) {
+	fixture := newResumableFixture(t)
+	ctx := context.Background()
+
+	upload, _ := fixture.service().Create(ctx, &file.CreateResumableUploadRequest{Filename: "scan.png", Length: 100})
+	stale := fixture.uploadRepo.uploads[upload.ID.String()]
+	stale.ExpiresAt = time.Now().Add(-time.Minute)
+	fixture.uploadRepo.uploads[upload.ID.String()] = stale
+
+	if _, err := fixture.service().Get(ctx, upload.ID.String()); domainCode(err) != shared.ErrCodeGone {
+		t.Fatalf("expected gone error for expired upload, got %v", err)
+	}
+
+	removed, err := fixture.service().CleanupExpired(ctx)
+	if err != nil || removed != 1 {
+		t.Fatalf("expected 1 removed upload, got %d (%v)", removed, err)
+	}
+	if len(fixture.storage.parts) != 0 {
+		t.Errorf("expected multipart upload to be aborted")
+	}
+}
+
+func TestTusHandler_Protocol(t *testing.T) {
+	fixture := newResumableFixture(t)
+	tus := handlers.NewTusHandler(fixture.service())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	group := r.Group("/files/tus", tus.RequireTusResumable)
+	group.OPTIONS("", tus.Options)
+	group.POST("", tus.Create)
+	group.HEAD("/:id", tus.Head)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/files/tus", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get("Tus-Max-Size") != "1024" {
+		t.Fatalf("unexpected OPTIONS response: %d %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/files/tus", nil))
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 without Tus-Resumable, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/files/tus", nil)
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("Upload-Length", "100")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("scan.pdf")))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	location := w.Header().Get("Location")
+	if w.Code != http.StatusCreated || !strings.HasPrefix(location, "/files/tus/") {
+		t.Fatalf("unexpected create response: %d location %q", w.Code, location)
+	}
+
+	req = httptest.NewRequest(http.MethodHead, location, nil)
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "0" || w.Header().Get("Upload-Length") != "100" {
+		t.Errorf("unexpected HEAD response: %d %v", w.Code, w.Header())
+	}
+}
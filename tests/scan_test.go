@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"taskflow/adapter/scanner"
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+)
+
+// fakeClamd serves the clamd INSTREAM and PING commands, reporting any
+// stream containing "EICAR" as infected
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveClamd(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch command {
+	case "zPING\x00":
+		conn.Write([]byte("PONG\x00"))
+	case "zINSTREAM\x00":
+		var stream bytes.Buffer
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+			if _, err := io.CopyN(&stream, r, int64(size)); err != nil {
+				return
+			}
+		}
+		if bytes.Contains(stream.Bytes(), []byte("EICAR")) {
+			conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		} else {
+			conn.Write([]byte("stream: OK\x00"))
+		}
+	default:
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+	}
+}
+
+func TestClamdScanner_Verdicts(t *testing.T) {
+	clamd := scanner.NewClamdScanner(fakeClamd(t), 5*time.Second)
+	ctx := context.Background()
+
+	if err := scanner.PingCheck(clamd)(ctx); err != nil {
+		t.Fatalf("expected ping to succeed, got %v", err)
+	}
+
+	// Larger than one chunk, so the stream is split
+	clean := strings.Repeat("clean content ", 10_000)
+	result, err := clamd.Scan(ctx, strings.NewReader(clean))
+	if err != nil || result.Infected {
+		t.Fatalf("expected clean verdict, got %+v (%v)", result, err)
+	}
+
+	result, err = clamd.Scan(ctx, strings.NewReader(clean+"EICAR"))
+	if err != nil || !result.Infected || result.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("expected infected verdict, got %+v (%v)", result, err)
+	}
+}
+
+func TestScan_QuarantinesUntilScanned(t *testing.T) {
+	service, repo, storage := newTestFileService(t, 1024)
+	ctx := context.Background()
+
+	published := make(chan string, 2)
+	scans := file.NewScanService(repo, storage, scanner.NewClamdScanner(fakeClamd(t), 5*time.Second), &mockMessaging{
+		PublishFn: func(ctx context.Context, topic string, message interface{}) error {
+			published <- topic
+			return nil
+		},
+	})
+
+	clean, _ := service.UploadFile(ctx, &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader("meeting notes"))
+	infected, _ := service.UploadFile(ctx, &file.CreateFileRequest{Filename: "virus.txt"}, strings.NewReader("X5O EICAR test"))
+	if clean.ScanStatus != file.ScanQuarantined {
+		t.Fatalf("expected new uploads to be quarantined, got %q", clean.ScanStatus)
+	}
+	if _, err := service.DownloadFile(ctx, clean.FileID); domainCode(err) != shared.ErrCodeConflict {
+		t.Fatalf("expected quarantined download to be refused, got %v", err)
+	}
+
+	for _, id := range []string{clean.FileID, infected.FileID} {
+		if err := scans.Scan(ctx, id); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if topic := <-published; topic != file.TopicFileScanned {
+			t.Errorf("expected %s event, got %s", file.TopicFileScanned, topic)
+		}
+	}
+
+	if _, err := service.DownloadFile(ctx, clean.FileID); err != nil {
+		t.Errorf("expected clean file to download, got %v", err)
+	}
+	if err := service.CheckLinkable(ctx, clean.FileID); err != nil {
+		t.Errorf("expected clean file to be linkable, got %v", err)
+	}
+	if _, err := service.DownloadFile(ctx, infected.FileID); domainCode(err) != shared.ErrCodeForbidden {
+		t.Errorf("expected infected download to be forbidden, got %v", err)
+	}
+	if f := repo.files[infected.FileID]; f.ScanStatus != file.ScanInfected || f.ScanSignature != "Eicar-Test-Signature" {
+		t.Errorf("expected infected verdict to be recorded, got %+v", f)
+	}
+}
+
+func TestScan_RescansStaleQuarantinedFiles(t *testing.T) {
+	service, repo, storage := newTestFileService(t, 1024)
+	ctx := context.Background()
+	scans := file.NewScanService(repo, storage, scanner.NewNoopScanner(), &mockMessaging{})
+
+	resp, _ := service.UploadFile(ctx, &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader("meeting notes"))
+	repo.files[resp.FileID].CreatedAt = time.Now().Add(-time.Hour)
+
+	scanned, err := scans.RescanQuarantined(ctx, 10*time.Minute)
+	if err != nil || scanned != 1 {
+		t.Fatalf("expected 1 file rescanned, got %d (%v)", scanned, err)
+	}
+	if repo.files[resp.FileID].ScanStatus != file.ScanClean {
+		t.Errorf("expected file to be released from quarantine")
+	}
+}
+
+func TestCreateTodo_RejectsUnscannedFile(t *testing.T) {
+	service := todo.NewTodoService(todo.Deps{Todos: &mockTodoRepo{}, Attachments: newMockAttachmentRepo(), Messaging: &mockMessaging{}, Cache: &mockCache{}, Files: &mockFileChecker{
+		CheckFn: func(ctx context.Context, fileID string) error {
+			return shared.NewDomainError(shared.ErrCodeConflict, "File is waiting for a malware scan", "")
+		},
+	}})
+
+	fileID := "7b1e3c1e-4a43-4d0b-9d44-2b0a2f5e8d10"
+	_, err := service.CreateTodo(context.Background(), &todo.CreateTodoRequest{
+		Description: "Review attachment",
+		DueDate:     time.Now().Add(time.Hour),
+		FileID:      &fileID,
+	})
+	if domainCode(err) != shared.ErrCodeConflict {
+		t.Fatalf("expected quarantined file to be refused, got %v", err)
+	}
+}
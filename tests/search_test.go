@@ -0,0 +1,265 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"taskflow/adapter/extract"
+	searchindex "taskflow/adapter/search"
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/search"
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+
+	"github.com/google/uuid"
+)
+
+type mockDocumentRepo struct {
+	docs map[string]*search.Document
+}
+
+func (m *mockDocumentRepo) Save(ctx context.Context, doc *search.Document) error {
+	m.docs[doc.ID] = doc
+	return nil
+}
+
+func (m *mockDocumentRepo) Get(ctx context.Context, id string) (*search.Document, error) {
+	if doc, ok := m.docs[id]; ok {
+		return doc, nil
+	}
+	return nil, shared.NewNotFoundError("search document not found")
+}
+
+func (m *mockDocumentRepo) Delete(ctx context.Context, id string) error {
+	delete(m.docs, id)
+	return nil
+}
+
+func (m *mockDocumentRepo) ListAfter(ctx context.Context, afterID string, limit int) ([]*search.Document, error) {
+	var docs []*search.Document
+	for id, doc := range m.docs {
+		if id > afterID {
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+	return docs[:min(limit, len(docs))], nil
+}
+
+// mockFiles serves file content as plain text and counts downloads
+type mockFiles struct {
+	content   map[string]string
+	downloads int
+}
+
+func (m *mockFiles) DownloadFile(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	m.downloads++
+	content, ok := m.content[fileID]
+	if !ok {
+		return nil, shared.NewNotFoundError("file not found")
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+type searchFixture struct {
+	service     search.Service
+	docs        *mockDocumentRepo
+	files       *mockFiles
+	todos       map[uuid.UUID]*todo.TodoItem
+	attachments *mockAttachmentRepo
+}
+
+func newSearchFixture(t *testing.T, config search.Config) *searchFixture {
+	t.Helper()
+	todoRepo, todos := newMemTodoRepo()
+	f := &searchFixture{
+		docs:        &mockDocumentRepo{docs: map[string]*search.Document{}},
+		files:       &mockFiles{content: map[string]string{}},
+		todos:       todos,
+		attachments: newMockAttachmentRepo(),
+	}
+	f.service = search.NewService(f.docs, searchindex.NewMemoryIndex(), extract.NewExtractor(), f.files,
+		todo.NewSearchTodos(todoRepo, f.attachments), config)
+	return f
+}
+
+// addTodo stores a todo and delivers its todo.created event
+func (f *searchFixture) addTodo(t *testing.T, description string, fileIDs ...string) *todo.TodoItem {
+	t.Helper()
+	item := &todo.TodoItem{ID: uuid.New(), Description: description}
+	f.todos[item.ID] = item
+	for _, fileID := range fileIDs {
+		f.attachments.Add(context.Background(), &todo.Attachment{TodoID: item.ID, FileID: fileID})
+	}
+	f.deliver(t, f.service.HandleTodoChanged, item)
+	return item
+}
+
+// scanned delivers the file.scanned event of a text file
+func (f *searchFixture) scanned(t *testing.T, fileID, content, scanStatus string) {
+	t.Helper()
+	f.files.content[fileID] = content
+	f.deliver(t, f.service.HandleFileScanned, &file.File{
+		ID:          uuid.MustParse(fileID),
+		ContentType: "text/plain; charset=utf-8",
+		Size:        int64(len(content)),
+		ScanStatus:  scanStatus,
+	})
+}
+
+func (f *searchFixture) deliver(t *testing.T, handler func(context.Context, []byte) error, event interface{}) {
+	t.Helper()
+	data, _ := json.Marshal(event)
+	if err := handler(context.Background(), data); err != nil {
+		t.Fatalf("expected event to be handled, got %v", err)
+	}
+}
+
+func TestSearch_RanksDescriptionsAndAttachmentsWithHighlights(t *testing.T) {
+	f := newSearchFixture(t, search.Config{})
+	ctx := context.Background()
+	contract := uuid.NewString()
+
+	f.scanned(t, contract, "Tenancy agreement.\nThe lease of the office runs until <June>.", file.ScanClean)
+	renew := f.addTodo(t, "Renew the office lease")
+	milk := f.addTodo(t, "Buy milk", contract)
+
+	results, err := f.service.Search(ctx, "Lease", 10)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(results) != 2 || results[0].TodoID != renew.ID.String() || results[1].TodoID != milk.ID.String() {
+		t.Fatalf("expected the description match to rank above the attachment match, got %+v", results)
+	}
+	if h := results[0].Highlights; len(h) != 1 || h[0].Field != search.FieldDescription || h[0].Snippet != "Renew the office <mark>lease</mark>" {
+		t.Errorf("unexpected description highlight: %+v", h)
+	}
+	h := results[1].Highlights
+	if len(h) != 1 || h[0].Field != search.FieldAttachment || h[0].FileID != contract ||
+		h[0].Snippet != "Tenancy agreement. The <mark>lease</mark> of the office runs until &lt;June&gt;." {
+		t.Errorf("unexpected attachment highlight: %+v", h)
+	}
+	if results[1].Description != "Buy milk" {
+		t.Errorf("expected attachment matches to show the todo's description, got %q", results[1].Description)
+	}
+
+	// Todos gone without an event drop out, deleted todos leave the index
+	delete(f.todos, renew.ID)
+	f.deliver(t, f.service.HandleTodoDeleted, milk)
+	results, _ = f.service.Search(ctx, "office", 10)
+	if len(results) != 1 || results[0].TodoID != milk.ID.String() {
+		t.Fatalf("expected only the todo with the attachment, got %+v", results)
+	}
+	if len(f.docs.docs) != 1 {
+		t.Errorf("expected only the attachment's document to remain, got %d", len(f.docs.docs))
+	}
+
+	if _, err := f.service.Search(ctx, "the of", 10); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected a query of stop words to be refused, got %v", err)
+	}
+}
+
+func TestSearch_IndexesCleanFilesOnlyAndRebuilds(t *testing.T) {
+	f := newSearchFixture(t, search.Config{MaxFileSize: 100, MaxTextSize: 20})
+	ctx := context.Background()
+	infected, large, notes := uuid.NewString(), uuid.NewString(), uuid.NewString()
+
+	f.scanned(t, infected, "quarterly budget", file.ScanInfected)
+	f.scanned(t, large, strings.Repeat("budget ", 20), file.ScanClean)
+	if f.files.downloads != 0 {
+		t.Errorf("expected infected and oversized files not to be read, got %d downloads", f.files.downloads)
+	}
+	f.scanned(t, notes, "quarterly budget review notes", file.ScanClean)
+	if doc := f.docs.docs[search.DocumentID(search.KindFile, notes)]; doc == nil || doc.Text != "quarterly budget rev" {
+		t.Fatalf("expected the text to be truncated to 20 bytes, got %+v", doc)
+	}
+	item := f.addTodo(t, "Prepare the meeting", infected, large, notes)
+
+	results, _ := f.service.Search(ctx, "budget", 10)
+	if len(results) != 1 || len(results[0].Highlights) != 1 || results[0].Highlights[0].FileID != notes {
+		t.Fatalf("expected the todo to match through its clean file only, got %+v", results)
+	}
+
+	// A fresh index is rebuilt from the stored documents
+	rebuilt := search.NewService(f.docs, searchindex.NewMemoryIndex(), extract.NewExtractor(), f.files,
+		todo.NewSearchTodos(newMemTodoRepoWith(item), f.attachments), search.Config{})
+	if n, err := rebuilt.Rebuild(ctx); err != nil || n != 2 {
+		t.Fatalf("expected 2 documents to be loaded, got %d (%v)", n, err)
+	}
+	if results, _ := rebuilt.Search(ctx, "meeting budget", 10); len(results) != 1 || len(results[0].Highlights) != 2 {
+		t.Fatalf("expected the rebuilt index to match description and file, got %+v", results)
+	}
+
+	// Deleted files leave the index
+	f.deliver(t, f.service.HandleFileDeleted, &file.File{ID: uuid.MustParse(notes)})
+	if results, _ := f.service.Search(ctx, "budget", 10); len(results) != 0 {
+		t.Errorf("expected no match once the file is deleted, got %+v", results)
+	}
+}
+
+// newMemTodoRepoWith returns a todo repository holding items
+func newMemTodoRepoWith(items ...*todo.TodoItem) todo.Repository {
+	repo, todos := newMemTodoRepo()
+	for _, item := range items {
+		todos[item.ID] = item
+	}
+	return repo
+}
+
+func TestExtract_PDFAndDOCX(t *testing.T) {
+	ctx := context.Background()
+	extractor := extract.NewExtractor()
+
+	// One Flate content stream and one uncompressed, with kerning, escapes
+	// and a UTF-16 hex string
+	var stream bytes.Buffer
+	zw := zlib.NewWriter(&stream)
+	zw.Write([]byte("BT /F1 12 Tf 72 712 Td (Quarterly \\(Q3\\) report) Tj 0 -14 Td [(Tot) -40 (al) -300 (sales)] TJ ET"))
+	zw.Close()
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	fmt.Fprintf(&pdf, "4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", stream.Len())
+	pdf.Write(stream.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n5 0 obj\n<< /Length 40 >>\nstream\nBT <FEFF00C9007400E9> Tj ET\nendstream\nendobj\n%%EOF\n")
+
+	text, err := extractor.Extract(ctx, "application/pdf", &pdf)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if text != "Quarterly (Q3) report\nTotal sales\nÉté" {
+		t.Errorf("unexpected PDF text: %q", text)
+	}
+
+	var docx bytes.Buffer
+	archive := zip.NewWriter(&docx)
+	body, _ := archive.Create("word/document.xml")
+	body.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>` +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:r><w:t>Meeting</w:t></w:r><w:r><w:t xml:space="preserve"> minutes</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Budget &amp; plan</w:t></w:r></w:p></w:body></w:document>`))
+	archive.Close()
+
+	text, err = extractor.Extract(ctx, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", &docx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if text != "Meeting minutes\nBudget & plan" {
+		t.Errorf("unexpected docx text: %q", text)
+	}
+
+	if _, err := extractor.Extract(ctx, "image/png", strings.NewReader("")); !errors.Is(err, search.ErrUnsupported) {
+		t.Errorf("expected images to be unsupported, got %v", err)
+	}
+	if _, err := extractor.Extract(ctx, "application/pdf", strings.NewReader("not a pdf")); err == nil {
+		t.Error("expected content that isn't a PDF to fail")
+	}
+}
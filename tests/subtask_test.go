@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+
+	"github.com/google/uuid"
+)
+
+// mockChecklistRepo keeps checklist items in memory
+type mockChecklistRepo struct {
+	items map[uuid.UUID]*todo.ChecklistItem
+}
+
+func newMockChecklistRepo() *mockChecklistRepo {
+	return &mockChecklistRepo{items: map[uuid.UUID]*todo.ChecklistItem{}}
+}
+
+func (m *mockChecklistRepo) Create(ctx context.Context, item *todo.ChecklistItem) error {
+	m.items[item.ID] = item
+	return nil
+}
+
+func (m *mockChecklistRepo) Get(ctx context.Context, id uuid.UUID) (*todo.ChecklistItem, error) {
+	if item, ok := m.items[id]; ok {
+		return item, nil
+	}
+	return nil, shared.NewNotFoundError("checklist item not found")
+}
+
+func (m *mockChecklistRepo) Update(ctx context.Context, item *todo.ChecklistItem) error {
+	m.items[item.ID] = item
+	return nil
+}
+
+func (m *mockChecklistRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	delete(m.items, id)
+	return nil
+}
+
+func (m *mockChecklistRepo) ListByTodos(ctx context.Context, todoIDs []uuid.UUID) ([]*todo.ChecklistItem, error) {
+	var items []*todo.ChecklistItem
+	for _, item := range m.items {
+		for _, id := range todoIDs {
+			if item.TodoID == id {
+				items = append(items, item)
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Position < items[j].Position })
+	return items, nil
+}
+
+func (m *mockChecklistRepo) DeleteByTodo(ctx context.Context, todoID uuid.UUID) error {
+	for id, item := range m.items {
+		if item.TodoID == todoID {
+			delete(m.items, id)
+		}
+	}
+	return nil
+}
+
+func newSubtaskService() (todo.TodoService, *mockChecklistRepo) {
+	todoRepo, _ := newMemTodoRepo()
+	checklists := newMockChecklistRepo()
+	return todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: &mockMessaging{}, Cache: &mockCache{}, Files: &mockFileChecker{}, Checklists: checklists}), checklists
+}
+
+// createSubtask creates a todo under parent, or a top-level one for nil
+func createSubtask(t *testing.T, service todo.TodoService, parent *todo.TodoItem, description string) *todo.TodoItem {
+	t.Helper()
+	req := &todo.CreateTodoRequest{Description: description, DueDate: time.Now().Add(time.Hour)}
+	if parent != nil {
+		parentID := parent.ID.String()
+		req.ParentID = &parentID
+	}
+	created, err := service.CreateTodo(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return created
+}
+
+func TestSubtasks_ProgressRollsUpChecklistsAndSubtasks(t *testing.T) {
+	service, _ := newSubtaskService()
+	ctx := context.Background()
+	root := createSubtask(t, service, nil, "Launch")
+	design := createSubtask(t, service, root, "Design")
+	build := createSubtask(t, service, root, "Build")
+
+	first, err := service.AddChecklistItem(ctx, design.ID, &todo.ChecklistItemRequest{Text: " Sketch "})
+	if err != nil || first.Text != "Sketch" {
+		t.Fatalf("expected the item to be added, got %+v (%v)", first, err)
+	}
+	service.AddChecklistItem(ctx, design.ID, &todo.ChecklistItemRequest{Text: "Review"})
+	done := true
+	if _, err := service.UpdateChecklistItem(ctx, design.ID, first.ID, &todo.UpdateChecklistItemRequest{Done: &done}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := service.UpdateChecklistItem(ctx, build.ID, first.ID, &todo.UpdateChecklistItemRequest{Done: &done}); domainCode(err) != shared.ErrCodeNotFound {
+		t.Errorf("expected another todo's item not to be found, got %v", err)
+	}
+
+	// Design is half done and build not started, so the launch is a quarter done
+	got, _ := service.GetTodo(ctx, root.ID)
+	if got.Progress.Percent != 25 || got.Progress.Subtasks != 2 || got.Progress.SubtasksDone != 0 {
+		t.Errorf("expected 25%% with 2 open subtasks, got %+v", got.Progress)
+	}
+	subtasks, _ := service.ListSubtasks(ctx, root.ID)
+	if len(subtasks) != 2 || len(subtasks[0].Checklist) != 2 || subtasks[0].Progress.Percent != 50 || subtasks[0].Progress.ChecklistDone != 1 {
+		t.Fatalf("expected the subtasks with design's checklist, got %+v", subtasks)
+	}
+
+	status := todo.StatusDone
+	if _, err := service.UpdateTodo(ctx, build.ID, &todo.UpdateTodoRequest{Status: &status}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got, _ = service.GetTodo(ctx, root.ID)
+	if got.Progress.Percent != 75 || got.Progress.SubtasksDone != 1 {
+		t.Errorf("expected 75%% with 1 subtask done, got %+v", got.Progress)
+	}
+}
+
+func TestSubtasks_RejectCyclesAndDeepTrees(t *testing.T) {
+	service, _ := newSubtaskService()
+	ctx := context.Background()
+
+	chain := []*todo.TodoItem{createSubtask(t, service, nil, "Level 1")}
+	for i := 2; i <= 5; i++ {
+		chain = append(chain, createSubtask(t, service, chain[len(chain)-1], "Level"))
+	}
+	parentID := chain[4].ID.String()
+	if _, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: "Level 6", DueDate: time.Now().Add(time.Hour), ParentID: &parentID}); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected a sixth level to be refused, got %v", err)
+	}
+
+	parentID = chain[3].ID.String()
+	if _, err := service.UpdateTodo(ctx, chain[1].ID, &todo.UpdateTodoRequest{ParentID: &parentID}); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected moving a todo under its own subtask to be refused, got %v", err)
+	}
+
+	// Moving level 2 under a new top-level todo would make its tree six deep
+	other := createSubtask(t, service, nil, "Other")
+	otherChild := createSubtask(t, service, other, "Other child")
+	parentID = otherChild.ID.String()
+	if _, err := service.UpdateTodo(ctx, chain[1].ID, &todo.UpdateTodoRequest{ParentID: &parentID}); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected a move making the tree too deep to be refused, got %v", err)
+	}
+	parentID = other.ID.String()
+	moved, err := service.UpdateTodo(ctx, chain[1].ID, &todo.UpdateTodoRequest{ParentID: &parentID})
+	if err != nil || *moved.ParentID != other.ID {
+		t.Fatalf("expected the move to succeed, got %v", err)
+	}
+	topLevel := ""
+	if moved, err = service.UpdateTodo(ctx, chain[1].ID, &todo.UpdateTodoRequest{ParentID: &topLevel}); err != nil || moved.ParentID != nil {
+		t.Errorf("expected the todo to become top-level, got %v", err)
+	}
+}
+
+func TestSubtasks_StatusFollowsTheTree(t *testing.T) {
+	service, checklists := newSubtaskService()
+	ctx := context.Background()
+	parent := createSubtask(t, service, nil, "Parent")
+	child := createSubtask(t, service, parent, "Child")
+	service.AddChecklistItem(ctx, parent.ID, &todo.ChecklistItemRequest{Text: "Step"})
+
+	done, open := todo.StatusDone, todo.StatusOpen
+	if _, err := service.UpdateTodo(ctx, parent.ID, &todo.UpdateTodoRequest{Status: &done}); domainCode(err) != shared.ErrCodeConflict {
+		t.Fatalf("expected completing a todo with open subtasks to conflict, got %v", err)
+	}
+	service.UpdateTodo(ctx, child.ID, &todo.UpdateTodoRequest{Status: &done})
+	completed, err := service.UpdateTodo(ctx, parent.ID, &todo.UpdateTodoRequest{Status: &done})
+	if err != nil || completed.CompletedAt == nil || completed.Progress.Percent != 100 {
+		t.Fatalf("expected the parent to be completed, got %+v (%v)", completed, err)
+	}
+
+	if _, err := service.UpdateTodo(ctx, child.ID, &todo.UpdateTodoRequest{Status: &open}); domainCode(err) != shared.ErrCodeConflict {
+		t.Errorf("expected reopening a subtask of a done todo to conflict, got %v", err)
+	}
+	parentID := parent.ID.String()
+	if _, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: "Late", DueDate: time.Now().Add(time.Hour), ParentID: &parentID}); domainCode(err) != shared.ErrCodeConflict {
+		t.Errorf("expected adding a subtask to a done todo to conflict, got %v", err)
+	}
+	reopened, err := service.UpdateTodo(ctx, parent.ID, &todo.UpdateTodoRequest{Status: &open})
+	if err != nil || reopened.CompletedAt != nil {
+		t.Fatalf("expected the parent to be reopened, got %+v (%v)", reopened, err)
+	}
+
+	if err := service.DeleteTodo(ctx, parent.ID); domainCode(err) != shared.ErrCodeConflict {
+		t.Errorf("expected deleting a todo with subtasks to conflict, got %v", err)
+	}
+	service.DeleteTodo(ctx, child.ID)
+	if err := service.DeleteTodo(ctx, parent.ID); err != nil || len(checklists.items) != 0 {
+		t.Errorf("expected the todo and its checklist to be deleted, got %v with %d items", err, len(checklists.items))
+	}
+}
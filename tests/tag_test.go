@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"taskflow/internal/domain/shared"
+	"taskflow/internal/domain/todo"
+	"taskflow/pkg/identity"
+
+	"github.com/google/uuid"
+)
+
+// mockTagRepo keeps tag catalogues and todo tags in memory
+type mockTagRepo struct {
+	tags  map[uuid.UUID]*todo.Tag
+	links map[uuid.UUID]map[uuid.UUID]bool
+}
+
+func newMockTagRepo() *mockTagRepo {
+	return &mockTagRepo{tags: map[uuid.UUID]*todo.Tag{}, links: map[uuid.UUID]map[uuid.UUID]bool{}}
+}
+
+func (m *mockTagRepo) count(tag *todo.Tag) *todo.Tag {
+	counted := *tag
+	for _, tags := range m.links {
+		if tags[tag.ID] {
+			counted.TodoCount++
+		}
+	}
+	return &counted
+}
+
+func (m *mockTagRepo) Ensure(ctx context.Context, workspaceID string, names []string) ([]*todo.Tag, error) {
+	existing, _ := m.GetByNames(ctx, workspaceID, names)
+	found := map[string]bool{}
+	for _, tag := range existing {
+		found[tag.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			tag := &todo.Tag{ID: uuid.New(), WorkspaceID: workspaceID, Name: name}
+			m.tags[tag.ID] = tag
+		}
+	}
+	return m.GetByNames(ctx, workspaceID, names)
+}
+
+func (m *mockTagRepo) Get(ctx context.Context, id uuid.UUID) (*todo.Tag, error) {
+	if tag, ok := m.tags[id]; ok {
+		return m.count(tag), nil
+	}
+	return nil, shared.NewNotFoundError("tag not found")
+}
+
+func (m *mockTagRepo) GetByNames(ctx context.Context, workspaceID string, names []string) ([]*todo.Tag, error) {
+	var tags []*todo.Tag
+	for _, tag := range m.tags {
+		for _, name := range names {
+			if tag.WorkspaceID == workspaceID && tag.Name == name {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (m *mockTagRepo) List(ctx context.Context, workspaceID string) ([]*todo.Tag, error) {
+	var tags []*todo.Tag
+	for _, tag := range m.tags {
+		if tag.WorkspaceID == workspaceID {
+			tags = append(tags, m.count(tag))
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (m *mockTagRepo) Update(ctx context.Context, tag *todo.Tag) error {
+	m.tags[tag.ID].Name = tag.Name
+	return nil
+}
+
+func (m *mockTagRepo) Merge(ctx context.Context, fromID, intoID uuid.UUID) error {
+	for _, tags := range m.links {
+		if tags[fromID] {
+			tags[intoID] = true
+		}
+	}
+	return m.Delete(ctx, fromID)
+}
+
+func (m *mockTagRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	for _, tags := range m.links {
+		delete(tags, id)
+	}
+	delete(m.tags, id)
+	return nil
+}
+
+func (m *mockTagRepo) SetTodoTags(ctx context.Context, todoID uuid.UUID, tagIDs []uuid.UUID) error {
+	m.links[todoID] = map[uuid.UUID]bool{}
+	for _, id := range tagIDs {
+		m.links[todoID][id] = true
+	}
+	return nil
+}
+
+func (m *mockTagRepo) ListByTodos(ctx context.Context, todoIDs []uuid.UUID) ([]*todo.TodoTag, error) {
+	var tags []*todo.TodoTag
+	for _, todoID := range todoIDs {
+		for tagID := range m.links[todoID] {
+			tags = append(tags, &todo.TodoTag{TodoID: todoID, TagID: tagID, Name: m.tags[tagID].Name})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func TestTags_TodosGetNormalizedTagsAndPriority(t *testing.T) {
+	todoRepo, _ := newMemTodoRepo()
+	var listed todo.ListFilter
+	list := todoRepo.ListFn
+	todoRepo.ListFn = func(ctx context.Context, filter todo.ListFilter, limit, offset int) ([]*todo.TodoItem, error) {
+		listed = filter
+		return list(ctx, filter, limit, offset)
+	}
+	tags := newMockTagRepo()
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: &mockMessaging{}, Cache: &mockCache{}, Files: &mockFileChecker{}, Tags: tags})
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice", WorkspaceID: "acme"})
+
+	created, err := service.CreateTodo(ctx, &todo.CreateTodoRequest{
+		Description: "Send the invoice",
+		DueDate:     time.Now().Add(time.Hour),
+		Priority:    todo.PriorityHigh,
+		Tags:        []string{" Work ", "work", "Finance   Q3"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if created.Priority != todo.PriorityHigh || len(created.Tags) != 2 || created.Tags[0] != "finance q3" || created.Tags[1] != "work" {
+		t.Fatalf("expected high priority and two normalized tags, got %s %v", created.Priority, created.Tags)
+	}
+
+	plain, _ := service.CreateTodo(ctx, &todo.CreateTodoRequest{Description: "Water plants", DueDate: time.Now().Add(time.Hour)})
+	if plain.Priority != todo.PriorityMedium || len(plain.Tags) != 0 {
+		t.Errorf("expected medium priority and no tags by default, got %s %v", plain.Priority, plain.Tags)
+	}
+
+	urgent := todo.PriorityUrgent
+	updated, err := service.UpdateTodo(ctx, created.ID, &todo.UpdateTodoRequest{Priority: &urgent, Tags: &[]string{"Work", "billing"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if updated.Priority != todo.PriorityUrgent || len(updated.Tags) != 2 || updated.Tags[0] != "billing" || updated.Tags[1] != "work" {
+		t.Errorf("expected the tags to be replaced, got %s %v", updated.Priority, updated.Tags)
+	}
+
+	catalogue, _ := todo.NewTagService(tags).ListTags(ctx)
+	counts := map[string]int64{}
+	for _, tag := range catalogue {
+		counts[tag.Name] = tag.TodoCount
+	}
+	if len(counts) != 3 || counts["work"] != 1 || counts["billing"] != 1 || counts["finance q3"] != 0 {
+		t.Errorf("unexpected catalogue: %v", counts)
+	}
+
+	todos, err := service.ListTodos(ctx, todo.ListFilter{Tags: []string{"WORK", "work"}, Priorities: []todo.Priority{todo.PriorityUrgent}}, 10, 0)
+	if err != nil || len(todos) != 2 || len(todos[0].Tags)+len(todos[1].Tags) != 2 {
+		t.Fatalf("expected the listed todos with their tags, got %v (%v)", todos, err)
+	}
+	if len(listed.Tags) != 1 || listed.Tags[0] != "work" || listed.WorkspaceID != "acme" {
+		t.Errorf("expected the filter to be normalized and scoped to the workspace, got %+v", listed)
+	}
+	if _, err := service.ListTodos(ctx, todo.ListFilter{Priorities: []todo.Priority{"critical"}}, 10, 0); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected an unknown priority to be refused, got %v", err)
+	}
+
+	many := make([]string, 21)
+	for i := range many {
+		many[i] = uuid.NewString()
+	}
+	if _, err := service.UpdateTodo(ctx, created.ID, &todo.UpdateTodoRequest{Tags: &many}); domainCode(err) != shared.ErrCodeValidation {
+		t.Errorf("expected too many tags to be refused, got %v", err)
+	}
+}
+
+func TestTags_RenameMergeAndDeleteCascadeToTodos(t *testing.T) {
+	todoRepo, _ := newMemTodoRepo()
+	tags := newMockTagRepo()
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: &mockMessaging{}, Cache: &mockCache{}, Files: &mockFileChecker{}, Tags: tags})
+	tagService := todo.NewTagService(tags)
+	acme := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice", WorkspaceID: "acme"})
+	other := identity.WithIdentity(context.Background(), identity.Identity{UserID: "bob", WorkspaceID: "other"})
+
+	first, _ := service.CreateTodo(acme, &todo.CreateTodoRequest{Description: "One", DueDate: time.Now().Add(time.Hour), Tags: []string{"bug", "defect"}})
+	second, _ := service.CreateTodo(acme, &todo.CreateTodoRequest{Description: "Two", DueDate: time.Now().Add(time.Hour), Tags: []string{"defect"}})
+	byName := map[string]*todo.Tag{}
+	catalogue, _ := tagService.ListTags(acme)
+	for _, tag := range catalogue {
+		byName[tag.Name] = tag
+	}
+
+	if _, err := tagService.RenameTag(acme, byName["defect"].ID, &todo.TagRequest{Name: "Bug"}); domainCode(err) != shared.ErrCodeConflict {
+		t.Fatalf("expected renaming onto an existing tag to conflict, got %v", err)
+	}
+	if _, err := tagService.RenameTag(other, byName["bug"].ID, &todo.TagRequest{Name: "issue"}); domainCode(err) != shared.ErrCodeNotFound {
+		t.Fatalf("expected another workspace's tag not to be found, got %v", err)
+	}
+
+	merged, err := tagService.MergeTag(acme, byName["defect"].ID, &todo.MergeTagRequest{Into: byName["bug"].ID.String()})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if merged.TodoCount != 2 {
+		t.Errorf("expected both todos to have the merged tag, got %d", merged.TodoCount)
+	}
+
+	renamed, err := tagService.RenameTag(acme, byName["bug"].ID, &todo.TagRequest{Name: "Issue"})
+	if err != nil || renamed.Name != "issue" {
+		t.Fatalf("expected the tag to be renamed, got %+v (%v)", renamed, err)
+	}
+	for _, id := range []uuid.UUID{first.ID, second.ID} {
+		if got, _ := service.GetTodo(acme, id); len(got.Tags) != 1 || got.Tags[0] != "issue" {
+			t.Errorf("expected todo %s to have only the renamed tag, got %v", id, got.Tags)
+		}
+	}
+
+	if err := tagService.DeleteTag(acme, renamed.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got, _ := service.GetTodo(acme, first.ID); len(got.Tags) != 0 {
+		t.Errorf("expected the deleted tag to be removed from todos, got %v", got.Tags)
+	}
+}
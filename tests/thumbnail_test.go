@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"testing"
+
+	"taskflow/internal/domain/file"
+
+	"github.com/google/uuid"
+)
+
+// gpsLatitude is the GPSLatitude value written into test photos
+var gpsLatitude = []byte{52, 0, 0, 0, 1, 0, 0, 0, 31, 0, 0, 0, 1, 0, 0, 0, 12, 0, 0, 0, 1, 0, 0, 0}
+
+// exifJPEG encodes a width x height JPEG with an EXIF segment holding the
+// given orientation and a GPS latitude
+func exifJPEG(t *testing.T, width, height int, orientation uint16) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, nil); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+
+	// Little-endian TIFF: IFD0 at 8 with orientation and GPS pointer, GPS IFD
+	// at 38 with one RATIONAL[3] latitude stored at 56
+	le := binary.LittleEndian
+	tiff := []byte("II\x2a\x00\x08\x00\x00\x00")
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = append(le.AppendUint16(le.AppendUint16(tiff, 0x0112), 3), 1, 0, 0, 0)
+	tiff = append(le.AppendUint16(tiff, orientation), 0, 0)
+	tiff = le.AppendUint32(le.AppendUint32(le.AppendUint16(le.AppendUint16(tiff, 0x8825), 4), 1), 38)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = le.AppendUint32(le.AppendUint32(le.AppendUint16(le.AppendUint16(tiff, 0x0002), 5), 3), 56)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = append(tiff, gpsLatitude...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	data := encoded.Bytes()
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func TestUploadFile_StripsJPEGLocation(t *testing.T) {
+	policy, _ := file.NewUploadPolicy(1<<20, []string{".jpg"})
+	repo, storage := newMockFileRepo(), newMockStorage()
+	service := file.NewFileService(file.Deps{Files: repo, Storage: storage, Messaging: &mockMessaging{}, Policy: policy})
+
+	photo := exifJPEG(t, 40, 20, 1)
+	resp, err := service.UploadFile(context.Background(), &file.CreateFileRequest{Filename: "photo.jpg"}, bytes.NewReader(photo))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	stored := storage.objects[repo.files[resp.FileID].StorageKey]
+	if len(stored) != len(photo) {
+		t.Fatalf("expected size to be kept, got %d want %d", len(stored), len(photo))
+	}
+	if bytes.Contains(stored, gpsLatitude) {
+		t.Errorf("expected GPS location to be stripped")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stored)); err != nil {
+		t.Errorf("stripped image must still decode: %v", err)
+	}
+}
+
+func TestCompleteUpload_StripsJPEGLocation(t *testing.T) {
+	policy, _ := file.NewUploadPolicy(1<<20, []string{".jpg"})
+	repo, storage := newMockFileRepo(), newMockStorage()
+	service := file.NewFileService(file.Deps{Files: repo, Storage: storage, Messaging: &mockMessaging{}, Policy: policy})
+	ctx := context.Background()
+
+	photo := exifJPEG(t, 40, 20, 1)
+	resp, err := service.CreateUploadURL(ctx, &file.CreateUploadURLRequest{Filename: "photo.jpg", Size: int64(len(photo))})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	storage.objects[repo.files[resp.FileID].StorageKey] = photo
+	completed, err := service.CompleteUpload(ctx, resp.FileID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if stored := storage.objects[completed.StorageKey]; len(stored) != len(photo) || bytes.Contains(stored, gpsLatitude) {
+		t.Errorf("expected GPS location to be stripped from the direct upload")
+	}
+	if len(storage.objects) != 1 {
+		t.Errorf("expected the received photo to be deleted, got %d objects", len(storage.objects))
+	}
+}
+
+func TestThumbnails_GeneratedUprightAndServedBySize(t *testing.T) {
+	repo, storage := newMockFileRepo(), newMockStorage()
+	ctx := context.Background()
+
+	// Orientation 6: stored landscape, displayed portrait
+	storage.objects["photo-key"] = exifJPEG(t, 400, 200, 6)
+	photo := &file.File{ID: uuid.New(), Filename: "photo.jpg", ContentType: "image/jpeg", StorageKey: "photo-key", Status: file.StatusAvailable, ScanStatus: file.ScanClean}
+	repo.Create(ctx, photo)
+
+	service := file.NewThumbnailService(repo, storage, file.ThumbnailConfig{Sizes: []int{256, 64}, MaxPixels: 1_000_000})
+	if err := service.Generate(ctx, photo.ID.String()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	thumbnails := repo.files[photo.ID.String()].Thumbnails
+	if len(thumbnails) != 2 {
+		t.Fatalf("expected 2 thumbnails, got %d", len(thumbnails))
+	}
+	small := thumbnails[0]
+	if small.Size != 64 || small.Width != 32 || small.Height != 64 {
+		t.Errorf("expected upright 32x64 thumbnail, got %+v", small)
+	}
+
+	thumbnail, content, err := service.GetThumbnail(ctx, photo.ID.String(), 100)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	data, _ := io.ReadAll(content)
+	decoded, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("thumbnail must decode: %v", err)
+	}
+	if thumbnail.Size != 256 || decoded.Bounds().Dx() != 128 || decoded.Bounds().Dy() != 256 {
+		t.Errorf("expected 128x256 thumbnail for size 100, got %+v", thumbnail)
+	}
+	if bytes.Contains(data, []byte("Exif")) {
+		t.Errorf("thumbnails must not carry EXIF metadata")
+	}
+}
+
+func TestThumbnails_SkipsOversizedAndNonImageFiles(t *testing.T) {
+	repo, storage := newMockFileRepo(), newMockStorage()
+	ctx := context.Background()
+	service := file.NewThumbnailService(repo, storage, file.ThumbnailConfig{Sizes: []int{64}, MaxPixels: 100})
+
+	storage.objects["photo-key"] = exifJPEG(t, 40, 20, 1)
+	photo := &file.File{ID: uuid.New(), Filename: "photo.jpg", ContentType: "image/jpeg", StorageKey: "photo-key", Status: file.StatusAvailable, ScanStatus: file.ScanClean}
+	doc := &file.File{ID: uuid.New(), Filename: "doc.pdf", ContentType: "application/pdf", StorageKey: "doc-key", Status: file.StatusAvailable, ScanStatus: file.ScanClean}
+	repo.Create(ctx, photo)
+	repo.Create(ctx, doc)
+
+	for _, f := range []*file.File{photo, doc} {
+		if err := service.Generate(ctx, f.ID.String()); err != nil {
+			t.Fatalf("expected skipped files not to fail, got %v", err)
+		}
+		if len(repo.files[f.ID.String()].Thumbnails) != 0 {
+			t.Errorf("expected no thumbnails for %s", f.Filename)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"taskflow/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTimeoutRouter(config middleware.TimeoutConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Recovery(middleware.DefaultRecoveryConfig()))
+	r.Use(middleware.Timeout(config))
+	return r
+}
+
+func TestTimeout_WritesBufferedResponse(t *testing.T) {
+	r := newTimeoutRouter(middleware.TimeoutConfig{Timeout: time.Second})
+	r.GET("/ok", func(c *gin.Context) {
+		c.Header("X-Custom", "yes")
+		c.JSON(http.StatusCreated, gin.H{"status": "ok"})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	if w.Header().Get("X-Custom") != "yes" {
+		t.Errorf("expected handler header to be copied")
+	}
+	if !strings.Contains(w.Body.String(), `"status":"ok"`) {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
+
+func TestTimeout_ReplacesResponseAfterDeadline(t *testing.T) {
+	r := newTimeoutRouter(middleware.TimeoutConfig{Timeout: 20 * time.Millisecond})
+	r.GET("/slow", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		c.JSON(http.StatusOK, gin.H{"status": "late"})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if w.Code != http.StatusRequestTimeout {
+		t.Fatalf("expected 408, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "late") {
+		t.Errorf("late handler output must be discarded, got %q", w.Body.String())
+	}
+}
+
+func TestTimeout_PerRouteOverride(t *testing.T) {
+	r := newTimeoutRouter(middleware.TimeoutConfig{
+		Timeout: 10 * time.Millisecond,
+		Routes: map[string]middleware.RouteTimeout{
+			"/upload": {Timeout: time.Second},
+		},
+	})
+	r.POST("/upload", func(c *gin.Context) {
+		time.Sleep(30 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 with route override, got %d", w.Code)
+	}
+}
+
+func TestTimeout_PanicReachesRecovery(t *testing.T) {
+	r := newTimeoutRouter(middleware.TimeoutConfig{Timeout: time.Second})
+	r.GET("/panic", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 from recovery, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "partial") {
+		t.Errorf("buffered output must not leak after a panic")
+	}
+}
+
+func TestTimeout_StreamingRouteIsNotBuffered(t *testing.T) {
+	r := newTimeoutRouter(middleware.TimeoutConfig{
+		Timeout: time.Second,
+		Routes: map[string]middleware.RouteTimeout{
+			"/download": {Streaming: true},
+		},
+	})
+	r.GET("/download", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		c.Writer.WriteString("chunk")
+		c.Writer.Flush()
+		if !c.Writer.Written() {
+			t.Errorf("expected streaming writer to report written")
+		}
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/download", nil))
+
+	if !w.Flushed {
+		t.Errorf("expected streaming response to be flushed to the client")
+	}
+	if w.Body.String() != "chunk" {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
+
+func TestTimeout_DefaultConfigStreamsDownloads(t *testing.T) {
+	r := newTimeoutRouter(middleware.DefaultTimeoutConfig())
+	stream := func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		c.Writer.WriteString("chunk")
+		c.Writer.Flush()
+	}
+	r.GET("/files/:id/download", stream)
+	r.GET("/files/:id/versions/:version/download", stream)
+
+	for _, path := range []string{"/files/abc/download", "/files/abc/versions/1/download"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if !w.Flushed || w.Body.String() != "chunk" {
+			t.Errorf("expected %s to bypass the buffer, got flushed=%v body %q", path, w.Flushed, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	repository "taskflow/adapter/repository/mysql"
+	"taskflow/adapter/storage"
+	"taskflow/adapter/streaming"
+	"taskflow/internal/domain/file"
+	"taskflow/pkg/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+var (
+	spanRecorderOnce sync.Once
+	spanRecorder     *tracetest.SpanRecorder
+)
+
+// startTrace installs a global tracer provider recording every span, once
+// per test run, and starts a root span. Tests share the recorder, so they
+// only look at spans of their own trace.
+func startTrace(t *testing.T) (context.Context, trace.Span) {
+	t.Helper()
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return otel.Tracer("tests").Start(context.Background(), "test")
+}
+
+// endedSpan returns the ended span of root's trace with the given name
+func endedSpan(t *testing.T, root trace.Span, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	var names []string
+	for _, span := range spanRecorder.Ended() {
+		if span.SpanContext().TraceID() != root.SpanContext().TraceID() {
+			continue
+		}
+		if span.Name() == name {
+			return span
+		}
+		names = append(names, span.Name())
+	}
+	t.Fatalf("expected an ended %q span, got %v", name, names)
+	return nil
+}
+
+func assertChildOf(t *testing.T, child sdktrace.ReadOnlySpan, parent trace.SpanContext) {
+	t.Helper()
+	if child.Parent().SpanID() != parent.SpanID() {
+		t.Errorf("expected %q to be a child of span %s, got parent %s", child.Name(), parent.SpanID(), child.Parent().SpanID())
+	}
+}
+
+func TestTracing_GormPluginSpansStatements(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:1)/taskflow", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.Use(repository.NewTracingPlugin()); err != nil {
+		t.Fatalf("failed to install plugin: %v", err)
+	}
+	ctx, root := startTrace(t)
+
+	db.WithContext(ctx).Where("status = ?", file.StatusAvailable).Find(&[]file.File{})
+	root.End()
+
+	span := endedSpan(t, root, "gorm.query")
+	assertChildOf(t, span, root.SpanContext())
+	if span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("expected a client span, got %v", span.SpanKind())
+	}
+	var statement string
+	for _, attr := range span.Attributes() {
+		if attr.Key == "db.statement" {
+			statement = attr.Value.AsString()
+		}
+	}
+	if !strings.HasPrefix(statement, "SELECT") {
+		t.Errorf("expected the statement to be recorded, got %q", statement)
+	}
+}
+
+// fakeRedis answers every command with OK, and XADD with a message ID, and
+// keeps the arguments of each XADD
+type fakeRedis struct {
+	mu    sync.Mutex
+	xadds [][]string
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	fake := &fakeRedis{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fake.serve(conn)
+		}
+	}()
+	return fake, listener.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readRESPArray(r)
+		if err != nil {
+			return
+		}
+		if strings.EqualFold(args[0], "xadd") {
+			f.mu.Lock()
+			f.xadds = append(f.xadds, args)
+			f.mu.Unlock()
+			conn.Write([]byte("$3\r\n1-0\r\n"))
+			continue
+		}
+		conn.Write([]byte("+OK\r\n"))
+	}
+}
+
+func readRESPArray(r *bufio.Reader) ([]string, error) {
+	n, err := readRESPLength(r, '*')
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		size, err := readRESPLength(r, '$')
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func readRESPLength(r *bufio.Reader, prefix byte) (int, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	if len(line) < 3 || line[0] != prefix {
+		return 0, fmt.Errorf("unexpected RESP line %q", line)
+	}
+	return strconv.Atoi(strings.TrimSpace(line[1:]))
+}
+
+func TestTracing_PublishedMessagesCarryTheTrace(t *testing.T) {
+	fake, addr := newFakeRedis(t)
+	client := streaming.NewRedisClient(addr)
+	defer client.Close()
+	ctx, root := startTrace(t)
+
+	if err := streaming.NewRedisMessaging(client).Publish(ctx, file.TopicFileUploaded, map[string]string{"id": "1"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	root.End()
+
+	publish := endedSpan(t, root, file.TopicFileUploaded+" publish")
+	assertChildOf(t, publish, root.SpanContext())
+	assertChildOf(t, endedSpan(t, root, "redis.xadd"), publish.SpanContext())
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.xadds) != 1 {
+		t.Fatalf("expected one XADD, got %d", len(fake.xadds))
+	}
+	// XADD stream * field value ...
+	values := map[string]interface{}{}
+	for i := 3; i+1 < len(fake.xadds[0]); i += 2 {
+		values[fake.xadds[0][i]] = fake.xadds[0][i+1]
+	}
+	consumed := trace.SpanContextFromContext(streaming.ExtractTraceContext(context.Background(), values))
+	if consumed.TraceID() != root.SpanContext().TraceID() || consumed.SpanID() != publish.SpanContext().SpanID() {
+		t.Errorf("expected the message to continue the publish span, got %s/%s", consumed.TraceID(), consumed.SpanID())
+	}
+}
+
+func TestTracing_S3RequestsAndPresignsEndTheirSpans(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client := storage.NewS3Client(config.S3Config{Region: "us-east-1", AccessKeyID: "key", SecretAccessKey: "secret", Endpoint: server.URL})
+	objects := storage.NewS3Storage(client, "taskflow").(file.DirectUploadStorage)
+	ctx, root := startTrace(t)
+
+	if err := objects.Delete(ctx, "report.pdf"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := objects.PresignPut(ctx, "upload.pdf", "application/pdf", time.Minute); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	root.End()
+
+	deleted := endedSpan(t, root, "S3.DeleteObject")
+	assertChildOf(t, deleted, root.SpanContext())
+	var status string
+	for _, attr := range deleted.Attributes() {
+		if attr.Key == "http.response.status_code" {
+			status = strconv.FormatInt(attr.Value.AsInt64(), 10)
+		}
+	}
+	if status != "204" {
+		t.Errorf("expected the response status to be recorded, got %q", status)
+	}
+	assertChildOf(t, endedSpan(t, root, "S3.PutObject"), root.SpanContext())
+}
@@ -3,9 +3,11 @@ package tests
 import (
 	"context"
 	"errors"
+	"sort"
 	"testing"
 	"time"
 
+	"taskflow/internal/domain/shared"
 	"taskflow/internal/domain/todo"
 
 	"github.com/google/uuid"
@@ -15,9 +17,15 @@ import (
 type mockTodoRepo struct {
 	CreateFn  func(ctx context.Context, todoItem *todo.TodoItem) error
 	GetByIDFn func(ctx context.Context, id uuid.UUID) (*todo.TodoItem, error)
-	ListFn    func(ctx context.Context, limit, offset int) ([]*todo.TodoItem, error)
+	ListFn    func(ctx context.Context, filter todo.ListFilter, limit, offset int) ([]*todo.TodoItem, error)
 	UpdateFn  func(ctx context.Context, todoItem *todo.TodoItem) error
 	DeleteFn  func(ctx context.Context, id uuid.UUID) error
+	// ListChildrenFn defaults to no subtasks
+	ListChildrenFn func(ctx context.Context, parentIDs []uuid.UUID) ([]*todo.TodoItem, error)
+	// AdjacentRankFn and ListColumnFn default to empty columns
+	AdjacentRankFn func(ctx context.Context, status todo.Status, rank string, before bool, exclude uuid.UUID) (string, error)
+	ListColumnFn   func(ctx context.Context, status todo.Status) ([]*todo.TodoItem, error)
+	SetRanksFn     func(ctx context.Context, ranks map[uuid.UUID]string) error
 }
 
 func (m *mockTodoRepo) Create(ctx context.Context, todoItem *todo.TodoItem) error {
@@ -26,8 +34,8 @@ func (m *mockTodoRepo) Create(ctx context.Context, todoItem *todo.TodoItem) erro
 func (m *mockTodoRepo) GetByID(ctx context.Context, id uuid.UUID) (*todo.TodoItem, error) {
 	return m.GetByIDFn(ctx, id)
 }
-func (m *mockTodoRepo) List(ctx context.Context, limit, offset int) ([]*todo.TodoItem, error) {
-	return m.ListFn(ctx, limit, offset)
+func (m *mockTodoRepo) List(ctx context.Context, filter todo.ListFilter, limit, offset int) ([]*todo.TodoItem, error) {
+	return m.ListFn(ctx, filter, limit, offset)
 }
 func (m *mockTodoRepo) Update(ctx context.Context, todoItem *todo.TodoItem) error {
 	return m.UpdateFn(ctx, todoItem)
@@ -35,6 +43,128 @@ func (m *mockTodoRepo) Update(ctx context.Context, todoItem *todo.TodoItem) erro
 func (m *mockTodoRepo) Delete(ctx context.Context, id uuid.UUID) error {
 	return m.DeleteFn(ctx, id)
 }
+func (m *mockTodoRepo) ListChildren(ctx context.Context, parentIDs []uuid.UUID) ([]*todo.TodoItem, error) {
+	if m.ListChildrenFn == nil {
+		return nil, nil
+	}
+	return m.ListChildrenFn(ctx, parentIDs)
+}
+func (m *mockTodoRepo) AdjacentRank(ctx context.Context, status todo.Status, rank string, before bool, exclude uuid.UUID) (string, error) {
+	if m.AdjacentRankFn == nil {
+		return "", nil
+	}
+	return m.AdjacentRankFn(ctx, status, rank, before, exclude)
+}
+func (m *mockTodoRepo) ListColumn(ctx context.Context, status todo.Status) ([]*todo.TodoItem, error) {
+	if m.ListColumnFn == nil {
+		return nil, nil
+	}
+	return m.ListColumnFn(ctx, status)
+}
+func (m *mockTodoRepo) SetRanks(ctx context.Context, ranks map[uuid.UUID]string) error {
+	if m.SetRanksFn == nil {
+		return nil
+	}
+	return m.SetRanksFn(ctx, ranks)
+}
+
+// copyTodo returns a copy of a todo, so stored todos are never shared with
+// the service, which publishes its own in the background
+func copyTodo(todoItem *todo.TodoItem) *todo.TodoItem {
+	copied := *todoItem
+	return &copied
+}
+
+// newMemTodoRepo returns a mockTodoRepo that stores todos in the returned map.
+// Like the database it stores and returns copies.
+func newMemTodoRepo() (*mockTodoRepo, map[uuid.UUID]*todo.TodoItem) {
+	todos := map[uuid.UUID]*todo.TodoItem{}
+	return &mockTodoRepo{
+		CreateFn: func(ctx context.Context, todoItem *todo.TodoItem) error {
+			todos[todoItem.ID] = copyTodo(todoItem)
+			return nil
+		},
+		GetByIDFn: func(ctx context.Context, id uuid.UUID) (*todo.TodoItem, error) {
+			if todoItem, ok := todos[id]; ok {
+				return copyTodo(todoItem), nil
+			}
+			return nil, shared.NewNotFoundError("todo not found")
+		},
+		ListFn: func(ctx context.Context, filter todo.ListFilter, limit, offset int) ([]*todo.TodoItem, error) {
+			var list []*todo.TodoItem
+			for _, todoItem := range todos {
+				list = append(list, copyTodo(todoItem))
+			}
+			return list, nil
+		},
+		UpdateFn: func(ctx context.Context, todoItem *todo.TodoItem) error {
+			// Like the database, updates leave the rank to SetRanks
+			if stored, ok := todos[todoItem.ID]; ok {
+				updated := copyTodo(todoItem)
+				updated.Rank = stored.Rank
+				todos[todoItem.ID] = updated
+			}
+			return nil
+		},
+		DeleteFn: func(ctx context.Context, id uuid.UUID) error {
+			delete(todos, id)
+			return nil
+		},
+		ListChildrenFn: func(ctx context.Context, parentIDs []uuid.UUID) ([]*todo.TodoItem, error) {
+			var children []*todo.TodoItem
+			for _, todoItem := range todos {
+				for _, parentID := range parentIDs {
+					if todoItem.ParentID != nil && *todoItem.ParentID == parentID {
+						children = append(children, copyTodo(todoItem))
+					}
+				}
+			}
+			sort.Slice(children, func(i, j int) bool { return children[i].CreatedAt.Before(children[j].CreatedAt) })
+			return children, nil
+		},
+		AdjacentRankFn: func(ctx context.Context, status todo.Status, rank string, before bool, exclude uuid.UUID) (string, error) {
+			adjacent := ""
+			for _, todoItem := range todos {
+				r := todoItem.Rank
+				if todoItem.Status != status || r == "" || todoItem.ID == exclude {
+					continue
+				}
+				if before && (rank == "" || r < rank) && (adjacent == "" || r > adjacent) {
+					adjacent = r
+				}
+				if !before && r > rank && (adjacent == "" || r < adjacent) {
+					adjacent = r
+				}
+			}
+			return adjacent, nil
+		},
+		ListColumnFn: func(ctx context.Context, status todo.Status) ([]*todo.TodoItem, error) {
+			var column []*todo.TodoItem
+			for _, todoItem := range todos {
+				if todoItem.Status == status {
+					column = append(column, copyTodo(todoItem))
+				}
+			}
+			sort.Slice(column, func(i, j int) bool {
+				a, b := column[i], column[j]
+				if (a.Rank == "") != (b.Rank == "") {
+					return b.Rank == ""
+				}
+				if a.Rank != b.Rank {
+					return a.Rank < b.Rank
+				}
+				return a.CreatedAt.Before(b.CreatedAt)
+			})
+			return column, nil
+		},
+		SetRanksFn: func(ctx context.Context, ranks map[uuid.UUID]string) error {
+			for id, rank := range ranks {
+				todos[id].Rank = rank
+			}
+			return nil
+		},
+	}, todos
+}
 
 type mockMessaging struct {
 	PublishFn func(ctx context.Context, topic string, message interface{}) error
@@ -58,6 +188,100 @@ func (m *mockCache) Set(ctx context.Context, key string, value string, ttl int)
 func (m *mockCache) Delete(ctx context.Context, key string) error                     { return nil }
 func (m *mockCache) Exists(ctx context.Context, key string) (bool, error)             { return false, nil }
 
+// mockAttachmentRepo keeps attachments in memory
+type mockAttachmentRepo struct {
+	attachments []*todo.Attachment
+}
+
+func newMockAttachmentRepo() *mockAttachmentRepo {
+	return &mockAttachmentRepo{}
+}
+
+func (m *mockAttachmentRepo) Add(ctx context.Context, attachment *todo.Attachment) error {
+	for _, a := range m.attachments {
+		if a.TodoID == attachment.TodoID && a.FileID == attachment.FileID {
+			return nil
+		}
+	}
+	m.attachments = append(m.attachments, attachment)
+	return nil
+}
+
+func (m *mockAttachmentRepo) Remove(ctx context.Context, todoID uuid.UUID, fileID string) error {
+	before := len(m.attachments)
+	m.filter(func(a *todo.Attachment) bool { return a.TodoID != todoID || a.FileID != fileID })
+	if len(m.attachments) == before {
+		return shared.NewNotFoundError("file is not attached to this todo")
+	}
+	return nil
+}
+
+func (m *mockAttachmentRepo) ListByTodos(ctx context.Context, todoIDs []uuid.UUID) ([]*todo.Attachment, error) {
+	var found []*todo.Attachment
+	for _, a := range m.attachments {
+		for _, id := range todoIDs {
+			if a.TodoID == id {
+				found = append(found, a)
+			}
+		}
+	}
+	return found, nil
+}
+
+func (m *mockAttachmentRepo) ListByFile(ctx context.Context, fileID string) ([]*todo.Attachment, error) {
+	var found []*todo.Attachment
+	for _, a := range m.attachments {
+		if a.FileID == fileID {
+			found = append(found, a)
+		}
+	}
+	return found, nil
+}
+
+func (m *mockAttachmentRepo) ListByFiles(ctx context.Context, fileIDs []string) ([]*todo.Attachment, error) {
+	var found []*todo.Attachment
+	for _, a := range m.attachments {
+		for _, id := range fileIDs {
+			if a.FileID == id {
+				found = append(found, a)
+			}
+		}
+	}
+	return found, nil
+}
+
+func (m *mockAttachmentRepo) DeleteByTodo(ctx context.Context, todoID uuid.UUID) error {
+	m.filter(func(a *todo.Attachment) bool { return a.TodoID != todoID })
+	return nil
+}
+
+func (m *mockAttachmentRepo) DeleteByFile(ctx context.Context, fileID string) error {
+	m.filter(func(a *todo.Attachment) bool { return a.FileID != fileID })
+	return nil
+}
+
+func (m *mockAttachmentRepo) filter(keep func(*todo.Attachment) bool) {
+	kept := m.attachments[:0]
+	for _, a := range m.attachments {
+		if keep(a) {
+			kept = append(kept, a)
+		}
+	}
+	m.attachments = kept
+}
+
+// mockFileChecker allows linking any file unless CheckFn is set
+type mockFileChecker struct {
+	CheckFn func(ctx context.Context, fileID string) error
+}
+
+func (m *mockFileChecker) CheckLinkable(ctx context.Context, fileID string) error {
+	if m.CheckFn != nil {
+		return m.CheckFn(ctx, fileID)
+	}
+	return nil
+}
+
 // --- Tests ---
 func TestCreateTodo_Success(t *testing.T) {
 	todoRepo := &mockTodoRepo{
@@ -65,7 +289,7 @@ func TestCreateTodo_Success(t *testing.T) {
 	}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	req := &todo.CreateTodoRequest{
 		Description: "Test todo",
@@ -84,7 +308,7 @@ func TestCreateTodo_ValidationError(t *testing.T) {
 	todoRepo := &mockTodoRepo{}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	req := &todo.CreateTodoRequest{Description: "", DueDate: time.Now().Add(24 * time.Hour)}
 	_, err := service.CreateTodo(context.Background(), req)
@@ -105,7 +329,7 @@ func TestCreateTodo_RepoError(t *testing.T) {
 	}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	req := &todo.CreateTodoRequest{Description: "desc", DueDate: time.Now().Add(24 * time.Hour)}
 	_, err := service.CreateTodo(context.Background(), req)
@@ -123,7 +347,7 @@ func TestGetTodo_Success(t *testing.T) {
 	}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	todoItem, err := service.GetTodo(context.Background(), id)
 	if err != nil {
@@ -142,7 +366,7 @@ func TestGetTodo_RepoError(t *testing.T) {
 	}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	_, err := service.GetTodo(context.Background(), uuid.New())
 	if err == nil {
@@ -152,15 +376,15 @@ func TestGetTodo_RepoError(t *testing.T) {
 
 func TestListTodos_Success(t *testing.T) {
 	todoRepo := &mockTodoRepo{
-		ListFn: func(ctx context.Context, limit, offset int) ([]*todo.TodoItem, error) {
+		ListFn: func(ctx context.Context, filter todo.ListFilter, limit, offset int) ([]*todo.TodoItem, error) {
 			return []*todo.TodoItem{{ID: uuid.New(), Description: "desc"}}, nil
 		},
 	}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
-	todos, err := service.ListTodos(context.Background(), 10, 0)
+	todos, err := service.ListTodos(context.Background(), todo.ListFilter{}, 10, 0)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -171,15 +395,15 @@ func TestListTodos_Success(t *testing.T) {
 
 func TestListTodos_RepoError(t *testing.T) {
 	todoRepo := &mockTodoRepo{
-		ListFn: func(ctx context.Context, limit, offset int) ([]*todo.TodoItem, error) {
+		ListFn: func(ctx context.Context, filter todo.ListFilter, limit, offset int) ([]*todo.TodoItem, error) {
 			return nil, errors.New("db error")
 		},
 	}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
-	_, err := service.ListTodos(context.Background(), 10, 0)
+	_, err := service.ListTodos(context.Background(), todo.ListFilter{}, 10, 0)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 	}
@@ -196,7 +420,7 @@ func TestUpdateTodo_Success(t *testing.T) {
 	}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	req := &todo.UpdateTodoRequest{Description: &desc}
 	todoItem, err := service.UpdateTodo(context.Background(), id, req)
@@ -216,7 +440,7 @@ func TestUpdateTodo_NotFound(t *testing.T) {
 	}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	req := &todo.UpdateTodoRequest{Description: new(string)}
 	_, err := service.UpdateTodo(context.Background(), uuid.New(), req)
@@ -236,7 +460,7 @@ func TestUpdateTodo_RepoError(t *testing.T) {
 	}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	req := &todo.UpdateTodoRequest{Description: &desc}
 	_, err := service.UpdateTodo(context.Background(), id, req)
@@ -255,7 +479,7 @@ func TestDeleteTodo_Success(t *testing.T) {
 	}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	err := service.DeleteTodo(context.Background(), id)
 	if err != nil {
@@ -271,7 +495,7 @@ func TestDeleteTodo_NotFound(t *testing.T) {
 	}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	err := service.DeleteTodo(context.Background(), uuid.New())
 	if err == nil {
@@ -289,7 +513,7 @@ func TestDeleteTodo_RepoError(t *testing.T) {
 	}
 	messaging := &mockMessaging{}
 	cache := &mockCache{}
-	service := todo.NewTodoService(todoRepo, messaging, cache)
+	service := todo.NewTodoService(todo.Deps{Todos: todoRepo, Attachments: newMockAttachmentRepo(), Messaging: messaging, Cache: cache, Files: &mockFileChecker{}})
 
 	err := service.DeleteTodo(context.Background(), id)
 	if err == nil {
@@ -0,0 +1,277 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"taskflow/internal/domain/file"
+	"taskflow/internal/domain/shared"
+	"taskflow/pkg/identity"
+)
+
+type mockVersionRepo struct {
+	versions map[string]*file.FileVersion
+}
+
+func newMockVersionRepo() *mockVersionRepo {
+	return &mockVersionRepo{versions: map[string]*file.FileVersion{}}
+}
+
+func (m *mockVersionRepo) Create(ctx context.Context, v *file.FileVersion) error {
+	m.versions[v.ID.String()] = v
+	return nil
+}
+
+func (m *mockVersionRepo) Get(ctx context.Context, fileID string, number int) (*file.FileVersion, error) {
+	for _, v := range m.versions {
+		if v.FileID == fileID && v.Number == number {
+			return v, nil
+		}
+	}
+	return nil, shared.NewNotFoundError("version not found")
+}
+
+func (m *mockVersionRepo) Update(ctx context.Context, v *file.FileVersion) error {
+	m.versions[v.ID.String()] = v
+	return nil
+}
+
+func (m *mockVersionRepo) Delete(ctx context.Context, id string) error {
+	delete(m.versions, id)
+	return nil
+}
+
+func (m *mockVersionRepo) ListByFile(ctx context.Context, fileID string) ([]*file.FileVersion, error) {
+	var versions []*file.FileVersion
+	for _, v := range m.versions {
+		if v.FileID == fileID {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Number > versions[j].Number })
+	return versions, nil
+}
+
+func (m *mockVersionRepo) ListBefore(ctx context.Context, before time.Time, limit int) ([]*file.FileVersion, error) {
+	var versions []*file.FileVersion
+	for _, v := range m.versions {
+		if v.CreatedAt.Before(before) && len(versions) < limit {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+func (m *mockVersionRepo) ListAfter(ctx context.Context, afterID string, limit int) ([]*file.FileVersion, error) {
+	var versions []*file.FileVersion
+	for id, v := range m.versions {
+		if id > afterID {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].ID.String() < versions[j].ID.String() })
+	return versions[:min(limit, len(versions))], nil
+}
+
+func (m *mockVersionRepo) ListWrappedAfter(ctx context.Context, keyID, afterID string, limit int) ([]*file.FileVersion, error) {
+	var versions []*file.FileVersion
+	all, _ := m.ListAfter(ctx, afterID, len(m.versions))
+	for _, v := range all {
+		if v.KeyID != "" && v.KeyID != keyID && len(versions) < limit {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+type versionFixture struct {
+	service  file.FileService
+	repo     *mockFileRepo
+	versions *mockVersionRepo
+	storage  *mockStorage
+	usage    *mockUsageRepo
+}
+
+func newVersionFixture(t *testing.T, policy file.VersionPolicy) *versionFixture {
+	t.Helper()
+	uploadPolicy, _ := file.NewUploadPolicy(1024, []string{".txt"})
+	f := &versionFixture{
+		repo:     newMockFileRepo(),
+		versions: newMockVersionRepo(),
+		storage:  newMockStorage(),
+		usage:    newMockUsageRepo(),
+	}
+	f.service = file.NewFileService(file.Deps{Files: f.repo, Storage: f.storage, Messaging: &mockMessaging{}, Policy: uploadPolicy, Quotas: file.NewQuotas(f.usage, file.QuotaConfig{}), Versions: file.NewVersioning(f.versions, policy)})
+	return f
+}
+
+// replace uploads content as the next version of a file and scans it clean
+func (f *versionFixture) replace(t *testing.T, ctx context.Context, fileID, content string) *file.File {
+	t.Helper()
+	updated, err := f.service.ReplaceContent(ctx, fileID, &file.CreateFileRequest{Filename: "ignored.txt"}, strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("expected content to be replaced, got %v", err)
+	}
+	markClean(f.repo, fileID)
+	return updated
+}
+
+func TestVersions_ReplaceKeepsEarlierContentAndRestoreSwaps(t *testing.T) {
+	f := newVersionFixture(t, file.VersionPolicy{})
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+
+	resp, err := f.service.UploadFile(ctx, &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader("first draft"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	markClean(f.repo, resp.FileID)
+
+	updated := f.replace(t, ctx, resp.FileID, "second draft!")
+	if updated.Version != 2 || updated.Size != 13 || updated.Filename != "notes.txt" {
+		t.Errorf("unexpected file after replace: version %d, size %d, name %q", updated.Version, updated.Size, updated.Filename)
+	}
+
+	versions, err := f.service.ListVersions(ctx, resp.FileID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(versions) != 2 || !versions[0].Current || versions[0].Number != 2 || versions[1].Number != 1 {
+		t.Fatalf("expected current version 2 then version 1, got %+v", versions)
+	}
+	if versions[1].UploadedBy != "alice" || versions[1].ScanStatus != file.ScanClean || versions[1].Checksum == "" {
+		t.Errorf("expected version 1 to keep its uploader, scan status and checksum, got %+v", versions[1])
+	}
+
+	_, content, err := f.service.DownloadVersion(ctx, resp.FileID, 1)
+	data, err := readContent(content, err)
+	if err != nil || string(data) != "first draft" {
+		t.Fatalf("expected the first draft, got %q (%v)", data, err)
+	}
+
+	// Both contents count against the owner
+	if got := f.usage.usage["user/alice"]; got.Bytes != 24 || got.Files != 1 {
+		t.Errorf("expected both versions to be charged, got %+v", got)
+	}
+
+	restored, err := f.service.RestoreVersion(ctx, resp.FileID, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if restored.Version != 1 || restored.Size != 11 || restored.ScanStatus != file.ScanQuarantined {
+		t.Errorf("expected version 1 to be current and rescanned, got version %d, size %d, scan %s", restored.Version, restored.Size, restored.ScanStatus)
+	}
+	if _, err := f.versions.Get(ctx, resp.FileID, 2); err != nil {
+		t.Errorf("expected the replaced content to be kept as version 2, got %v", err)
+	}
+	if got := f.usage.usage["user/alice"]; got.Bytes != 24 {
+		t.Errorf("expected restoring not to change usage, got %+v", got)
+	}
+
+	// New content continues the numbering
+	markClean(f.repo, resp.FileID)
+	if updated := f.replace(t, ctx, resp.FileID, "third"); updated.Version != 3 {
+		t.Errorf("expected version 3, got %d", updated.Version)
+	}
+}
+
+func TestVersions_RetentionPrunesOldestAndDeleteDropsAll(t *testing.T) {
+	f := newVersionFixture(t, file.VersionPolicy{MaxVersions: 2})
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice", WorkspaceID: "acme"})
+
+	resp, _ := f.service.UploadFile(ctx, &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader("v1"))
+	markClean(f.repo, resp.FileID)
+	first := f.repo.files[resp.FileID].StorageKey
+	for _, content := range []string{"v2", "v3", "v4"} {
+		f.replace(t, ctx, resp.FileID, content)
+	}
+
+	versions, _ := f.service.ListVersions(ctx, resp.FileID)
+	if len(versions) != 3 || versions[1].Number != 3 || versions[2].Number != 2 {
+		t.Fatalf("expected the current and the two newest earlier versions, got %+v", versions)
+	}
+	if _, ok := f.storage.objects[first]; ok {
+		t.Error("expected the pruned version's content to be deleted")
+	}
+	if got := f.usage.usage["workspace/acme"]; got.Bytes != 6 || got.Files != 1 {
+		t.Errorf("expected pruning to credit the workspace, got %+v", got)
+	}
+
+	if err := f.service.DeleteFile(ctx, resp.FileID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(f.versions.versions) != 0 || len(f.storage.objects) != 0 {
+		t.Errorf("expected every version and its content to be deleted, got %d versions and %d objects",
+			len(f.versions.versions), len(f.storage.objects))
+	}
+	if got := f.usage.usage["user/alice"]; got.Bytes != 0 || got.Files != 0 {
+		t.Errorf("expected delete to credit every version, got %+v", got)
+	}
+}
+
+func TestVersions_OnlyTheOwnerReplacesAndGCKeepsVersions(t *testing.T) {
+	f := newVersionFixture(t, file.VersionPolicy{})
+	alice := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+	bob := identity.WithIdentity(context.Background(), identity.Identity{UserID: "bob"})
+
+	resp, _ := f.service.UploadFile(alice, &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader("mine"))
+	markClean(f.repo, resp.FileID)
+
+	_, err := f.service.ReplaceContent(bob, resp.FileID, &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader("yours"))
+	if domainCode(err) != shared.ErrCodeForbidden {
+		t.Fatalf("expected another user to be refused, got %v", err)
+	}
+	_, err = f.service.ReplaceContent(alice, resp.FileID, &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader("%PDF-1.4"))
+	if domainCode(err) != shared.ErrCodeUnsupported {
+		t.Fatalf("expected content not matching the file's type to be refused, got %v", err)
+	}
+
+	f.replace(t, alice, resp.FileID, "mine, edited")
+	report, err := f.service.CollectGarbage(alice, file.GCOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(report.OrphanedObjects) != 0 {
+		t.Errorf("expected earlier versions not to be collected, got %v", report.OrphanedObjects)
+	}
+}
+
+// replacingScanner replaces a file's content while scanning the old one
+type replacingScanner struct {
+	replace func()
+}
+
+func (s replacingScanner) Scan(ctx context.Context, content io.Reader) (*shared.ScanResult, error) {
+	io.Copy(io.Discard, content) // nolint: errcheck
+	s.replace()
+	return &shared.ScanResult{}, nil
+}
+
+func TestVersions_StaleScanDoesNotRevertReplacement(t *testing.T) {
+	f := newVersionFixture(t, file.VersionPolicy{})
+	ctx := identity.WithIdentity(context.Background(), identity.Identity{UserID: "alice"})
+	resp, err := f.service.UploadFile(ctx, &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader("first draft"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	scans := file.NewScanService(f.repo, f.storage, replacingScanner{replace: func() {
+		if _, err := f.service.ReplaceContent(ctx, resp.FileID, &file.CreateFileRequest{Filename: "notes.txt"}, strings.NewReader("second draft")); err != nil {
+			t.Errorf("expected content to be replaced, got %v", err)
+		}
+	}}, &mockMessaging{})
+	if err := scans.Scan(ctx, resp.FileID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	stored := f.repo.files[resp.FileID]
+	if stored.Version != 2 || stored.Size != 12 || stored.ScanStatus != file.ScanQuarantined {
+		t.Errorf("expected the replacement to stand unscanned, got version %d, size %d, scan %s", stored.Version, stored.Size, stored.ScanStatus)
+	}
+	if _, ok := f.storage.objects[stored.StorageKey]; !ok {
+		t.Errorf("expected the new content to stay in storage")
+	}
+}